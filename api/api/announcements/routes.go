@@ -3,20 +3,29 @@ package announcements
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
 	"net/http"
+	"strings"
 
 	"github.com/go-chi/chi"
+	"github.com/rs/zerolog/hlog"
 	"github.com/segmentio/ksuid"
 
 	"github.com/jd-116/klemis-kitchen-api/auth"
+	"github.com/jd-116/klemis-kitchen-api/broadcast"
 	"github.com/jd-116/klemis-kitchen-api/db"
+	"github.com/jd-116/klemis-kitchen-api/locale"
+	"github.com/jd-116/klemis-kitchen-api/markdown"
 	"github.com/jd-116/klemis-kitchen-api/types"
+	"github.com/jd-116/klemis-kitchen-api/upload"
 	"github.com/jd-116/klemis-kitchen-api/util"
 )
 
 // Routes creates a new Chi router with all of the routes for the announcement resource,
 // at the root level
-func Routes(database db.Provider) *chi.Mux {
+func Routes(database db.Provider, broadcaster *broadcast.Broadcaster, uploadProvider upload.Provider,
+	locales *locale.Negotiator) *chi.Mux {
+
 	router := chi.NewRouter()
 	router.Get("/", GetAll(database))
 	router.Get("/{id}", GetSingle(database))
@@ -26,9 +35,10 @@ func Routes(database db.Provider) *chi.Mux {
 		// Ensure the user has access
 		r.Use(auth.AdminAuthenticated)
 
-		r.Post("/", Create(database))
+		r.Post("/", Create(database, broadcaster, uploadProvider, locales))
 		r.Delete("/{id}", Delete(database))
-		r.Patch("/{id}", Update(database))
+		r.Patch("/{id}", Update(database, broadcaster, uploadProvider, locales))
+		r.Get("/{id}/delivery", GetDelivery(database))
 	})
 	return router
 }
@@ -43,6 +53,21 @@ func GetAll(announcementProvider db.AnnouncementProvider) http.HandlerFunc {
 			return
 		}
 
+		// Only admins can see drafts
+		if _, admin := auth.CurrentUser(r.Context()); !admin {
+			published := []types.Announcement{}
+			for _, announcement := range announcements {
+				if !announcement.Draft {
+					published = append(published, announcement)
+				}
+			}
+			announcements = published
+		}
+
+		for i := range announcements {
+			render(w, r, &announcements[i])
+		}
+
 		// Return the list in a JSON object
 		jsonResponse, err := json.Marshal(map[string]interface{}{
 			"announcements": announcements,
@@ -74,6 +99,14 @@ func GetSingle(announcementProvider db.AnnouncementProvider) http.HandlerFunc {
 			return
 		}
 
+		// Only admins can see drafts
+		if _, admin := auth.CurrentUser(r.Context()); announcement.Draft && !admin {
+			util.Error(r, w, db.NewNotFoundError(id))
+			return
+		}
+
+		render(w, r, announcement)
+
 		// Return the single announcement as the top-level JSON
 		jsonResponse, err := json.Marshal(announcement)
 		if err != nil {
@@ -87,8 +120,13 @@ func GetSingle(announcementProvider db.AnnouncementProvider) http.HandlerFunc {
 	}
 }
 
-// Create creates a new announcement in the database
-func Create(announcementProvider db.AnnouncementProvider) http.HandlerFunc {
+// Create creates a new announcement in the database,
+// pushing it to devices unless it is a draft.
+// Image attachments have to be files in the upload storage,
+// and translations have to be into supported locales
+func Create(announcementProvider db.AnnouncementProvider, broadcaster *broadcast.Broadcaster,
+	uploadProvider upload.Provider, locales *locale.Negotiator) http.HandlerFunc {
+
 	return func(w http.ResponseWriter, r *http.Request) {
 
 		var announcementCreate types.AnnouncementCreate
@@ -98,10 +136,32 @@ func Create(announcementProvider db.AnnouncementProvider) http.HandlerFunc {
 			return
 		}
 
+		images, err := cleanImages(uploadProvider, announcementCreate.Images)
+		if err != nil {
+			util.ErrorWithCode(r, w, err, http.StatusBadRequest)
+			return
+		}
+		links, err := cleanLinks(announcementCreate.Links)
+		if err != nil {
+			util.ErrorWithCode(r, w, err, http.StatusBadRequest)
+			return
+		}
+		translations, err := cleanTranslations(locales, announcementCreate.Translations)
+		if err != nil {
+			util.ErrorWithCode(r, w, err, http.StatusBadRequest)
+			return
+		}
+
 		announcement := types.Announcement{
-			Title:     announcementCreate.Title,
-			Body:      announcementCreate.Body,
-			Timestamp: announcementCreate.Timestamp,
+			Title:      announcementCreate.Title,
+			Body:       markdown.Sanitize(announcementCreate.Body),
+			Timestamp:  announcementCreate.Timestamp,
+			Draft:      announcementCreate.Draft,
+			LocationID: announcementCreate.LocationID,
+			Images:     images,
+			Links:      links,
+
+			Translations: translations,
 		}
 
 		// Generate globally unique IDs for the announcement
@@ -124,6 +184,9 @@ func Create(announcementProvider db.AnnouncementProvider) http.HandlerFunc {
 					return
 				}
 			} else {
+				publish(r, broadcaster, announcement)
+				render(w, r, &announcement)
+
 				// Return the single announcement as the top-level JSON
 				jsonResponse, err := json.Marshal(announcement)
 				if err != nil {
@@ -160,8 +223,11 @@ func Delete(announcementProvider db.AnnouncementProvider) http.HandlerFunc {
 	}
 }
 
-// Update updates a announcement in the database
-func Update(announcementProvider db.AnnouncementProvider) http.HandlerFunc {
+// Update updates a announcement in the database,
+// pushing it to devices if it was just published
+func Update(announcementProvider db.AnnouncementProvider, broadcaster *broadcast.Broadcaster,
+	uploadProvider upload.Provider, locales *locale.Negotiator) http.HandlerFunc {
+
 	return func(w http.ResponseWriter, r *http.Request) {
 		id := chi.URLParam(r, "id")
 		if id == "" {
@@ -177,12 +243,80 @@ func Update(announcementProvider db.AnnouncementProvider) http.HandlerFunc {
 			return
 		}
 
+		// Sanitize the body and attachments the same way as when creating an announcement
+		if value, ok := partial["body"]; ok {
+			body, ok := value.(string)
+			if !ok {
+				util.ErrorWithCode(r, w, errors.New("announcement Body must be a string"),
+					http.StatusBadRequest)
+				return
+			}
+			partial["body"] = markdown.Sanitize(body)
+		}
+		if value, ok := partial["images"]; ok {
+			var images []string
+			err = util.DecodePartial(value, &images)
+			if err != nil {
+				util.ErrorWithCode(r, w, errors.New("announcement Images must be a list of URLs"),
+					http.StatusBadRequest)
+				return
+			}
+			partial["images"], err = cleanImages(uploadProvider, images)
+			if err != nil {
+				util.ErrorWithCode(r, w, err, http.StatusBadRequest)
+				return
+			}
+		}
+		if value, ok := partial["links"]; ok {
+			var links []types.AnnouncementLink
+			err = util.DecodePartial(value, &links)
+			if err != nil {
+				util.ErrorWithCode(r, w, errors.New("announcement Links must be a list of links"),
+					http.StatusBadRequest)
+				return
+			}
+			partial["links"], err = cleanLinks(links)
+			if err != nil {
+				util.ErrorWithCode(r, w, err, http.StatusBadRequest)
+				return
+			}
+		}
+		if value, ok := partial["translations"]; ok {
+			var translations map[string]types.AnnouncementTranslation
+			err = util.DecodePartial(value, &translations)
+			if err != nil {
+				util.ErrorWithCode(r, w, errors.New("announcement Translations must map locales to a title and body"),
+					http.StatusBadRequest)
+				return
+			}
+			partial["translations"], err = cleanTranslations(locales, translations)
+			if err != nil {
+				util.ErrorWithCode(r, w, err, http.StatusBadRequest)
+				return
+			}
+		}
+
 		updated, err := announcementProvider.UpdateAnnouncement(r.Context(), id, partial)
 		if err != nil {
 			util.Error(r, w, err)
 			return
 		}
 
+		// Publishing a draft pushes it
+		// (the broadcaster skips announcements that were already pushed)
+		if _, ok := partial["draft"]; ok {
+			current, err := announcementProvider.GetAnnouncement(r.Context(), id)
+			if err != nil {
+				util.Error(r, w, err)
+				return
+			}
+
+			publish(r, broadcaster, *current)
+			updated = current
+		}
+
+		render(w, r, updated)
+
 		// Return the updated announcement as the top-level JSON
 		jsonResponse, err := json.Marshal(updated)
 		if err != nil {
@@ -195,3 +329,127 @@ func Update(announcementProvider db.AnnouncementProvider) http.HandlerFunc {
 		w.Write(jsonResponse)
 	}
 }
+
+// GetDelivery gets the push notification delivery stats of an announcement
+func GetDelivery(deliveryProvider db.AnnouncementDeliveryProvider) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := chi.URLParam(r, "id")
+		if id == "" {
+			util.ErrorWithCode(r, w, errors.New("the URL parameter is empty"),
+				http.StatusBadRequest)
+			return
+		}
+
+		delivery, err := deliveryProvider.GetAnnouncementDelivery(r.Context(), id)
+		if err != nil {
+			util.Error(r, w, err)
+			return
+		}
+
+		// Return the delivery stats as the top-level JSON
+		jsonResponse, err := json.Marshal(delivery)
+		if err != nil {
+			util.ErrorWithCode(r, w, err, http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		w.Write(jsonResponse)
+	}
+}
+
+// Queues the announcement to be pushed to devices.
+// Failures are only logged, since the announcement itself was already saved
+func publish(r *http.Request, broadcaster *broadcast.Broadcaster, announcement types.Announcement) {
+	err := broadcaster.Publish(r.Context(), announcement)
+	if err != nil {
+		hlog.FromRequest(r).
+			Error().
+			Err(err).
+			Str("announcement_id", announcement.ID).
+			Msg("could not queue announcement push notifications")
+	}
+}
+
+// Translates the announcement into the request's locale
+// and renders its Markdown body to HTML for a response.
+// Admins (and requests with ?raw=true) get the original title and body instead,
+// so that the dashboard never saves a translation over them,
+// and only admins get the list of every translation
+func render(w http.ResponseWriter, r *http.Request, announcement *types.Announcement) {
+	_, admin := auth.CurrentUser(r.Context())
+	if admin || r.URL.Query().Get("raw") == "true" {
+		w.Header().Set("Content-Language", locale.Default)
+	} else {
+		announcement.Localize(locale.FromContext(r.Context()))
+	}
+	announcement.BodyHTML = markdown.Render(announcement.Body)
+	if !admin {
+		announcement.Translations = nil
+	}
+
+	// Use non-nil slices so JSON serialization is nice
+	if announcement.Images == nil {
+		announcement.Images = []string{}
+	}
+	if announcement.Links == nil {
+		announcement.Links = []types.AnnouncementLink{}
+	}
+}
+
+// Makes sure that every image attachment points to a file in the upload storage
+func cleanImages(uploadProvider upload.Provider, images []string) ([]string, error) {
+	cleaned := []string{}
+	for _, image := range images {
+		image = strings.TrimSpace(image)
+		if !uploadProvider.Owns(image) {
+			return nil, fmt.Errorf("announcement image '%s' isn't an uploaded file", image)
+		}
+
+		cleaned = append(cleaned, image)
+	}
+
+	return cleaned, nil
+}
+
+// Makes sure that every call-to-action link has a label
+// and a URL that can be safely linked to
+func cleanLinks(links []types.AnnouncementLink) ([]types.AnnouncementLink, error) {
+	cleaned := []types.AnnouncementLink{}
+	for _, link := range links {
+		link.Label = strings.TrimSpace(link.Label)
+		link.URL = strings.TrimSpace(link.URL)
+		if link.Label == "" {
+			return nil, errors.New("announcement link Label cannot be empty")
+		}
+		if !markdown.SafeURL(link.URL) {
+			return nil, fmt.Errorf("announcement link URL '%s' must be an http, https, or mailto URL", link.URL)
+		}
+
+		cleaned = append(cleaned, link)
+	}
+
+	return cleaned, nil
+}
+
+// Makes sure that every translation is into a supported locale,
+// sanitizing each translated body the same way as the original
+func cleanTranslations(locales *locale.Negotiator,
+	translations map[string]types.AnnouncementTranslation) (map[string]types.AnnouncementTranslation, error) {
+
+	cleaned := make(map[string]types.AnnouncementTranslation)
+	for tag, translation := range translations {
+		err := locales.CheckTranslations([]string{tag})
+		if err != nil {
+			return nil, err
+		}
+
+		cleaned[tag] = types.AnnouncementTranslation{
+			Title: strings.TrimSpace(translation.Title),
+			Body:  markdown.Sanitize(translation.Body),
+		}
+	}
+
+	return cleaned, nil
+}
@@ -6,8 +6,6 @@ import (
 	"io/ioutil"
 	"net/http"
 	"net/url"
-	"os"
-	"strconv"
 	"strings"
 	"time"
 
@@ -17,7 +15,9 @@ import (
 
 	"github.com/jd-116/klemis-kitchen-api/auth"
 	"github.com/jd-116/klemis-kitchen-api/cas"
+	"github.com/jd-116/klemis-kitchen-api/config"
 	"github.com/jd-116/klemis-kitchen-api/db"
+	"github.com/jd-116/klemis-kitchen-api/settings"
 	"github.com/jd-116/klemis-kitchen-api/types"
 	"github.com/jd-116/klemis-kitchen-api/util"
 )
@@ -30,17 +30,11 @@ func Routes(
 	casProvider *cas.Provider,
 	database db.Provider,
 	jwtManager *auth.JWTManager,
+	settingsStore *settings.Store,
+	cfg *config.Config,
 ) *chi.Mux {
-	// Try to get the domain env variable if it is set
-	cookieDomain := strings.TrimSpace(os.Getenv("API_SERVER_DOMAIN"))
-
-	// Try to see if the continuation cookies should be secure
-	secureContinuationCookies := false
-	if value, ok := os.LookupEnv("AUTH_SECURE_CONTINUATION"); ok {
-		if strings.TrimSpace(value) == "1" {
-			secureContinuationCookies = true
-		}
-	}
+	// Scope the cookies to the server's domain if it is set
+	cookieDomain := strings.TrimSpace(cfg.Server.Domain)
 
 	// Create the flow continuation nonce map
 	pollInterval := 2 * time.Minute
@@ -52,15 +46,11 @@ func Routes(
 	maxTTL = 5 * time.Minute
 	authCodes := NewNonceMap(pollInterval, int64(maxTTL/time.Second))
 
-	// Determine if the redirect URIs are valid using lambda
-	validRedirectURIPrefixes := []string{}
-	if value, ok := os.LookupEnv("AUTH_REDIRECT_URI_PREFIXES"); ok {
-		value = strings.TrimSpace(value)
-		if value != "" {
-			validRedirectURIPrefixes = strings.Split(value, "|")
-		}
-	}
+	// Determine if the redirect URIs are valid using lambda,
+	// which reads the prefixes each time since they can be reloaded
 	isRedirectURIValid := func(uri string) bool {
+		validRedirectURIPrefixes := settingsStore.Get().AuthRedirectURIPrefixes
+
 		// If no prefixes supplied, then accept any
 		if len(validRedirectURIPrefixes) == 0 {
 			return true
@@ -76,14 +66,11 @@ func Routes(
 		return false
 	}
 
-	// Try to see if the continuation cookies should be secure
+	// Expire the issued tokens if configured to
 	var tokenExpirationHours *int64 = nil
-	if value, ok := os.LookupEnv("AUTH_JWT_TOKEN_EXPIRES_AFTER"); ok {
-		valueInt, err := strconv.Atoi(value)
-		if err == nil {
-			valueInt64 := int64(valueInt)
-			tokenExpirationHours = &valueInt64
-		}
+	if cfg.Auth.JWTTokenExpiresAfter != nil {
+		valueInt64 := int64(*cfg.Auth.JWTTokenExpiresAfter)
+		tokenExpirationHours = &valueInt64
 	}
 
 	router := chi.NewRouter()
@@ -91,7 +78,7 @@ func Routes(
 	// Public routes
 	router.Group(func(r chi.Router) {
 		r.Get("/login", Login(casProvider, flowContinuation, authCodes, cookieDomain,
-			secureContinuationCookies, isRedirectURIValid, database, jwtManager,
+			cfg.Auth.SecureContinuation, isRedirectURIValid, database, jwtManager,
 			tokenExpirationHours))
 		r.Post("/token-exchange", TokenExchange(authCodes, jwtManager))
 	})
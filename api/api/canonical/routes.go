@@ -0,0 +1,298 @@
+package canonical
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/segmentio/ksuid"
+
+	"github.com/jd-116/klemis-kitchen-api/auth"
+	"github.com/jd-116/klemis-kitchen-api/db"
+	"github.com/jd-116/klemis-kitchen-api/products"
+	"github.com/jd-116/klemis-kitchen-api/search"
+	"github.com/jd-116/klemis-kitchen-api/types"
+	"github.com/jd-116/klemis-kitchen-api/util"
+)
+
+// Routes creates a new Chi router with all of the admin routes
+// for the canonical product resource, at the root level
+func Routes(database db.Provider, indexer *search.Indexer) *chi.Mux {
+	router := chi.NewRouter()
+
+	// Admin-only routes
+	router.Group(func(r chi.Router) {
+		// Ensure the user has access
+		r.Use(auth.AdminAuthenticated)
+
+		r.Get("/", GetAll(database))
+		r.Get("/{id}", GetSingle(database))
+		r.Post("/", Create(database, indexer))
+		r.Put("/{id}", Replace(database, indexer))
+		r.Delete("/{id}", Delete(database, indexer))
+	})
+	return router
+}
+
+// GetAll gets all canonical products from the database
+func GetAll(canonicalProductProvider db.CanonicalProductProvider) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		canonicalProducts, err := canonicalProductProvider.GetAllCanonicalProducts(r.Context())
+		if err != nil {
+			util.Error(r, w, err)
+			return
+		}
+
+		// Return the list in a JSON object
+		jsonResponse, err := json.Marshal(map[string]interface{}{
+			"canonical_products": canonicalProducts,
+		})
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		w.Write(jsonResponse)
+	}
+}
+
+// GetSingle gets a single canonical product from the database by its ID
+func GetSingle(canonicalProductProvider db.CanonicalProductProvider) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := chi.URLParam(r, "id")
+		if id == "" {
+			util.ErrorWithCode(r, w, errors.New("the URL parameter is empty"),
+				http.StatusBadRequest)
+			return
+		}
+
+		canonicalProduct, err := canonicalProductProvider.GetCanonicalProduct(r.Context(), id)
+		if err != nil {
+			util.Error(r, w, err)
+			return
+		}
+
+		// Return the single canonical product as the top-level JSON
+		jsonResponse, err := json.Marshal(canonicalProduct)
+		if err != nil {
+			util.ErrorWithCode(r, w, err, http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		w.Write(jsonResponse)
+	}
+}
+
+// Create creates a new canonical product in the database
+// and rebuilds the search index with it
+func Create(aliasSource products.AliasSource, indexer *search.Indexer) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var canonicalProductCreate types.CanonicalProductCreate
+		err := json.NewDecoder(r.Body).Decode(&canonicalProductCreate)
+		if err != nil {
+			util.Error(r, w, err)
+			return
+		}
+
+		canonicalProduct, ok := prepare(w, r, aliasSource, canonicalProductCreate, "")
+		if !ok {
+			return
+		}
+		canonicalProduct.CreatedAt = time.Now()
+
+		// Generate globally unique IDs for the canonical product
+		for {
+			rand, err := ksuid.NewRandom()
+			if err != nil {
+				util.Error(r, w, err)
+				return
+			}
+
+			canonicalProduct.ID = rand.String()
+
+			err = aliasSource.CreateCanonicalProduct(r.Context(), *canonicalProduct)
+			if err != nil {
+				// If the error was a duplicate ID; try again
+				if _, ok := err.(*db.DuplicateIDError); ok {
+					continue
+				} else {
+					util.Error(r, w, err)
+					return
+				}
+			} else {
+				indexer.Refresh()
+
+				// Return the single canonical product as the top-level JSON
+				jsonResponse, err := json.Marshal(canonicalProduct)
+				if err != nil {
+					util.ErrorWithCode(r, w, err, http.StatusInternalServerError)
+					return
+				}
+
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusCreated)
+				w.Write(jsonResponse)
+				return
+			}
+		}
+	}
+}
+
+// Replace replaces the name and aliases of a canonical product in the database
+// and rebuilds the search index with them
+func Replace(aliasSource products.AliasSource, indexer *search.Indexer) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := chi.URLParam(r, "id")
+		if id == "" {
+			util.ErrorWithCode(r, w, errors.New("the URL parameter is empty"),
+				http.StatusBadRequest)
+			return
+		}
+
+		existing, err := aliasSource.GetCanonicalProduct(r.Context(), id)
+		if err != nil {
+			util.Error(r, w, err)
+			return
+		}
+
+		var canonicalProductCreate types.CanonicalProductCreate
+		err = json.NewDecoder(r.Body).Decode(&canonicalProductCreate)
+		if err != nil {
+			util.Error(r, w, err)
+			return
+		}
+
+		canonicalProduct, ok := prepare(w, r, aliasSource, canonicalProductCreate, id)
+		if !ok {
+			return
+		}
+		canonicalProduct.ID = existing.ID
+		canonicalProduct.CreatedAt = existing.CreatedAt
+
+		err = aliasSource.ReplaceCanonicalProduct(r.Context(), *canonicalProduct)
+		if err != nil {
+			util.Error(r, w, err)
+			return
+		}
+		indexer.Refresh()
+
+		// Return the replaced canonical product as the top-level JSON
+		jsonResponse, err := json.Marshal(canonicalProduct)
+		if err != nil {
+			util.ErrorWithCode(r, w, err, http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		w.Write(jsonResponse)
+	}
+}
+
+// Delete deletes a canonical product in the database,
+// so that its aliases are shown under their own IDs again
+// (its product metadata is kept)
+func Delete(canonicalProductProvider db.CanonicalProductProvider, indexer *search.Indexer) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := chi.URLParam(r, "id")
+		if id == "" {
+			util.ErrorWithCode(r, w, errors.New("the URL parameter is empty"),
+				http.StatusBadRequest)
+			return
+		}
+
+		err := canonicalProductProvider.DeleteCanonicalProduct(r.Context(), id)
+		if err != nil {
+			util.Error(r, w, err)
+			return
+		}
+
+		indexer.Refresh()
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// Validates a canonical product before it's created or replaced,
+// making sure that every alias is at an existing location
+// and isn't already merged into another canonical product
+// (other than the one with the given ID, which is being replaced).
+// If it isn't valid, the error response is written and false is returned
+func prepare(w http.ResponseWriter, r *http.Request, aliasSource products.AliasSource,
+	canonicalProductCreate types.CanonicalProductCreate, id string) (*types.CanonicalProduct, bool) {
+
+	name := strings.TrimSpace(canonicalProductCreate.Name)
+	if name == "" {
+		util.ErrorWithCode(r, w, errors.New("canonicalProduct Name cannot be empty"),
+			http.StatusBadRequest)
+		return nil, false
+	}
+
+	if len(canonicalProductCreate.Aliases) == 0 {
+		util.ErrorWithCode(r, w, errors.New("canonicalProduct Aliases cannot be empty"),
+			http.StatusBadRequest)
+		return nil, false
+	}
+
+	canonicalProducts, err := aliasSource.GetAllCanonicalProducts(r.Context())
+	if err != nil {
+		util.Error(r, w, err)
+		return nil, false
+	}
+
+	// Create alias -> canonical product ID map so we can find conflicts quickly
+	existing := make(map[types.ProductAlias]string)
+	for _, canonicalProduct := range canonicalProducts {
+		if canonicalProduct.ID == id {
+			continue
+		}
+
+		for _, alias := range canonicalProduct.Aliases {
+			existing[alias] = canonicalProduct.ID
+		}
+	}
+
+	aliases := []types.ProductAlias{}
+	seen := make(map[types.ProductAlias]struct{})
+	for _, alias := range canonicalProductCreate.Aliases {
+		alias.LocationID = strings.TrimSpace(alias.LocationID)
+		alias.ProductID = strings.TrimSpace(alias.ProductID)
+		if alias.LocationID == "" || alias.ProductID == "" {
+			util.ErrorWithCode(r, w, errors.New("alias LocationID and ProductID cannot be empty"),
+				http.StatusBadRequest)
+			return nil, false
+		}
+
+		if _, ok := seen[alias]; ok {
+			continue
+		}
+		seen[alias] = struct{}{}
+
+		if otherID, ok := existing[alias]; ok {
+			util.ErrorWithCode(r, w, fmt.Errorf(
+				"product '%s' at location '%s' is already merged into canonical product '%s'",
+				alias.ProductID, alias.LocationID, otherID), http.StatusConflict)
+			return nil, false
+		}
+
+		_, err := aliasSource.GetLocation(r.Context(), alias.LocationID)
+		if err != nil {
+			util.Error(r, w, err)
+			return nil, false
+		}
+
+		aliases = append(aliases, alias)
+	}
+
+	return &types.CanonicalProduct{
+		Name:    name,
+		Aliases: aliases,
+	}, true
+}
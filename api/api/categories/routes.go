@@ -0,0 +1,343 @@
+package categories
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/segmentio/ksuid"
+
+	"github.com/jd-116/klemis-kitchen-api/auth"
+	"github.com/jd-116/klemis-kitchen-api/db"
+	"github.com/jd-116/klemis-kitchen-api/products"
+	"github.com/jd-116/klemis-kitchen-api/types"
+	"github.com/jd-116/klemis-kitchen-api/util"
+)
+
+// Routes creates a new Chi router with all of the routes for the category resource,
+// at the root level
+func Routes(database db.Provider, products products.Provider) *chi.Mux {
+	router := chi.NewRouter()
+	router.Get("/", GetAll(database))
+	router.Get("/{id}", GetSingle(database))
+
+	// Admin-only routes
+	router.Group(func(r chi.Router) {
+		// Ensure the user has access
+		r.Use(auth.AdminAuthenticated)
+
+		r.Post("/", Create(database))
+		r.Delete("/{id}", Delete(database))
+		r.Patch("/{id}", Update(database))
+		r.Post("/{id}/assign", Assign(database, database, database, products))
+	})
+	return router
+}
+
+// GetAll gets all categories from the database
+func GetAll(categoryProvider db.CategoryProvider) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		categories, err := categoryProvider.GetAllCategories(r.Context())
+		if err != nil {
+			util.Error(r, w, err)
+			return
+		}
+
+		// Return the list in a JSON object
+		jsonResponse, err := json.Marshal(map[string]interface{}{
+			"categories": categories,
+		})
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		w.Write(jsonResponse)
+	}
+}
+
+// GetSingle gets a single category from the database by its ID
+func GetSingle(categoryProvider db.CategoryProvider) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := chi.URLParam(r, "id")
+		if id == "" {
+			util.ErrorWithCode(r, w, errors.New("the URL parameter is empty"),
+				http.StatusBadRequest)
+			return
+		}
+
+		category, err := categoryProvider.GetCategory(r.Context(), id)
+		if err != nil {
+			util.Error(r, w, err)
+			return
+		}
+
+		// Return the single category as the top-level JSON
+		jsonResponse, err := json.Marshal(category)
+		if err != nil {
+			util.ErrorWithCode(r, w, err, http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		w.Write(jsonResponse)
+	}
+}
+
+// Create creates a new category in the database
+func Create(categoryProvider db.CategoryProvider) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var categoryCreate types.CategoryCreate
+		err := json.NewDecoder(r.Body).Decode(&categoryCreate)
+		if err != nil {
+			util.Error(r, w, err)
+			return
+		}
+
+		categoryCreate.Name = strings.TrimSpace(categoryCreate.Name)
+		if categoryCreate.Name == "" {
+			util.ErrorWithCode(r, w, errors.New("category Name cannot be empty"),
+				http.StatusBadRequest)
+			return
+		}
+
+		category := types.Category{
+			Name:      categoryCreate.Name,
+			CreatedAt: time.Now(),
+		}
+
+		// Generate globally unique IDs for the category
+		for {
+			rand, err := ksuid.NewRandom()
+			if err != nil {
+				util.Error(r, w, err)
+				return
+			}
+
+			category.ID = rand.String()
+
+			err = categoryProvider.CreateCategory(r.Context(), category)
+			if err != nil {
+				// If the error was a duplicate ID; try again
+				if _, ok := err.(*db.DuplicateIDError); ok {
+					continue
+				} else {
+					util.Error(r, w, err)
+					return
+				}
+			} else {
+				// Return the single category as the top-level JSON
+				jsonResponse, err := json.Marshal(category)
+				if err != nil {
+					util.ErrorWithCode(r, w, err, http.StatusInternalServerError)
+					return
+				}
+
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusCreated)
+				w.Write(jsonResponse)
+				return
+			}
+		}
+	}
+}
+
+// Delete deletes a category in the database,
+// leaving its products uncategorized
+func Delete(categoryProvider db.CategoryProvider) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := chi.URLParam(r, "id")
+		if id == "" {
+			util.ErrorWithCode(r, w, errors.New("the URL parameter is empty"),
+				http.StatusBadRequest)
+			return
+		}
+
+		err := categoryProvider.DeleteCategory(r.Context(), id)
+		if err != nil {
+			util.Error(r, w, err)
+			return
+		}
+
+		err = categoryProvider.ClearProductCategories(r.Context(), id)
+		if err != nil {
+			util.Error(r, w, err)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// Update updates a category in the database
+func Update(categoryProvider db.CategoryProvider) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := chi.URLParam(r, "id")
+		if id == "" {
+			util.ErrorWithCode(r, w, errors.New("the URL parameter is empty"),
+				http.StatusBadRequest)
+			return
+		}
+
+		partial := make(map[string]interface{})
+		err := json.NewDecoder(r.Body).Decode(&partial)
+		if err != nil {
+			util.Error(r, w, err)
+			return
+		}
+
+		if value, ok := partial["name"]; ok {
+			if name, ok := value.(string); !ok || strings.TrimSpace(name) == "" {
+				util.ErrorWithCode(r, w, errors.New("category Name cannot be empty"),
+					http.StatusBadRequest)
+				return
+			}
+		}
+
+		updated, err := categoryProvider.UpdateCategory(r.Context(), id, partial)
+		if err != nil {
+			util.Error(r, w, err)
+			return
+		}
+
+		// Return the updated category as the top-level JSON
+		jsonResponse, err := json.Marshal(updated)
+		if err != nil {
+			util.ErrorWithCode(r, w, err, http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		w.Write(jsonResponse)
+	}
+}
+
+// Assign puts every product (at any location) whose name matches a pattern
+// into this category, or only lists the matches for a dry run.
+// Aliased products are assigned through the canonical product they were merged into
+func Assign(categoryProvider db.CategoryProvider, locationProvider db.LocationProvider,
+	canonicalProductProvider db.CanonicalProductProvider, cacheProducts products.Provider) http.HandlerFunc {
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := chi.URLParam(r, "id")
+		if id == "" {
+			util.ErrorWithCode(r, w, errors.New("the URL parameter is empty"),
+				http.StatusBadRequest)
+			return
+		}
+
+		var assignment types.CategoryAssignment
+		err := json.NewDecoder(r.Body).Decode(&assignment)
+		if err != nil {
+			util.Error(r, w, err)
+			return
+		}
+
+		assignment.Pattern = strings.TrimSpace(assignment.Pattern)
+		if assignment.Pattern == "" {
+			util.ErrorWithCode(r, w, errors.New("assignment Pattern cannot be empty"),
+				http.StatusBadRequest)
+			return
+		}
+		pattern, err := compilePattern(assignment.Pattern)
+		if err != nil {
+			util.ErrorWithCode(r, w, err, http.StatusBadRequest)
+			return
+		}
+
+		category, err := categoryProvider.GetCategory(r.Context(), id)
+		if err != nil {
+			util.Error(r, w, err)
+			return
+		}
+
+		dbLocations, err := locationProvider.GetAllLocations(r.Context())
+		if err != nil {
+			util.Error(r, w, err)
+			return
+		}
+
+		canonicalProducts, err := canonicalProductProvider.GetAllCanonicalProducts(r.Context())
+		if err != nil {
+			util.Error(r, w, err)
+			return
+		}
+		aliases := products.NewAliases(canonicalProducts, dbLocations)
+
+		// Find every matching product at every location
+		matchMap := make(map[string]types.CategoryAssignmentMatch)
+		for _, dbLocation := range dbLocations {
+			partialProducts, err := cacheProducts.GetAllProducts(dbLocation.InventoryIdentifier())
+			if err != nil {
+				switch err.(type) {
+				case *products.LocationNotFoundError:
+					continue
+				default:
+					util.Error(r, w, err)
+					return
+				}
+			}
+
+			for _, partialProduct := range partialProducts {
+				productID := aliases.CanonicalID(dbLocation.InventoryIdentifier(), partialProduct.ID)
+				if _, ok := matchMap[productID]; !ok && pattern.MatchString(partialProduct.Name) {
+					matchMap[productID] = types.CategoryAssignmentMatch{
+						ID:   productID,
+						Name: partialProduct.Name,
+					}
+				}
+			}
+		}
+
+		matches := []types.CategoryAssignmentMatch{}
+		productIDs := []string{}
+		for productID, match := range matchMap {
+			matches = append(matches, match)
+			productIDs = append(productIDs, productID)
+		}
+		sort.Slice(matches, func(i, j int) bool {
+			return matches[i].Name < matches[j].Name
+		})
+
+		if !assignment.DryRun {
+			err = categoryProvider.SetProductCategories(r.Context(), productIDs, &category.ID)
+			if err != nil {
+				util.Error(r, w, err)
+				return
+			}
+		}
+
+		// Return the matches in a JSON object
+		jsonResponse, err := json.Marshal(map[string]interface{}{
+			"category": category,
+			"dry_run":  assignment.DryRun,
+			"products": matches,
+		})
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		w.Write(jsonResponse)
+	}
+}
+
+// Converts a case-insensitive name pattern, where '*' matches any run of characters
+// and '?' matches any single character, into a regular expression
+func compilePattern(pattern string) (*regexp.Regexp, error) {
+	expression := regexp.QuoteMeta(pattern)
+	expression = strings.ReplaceAll(expression, `\*`, ".*")
+	expression = strings.ReplaceAll(expression, `\?`, ".")
+	return regexp.Compile("(?i)^" + expression + "$")
+}
@@ -0,0 +1,51 @@
+package files
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/go-chi/chi"
+
+	"github.com/jd-116/klemis-kitchen-api/upload"
+	"github.com/jd-116/klemis-kitchen-api/util"
+)
+
+// Routes creates a new Chi router with all of the routes
+// for serving uploaded files, at the root level
+func Routes(fileSource upload.FileSource) *chi.Mux {
+	router := chi.NewRouter()
+	router.Get("/{name}", GetSingle(fileSource))
+	return router
+}
+
+// GetSingle serves a single uploaded file by its name.
+// Uploaded files never change once stored, so they can be cached indefinitely
+func GetSingle(fileSource upload.FileSource) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		name := chi.URLParam(r, "name")
+		if name == "" {
+			util.ErrorWithCode(r, w, errors.New("the URL parameter is empty"),
+				http.StatusBadRequest)
+			return
+		}
+
+		file, err := fileSource.Open(name)
+		if err != nil {
+			util.Error(r, w, err)
+			return
+		}
+		defer file.Close()
+
+		info, err := file.Stat()
+		if err != nil {
+			util.Error(r, w, err)
+			return
+		}
+
+		// Override the headers set by the no-cache middleware
+		w.Header().Del("Expires")
+		w.Header().Del("Pragma")
+		w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+		http.ServeContent(w, r, name, info.ModTime(), file)
+	}
+}
@@ -0,0 +1,104 @@
+package health
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/go-chi/chi"
+
+	"github.com/jd-116/klemis-kitchen-api/auth"
+	"github.com/jd-116/klemis-kitchen-api/health"
+)
+
+// Routes creates a new Chi router with all of the health check routes,
+// at the root level
+func Routes(checker *health.Checker, jwtManager *auth.JWTManager) *chi.Mux {
+	router := chi.NewRouter()
+
+	// Public routes, used by load balancers
+	router.Group(func(r chi.Router) {
+		r.Get("/", Live())
+		r.Get("/live", Live())
+		r.Get("/ready", Ready(checker))
+	})
+
+	// Admin-only routes
+	router.Group(func(r chi.Router) {
+		// Ensure the user has access
+		r.Use(jwtManager.Authenticated())
+		r.Use(auth.AdminAuthenticated)
+
+		r.Get("/details", Details(checker))
+	})
+	return router
+}
+
+// Live responds as long as the server is able to handle requests at all
+func Live() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// ReadyComponent is the public shape of a single component's readiness,
+// which omits any error details
+type ReadyComponent struct {
+	Name    string `json:"name"`
+	Healthy bool   `json:"healthy"`
+}
+
+// Ready responds successfully only if every downstream component is healthy,
+// so that load balancers stop routing to instances that can't serve requests
+func Ready(checker *health.Checker) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		statuses, healthy := checker.Run(r.Context())
+		if healthy {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		components := []ReadyComponent{}
+		for _, status := range statuses {
+			components = append(components, ReadyComponent{
+				Name:    status.Name,
+				Healthy: status.Healthy,
+			})
+		}
+
+		// Return the list in a JSON object
+		jsonResponse, err := json.Marshal(map[string]interface{}{
+			"ready":      false,
+			"components": components,
+		})
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusServiceUnavailable)
+		w.Write(jsonResponse)
+	}
+}
+
+// Details runs all checks and returns the full status of each component,
+// including its latency and the last error it encountered
+func Details(checker *health.Checker) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		statuses, healthy := checker.Run(r.Context())
+
+		// Return the list in a JSON object
+		jsonResponse, err := json.Marshal(map[string]interface{}{
+			"ready":      healthy,
+			"components": statuses,
+		})
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		w.Write(jsonResponse)
+	}
+}
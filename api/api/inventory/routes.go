@@ -0,0 +1,96 @@
+package inventory
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi"
+
+	"github.com/jd-116/klemis-kitchen-api/auth"
+	"github.com/jd-116/klemis-kitchen-api/products"
+	"github.com/jd-116/klemis-kitchen-api/products/transact"
+	"github.com/jd-116/klemis-kitchen-api/util"
+)
+
+// Routes creates a new Chi router with all of the admin routes
+// for managing the inventory cache, at the root level
+func Routes(transactProvider *transact.Provider) *chi.Mux {
+	router := chi.NewRouter()
+
+	// Admin-only routes
+	router.Group(func(r chi.Router) {
+		// Ensure the user has access
+		r.Use(auth.AdminAuthenticated)
+
+		r.Post("/refresh", TriggerRefresh(transactProvider))
+		r.Get("/refresh/{id}", GetRefresh(transactProvider))
+	})
+	return router
+}
+
+// TriggerRefresh immediately starts fetching the inventory report from Transact,
+// responding with the refresh job that can be polled for its status.
+// If a refresh is already running, that job is returned instead
+func TriggerRefresh(transactProvider *transact.Provider) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		job, started, err := transactProvider.TriggerRefresh()
+		if err != nil {
+			var rateLimited *products.RefreshRateLimitedError
+			if errors.As(err, &rateLimited) {
+				retryAfter := int(math.Ceil(rateLimited.RetryAfter.Seconds()))
+				w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+			}
+
+			util.Error(r, w, err)
+			return
+		}
+
+		// Return the single job as the top-level JSON
+		jsonResponse, err := json.Marshal(job)
+		if err != nil {
+			util.ErrorWithCode(r, w, err, http.StatusInternalServerError)
+			return
+		}
+
+		status := http.StatusOK
+		if started {
+			status = http.StatusAccepted
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		w.Write(jsonResponse)
+	}
+}
+
+// GetRefresh gets the status and result counts of a single refresh job by its ID
+func GetRefresh(transactProvider *transact.Provider) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := chi.URLParam(r, "id")
+		if id == "" {
+			util.ErrorWithCode(r, w, errors.New("the URL parameter is empty"),
+				http.StatusBadRequest)
+			return
+		}
+
+		job, err := transactProvider.GetRefreshJob(id)
+		if err != nil {
+			util.Error(r, w, err)
+			return
+		}
+
+		// Return the single job as the top-level JSON
+		jsonResponse, err := json.Marshal(job)
+		if err != nil {
+			util.ErrorWithCode(r, w, err, http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		w.Write(jsonResponse)
+	}
+}
@@ -0,0 +1,231 @@
+package locations
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/rs/zerolog/hlog"
+	"github.com/segmentio/ksuid"
+
+	"github.com/jd-116/klemis-kitchen-api/auth"
+	"github.com/jd-116/klemis-kitchen-api/db"
+	"github.com/jd-116/klemis-kitchen-api/products"
+	"github.com/jd-116/klemis-kitchen-api/types"
+	"github.com/jd-116/klemis-kitchen-api/util"
+)
+
+// GetAdjustments gets all manual inventory adjustments at this location,
+// including ones that no longer apply
+func GetAdjustments(locationProvider db.LocationProvider, adjustmentProvider db.AdjustmentProvider,
+	products products.Provider) http.HandlerFunc {
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := chi.URLParam(r, "id")
+		if id == "" {
+			util.ErrorWithCode(r, w, errors.New("the URL parameter is empty"),
+				http.StatusBadRequest)
+			return
+		}
+
+		dbLocation, err := locationProvider.GetLocation(r.Context(), id)
+		if err != nil {
+			util.Error(r, w, err)
+			return
+		}
+
+		adjustments, err := adjustmentProvider.GetLocationAdjustments(r.Context(), id)
+		if err != nil {
+			util.Error(r, w, err)
+			return
+		}
+
+		// Determine whether each adjustment currently applies
+		// (if the product is no longer in the cache, none of its adjustments do)
+		now := time.Now()
+		statuses := []types.AdjustmentStatus{}
+		for _, adjustment := range adjustments {
+			active := false
+			if _, err := products.GetUnadjustedProduct(dbLocation.InventoryIdentifier(),
+				adjustment.ProductID); err == nil {
+				active = adjustment.AppliesTo(now)
+			}
+
+			statuses = append(statuses, types.AdjustmentStatus{
+				Adjustment: adjustment,
+				Active:     active,
+			})
+		}
+
+		// Return the list in a JSON object
+		jsonResponse, err := json.Marshal(map[string]interface{}{
+			"adjustments": statuses,
+		})
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		w.Write(jsonResponse)
+	}
+}
+
+// CreateAdjustment records a new manual inventory adjustment
+// for a product at this location
+func CreateAdjustment(database products.AdjustmentSource, products products.Provider) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := chi.URLParam(r, "id")
+		if id == "" {
+			util.ErrorWithCode(r, w, errors.New("the URL parameter is empty"),
+				http.StatusBadRequest)
+			return
+		}
+
+		var adjustmentCreate types.AdjustmentCreate
+		err := json.NewDecoder(r.Body).Decode(&adjustmentCreate)
+		if err != nil {
+			util.Error(r, w, err)
+			return
+		}
+
+		adjustmentCreate.Reason = strings.TrimSpace(adjustmentCreate.Reason)
+		if adjustmentCreate.Reason == "" {
+			util.ErrorWithCode(r, w, errors.New("adjustment Reason cannot be empty"),
+				http.StatusBadRequest)
+			return
+		}
+		if adjustmentCreate.Delta == 0 {
+			util.ErrorWithCode(r, w, errors.New("adjustment Delta cannot be zero"),
+				http.StatusBadRequest)
+			return
+		}
+		if adjustmentCreate.ExpiresAt == nil && !adjustmentCreate.UntilTransactChange {
+			util.ErrorWithCode(r, w, errors.New("adjustment needs an ExpiresAt time or UntilTransactChange"),
+				http.StatusBadRequest)
+			return
+		}
+		if adjustmentCreate.ExpiresAt != nil && !adjustmentCreate.ExpiresAt.After(time.Now()) {
+			util.ErrorWithCode(r, w, errors.New("adjustment ExpiresAt must be in the future"),
+				http.StatusBadRequest)
+			return
+		}
+
+		dbLocation, err := database.GetLocation(r.Context(), id)
+		if err != nil {
+			util.Error(r, w, err)
+			return
+		}
+
+		// Adjustments can only be made to products that already exist at the location,
+		// and remember the current amount so "until next Transact change" can be detected
+		partialProduct, err := products.GetUnadjustedProduct(dbLocation.InventoryIdentifier(),
+			adjustmentCreate.ProductID)
+		if err != nil {
+			util.Error(r, w, err)
+			return
+		}
+
+		createdBy, _ := auth.CurrentUser(r.Context())
+
+		adjustment := types.Adjustment{
+			LocationID:          id,
+			ProductID:           adjustmentCreate.ProductID,
+			Delta:               adjustmentCreate.Delta,
+			Reason:              adjustmentCreate.Reason,
+			CreatedBy:           createdBy,
+			CreatedAt:           time.Now(),
+			ExpiresAt:           adjustmentCreate.ExpiresAt,
+			UntilTransactChange: adjustmentCreate.UntilTransactChange,
+			BaseAmount:          partialProduct.Amount,
+		}
+
+		// Generate globally unique IDs for the adjustment
+		for {
+			rand, err := ksuid.NewRandom()
+			if err != nil {
+				util.Error(r, w, err)
+				return
+			}
+
+			adjustment.ID = rand.String()
+
+			err = database.CreateAdjustment(r.Context(), adjustment)
+			if err != nil {
+				// If the error was a duplicate ID; try again
+				if _, ok := err.(*db.DuplicateIDError); ok {
+					continue
+				} else {
+					util.Error(r, w, err)
+					return
+				}
+			} else {
+				syncAdjustments(r, database, products)
+
+				// Return the single adjustment as the top-level JSON
+				jsonResponse, err := json.Marshal(types.AdjustmentStatus{
+					Adjustment: adjustment,
+					Active:     true,
+				})
+				if err != nil {
+					util.ErrorWithCode(r, w, err, http.StatusInternalServerError)
+					return
+				}
+
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusCreated)
+				w.Write(jsonResponse)
+				return
+			}
+		}
+	}
+}
+
+// DeleteAdjustment reverts a manual inventory adjustment at this location
+func DeleteAdjustment(database products.AdjustmentSource, products products.Provider) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := chi.URLParam(r, "id")
+		adjustmentID := chi.URLParam(r, "adjustment_id")
+		if id == "" || adjustmentID == "" {
+			util.ErrorWithCode(r, w, errors.New("the URL parameter is empty"),
+				http.StatusBadRequest)
+			return
+		}
+
+		// Make sure the adjustment actually belongs to this location
+		adjustment, err := database.GetAdjustment(r.Context(), adjustmentID)
+		if err != nil {
+			util.Error(r, w, err)
+			return
+		}
+		if adjustment.LocationID != id {
+			util.Error(r, w, db.NewNotFoundError(adjustmentID))
+			return
+		}
+
+		err = database.DeleteAdjustment(r.Context(), adjustmentID)
+		if err != nil {
+			util.Error(r, w, err)
+			return
+		}
+
+		syncAdjustments(r, database, products)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// Reloads the adjustments in the products provider after they change.
+// Failures are only logged, since the change itself was already saved
+func syncAdjustments(r *http.Request, database products.AdjustmentSource, provider products.Provider) {
+	err := products.SyncAdjustments(r.Context(), database, provider)
+	if err != nil {
+		hlog.FromRequest(r).
+			Error().
+			Err(err).
+			Msg("could not reload inventory adjustments into the products provider")
+	}
+}
@@ -0,0 +1,123 @@
+package locations
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sort"
+
+	"github.com/go-chi/chi"
+
+	"github.com/jd-116/klemis-kitchen-api/db"
+	"github.com/jd-116/klemis-kitchen-api/products"
+	"github.com/jd-116/klemis-kitchen-api/types"
+	"github.com/jd-116/klemis-kitchen-api/util"
+)
+
+// GetCategories gets every category with products at this location,
+// with how many of its products are there and how many are in stock.
+// Products without a category are counted under an "Uncategorized" entry with a null ID,
+// and aliased products are counted as their canonical product
+func GetCategories(locationProvider db.LocationProvider, productMetadataProvider db.ProductMetadataProvider,
+	categoryProvider db.CategoryProvider, canonicalProductProvider db.CanonicalProductProvider,
+	cacheProducts products.Provider) http.HandlerFunc {
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := chi.URLParam(r, "id")
+		if id == "" {
+			util.ErrorWithCode(r, w, errors.New("the URL parameter is empty"),
+				http.StatusBadRequest)
+			return
+		}
+
+		dbLocation, err := locationProvider.GetLocation(r.Context(), id)
+		if err != nil {
+			util.Error(r, w, err)
+			return
+		}
+
+		partialProducts, err := cacheProducts.GetAllProducts(dbLocation.InventoryIdentifier())
+		if err != nil {
+			util.Error(r, w, err)
+			return
+		}
+
+		canonicalProducts, err := canonicalProductProvider.GetAllCanonicalProducts(r.Context())
+		if err != nil {
+			util.Error(r, w, err)
+			return
+		}
+		aliases := products.NewAliases(canonicalProducts, []types.Location{*dbLocation})
+		partialProducts = aliases.Merge(dbLocation.InventoryIdentifier(), partialProducts)
+
+		dbProducts, err := productMetadataProvider.GetAllProducts(r.Context())
+		if err != nil {
+			util.Error(r, w, err)
+			return
+		}
+
+		categories, err := categoryProvider.GetAllCategories(r.Context())
+		if err != nil {
+			util.Error(r, w, err)
+			return
+		}
+
+		// Create id -> category ID map so we can index it quickly
+		productCategories := make(map[string]string)
+		for _, dbProduct := range dbProducts {
+			if dbProduct.CategoryID != nil {
+				productCategories[dbProduct.ID] = *dbProduct.CategoryID
+			}
+		}
+
+		// Count the products in each category, treating products
+		// whose category no longer exists as uncategorized
+		locationCategories := make(map[string]*types.LocationCategory)
+		for i := range categories {
+			locationCategories[categories[i].ID] = &types.LocationCategory{
+				ID:   &categories[i].ID,
+				Name: categories[i].Name,
+			}
+		}
+		uncategorized := &types.LocationCategory{Name: "Uncategorized"}
+		for _, partialProduct := range partialProducts {
+			locationCategory, ok := locationCategories[productCategories[partialProduct.ID]]
+			if !ok {
+				locationCategory = uncategorized
+			}
+
+			locationCategory.ItemCount++
+			if partialProduct.Amount > 0 {
+				locationCategory.InStockCount++
+			}
+		}
+
+		// Only include categories with products here,
+		// in the order of ascending name (with uncategorized products last)
+		result := []types.LocationCategory{}
+		for _, locationCategory := range locationCategories {
+			if locationCategory.ItemCount > 0 {
+				result = append(result, *locationCategory)
+			}
+		}
+		sort.Slice(result, func(i, j int) bool {
+			return result[i].Name < result[j].Name
+		})
+		if uncategorized.ItemCount > 0 {
+			result = append(result, *uncategorized)
+		}
+
+		// Return the list in a JSON object
+		jsonResponse, err := json.Marshal(map[string]interface{}{
+			"categories": result,
+		})
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		w.Write(jsonResponse)
+	}
+}
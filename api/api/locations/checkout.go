@@ -0,0 +1,89 @@
+package locations
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi"
+
+	"github.com/jd-116/klemis-kitchen-api/auth"
+	"github.com/jd-116/klemis-kitchen-api/db"
+	"github.com/jd-116/klemis-kitchen-api/limits"
+	"github.com/jd-116/klemis-kitchen-api/types"
+	"github.com/jd-116/klemis-kitchen-api/util"
+)
+
+// Checkout records a user's visit to this location (and the items they took),
+// rejecting it if it would go over any of the visit or item limits
+func Checkout(locationProvider db.LocationProvider, enforcer *limits.Enforcer) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := chi.URLParam(r, "id")
+		if id == "" {
+			util.ErrorWithCode(r, w, errors.New("the URL parameter is empty"),
+				http.StatusBadRequest)
+			return
+		}
+
+		var visitCreate types.VisitCreate
+		err := json.NewDecoder(r.Body).Decode(&visitCreate)
+		if err != nil {
+			util.Error(r, w, err)
+			return
+		}
+
+		visitCreate.Username = strings.TrimSpace(visitCreate.Username)
+		if visitCreate.Username == "" {
+			util.ErrorWithCode(r, w, errors.New("visit Username cannot be empty"),
+				http.StatusBadRequest)
+			return
+		}
+		if len(visitCreate.Items) == 0 {
+			util.ErrorWithCode(r, w, errors.New("visit Items cannot be empty"),
+				http.StatusBadRequest)
+			return
+		}
+		for i, item := range visitCreate.Items {
+			visitCreate.Items[i].ProductID = strings.TrimSpace(item.ProductID)
+			if visitCreate.Items[i].ProductID == "" {
+				util.ErrorWithCode(r, w, errors.New("visit item ProductID cannot be empty"),
+					http.StatusBadRequest)
+				return
+			}
+			if item.Quantity <= 0 {
+				util.ErrorWithCode(r, w, errors.New("visit item Quantity must be positive"),
+					http.StatusBadRequest)
+				return
+			}
+		}
+
+		dbLocation, err := locationProvider.GetLocation(r.Context(), id)
+		if err != nil {
+			util.Error(r, w, err)
+			return
+		}
+
+		recordedBy, _ := auth.CurrentUser(r.Context())
+		visit, usage, err := enforcer.Checkout(r.Context(), *dbLocation, visitCreate.Username,
+			visitCreate.Items, recordedBy)
+		if err != nil {
+			util.Error(r, w, err)
+			return
+		}
+
+		// Return the visit along with what the user has left this week
+		jsonResponse, err := json.Marshal(map[string]interface{}{
+			"visit": visit,
+			"usage": usage,
+		})
+		if err != nil {
+			util.ErrorWithCode(r, w, err, http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		w.Write(jsonResponse)
+	}
+}
@@ -0,0 +1,245 @@
+package locations
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/rs/zerolog/hlog"
+	"github.com/segmentio/ksuid"
+
+	"github.com/jd-116/klemis-kitchen-api/auth"
+	"github.com/jd-116/klemis-kitchen-api/db"
+	"github.com/jd-116/klemis-kitchen-api/products/native"
+	"github.com/jd-116/klemis-kitchen-api/types"
+	"github.com/jd-116/klemis-kitchen-api/util"
+)
+
+// CreateItem creates a new item at a location with native inventory
+func CreateItem(locationProvider db.LocationProvider, nativeProvider *native.Provider) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		dbLocation, ok := nativeLocation(w, r, locationProvider)
+		if !ok {
+			return
+		}
+
+		var itemCreate types.NativeItemCreate
+		err := json.NewDecoder(r.Body).Decode(&itemCreate)
+		if err != nil {
+			util.Error(r, w, err)
+			return
+		}
+
+		itemCreate.ProductID = strings.TrimSpace(itemCreate.ProductID)
+		itemCreate.Name = strings.TrimSpace(itemCreate.Name)
+		if itemCreate.ProductID == "" {
+			util.ErrorWithCode(r, w, errors.New("item ProductID cannot be empty"),
+				http.StatusBadRequest)
+			return
+		}
+		if itemCreate.Name == "" {
+			util.ErrorWithCode(r, w, errors.New("item Name cannot be empty"),
+				http.StatusBadRequest)
+			return
+		}
+		if itemCreate.Amount < 0 {
+			util.ErrorWithCode(r, w, errors.New("item Amount cannot be negative"),
+				http.StatusBadRequest)
+			return
+		}
+
+		item := types.NativeItem{
+			LocationID: dbLocation.ID,
+			ProductID:  itemCreate.ProductID,
+			Name:       itemCreate.Name,
+			Amount:     itemCreate.Amount,
+			UpdatedAt:  time.Now(),
+		}
+
+		err = nativeProvider.CreateItem(r.Context(), *dbLocation, item)
+		if err != nil {
+			util.Error(r, w, err)
+			return
+		}
+
+		// Return the single item as the top-level JSON
+		jsonResponse, err := json.Marshal(item)
+		if err != nil {
+			util.ErrorWithCode(r, w, err, http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		w.Write(jsonResponse)
+	}
+}
+
+// GetInventoryTransactions gets all stock-in, stock-out, and set transactions
+// at a location with native inventory
+func GetInventoryTransactions(locationProvider db.LocationProvider,
+	inventoryProvider db.NativeInventoryProvider) http.HandlerFunc {
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		dbLocation, ok := nativeLocation(w, r, locationProvider)
+		if !ok {
+			return
+		}
+
+		transactions, err := inventoryProvider.GetInventoryTransactions(r.Context(), dbLocation.ID)
+		if err != nil {
+			util.Error(r, w, err)
+			return
+		}
+
+		// Return the list in a JSON object
+		jsonResponse, err := json.Marshal(map[string]interface{}{
+			"transactions": transactions,
+		})
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		w.Write(jsonResponse)
+	}
+}
+
+// CreateInventoryTransaction records a stock-in, stock-out, or set transaction
+// for an item at a location with native inventory
+func CreateInventoryTransaction(locationProvider db.LocationProvider,
+	nativeProvider *native.Provider) http.HandlerFunc {
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		dbLocation, ok := nativeLocation(w, r, locationProvider)
+		if !ok {
+			return
+		}
+
+		var transactionCreate types.InventoryTransactionCreate
+		err := json.NewDecoder(r.Body).Decode(&transactionCreate)
+		if err != nil {
+			util.Error(r, w, err)
+			return
+		}
+
+		transactionCreate.Reason = strings.TrimSpace(transactionCreate.Reason)
+		if transactionCreate.Reason == "" {
+			util.ErrorWithCode(r, w, errors.New("transaction Reason cannot be empty"),
+				http.StatusBadRequest)
+			return
+		}
+		switch transactionCreate.Kind {
+		case types.TransactionStockIn, types.TransactionStockOut:
+			if transactionCreate.Quantity <= 0 {
+				util.ErrorWithCode(r, w, errors.New("transaction Quantity must be positive"),
+					http.StatusBadRequest)
+				return
+			}
+		case types.TransactionSet:
+			if transactionCreate.Quantity < 0 {
+				util.ErrorWithCode(r, w, errors.New("transaction Quantity cannot be negative"),
+					http.StatusBadRequest)
+				return
+			}
+		default:
+			util.ErrorWithCode(r, w, errors.New("transaction Kind must be 'stock_in', 'stock_out', or 'set'"),
+				http.StatusBadRequest)
+			return
+		}
+
+		createdBy, _ := auth.CurrentUser(r.Context())
+
+		transaction := types.InventoryTransaction{
+			LocationID: dbLocation.ID,
+			ProductID:  transactionCreate.ProductID,
+			Kind:       transactionCreate.Kind,
+			Quantity:   transactionCreate.Quantity,
+			Reason:     transactionCreate.Reason,
+			CreatedBy:  createdBy,
+			CreatedAt:  time.Now(),
+		}
+
+		// Generate globally unique IDs for the transaction
+		for {
+			rand, err := ksuid.NewRandom()
+			if err != nil {
+				util.Error(r, w, err)
+				return
+			}
+
+			transaction.ID = rand.String()
+
+			applied, err := nativeProvider.RecordTransaction(r.Context(), *dbLocation, transaction)
+			if err != nil {
+				// If the error was a duplicate ID; try again
+				if _, ok := err.(*db.DuplicateIDError); ok {
+					continue
+				} else {
+					util.Error(r, w, err)
+					return
+				}
+			} else {
+				// Return the single transaction as the top-level JSON
+				jsonResponse, err := json.Marshal(applied)
+				if err != nil {
+					util.ErrorWithCode(r, w, err, http.StatusInternalServerError)
+					return
+				}
+
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusCreated)
+				w.Write(jsonResponse)
+				return
+			}
+		}
+	}
+}
+
+// Gets the location from the URL, sending an error response
+// if it doesn't exist or doesn't have native inventory
+func nativeLocation(w http.ResponseWriter, r *http.Request,
+	locationProvider db.LocationProvider) (*types.Location, bool) {
+
+	id := chi.URLParam(r, "id")
+	if id == "" {
+		util.ErrorWithCode(r, w, errors.New("the URL parameter is empty"),
+			http.StatusBadRequest)
+		return nil, false
+	}
+
+	dbLocation, err := locationProvider.GetLocation(r.Context(), id)
+	if err != nil {
+		util.Error(r, w, err)
+		return nil, false
+	}
+
+	if !dbLocation.IsNative() {
+		util.ErrorWithCode(r, w, errors.New("the location does not have native inventory"),
+			http.StatusBadRequest)
+		return nil, false
+	}
+
+	return dbLocation, true
+}
+
+func validInventorySource(source string) bool {
+	return source == types.InventorySourceTransact || source == types.InventorySourceNative
+}
+
+// Reloads the native inventory provider after the set of native locations changes.
+// Failures are only logged, since the change itself was already saved
+func reloadNativeInventory(r *http.Request, nativeProvider *native.Provider) {
+	err := nativeProvider.Reload(r.Context())
+	if err != nil {
+		hlog.FromRequest(r).
+			Error().
+			Err(err).
+			Msg("could not reload native inventory")
+	}
+}
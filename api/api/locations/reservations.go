@@ -0,0 +1,231 @@
+package locations
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi"
+
+	"github.com/jd-116/klemis-kitchen-api/auth"
+	"github.com/jd-116/klemis-kitchen-api/db"
+	"github.com/jd-116/klemis-kitchen-api/reservations"
+	"github.com/jd-116/klemis-kitchen-api/types"
+	"github.com/jd-116/klemis-kitchen-api/util"
+)
+
+// CreateReservation reserves a bag of items at this location
+// for the current user to pick up later
+func CreateReservation(locationProvider db.LocationProvider, manager *reservations.Manager) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := chi.URLParam(r, "id")
+		if id == "" {
+			util.ErrorWithCode(r, w, errors.New("the URL parameter is empty"),
+				http.StatusBadRequest)
+			return
+		}
+
+		var reservationCreate types.ReservationCreate
+		err := json.NewDecoder(r.Body).Decode(&reservationCreate)
+		if err != nil {
+			util.Error(r, w, err)
+			return
+		}
+
+		if len(reservationCreate.Items) == 0 {
+			util.ErrorWithCode(r, w, errors.New("reservation Items cannot be empty"),
+				http.StatusBadRequest)
+			return
+		}
+		for i, item := range reservationCreate.Items {
+			reservationCreate.Items[i].ProductID = strings.TrimSpace(item.ProductID)
+			if reservationCreate.Items[i].ProductID == "" {
+				util.ErrorWithCode(r, w, errors.New("reservation item ProductID cannot be empty"),
+					http.StatusBadRequest)
+				return
+			}
+			if item.Quantity <= 0 {
+				util.ErrorWithCode(r, w, errors.New("reservation item Quantity must be positive"),
+					http.StatusBadRequest)
+				return
+			}
+		}
+
+		dbLocation, err := locationProvider.GetLocation(r.Context(), id)
+		if err != nil {
+			util.Error(r, w, err)
+			return
+		}
+
+		username, _ := auth.CurrentUser(r.Context())
+		reservation, err := manager.Create(r.Context(), *dbLocation, username, reservationCreate.Items)
+		if err != nil {
+			util.Error(r, w, err)
+			return
+		}
+
+		// Return the single reservation as the top-level JSON
+		jsonResponse, err := json.Marshal(reservation)
+		if err != nil {
+			util.ErrorWithCode(r, w, err, http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		w.Write(jsonResponse)
+	}
+}
+
+// GetMyReservations gets all of the current user's reservations at this location
+func GetMyReservations(reservationProvider db.ReservationProvider) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := chi.URLParam(r, "id")
+		if id == "" {
+			util.ErrorWithCode(r, w, errors.New("the URL parameter is empty"),
+				http.StatusBadRequest)
+			return
+		}
+
+		username, _ := auth.CurrentUser(r.Context())
+		userReservations, err := reservationProvider.GetUserReservations(r.Context(), username, nil)
+		if err != nil {
+			util.Error(r, w, err)
+			return
+		}
+
+		locationReservations := []types.Reservation{}
+		for _, reservation := range userReservations {
+			if reservation.LocationID == id {
+				locationReservations = append(locationReservations, reservation)
+			}
+		}
+
+		// Return the list in a JSON object
+		jsonResponse, err := json.Marshal(map[string]interface{}{
+			"reservations": locationReservations,
+		})
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		w.Write(jsonResponse)
+	}
+}
+
+// GetReservation gets a single reservation at this location,
+// as long as it belongs to the current user (or the user is an admin)
+func GetReservation(reservationProvider db.ReservationProvider) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := chi.URLParam(r, "id")
+		reservationID := chi.URLParam(r, "reservation_id")
+		if id == "" || reservationID == "" {
+			util.ErrorWithCode(r, w, errors.New("the URL parameter is empty"),
+				http.StatusBadRequest)
+			return
+		}
+
+		reservation, err := reservationProvider.GetReservation(r.Context(), reservationID)
+		if err != nil {
+			util.Error(r, w, err)
+			return
+		}
+
+		// Don't reveal other users' reservations
+		username, admin := auth.CurrentUser(r.Context())
+		if reservation.LocationID != id || (!admin && reservation.Username != username) {
+			util.Error(r, w, db.NewNotFoundError(reservationID))
+			return
+		}
+
+		// Return the single reservation as the top-level JSON
+		jsonResponse, err := json.Marshal(reservation)
+		if err != nil {
+			util.ErrorWithCode(r, w, err, http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		w.Write(jsonResponse)
+	}
+}
+
+// GetReservations gets all reservations at this location,
+// optionally filtered by a comma-separated status querystring param
+func GetReservations(reservationProvider db.ReservationProvider) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := chi.URLParam(r, "id")
+		if id == "" {
+			util.ErrorWithCode(r, w, errors.New("the URL parameter is empty"),
+				http.StatusBadRequest)
+			return
+		}
+
+		var statuses []string
+		if status := r.URL.Query().Get("status"); status != "" {
+			statuses = strings.Split(status, ",")
+		}
+
+		locationReservations, err := reservationProvider.GetLocationReservations(r.Context(), id, statuses)
+		if err != nil {
+			util.Error(r, w, err)
+			return
+		}
+
+		// Return the list in a JSON object
+		jsonResponse, err := json.Marshal(map[string]interface{}{
+			"reservations": locationReservations,
+		})
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		w.Write(jsonResponse)
+	}
+}
+
+// UpdateReservation moves a reservation at this location to a new status,
+// such as when it has been bagged (ready) or handed over (picked up)
+func UpdateReservation(manager *reservations.Manager) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := chi.URLParam(r, "id")
+		reservationID := chi.URLParam(r, "reservation_id")
+		if id == "" || reservationID == "" {
+			util.ErrorWithCode(r, w, errors.New("the URL parameter is empty"),
+				http.StatusBadRequest)
+			return
+		}
+
+		var reservationUpdate types.ReservationUpdate
+		err := json.NewDecoder(r.Body).Decode(&reservationUpdate)
+		if err != nil {
+			util.Error(r, w, err)
+			return
+		}
+
+		updated, err := manager.Transition(r.Context(), id, reservationID, reservationUpdate.Status)
+		if err != nil {
+			util.Error(r, w, err)
+			return
+		}
+
+		// Return the updated reservation as the top-level JSON
+		jsonResponse, err := json.Marshal(updated)
+		if err != nil {
+			util.ErrorWithCode(r, w, err, http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		w.Write(jsonResponse)
+	}
+}
@@ -8,33 +8,57 @@ import (
 	"strings"
 
 	"github.com/go-chi/chi"
-	"github.com/lithammer/fuzzysearch/fuzzy"
 	"github.com/segmentio/ksuid"
 
 	"github.com/jd-116/klemis-kitchen-api/auth"
 	"github.com/jd-116/klemis-kitchen-api/db"
+	"github.com/jd-116/klemis-kitchen-api/limits"
+	"github.com/jd-116/klemis-kitchen-api/locale"
 	"github.com/jd-116/klemis-kitchen-api/products"
+	"github.com/jd-116/klemis-kitchen-api/products/native"
+	"github.com/jd-116/klemis-kitchen-api/reservations"
+	"github.com/jd-116/klemis-kitchen-api/search"
+	"github.com/jd-116/klemis-kitchen-api/tracing"
 	"github.com/jd-116/klemis-kitchen-api/types"
 	"github.com/jd-116/klemis-kitchen-api/util"
 )
 
 // Routes creates a new Chi router with all of the routes for the location resource,
 // at the root level
-func Routes(database db.Provider, products products.Provider) *chi.Mux {
+func Routes(database db.Provider, products products.Provider, nativeProvider *native.Provider,
+	reservationManager *reservations.Manager, enforcer *limits.Enforcer, indexer *search.Indexer) *chi.Mux {
+
 	router := chi.NewRouter()
 	router.Get("/", GetAll(database))
 	router.Get("/{id}", GetSingle(database))
-	router.Get("/{id}/products", GetProducts(database, database, products))
-	router.Get("/{id}/products/{product_id}", GetProduct(database, database, products))
+	router.Get("/{id}/products", GetProducts(database, database, database, products, indexer))
+	router.Get("/{id}/products/{product_id}", GetProduct(database, database, database, products))
+	router.Get("/{id}/categories", GetCategories(database, database, database, database, products))
+	router.Post("/{id}/reservations", CreateReservation(database, reservationManager))
+	router.Get("/{id}/reservations/mine", GetMyReservations(database))
+	router.Get("/{id}/reservations/{reservation_id}", GetReservation(database))
 
 	// Admin-only routes
 	router.Group(func(r chi.Router) {
 		// Ensure the user has access
 		r.Use(auth.AdminAuthenticated)
 
-		r.Post("/", Create(database))
-		r.Delete("/{id}", Delete(database))
-		r.Patch("/{id}", Update(database))
+		r.Post("/", Create(database, nativeProvider))
+		r.Delete("/{id}", Delete(database, nativeProvider))
+		r.Patch("/{id}", Update(database, products, nativeProvider))
+
+		r.Get("/{id}/adjustments", GetAdjustments(database, database, products))
+		r.Post("/{id}/adjustments", CreateAdjustment(database, products))
+		r.Delete("/{id}/adjustments/{adjustment_id}", DeleteAdjustment(database, products))
+
+		r.Post("/{id}/inventory/items", CreateItem(database, nativeProvider))
+		r.Get("/{id}/inventory/transactions", GetInventoryTransactions(database, database))
+		r.Post("/{id}/inventory/transactions", CreateInventoryTransaction(database, nativeProvider))
+
+		r.Get("/{id}/reservations", GetReservations(database))
+		r.Patch("/{id}/reservations/{reservation_id}", UpdateReservation(reservationManager))
+
+		r.Post("/{id}/checkout", Checkout(database, enforcer))
 	})
 	return router
 }
@@ -117,8 +141,11 @@ func GetSingle(locationProvider db.LocationProvider) http.HandlerFunc {
 
 // GetProducts gets all products that exist at this location,
 // with an optional search querystring param
+// that orders the results by relevance.
+// Aliased products are listed as their canonical product
 func GetProducts(locationProvider db.LocationProvider, productMetadataProvider db.ProductMetadataProvider,
-	products products.Provider) http.HandlerFunc {
+	canonicalProductProvider db.CanonicalProductProvider, cacheProducts products.Provider,
+	indexer *search.Indexer) http.HandlerFunc {
 
 	return func(w http.ResponseWriter, r *http.Request) {
 		id := chi.URLParam(r, "id")
@@ -130,7 +157,15 @@ func GetProducts(locationProvider db.LocationProvider, productMetadataProvider d
 
 		// See if we have search parameter,
 		// which can be empty
-		search := strings.ToLower(r.URL.Query().Get("search"))
+		query := strings.TrimSpace(r.URL.Query().Get("search"))
+		var scores map[string]float64
+		if query != "" {
+			scores = indexer.Search(query)
+		}
+
+		// See if we have a category parameter,
+		// which can be a category ID or "none" for uncategorized products
+		category := r.URL.Query().Get("category")
 
 		dbLocation, err := locationProvider.GetLocation(r.Context(), id)
 		if err != nil {
@@ -138,15 +173,27 @@ func GetProducts(locationProvider db.LocationProvider, productMetadataProvider d
 			return
 		}
 
-		partialProducts, err := products.GetAllProducts(dbLocation.TransactIdentifier)
+		_, cacheSpan := tracing.Start(r.Context(), "products.cache.GetAllProducts")
+		partialProducts, err := cacheProducts.GetAllProducts(dbLocation.InventoryIdentifier())
+		tracing.End(cacheSpan, err)
 		if err != nil {
 			util.Error(r, w, err)
+			return
 		}
 
 		dbProducts, err := productMetadataProvider.GetAllProducts(r.Context())
 		if err != nil {
 			util.Error(r, w, err)
+			return
+		}
+
+		canonicalProducts, err := canonicalProductProvider.GetAllCanonicalProducts(r.Context())
+		if err != nil {
+			util.Error(r, w, err)
+			return
 		}
+		aliases := products.NewAliases(canonicalProducts, []types.Location{*dbLocation})
+		partialProducts = aliases.Merge(dbLocation.InventoryIdentifier(), partialProducts)
 
 		// Create id -> dbProduct map so we can index it quickly
 		dbProductMap := make(map[string]types.ProductMetadata)
@@ -155,14 +202,12 @@ func GetProducts(locationProvider db.LocationProvider, productMetadataProvider d
 		}
 
 		// Merge db products with partial products
-		// to make `LocationProductDataSearch` structs
+		// to make `LocationProductDataSearch` structs,
+		// translated into the request's locale
+		lang := locale.FromContext(r.Context())
+		_, admin := auth.CurrentUser(r.Context())
 		locationProducts := []types.LocationProductDataSearch{}
 		for _, partialProduct := range partialProducts {
-			// Make sure the name passes a search if it was given
-			if search != "" && !fuzzy.MatchNormalized(search, strings.ToLower(partialProduct.Name)) {
-				continue
-			}
-
 			locationProduct := types.LocationProductDataSearch{
 				Name:      partialProduct.Name,
 				ID:        partialProduct.ID,
@@ -170,18 +215,66 @@ func GetProducts(locationProvider db.LocationProvider, productMetadataProvider d
 				Thumbnail: nil,
 			}
 
+			// Only admins see the raw names from Transact
+			if admin {
+				locationProduct.TransactName = partialProduct.TransactName()
+			}
+
+			// Make sure the product matches the search if it was given
+			if scores != nil {
+				score, ok := scores[partialProduct.ID]
+				if !ok {
+					continue
+				}
+				locationProduct.Score = &score
+			}
+
 			// See if this has additional metadata, and attach if so
-			if dbProduct, ok := dbProductMap[locationProduct.ID]; ok {
+			dbProduct, ok := dbProductMap[locationProduct.ID]
+			if ok {
+				locationProduct.Name = dbProduct.LocalizedName(lang, locationProduct.Name)
+				locationProduct.Description = dbProduct.LocalizedDescription(lang)
+				locationProduct.Unit = dbProduct.Unit
 				locationProduct.Thumbnail = dbProduct.Thumbnail
+				locationProduct.ThumbnailVariants = dbProduct.ThumbnailVariants
+				locationProduct.CategoryID = dbProduct.CategoryID
+			}
+
+			// Make sure the product is in the category if it was given
+			if category != "" {
+				var metadata *types.ProductMetadata
+				if ok {
+					metadata = &dbProduct
+				}
+				if !metadata.InCategory(category) {
+					continue
+				}
 			}
 
 			locationProducts = append(locationProducts, locationProduct)
 		}
 
-		// Sort the location products map in the order of descending ID
-		sort.Slice(locationProducts, func(i, j int) bool {
-			return locationProducts[i].ID < locationProducts[j].ID
-		})
+		// Sort the location products in the order of descending relevance if searching,
+		// and otherwise ascending ID
+		if scores != nil {
+			matchedScores := make(map[string]float64)
+			names := make(map[string]string)
+			byID := make(map[string]types.LocationProductDataSearch)
+			for _, locationProduct := range locationProducts {
+				matchedScores[locationProduct.ID] = *locationProduct.Score
+				names[locationProduct.ID] = locationProduct.Name
+				byID[locationProduct.ID] = locationProduct
+			}
+
+			locationProducts = []types.LocationProductDataSearch{}
+			for _, id := range search.Rank(matchedScores, names) {
+				locationProducts = append(locationProducts, byID[id])
+			}
+		} else {
+			sort.Slice(locationProducts, func(i, j int) bool {
+				return locationProducts[i].ID < locationProducts[j].ID
+			})
+		}
 
 		// Return the list in a JSON object
 		jsonResponse, err := json.Marshal(map[string]interface{}{
@@ -198,9 +291,11 @@ func GetProducts(locationProvider db.LocationProvider, productMetadataProvider d
 	}
 }
 
-// GetProduct gets a single product at this location
+// GetProduct gets a single product at this location,
+// which can be a canonical product
+// (in which case the amounts of its aliases here are added up)
 func GetProduct(locationProvider db.LocationProvider, productMetadataProvider db.ProductMetadataProvider,
-	products products.Provider) http.HandlerFunc {
+	canonicalProductProvider db.CanonicalProductProvider, cacheProducts products.Provider) http.HandlerFunc {
 
 	return func(w http.ResponseWriter, r *http.Request) {
 		locationID := chi.URLParam(r, "id")
@@ -223,9 +318,19 @@ func GetProduct(locationProvider db.LocationProvider, productMetadataProvider db
 			return
 		}
 
-		partialProduct, err := products.GetProduct(dbLocation.TransactIdentifier, productID)
+		canonicalProducts, err := canonicalProductProvider.GetAllCanonicalProducts(r.Context())
+		if err != nil {
+			util.Error(r, w, err)
+			return
+		}
+		aliases := products.NewAliases(canonicalProducts, []types.Location{*dbLocation})
+
+		_, cacheSpan := tracing.Start(r.Context(), "products.cache.GetProduct")
+		partialProduct, err := aliases.GetProduct(cacheProducts, dbLocation.InventoryIdentifier(), productID)
+		tracing.End(cacheSpan, err)
 		if err != nil {
 			util.Error(r, w, err)
+			return
 		}
 
 		// Construct a `LocationProductData` struct
@@ -237,10 +342,22 @@ func GetProduct(locationProvider db.LocationProvider, productMetadataProvider db
 			Thumbnail: nil,
 		}
 
-		// See if this has a corresponding DB product object
+		// Only admins see the raw name from Transact
+		if _, admin := auth.CurrentUser(r.Context()); admin {
+			resultProduct.TransactName = partialProduct.TransactName()
+		}
+
+		// See if this has a corresponding DB product object,
+		// translated into the request's locale
 		if dbProduct, err := productMetadataProvider.GetProduct(r.Context(), productID); err == nil {
-			resultProduct.Nutrition = dbProduct.Nutrition
+			lang := locale.FromContext(r.Context())
+			resultProduct.Name = dbProduct.LocalizedName(lang, resultProduct.Name)
+			resultProduct.Description = dbProduct.LocalizedDescription(lang)
+			resultProduct.Unit = dbProduct.Unit
+			resultProduct.Nutrition = dbProduct.LocalizedNutrition(lang)
 			resultProduct.Thumbnail = dbProduct.Thumbnail
+			resultProduct.ThumbnailVariants = dbProduct.ThumbnailVariants
+			resultProduct.CategoryID = dbProduct.CategoryID
 		}
 
 		// Return the product as JSON
@@ -257,7 +374,7 @@ func GetProduct(locationProvider db.LocationProvider, productMetadataProvider db
 }
 
 // Create creates a new location in the database
-func Create(locationProvider db.LocationProvider) http.HandlerFunc {
+func Create(locationProvider db.LocationProvider, nativeProvider *native.Provider) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		var locationCreate types.LocationCreate
 		err := json.NewDecoder(r.Body).Decode(&locationCreate)
@@ -273,10 +390,30 @@ func Create(locationProvider db.LocationProvider) http.HandlerFunc {
 			return
 		}
 
+		// Locations use Transact for their inventory unless told otherwise
+		if locationCreate.InventorySource == "" {
+			locationCreate.InventorySource = types.InventorySourceTransact
+		}
+		if !validInventorySource(locationCreate.InventorySource) {
+			util.ErrorWithCode(r, w, errors.New("location InventorySource must be 'transact' or 'native'"),
+				http.StatusBadRequest)
+			return
+		}
+
+		if (locationCreate.MaxItemsPerVisit != nil && *locationCreate.MaxItemsPerVisit < 0) ||
+			(locationCreate.MaxVisitsPerWeek != nil && *locationCreate.MaxVisitsPerWeek < 0) {
+			util.ErrorWithCode(r, w, errors.New("location limits cannot be negative"),
+				http.StatusBadRequest)
+			return
+		}
+
 		location := types.Location{
 			Name:               locationCreate.Name,
 			Location:           locationCreate.Location,
 			TransactIdentifier: locationCreate.TransactIdentifier,
+			InventorySource:    locationCreate.InventorySource,
+			MaxItemsPerVisit:   locationCreate.MaxItemsPerVisit,
+			MaxVisitsPerWeek:   locationCreate.MaxVisitsPerWeek,
 		}
 
 		// Generate globally unique IDs for the location
@@ -299,6 +436,10 @@ func Create(locationProvider db.LocationProvider) http.HandlerFunc {
 					return
 				}
 			} else {
+				if location.IsNative() {
+					reloadNativeInventory(r, nativeProvider)
+				}
+
 				// Return the single location as the top-level JSON
 				jsonResponse, err := json.Marshal(location)
 				if err != nil {
@@ -316,7 +457,7 @@ func Create(locationProvider db.LocationProvider) http.HandlerFunc {
 }
 
 // Delete deletes a location in the database
-func Delete(locationProvider db.LocationProvider) http.HandlerFunc {
+func Delete(locationProvider db.LocationProvider, nativeProvider *native.Provider) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		id := chi.URLParam(r, "id")
 		if id == "" {
@@ -331,12 +472,16 @@ func Delete(locationProvider db.LocationProvider) http.HandlerFunc {
 			return
 		}
 
+		// Stop serving the location's inventory if it was native
+		reloadNativeInventory(r, nativeProvider)
 		w.WriteHeader(http.StatusNoContent)
 	}
 }
 
 // Update updates a location in the database
-func Update(locationProvider db.LocationProvider) http.HandlerFunc {
+func Update(database products.AdjustmentSource, products products.Provider,
+	nativeProvider *native.Provider) http.HandlerFunc {
+
 	return func(w http.ResponseWriter, r *http.Request) {
 		id := chi.URLParam(r, "id")
 		if id == "" {
@@ -352,12 +497,38 @@ func Update(locationProvider db.LocationProvider) http.HandlerFunc {
 			return
 		}
 
-		updated, err := locationProvider.UpdateLocation(r.Context(), id, partial)
+		if value, ok := partial["inventory_source"]; ok {
+			if source, ok := value.(string); !ok || !validInventorySource(source) {
+				util.ErrorWithCode(r, w, errors.New("location InventorySource must be 'transact' or 'native'"),
+					http.StatusBadRequest)
+				return
+			}
+		}
+
+		for _, key := range []string{"max_items_per_visit", "max_visits_per_week"} {
+			if value, ok := partial[key]; ok && !limits.ValidLimit(value) {
+				util.ErrorWithCode(r, w, errors.New("location limits must be null or a non-negative whole number"),
+					http.StatusBadRequest)
+				return
+			}
+		}
+
+		updated, err := database.UpdateLocation(r.Context(), id, partial)
 		if err != nil {
 			util.Error(r, w, err)
 			return
 		}
 
+		// Adjustments are keyed by inventory identifier in the products provider
+		_, identifierChanged := partial["transact_identifier"]
+		_, sourceChanged := partial["inventory_source"]
+		if sourceChanged {
+			reloadNativeInventory(r, nativeProvider)
+		}
+		if identifierChanged || sourceChanged {
+			syncAdjustments(r, database, products)
+		}
+
 		// Return the updated location as the top-level JSON
 		jsonResponse, err := json.Marshal(updated)
 		if err != nil {
@@ -0,0 +1,205 @@
+package me
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/segmentio/ksuid"
+
+	"github.com/jd-116/klemis-kitchen-api/auth"
+	"github.com/jd-116/klemis-kitchen-api/db"
+	"github.com/jd-116/klemis-kitchen-api/types"
+	"github.com/jd-116/klemis-kitchen-api/util"
+)
+
+// GetDevices gets all of the current user's registered devices
+func GetDevices(deviceProvider db.DeviceProvider) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		username, _ := auth.CurrentUser(r.Context())
+		devices, err := deviceProvider.GetUserDevices(r.Context(), username)
+		if err != nil {
+			util.Error(r, w, err)
+			return
+		}
+
+		// Return the list in a JSON object
+		jsonResponse, err := json.Marshal(map[string]interface{}{
+			"devices": devices,
+		})
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		w.Write(jsonResponse)
+	}
+}
+
+// RegisterDevice registers a device's push token for the current user.
+// Registering a token that is already registered
+// moves it to the current user and replaces its subscriptions
+func RegisterDevice(deviceProvider db.DeviceProvider, locationProvider db.LocationProvider) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var deviceCreate types.DeviceCreate
+		err := json.NewDecoder(r.Body).Decode(&deviceCreate)
+		if err != nil {
+			util.Error(r, w, err)
+			return
+		}
+
+		deviceCreate.Token = strings.TrimSpace(deviceCreate.Token)
+		if deviceCreate.Token == "" {
+			util.ErrorWithCode(r, w, errors.New("device Token cannot be empty"),
+				http.StatusBadRequest)
+			return
+		}
+
+		locationIDs, ok := validLocationIDs(w, r, locationProvider, deviceCreate.LocationIDs)
+		if !ok {
+			return
+		}
+
+		username, _ := auth.CurrentUser(r.Context())
+		now := time.Now()
+		device := types.Device{
+			Username:    username,
+			Token:       deviceCreate.Token,
+			Platform:    strings.TrimSpace(deviceCreate.Platform),
+			LocationIDs: locationIDs,
+			CreatedAt:   now,
+			UpdatedAt:   now,
+		}
+
+		// Generate globally unique IDs for the device
+		for {
+			rand, err := ksuid.NewRandom()
+			if err != nil {
+				util.Error(r, w, err)
+				return
+			}
+
+			device.ID = rand.String()
+
+			registered, err := deviceProvider.RegisterDevice(r.Context(), device)
+			if err != nil {
+				// If the error was a duplicate ID; try again
+				if _, ok := err.(*db.DuplicateIDError); ok {
+					continue
+				} else {
+					util.Error(r, w, err)
+					return
+				}
+			} else {
+				// Return the single device as the top-level JSON
+				jsonResponse, err := json.Marshal(registered)
+				if err != nil {
+					util.ErrorWithCode(r, w, err, http.StatusInternalServerError)
+					return
+				}
+
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusOK)
+				w.Write(jsonResponse)
+				return
+			}
+		}
+	}
+}
+
+// UpdateDevice changes which locations one of the current user's devices is subscribed to
+func UpdateDevice(deviceProvider db.DeviceProvider, locationProvider db.LocationProvider) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := chi.URLParam(r, "id")
+		if id == "" {
+			util.ErrorWithCode(r, w, errors.New("the URL parameter is empty"),
+				http.StatusBadRequest)
+			return
+		}
+
+		var deviceUpdate types.DeviceUpdate
+		err := json.NewDecoder(r.Body).Decode(&deviceUpdate)
+		if err != nil {
+			util.Error(r, w, err)
+			return
+		}
+
+		locationIDs, ok := validLocationIDs(w, r, locationProvider, deviceUpdate.LocationIDs)
+		if !ok {
+			return
+		}
+
+		username, _ := auth.CurrentUser(r.Context())
+		updated, err := deviceProvider.UpdateDevice(r.Context(), username, id, map[string]interface{}{
+			"location_ids": locationIDs,
+			"updated_at":   time.Now(),
+		})
+		if err != nil {
+			util.Error(r, w, err)
+			return
+		}
+
+		// Return the updated device as the top-level JSON
+		jsonResponse, err := json.Marshal(updated)
+		if err != nil {
+			util.ErrorWithCode(r, w, err, http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		w.Write(jsonResponse)
+	}
+}
+
+// DeleteDevice unregisters one of the current user's devices
+func DeleteDevice(deviceProvider db.DeviceProvider) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := chi.URLParam(r, "id")
+		if id == "" {
+			util.ErrorWithCode(r, w, errors.New("the URL parameter is empty"),
+				http.StatusBadRequest)
+			return
+		}
+
+		username, _ := auth.CurrentUser(r.Context())
+		err := deviceProvider.DeleteDevice(r.Context(), username, id)
+		if err != nil {
+			util.Error(r, w, err)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// Makes sure every subscribed location exists,
+// sending an error response if one doesn't
+func validLocationIDs(w http.ResponseWriter, r *http.Request, locationProvider db.LocationProvider,
+	locationIDs []string) ([]string, bool) {
+
+	valid := []string{}
+	for _, locationID := range locationIDs {
+		locationID = strings.TrimSpace(locationID)
+		if locationID == "" {
+			util.ErrorWithCode(r, w, errors.New("device LocationIDs cannot contain empty IDs"),
+				http.StatusBadRequest)
+			return nil, false
+		}
+
+		_, err := locationProvider.GetLocation(r.Context(), locationID)
+		if err != nil {
+			util.Error(r, w, err)
+			return nil, false
+		}
+
+		valid = append(valid, locationID)
+	}
+
+	return valid, true
+}
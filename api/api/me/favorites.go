@@ -0,0 +1,151 @@
+package me
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/segmentio/ksuid"
+
+	"github.com/jd-116/klemis-kitchen-api/auth"
+	"github.com/jd-116/klemis-kitchen-api/db"
+	"github.com/jd-116/klemis-kitchen-api/types"
+	"github.com/jd-116/klemis-kitchen-api/util"
+)
+
+// GetFavorites gets all of the current user's favorites
+func GetFavorites(favoriteProvider db.FavoriteProvider) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		username, _ := auth.CurrentUser(r.Context())
+		favorites, err := favoriteProvider.GetUserFavorites(r.Context(), username)
+		if err != nil {
+			util.Error(r, w, err)
+			return
+		}
+
+		// Return the list in a JSON object
+		jsonResponse, err := json.Marshal(map[string]interface{}{
+			"favorites": favorites,
+		})
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		w.Write(jsonResponse)
+	}
+}
+
+// CreateFavorite adds a product (optionally at a single location)
+// to the current user's favorites.
+// Adding a favorite the user already has returns the existing one
+func CreateFavorite(favoriteProvider db.FavoriteProvider, locationProvider db.LocationProvider) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var favoriteCreate types.FavoriteCreate
+		err := json.NewDecoder(r.Body).Decode(&favoriteCreate)
+		if err != nil {
+			util.Error(r, w, err)
+			return
+		}
+
+		favoriteCreate.ProductID = strings.TrimSpace(favoriteCreate.ProductID)
+		favoriteCreate.LocationID = strings.TrimSpace(favoriteCreate.LocationID)
+		if favoriteCreate.ProductID == "" {
+			util.ErrorWithCode(r, w, errors.New("favorite ProductID cannot be empty"),
+				http.StatusBadRequest)
+			return
+		}
+
+		if favoriteCreate.LocationID != "" {
+			_, err := locationProvider.GetLocation(r.Context(), favoriteCreate.LocationID)
+			if err != nil {
+				util.Error(r, w, err)
+				return
+			}
+		}
+
+		username, _ := auth.CurrentUser(r.Context())
+		existing, err := favoriteProvider.GetUserFavorites(r.Context(), username)
+		if err != nil {
+			util.Error(r, w, err)
+			return
+		}
+		for _, favorite := range existing {
+			if favorite.ProductID == favoriteCreate.ProductID && favorite.LocationID == favoriteCreate.LocationID {
+				writeFavorite(r, w, favorite, http.StatusOK)
+				return
+			}
+		}
+
+		favorite := types.Favorite{
+			Username:   username,
+			ProductID:  favoriteCreate.ProductID,
+			LocationID: favoriteCreate.LocationID,
+			CreatedAt:  time.Now(),
+		}
+
+		// Generate globally unique IDs for the favorite
+		for {
+			rand, err := ksuid.NewRandom()
+			if err != nil {
+				util.Error(r, w, err)
+				return
+			}
+
+			favorite.ID = rand.String()
+
+			err = favoriteProvider.CreateFavorite(r.Context(), favorite)
+			if err != nil {
+				// If the error was a duplicate ID; try again
+				if _, ok := err.(*db.DuplicateIDError); ok {
+					continue
+				} else {
+					util.Error(r, w, err)
+					return
+				}
+			} else {
+				writeFavorite(r, w, favorite, http.StatusCreated)
+				return
+			}
+		}
+	}
+}
+
+// DeleteFavorite removes one of the current user's favorites
+func DeleteFavorite(favoriteProvider db.FavoriteProvider) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := chi.URLParam(r, "id")
+		if id == "" {
+			util.ErrorWithCode(r, w, errors.New("the URL parameter is empty"),
+				http.StatusBadRequest)
+			return
+		}
+
+		username, _ := auth.CurrentUser(r.Context())
+		err := favoriteProvider.DeleteFavorite(r.Context(), username, id)
+		if err != nil {
+			util.Error(r, w, err)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// Sends the single favorite as the top-level JSON
+func writeFavorite(r *http.Request, w http.ResponseWriter, favorite types.Favorite, statusCode int) {
+	jsonResponse, err := json.Marshal(favorite)
+	if err != nil {
+		util.ErrorWithCode(r, w, err, http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	w.Write(jsonResponse)
+}
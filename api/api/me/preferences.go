@@ -0,0 +1,63 @@
+package me
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/jd-116/klemis-kitchen-api/auth"
+	"github.com/jd-116/klemis-kitchen-api/db"
+	"github.com/jd-116/klemis-kitchen-api/types"
+	"github.com/jd-116/klemis-kitchen-api/util"
+)
+
+// GetNotificationPreferences gets which notifications the current user has opted out of
+func GetNotificationPreferences(preferencesProvider db.NotificationPreferencesProvider) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		username, _ := auth.CurrentUser(r.Context())
+		preferences, err := preferencesProvider.GetNotificationPreferences(r.Context(), username)
+		if err != nil {
+			util.Error(r, w, err)
+			return
+		}
+
+		writePreferences(r, w, *preferences)
+	}
+}
+
+// SetNotificationPreferences replaces the current user's notification preferences
+func SetNotificationPreferences(preferencesProvider db.NotificationPreferencesProvider) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var preferences types.NotificationPreferences
+		err := json.NewDecoder(r.Body).Decode(&preferences)
+		if err != nil {
+			util.Error(r, w, err)
+			return
+		}
+
+		// Users can only change their own preferences
+		preferences.Username, _ = auth.CurrentUser(r.Context())
+		preferences.UpdatedAt = time.Now()
+
+		err = preferencesProvider.SetNotificationPreferences(r.Context(), preferences)
+		if err != nil {
+			util.Error(r, w, err)
+			return
+		}
+
+		writePreferences(r, w, preferences)
+	}
+}
+
+// Sends the preferences as the top-level JSON
+func writePreferences(r *http.Request, w http.ResponseWriter, preferences types.NotificationPreferences) {
+	jsonResponse, err := json.Marshal(preferences)
+	if err != nil {
+		util.ErrorWithCode(r, w, err, http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(jsonResponse)
+}
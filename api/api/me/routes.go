@@ -0,0 +1,54 @@
+package me
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/go-chi/chi"
+
+	"github.com/jd-116/klemis-kitchen-api/auth"
+	"github.com/jd-116/klemis-kitchen-api/db"
+	"github.com/jd-116/klemis-kitchen-api/limits"
+	"github.com/jd-116/klemis-kitchen-api/util"
+)
+
+// Routes creates a new Chi router with all of the routes
+// for the current user's own resources, at the root level
+func Routes(database db.Provider, enforcer *limits.Enforcer) *chi.Mux {
+	router := chi.NewRouter()
+	router.Get("/usage", GetUsage(enforcer))
+	router.Get("/favorites", GetFavorites(database))
+	router.Post("/favorites", CreateFavorite(database, database))
+	router.Delete("/favorites/{id}", DeleteFavorite(database))
+	router.Get("/notification-preferences", GetNotificationPreferences(database))
+	router.Put("/notification-preferences", SetNotificationPreferences(database))
+	router.Get("/devices", GetDevices(database))
+	router.Post("/devices", RegisterDevice(database, database))
+	router.Patch("/devices/{id}", UpdateDevice(database, database))
+	router.Delete("/devices/{id}", DeleteDevice(database))
+	return router
+}
+
+// GetUsage gets the current user's usage of each location and limited product this week,
+// including how much they have left
+func GetUsage(enforcer *limits.Enforcer) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		username, _ := auth.CurrentUser(r.Context())
+		usage, err := enforcer.Usage(r.Context(), username)
+		if err != nil {
+			util.Error(r, w, err)
+			return
+		}
+
+		// Return the usage as the top-level JSON
+		jsonResponse, err := json.Marshal(usage)
+		if err != nil {
+			util.ErrorWithCode(r, w, err, http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		w.Write(jsonResponse)
+	}
+}
@@ -3,61 +3,94 @@ package products
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
 	"net/http"
 	"sort"
 	"strings"
 
 	"github.com/go-chi/chi"
-	"github.com/lithammer/fuzzysearch/fuzzy"
 
 	"github.com/jd-116/klemis-kitchen-api/auth"
 	"github.com/jd-116/klemis-kitchen-api/db"
+	"github.com/jd-116/klemis-kitchen-api/limits"
+	"github.com/jd-116/klemis-kitchen-api/locale"
 	"github.com/jd-116/klemis-kitchen-api/products"
+	"github.com/jd-116/klemis-kitchen-api/search"
+	"github.com/jd-116/klemis-kitchen-api/tracing"
 	"github.com/jd-116/klemis-kitchen-api/types"
 	"github.com/jd-116/klemis-kitchen-api/util"
 )
 
 // Routes creates a new Chi router with all of the routes for the product resource,
 // at the root level
-func Routes(database db.Provider, products products.Provider) *chi.Mux {
+func Routes(database db.Provider, products products.Provider, indexer *search.Indexer,
+	locales *locale.Negotiator) *chi.Mux {
+
 	router := chi.NewRouter()
-	router.Get("/", GetAll(database, database, products))
+	router.Get("/", GetAll(database, database, products, indexer))
 	router.Get("/{id}", GetSingle(database, database, products))
 
 	// Admin-only routes
 	router.Group(func(r chi.Router) {
 		// Ensure the user has access
 		r.Use(auth.AdminAuthenticated)
-		r.Patch("/{id}", Update(database))
+		r.Patch("/{id}", Update(database, database, indexer, locales))
 	})
 	return router
 }
 
 // GetAll gets all products from the database,
 // with an optional search querystring param
-func GetAll(productMetadataProvider db.ProductMetadataProvider, locationProvider db.LocationProvider,
-	cacheProducts products.Provider) http.HandlerFunc {
+// that orders the results by relevance.
+// Aliased products are listed as their canonical product
+func GetAll(productMetadataProvider db.ProductMetadataProvider, aliasSource products.AliasSource,
+	cacheProducts products.Provider, indexer *search.Indexer) http.HandlerFunc {
 
 	// Use a closure to inject the database provider
 	return func(w http.ResponseWriter, r *http.Request) {
 		// See if we have search parameter,
 		// which can be empty
-		search := strings.ToLower(r.URL.Query().Get("search"))
+		query := strings.TrimSpace(r.URL.Query().Get("search"))
+		var scores map[string]float64
+		if query != "" {
+			scores = indexer.Search(query)
+		}
+
+		// See if we have a category parameter,
+		// which can be a category ID or "none" for uncategorized products
+		category := r.URL.Query().Get("category")
 
-		dbLocations, err := locationProvider.GetAllLocations(r.Context())
+		dbLocations, err := aliasSource.GetAllLocations(r.Context())
 		if err != nil {
 			util.Error(r, w, err)
 			return
 		}
 
+		canonicalProducts, err := aliasSource.GetAllCanonicalProducts(r.Context())
+		if err != nil {
+			util.Error(r, w, err)
+			return
+		}
+		aliases := products.NewAliases(canonicalProducts, dbLocations)
+
 		dbProducts, err := productMetadataProvider.GetAllProducts(r.Context())
 		if err != nil {
 			util.Error(r, w, err)
 			return
 		}
 
+		// Create id -> dbProduct map so we can filter by category quickly
+		dbProductMap := make(map[string]*types.ProductMetadata)
+		for i := range dbProducts {
+			dbProductMap[dbProducts[i].ID] = &dbProducts[i]
+		}
+
+		// Trace the time spent reading from (and waiting on the lock of) the cache
+		_, cacheSpan := tracing.Start(r.Context(), "products.cache.collect")
+
 		cacheLocations, err := cacheProducts.GetAllLocations()
 		if err != nil {
+			tracing.End(cacheSpan, err)
 			util.Error(r, w, err)
 			return
 		}
@@ -66,9 +99,12 @@ func GetAll(productMetadataProvider db.ProductMetadataProvider, locationProvider
 		// locations from Transact have corresponding concrete locations
 		locationIdentifierSet := make(map[string]struct{})
 		for _, dbLocation := range dbLocations {
-			locationIdentifierSet[dbLocation.TransactIdentifier] = struct{}{}
+			locationIdentifierSet[dbLocation.InventoryIdentifier()] = struct{}{}
 		}
 
+		// Only admins see the raw names from Transact
+		_, admin := auth.CurrentUser(r.Context())
+
 		// Create id -> ProductDataSearch map
 		// Since some locations might have PartialProducts with duplicate IDs
 		productMap := make(map[string]types.ProductDataSearch)
@@ -80,9 +116,11 @@ func GetAll(productMetadataProvider db.ProductMetadataProvider, locationProvider
 
 			partialProducts, err := cacheProducts.GetAllProducts(cacheLocation)
 			if err != nil {
+				tracing.End(cacheSpan, err)
 				util.Error(r, w, err)
 				return
 			}
+			partialProducts = aliases.Merge(cacheLocation, partialProducts)
 
 			for _, partialProduct := range partialProducts {
 				// Only create a new ProductDataSearch if this ID isn't already in the map
@@ -90,37 +128,70 @@ func GetAll(productMetadataProvider db.ProductMetadataProvider, locationProvider
 					continue
 				}
 
-				// Make sure the name passes a search if it was given
-				if search != "" && !fuzzy.MatchNormalized(search, strings.ToLower(partialProduct.Name)) {
-					continue
-				}
-
-				productMap[partialProduct.ID] = types.ProductDataSearch{
+				// Make sure the product matches the search if it was given
+				product := types.ProductDataSearch{
 					Name:      partialProduct.Name,
 					ID:        partialProduct.ID,
 					Thumbnail: nil,
 					Nutrition: nil,
 				}
+				if admin {
+					product.TransactName = partialProduct.TransactName()
+				}
+				if scores != nil {
+					score, ok := scores[partialProduct.ID]
+					if !ok {
+						continue
+					}
+					product.Score = &score
+				}
+
+				// Make sure the product is in the category if it was given
+				if category != "" && !dbProductMap[partialProduct.ID].InCategory(category) {
+					continue
+				}
+
+				productMap[partialProduct.ID] = product
 			}
 		}
 
-		// Fold in any thumbnail/nutrition metadata for each DB Product
+		cacheSpan.End()
+
+		// Fold in any display name/thumbnail/nutrition metadata for each DB Product,
+		// translated into the request's locale
+		lang := locale.FromContext(r.Context())
 		for _, dbProduct := range dbProducts {
 			if product, ok := productMap[dbProduct.ID]; ok {
 				// Update the ProductDataSearch struct with the metadata
+				product.Name = dbProduct.LocalizedName(lang, product.Name)
+				product.Description = dbProduct.LocalizedDescription(lang)
+				product.Unit = dbProduct.Unit
 				product.Thumbnail = dbProduct.Thumbnail
-				product.Nutrition = dbProduct.Nutrition
+				product.ThumbnailVariants = dbProduct.ThumbnailVariants
+				product.Nutrition = dbProduct.LocalizedNutrition(lang)
+				product.CategoryID = dbProduct.CategoryID
 				productMap[dbProduct.ID] = product
 			}
 		}
 
 		// Collect the product map into a slice,
-		// in the order of ascending IDs by first extracting all IDs
+		// in the order of descending relevance if searching
+		// and otherwise ascending IDs, by first extracting all IDs
 		ids := []string{}
-		for id := range productMap {
-			ids = append(ids, id)
+		if scores != nil {
+			matchedScores := make(map[string]float64)
+			names := make(map[string]string)
+			for id, product := range productMap {
+				matchedScores[id] = *product.Score
+				names[id] = product.Name
+			}
+			ids = search.Rank(matchedScores, names)
+		} else {
+			for id := range productMap {
+				ids = append(ids, id)
+			}
+			sort.Strings(ids)
 		}
-		sort.Strings(ids)
 
 		// Finally, actually collect
 		resultProducts := []types.ProductDataSearch{}
@@ -150,8 +221,10 @@ type productsData struct {
 	amounts        map[string]int
 }
 
-// GetSingle gets a single product from the database by its ID
-func GetSingle(productMetadataProvider db.ProductMetadataProvider, locationProvider db.LocationProvider,
+// GetSingle gets a single product from the database by its ID,
+// which can be a canonical product
+// (in which case the amounts of its aliases at each location are added up)
+func GetSingle(productMetadataProvider db.ProductMetadataProvider, aliasSource products.AliasSource,
 	cacheProducts products.Provider) http.HandlerFunc {
 
 	return func(w http.ResponseWriter, r *http.Request) {
@@ -168,33 +241,61 @@ func GetSingle(productMetadataProvider db.ProductMetadataProvider, locationProvi
 			productMetadata = nil
 		}
 
-		cacheLocations, err := cacheProducts.GetAllLocations()
+		dbLocations, err := aliasSource.GetAllLocations(r.Context())
 		if err != nil {
 			util.Error(r, w, err)
 			return
 		}
 
-		dbLocations, err := locationProvider.GetAllLocations(r.Context())
+		canonicalProducts, err := aliasSource.GetAllCanonicalProducts(r.Context())
 		if err != nil {
 			util.Error(r, w, err)
 			return
 		}
+		aliases := products.NewAliases(canonicalProducts, dbLocations)
+		canonicalProduct, isCanonical := aliases.Canonical(id)
 
 		// Create identifier -> DB Location map
 		dbLocationMap := make(map[string]types.Location)
 		for _, dbLocation := range dbLocations {
-			dbLocationMap[dbLocation.TransactIdentifier] = dbLocation
+			dbLocationMap[dbLocation.InventoryIdentifier()] = dbLocation
+		}
+
+		// Trace the time spent reading from (and waiting on the lock of) the cache
+		_, cacheSpan := tracing.Start(r.Context(), "products.cache.collect")
+
+		cacheLocations, err := cacheProducts.GetAllLocations()
+		if err != nil {
+			tracing.End(cacheSpan, err)
+			util.Error(r, w, err)
+			return
 		}
 
 		var finalProduct productsData
 		finalProduct.partialProduct.ID = id
 		finalProduct.amounts = make(map[string]int)
+		if isCanonical {
+			finalProduct.partialProduct.Name = canonicalProduct.Name
+			finalProduct.partialProduct.TransactNames = []string{}
+		}
 
 		for _, cacheLocation := range cacheLocations {
 			// Make sure this is a concrete location
 			if dbLocation, ok := dbLocationMap[cacheLocation]; ok {
-				singleProduct, err := cacheProducts.GetProduct(cacheLocation, id)
+				// Canonical products only exist where they have aliases
+				if isCanonical && !aliases.Has(cacheLocation, id) {
+					continue
+				}
+
+				singleProduct, err := aliases.GetProduct(cacheProducts, cacheLocation, id)
 				if err != nil {
+					// Aliases that aren't in the latest inventory are out of stock
+					if _, ok := err.(*products.PartialProductNotFoundError); ok && isCanonical {
+						finalProduct.amounts[dbLocation.ID] = 0
+						continue
+					}
+
+					tracing.End(cacheSpan, err)
 					util.Error(r, w, err)
 					return
 				}
@@ -205,18 +306,36 @@ func GetSingle(productMetadataProvider db.ProductMetadataProvider, locationProvi
 				if finalProduct.partialProduct.Name == "" {
 					finalProduct.partialProduct.Name = singleProduct.Name
 				}
+				if isCanonical {
+					finalProduct.partialProduct.TransactNames = append(finalProduct.partialProduct.TransactNames,
+						singleProduct.TransactNames...)
+				}
 			}
 		}
 
+		cacheSpan.End()
+
 		var resultProduct types.ProductData
 		resultProduct.ID = finalProduct.partialProduct.ID
 		resultProduct.Name = finalProduct.partialProduct.Name
 		resultProduct.Amounts = finalProduct.amounts
 
-		// Attach product metadata if found
+		// Only admins see the raw name from Transact
+		if _, admin := auth.CurrentUser(r.Context()); admin {
+			resultProduct.TransactName = finalProduct.partialProduct.TransactName()
+		}
+
+		// Attach product metadata if found,
+		// translated into the request's locale
 		if productMetadata != nil {
-			resultProduct.Nutrition = productMetadata.Nutrition
+			lang := locale.FromContext(r.Context())
+			resultProduct.Name = productMetadata.LocalizedName(lang, resultProduct.Name)
+			resultProduct.Description = productMetadata.LocalizedDescription(lang)
+			resultProduct.Unit = productMetadata.Unit
+			resultProduct.Nutrition = productMetadata.LocalizedNutrition(lang)
 			resultProduct.Thumbnail = productMetadata.Thumbnail
+			resultProduct.ThumbnailVariants = productMetadata.ThumbnailVariants
+			resultProduct.CategoryID = productMetadata.CategoryID
 		}
 
 		// Return the single product as the top-level JSON
@@ -232,8 +351,11 @@ func GetSingle(productMetadataProvider db.ProductMetadataProvider, locationProvi
 	}
 }
 
-// Update updates a products metadata in the database
-func Update(productMetadataProvider db.ProductMetadataProvider) http.HandlerFunc {
+// Update updates a products metadata in the database,
+// refreshing the search index since it includes the metadata
+func Update(productMetadataProvider db.ProductMetadataProvider, categoryProvider db.CategoryProvider,
+	indexer *search.Indexer, locales *locale.Negotiator) http.HandlerFunc {
+
 	return func(w http.ResponseWriter, r *http.Request) {
 		id := chi.URLParam(r, "id")
 		if id == "" {
@@ -256,11 +378,79 @@ func Update(productMetadataProvider db.ProductMetadataProvider) http.HandlerFunc
 				return
 			}
 
+			// Trim the display text, clearing it if empty
+			for _, key := range []string{"display_name", "description", "unit"} {
+				if value, ok := partial[key]; ok {
+					text, ok := parseText(value)
+					if !ok {
+						util.ErrorWithCode(r, w, fmt.Errorf("product %s must be a string or null", key),
+							http.StatusBadRequest)
+						return
+					}
+					partial[key] = text
+				}
+			}
+
+			for _, key := range []string{"max_per_visit", "max_per_week"} {
+				if value, ok := partial[key]; ok && !limits.ValidLimit(value) {
+					util.ErrorWithCode(r, w, errors.New("product limits must be null or a non-negative whole number"),
+						http.StatusBadRequest)
+					return
+				}
+			}
+
+			// Make sure the category exists if one is being assigned
+			if value, ok := partial["category_id"]; ok && value != nil {
+				categoryID, ok := value.(string)
+				if !ok {
+					util.ErrorWithCode(r, w, errors.New("product CategoryID must be a string or null"),
+						http.StatusBadRequest)
+					return
+				}
+				_, err := categoryProvider.GetCategory(r.Context(), categoryID)
+				if err != nil {
+					util.Error(r, w, err)
+					return
+				}
+			}
+
+			// Make sure the thumbnail variants are a complete set if given,
+			// and use the thumbnail variant as the thumbnail unless one was also given
+			if value, ok := partial["thumbnail_variants"]; ok && value != nil {
+				variants, ok := parseImageVariants(value)
+				if !ok {
+					util.ErrorWithCode(r, w, errors.New("product ThumbnailVariants must have non-empty thumbnail, medium, and original URLs"),
+						http.StatusBadRequest)
+					return
+				}
+				partial["thumbnail_variants"] = variants
+				if _, ok := partial["thumbnail"]; !ok {
+					partial["thumbnail"] = variants.Thumbnail
+				}
+			}
+
+			// Make sure the translations are into supported locales if given
+			if value, ok := partial["translations"]; ok && value != nil {
+				translations, err := parseTranslations(value)
+				if err != nil {
+					util.ErrorWithCode(r, w, errors.New("product Translations must map locales to a name and nutritional facts"),
+						http.StatusBadRequest)
+					return
+				}
+				err = locales.CheckTranslations(translationLocales(translations))
+				if err != nil {
+					util.ErrorWithCode(r, w, err, http.StatusBadRequest)
+					return
+				}
+				partial["translations"] = translations
+			}
+
 			updated, err := productMetadataProvider.UpdateProduct(r.Context(), id, partial)
 			if err != nil {
 				util.Error(r, w, err)
 				return
 			}
+			indexer.Refresh()
 
 			// Return the updated product metadata as the top-level JSON
 			jsonResponse, err := json.Marshal(updated)
@@ -289,11 +479,50 @@ func Update(productMetadataProvider db.ProductMetadataProvider) http.HandlerFunc
 				return
 			}
 
+			// Trim the display text, clearing it if empty
+			productMetadata.DisplayName = trimText(productMetadata.DisplayName)
+			productMetadata.Description = trimText(productMetadata.Description)
+			productMetadata.Unit = trimText(productMetadata.Unit)
+
+			if (productMetadata.MaxPerVisit != nil && *productMetadata.MaxPerVisit < 0) ||
+				(productMetadata.MaxPerWeek != nil && *productMetadata.MaxPerWeek < 0) {
+				util.ErrorWithCode(r, w, errors.New("product limits cannot be negative"),
+					http.StatusBadRequest)
+				return
+			}
+
+			// Make sure the category exists if one is being assigned
+			if productMetadata.CategoryID != nil {
+				_, err := categoryProvider.GetCategory(r.Context(), *productMetadata.CategoryID)
+				if err != nil {
+					util.Error(r, w, err)
+					return
+				}
+			}
+
+			if variants := productMetadata.ThumbnailVariants; variants != nil {
+				if variants.Thumbnail == "" || variants.Medium == "" || variants.Original == "" {
+					util.ErrorWithCode(r, w, errors.New("product ThumbnailVariants must have non-empty thumbnail, medium, and original URLs"),
+						http.StatusBadRequest)
+					return
+				}
+				if productMetadata.Thumbnail == nil {
+					productMetadata.Thumbnail = &variants.Thumbnail
+				}
+			}
+
+			err = locales.CheckTranslations(translationLocales(productMetadata.Translations))
+			if err != nil {
+				util.ErrorWithCode(r, w, err, http.StatusBadRequest)
+				return
+			}
+
 			err = productMetadataProvider.CreateProduct(r.Context(), productMetadata)
 			if err != nil {
 				util.Error(r, w, err)
 				return
 			}
+			indexer.Refresh()
 
 			// Return the updated product metadata as the top-level JSON
 			jsonResponse, err := json.Marshal(productMetadata)
@@ -308,3 +537,74 @@ func Update(productMetadataProvider db.ProductMetadataProvider) http.HandlerFunc
 		}
 	}
 }
+
+// Parses a set of image variants from a decoded JSON object,
+// making sure that each variant has a URL
+func parseImageVariants(value interface{}) (*types.ImageVariants, bool) {
+	object, ok := value.(map[string]interface{})
+	if !ok {
+		return nil, false
+	}
+
+	urls := make(map[string]string)
+	for _, key := range []string{"thumbnail", "medium", "original"} {
+		url, ok := object[key].(string)
+		if !ok || strings.TrimSpace(url) == "" {
+			return nil, false
+		}
+		urls[key] = url
+	}
+
+	return &types.ImageVariants{
+		Thumbnail: urls["thumbnail"],
+		Medium:    urls["medium"],
+		Original:  urls["original"],
+	}, true
+}
+
+// Parses an optional piece of display text from a decoded JSON value,
+// which is nil if it's null or empty
+func parseText(value interface{}) (*string, bool) {
+	if value == nil {
+		return nil, true
+	}
+
+	text, ok := value.(string)
+	if !ok {
+		return nil, false
+	}
+	return trimText(&text), true
+}
+
+// Trims an optional piece of display text, clearing it if it's empty
+func trimText(text *string) *string {
+	if text == nil {
+		return nil
+	}
+
+	trimmed := strings.TrimSpace(*text)
+	if trimmed == "" {
+		return nil
+	}
+	return &trimmed
+}
+
+// Parses a set of product translations from a decoded JSON object
+func parseTranslations(value interface{}) (map[string]types.ProductTranslation, error) {
+	var translations map[string]types.ProductTranslation
+	err := util.DecodePartial(value, &translations)
+	if err != nil {
+		return nil, err
+	}
+
+	return translations, nil
+}
+
+// Gets the locale of each product translation
+func translationLocales(translations map[string]types.ProductTranslation) []string {
+	tags := []string{}
+	for tag := range translations {
+		tags = append(tags, tag)
+	}
+	return tags
+}
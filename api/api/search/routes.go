@@ -0,0 +1,147 @@
+package search
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/segmentio/ksuid"
+
+	"github.com/jd-116/klemis-kitchen-api/auth"
+	"github.com/jd-116/klemis-kitchen-api/db"
+	"github.com/jd-116/klemis-kitchen-api/search"
+	"github.com/jd-116/klemis-kitchen-api/types"
+	"github.com/jd-116/klemis-kitchen-api/util"
+)
+
+// Routes creates a new Chi router with all of the admin routes
+// for managing product search, at the root level
+func Routes(database db.Provider, indexer *search.Indexer) *chi.Mux {
+	router := chi.NewRouter()
+
+	// Admin-only routes
+	router.Group(func(r chi.Router) {
+		// Ensure the user has access
+		r.Use(auth.AdminAuthenticated)
+
+		r.Get("/synonyms", GetSynonyms(database))
+		r.Post("/synonyms", CreateSynonym(database, indexer))
+		r.Delete("/synonyms/{id}", DeleteSynonym(database, indexer))
+	})
+	return router
+}
+
+// GetSynonyms gets all groups of equivalent search terms
+func GetSynonyms(synonymProvider db.SynonymProvider) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		synonyms, err := synonymProvider.GetAllSynonyms(r.Context())
+		if err != nil {
+			util.Error(r, w, err)
+			return
+		}
+
+		// Return the list in a JSON object
+		jsonResponse, err := json.Marshal(map[string]interface{}{
+			"synonyms": synonyms,
+		})
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		w.Write(jsonResponse)
+	}
+}
+
+// CreateSynonym adds a new group of equivalent search terms
+// and rebuilds the search index with it
+func CreateSynonym(synonymProvider db.SynonymProvider, indexer *search.Indexer) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var synonymCreate types.SynonymCreate
+		err := json.NewDecoder(r.Body).Decode(&synonymCreate)
+		if err != nil {
+			util.Error(r, w, err)
+			return
+		}
+
+		terms := []string{}
+		for _, term := range synonymCreate.Terms {
+			if term = strings.TrimSpace(term); term != "" {
+				terms = append(terms, term)
+			}
+		}
+		if len(terms) < 2 {
+			util.ErrorWithCode(r, w, errors.New("synonym Terms must contain at least two non-empty terms"),
+				http.StatusBadRequest)
+			return
+		}
+
+		synonym := types.Synonym{
+			Terms:     terms,
+			CreatedAt: time.Now(),
+		}
+
+		// Generate globally unique IDs for the synonym
+		for {
+			rand, err := ksuid.NewRandom()
+			if err != nil {
+				util.Error(r, w, err)
+				return
+			}
+
+			synonym.ID = rand.String()
+
+			err = synonymProvider.CreateSynonym(r.Context(), synonym)
+			if err != nil {
+				// If the error was a duplicate ID; try again
+				if _, ok := err.(*db.DuplicateIDError); ok {
+					continue
+				} else {
+					util.Error(r, w, err)
+					return
+				}
+			} else {
+				indexer.Refresh()
+
+				// Return the single synonym as the top-level JSON
+				jsonResponse, err := json.Marshal(synonym)
+				if err != nil {
+					util.ErrorWithCode(r, w, err, http.StatusInternalServerError)
+					return
+				}
+
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusCreated)
+				w.Write(jsonResponse)
+				return
+			}
+		}
+	}
+}
+
+// DeleteSynonym removes a group of equivalent search terms
+// and rebuilds the search index without it
+func DeleteSynonym(synonymProvider db.SynonymProvider, indexer *search.Indexer) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := chi.URLParam(r, "id")
+		if id == "" {
+			util.ErrorWithCode(r, w, errors.New("the URL parameter is empty"),
+				http.StatusBadRequest)
+			return
+		}
+
+		err := synonymProvider.DeleteSynonym(r.Context(), id)
+		if err != nil {
+			util.Error(r, w, err)
+			return
+		}
+
+		indexer.Refresh()
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
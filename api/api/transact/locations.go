@@ -0,0 +1,228 @@
+package transact
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/segmentio/ksuid"
+
+	"github.com/jd-116/klemis-kitchen-api/db"
+	"github.com/jd-116/klemis-kitchen-api/products"
+	"github.com/jd-116/klemis-kitchen-api/products/transact"
+	"github.com/jd-116/klemis-kitchen-api/types"
+	"github.com/jd-116/klemis-kitchen-api/util"
+)
+
+// GetLocations lists every profit center in the last Transact report
+// along with the locations mapped to it,
+// and flags Transact-backed locations whose identifier isn't in the report
+// (such as when it has a typo)
+func GetLocations(transactProvider *transact.Provider, locationProvider db.LocationProvider) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		loadedAt, ok := transactProvider.LoadedAt()
+		if !ok {
+			util.Error(r, w, products.NewCacheNotInitializedError("list profit centers from the Transact API"))
+			return
+		}
+
+		identifiers, err := transactProvider.GetAllLocations()
+		if err != nil {
+			util.Error(r, w, err)
+			return
+		}
+
+		dbLocations, err := locationProvider.GetAllLocations(r.Context())
+		if err != nil {
+			util.Error(r, w, err)
+			return
+		}
+
+		// Create identifier -> location IDs map for the Transact-backed locations
+		mappedLocations := make(map[string][]string)
+		for _, dbLocation := range dbLocations {
+			if !dbLocation.IsNative() {
+				mappedLocations[dbLocation.TransactIdentifier] = append(
+					mappedLocations[dbLocation.TransactIdentifier], dbLocation.ID)
+			}
+		}
+
+		profitCenters := []types.TransactProfitCenter{}
+		for _, identifier := range identifiers {
+			partialProducts, err := transactProvider.GetAllProducts(identifier)
+			if err != nil {
+				util.Error(r, w, err)
+				return
+			}
+
+			profitCenter := types.TransactProfitCenter{
+				Identifier:  identifier,
+				ItemCount:   len(partialProducts),
+				LocationIDs: []string{},
+			}
+			for _, partialProduct := range partialProducts {
+				if partialProduct.Amount > 0 {
+					profitCenter.InStockCount++
+				}
+			}
+			if locationIDs, ok := mappedLocations[identifier]; ok {
+				profitCenter.Mapped = true
+				profitCenter.LocationIDs = locationIDs
+			}
+
+			profitCenters = append(profitCenters, profitCenter)
+		}
+
+		// List the unmapped profit centers first
+		// since they're the ones that need attention
+		sort.Slice(profitCenters, func(i, j int) bool {
+			if profitCenters[i].Mapped != profitCenters[j].Mapped {
+				return !profitCenters[i].Mapped
+			}
+			return profitCenters[i].Identifier < profitCenters[j].Identifier
+		})
+
+		// Flag every Transact-backed location that has no data,
+		// suggesting a profit center whose identifier only differs
+		// in case or whitespace
+		identifierSet := make(map[string]struct{})
+		normalizedIdentifiers := make(map[string]string)
+		for _, identifier := range identifiers {
+			identifierSet[identifier] = struct{}{}
+			normalizedIdentifiers[normalizeIdentifier(identifier)] = identifier
+		}
+
+		unmatched := []types.TransactUnmatchedLocation{}
+		for _, dbLocation := range dbLocations {
+			if dbLocation.IsNative() {
+				continue
+			}
+
+			if _, ok := identifierSet[dbLocation.TransactIdentifier]; ok {
+				continue
+			}
+
+			unmatchedLocation := types.TransactUnmatchedLocation{
+				ID:                 dbLocation.ID,
+				Name:               dbLocation.Name,
+				TransactIdentifier: dbLocation.TransactIdentifier,
+			}
+			if suggestion, ok := normalizedIdentifiers[normalizeIdentifier(dbLocation.TransactIdentifier)]; ok {
+				unmatchedLocation.Suggestion = &suggestion
+			}
+
+			unmatched = append(unmatched, unmatchedLocation)
+		}
+
+		// Return the overview as the top-level JSON
+		jsonResponse, err := json.Marshal(types.TransactLocations{
+			LoadedAt:      loadedAt,
+			ProfitCenters: profitCenters,
+			Unmatched:     unmatched,
+		})
+		if err != nil {
+			util.ErrorWithCode(r, w, err, http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		w.Write(jsonResponse)
+	}
+}
+
+// CreateLocation creates a new Transact-backed location in the database
+// from a profit center in the last Transact report that isn't mapped yet
+func CreateLocation(transactProvider *transact.Provider, locationProvider db.LocationProvider) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var transactLocationCreate types.TransactLocationCreate
+		err := json.NewDecoder(r.Body).Decode(&transactLocationCreate)
+		if err != nil {
+			util.Error(r, w, err)
+			return
+		}
+
+		if transactLocationCreate.ProfitCenter == "" {
+			util.ErrorWithCode(r, w, errors.New("transactLocation ProfitCenter cannot be empty"),
+				http.StatusBadRequest)
+			return
+		}
+
+		// Make sure the profit center has data
+		// so that the new location isn't empty
+		_, err = transactProvider.GetAllProducts(transactLocationCreate.ProfitCenter)
+		if err != nil {
+			util.Error(r, w, err)
+			return
+		}
+
+		dbLocations, err := locationProvider.GetAllLocations(r.Context())
+		if err != nil {
+			util.Error(r, w, err)
+			return
+		}
+
+		for _, dbLocation := range dbLocations {
+			if !dbLocation.IsNative() && dbLocation.TransactIdentifier == transactLocationCreate.ProfitCenter {
+				util.ErrorWithCode(r, w, fmt.Errorf("profit center '%s' is already mapped to location '%s'",
+					transactLocationCreate.ProfitCenter, dbLocation.ID), http.StatusConflict)
+				return
+			}
+		}
+
+		name := strings.TrimSpace(transactLocationCreate.Name)
+		if name == "" {
+			name = transactLocationCreate.ProfitCenter
+		}
+
+		location := types.Location{
+			Name:               name,
+			Location:           transactLocationCreate.Location,
+			TransactIdentifier: transactLocationCreate.ProfitCenter,
+			InventorySource:    types.InventorySourceTransact,
+		}
+
+		// Generate globally unique IDs for the location
+		for {
+			rand, err := ksuid.NewRandom()
+			if err != nil {
+				util.Error(r, w, err)
+				return
+			}
+
+			location.ID = rand.String()
+
+			err = locationProvider.CreateLocation(r.Context(), location)
+			if err != nil {
+				// If the error was a duplicate ID; try again
+				if _, ok := err.(*db.DuplicateIDError); ok {
+					continue
+				} else {
+					util.Error(r, w, err)
+					return
+				}
+			} else {
+				// Return the single location as the top-level JSON
+				jsonResponse, err := json.Marshal(location)
+				if err != nil {
+					util.ErrorWithCode(r, w, err, http.StatusInternalServerError)
+					return
+				}
+
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusCreated)
+				w.Write(jsonResponse)
+				return
+			}
+		}
+	}
+}
+
+// Normalizes a profit center identifier for finding near-misses,
+// ignoring case and runs of whitespace
+func normalizeIdentifier(identifier string) string {
+	return strings.ToLower(strings.Join(strings.Fields(identifier), " "))
+}
@@ -0,0 +1,53 @@
+package transact
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/go-chi/chi"
+
+	"github.com/jd-116/klemis-kitchen-api/auth"
+	"github.com/jd-116/klemis-kitchen-api/db"
+	"github.com/jd-116/klemis-kitchen-api/products/transact"
+	"github.com/jd-116/klemis-kitchen-api/util"
+)
+
+// Routes creates a new Chi router with all of the admin routes
+// for inspecting the Transact integration, at the root level
+func Routes(transactProvider *transact.Provider, database db.Provider) *chi.Mux {
+	router := chi.NewRouter()
+
+	// Admin-only routes
+	router.Group(func(r chi.Router) {
+		// Ensure the user has access
+		r.Use(auth.AdminAuthenticated)
+
+		r.Get("/last-report", GetLastReport(transactProvider))
+		r.Get("/locations", GetLocations(transactProvider, database))
+		r.Post("/locations", CreateLocation(transactProvider, database))
+	})
+	return router
+}
+
+// GetLastReport gets the parse report of the most recently fetched CSV report,
+// including the reasons that any rows were rejected
+func GetLastReport(transactProvider *transact.Provider) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		report, err := transactProvider.LastReport()
+		if err != nil {
+			util.Error(r, w, err)
+			return
+		}
+
+		// Return the report as the top-level JSON
+		jsonResponse, err := json.Marshal(report)
+		if err != nil {
+			util.ErrorWithCode(r, w, err, http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		w.Write(jsonResponse)
+	}
+}
@@ -0,0 +1,169 @@
+package translations
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+
+	"github.com/go-chi/chi"
+
+	"github.com/jd-116/klemis-kitchen-api/auth"
+	"github.com/jd-116/klemis-kitchen-api/db"
+	"github.com/jd-116/klemis-kitchen-api/locale"
+	"github.com/jd-116/klemis-kitchen-api/products"
+	"github.com/jd-116/klemis-kitchen-api/types"
+	"github.com/jd-116/klemis-kitchen-api/util"
+)
+
+// Database is the subset of the database provider
+// that the translation routes need
+type Database interface {
+	db.AnnouncementProvider
+	db.ProductMetadataProvider
+	products.AliasSource
+}
+
+// Routes creates a new Chi router with all of the admin routes
+// for translated content, at the root level
+func Routes(database Database, cacheProducts products.Provider, locales *locale.Negotiator) *chi.Mux {
+	router := chi.NewRouter()
+
+	// Admin-only routes
+	router.Group(func(r chi.Router) {
+		// Ensure the user has access
+		r.Use(auth.AdminAuthenticated)
+
+		r.Get("/missing", GetMissing(database, cacheProducts, locales))
+	})
+	return router
+}
+
+// GetMissing lists every announcement and product (currently in the inventory)
+// that hasn't been translated into each of the supported locales.
+// Descriptions and nutritional facts only need to be translated if the product has them
+func GetMissing(database Database, cacheProducts products.Provider, locales *locale.Negotiator) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		announcements, err := database.GetAllAnnouncements(r.Context())
+		if err != nil {
+			util.Error(r, w, err)
+			return
+		}
+
+		missingAnnouncements := []types.MissingTranslations{}
+		for _, announcement := range announcements {
+			missing := make(map[string][]string)
+			for _, tag := range locales.Translations() {
+				translation := announcement.Translations[tag]
+				if translation.Title == "" && announcement.Title != "" {
+					missing[tag] = append(missing[tag], "title")
+				}
+				if translation.Body == "" && announcement.Body != "" {
+					missing[tag] = append(missing[tag], "body")
+				}
+			}
+
+			if len(missing) > 0 {
+				missingAnnouncements = append(missingAnnouncements, types.MissingTranslations{
+					ID:      announcement.ID,
+					Name:    announcement.Title,
+					Missing: missing,
+				})
+			}
+		}
+
+		dbLocations, err := database.GetAllLocations(r.Context())
+		if err != nil {
+			util.Error(r, w, err)
+			return
+		}
+
+		aliases, err := products.LoadAliases(r.Context(), database)
+		if err != nil {
+			util.Error(r, w, err)
+			return
+		}
+
+		dbProducts, err := database.GetAllProducts(r.Context())
+		if err != nil {
+			util.Error(r, w, err)
+			return
+		}
+
+		// Create id -> dbProduct map so we can index it quickly
+		dbProductMap := make(map[string]*types.ProductMetadata)
+		for i := range dbProducts {
+			dbProductMap[dbProducts[i].ID] = &dbProducts[i]
+		}
+
+		// Find the name of every product at every location
+		names := make(map[string]string)
+		for _, dbLocation := range dbLocations {
+			partialProducts, err := cacheProducts.GetAllProducts(dbLocation.InventoryIdentifier())
+			if err != nil {
+				switch err.(type) {
+				case *products.LocationNotFoundError:
+					continue
+				default:
+					util.Error(r, w, err)
+					return
+				}
+			}
+
+			for _, partialProduct := range aliases.Merge(dbLocation.InventoryIdentifier(), partialProducts) {
+				if _, ok := names[partialProduct.ID]; !ok {
+					names[partialProduct.ID] = partialProduct.Name
+				}
+			}
+		}
+
+		missingProducts := []types.MissingTranslations{}
+		for id, name := range names {
+			metadata := dbProductMap[id]
+			missing := make(map[string][]string)
+			for _, tag := range locales.Translations() {
+				var translation types.ProductTranslation
+				if metadata != nil {
+					translation = metadata.Translations[tag]
+				}
+
+				if translation.Name == nil || *translation.Name == "" {
+					missing[tag] = append(missing[tag], "name")
+				}
+				if metadata != nil && metadata.Description != nil && *metadata.Description != "" &&
+					(translation.Description == nil || *translation.Description == "") {
+					missing[tag] = append(missing[tag], "description")
+				}
+				if metadata != nil && metadata.Nutrition != nil && *metadata.Nutrition != "" &&
+					(translation.Nutrition == nil || *translation.Nutrition == "") {
+					missing[tag] = append(missing[tag], "nutritional_facts")
+				}
+			}
+
+			if len(missing) > 0 {
+				missingProducts = append(missingProducts, types.MissingTranslations{
+					ID:      id,
+					Name:    metadata.LocalizedName(locale.Default, name),
+					Missing: missing,
+				})
+			}
+		}
+		sort.Slice(missingProducts, func(i, j int) bool {
+			return missingProducts[i].ID < missingProducts[j].ID
+		})
+
+		// Return the lists in a JSON object
+		jsonResponse, err := json.Marshal(map[string]interface{}{
+			"locales":       locales.Translations(),
+			"announcements": missingAnnouncements,
+			"products":      missingProducts,
+		})
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		w.Write(jsonResponse)
+	}
+}
@@ -2,18 +2,25 @@ package products
 
 import (
 	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"mime"
 	"mime/multipart"
 	"net/http"
-	"os"
 	"strings"
 
 	"github.com/go-chi/chi"
+	"github.com/segmentio/ksuid"
 
 	"github.com/jd-116/klemis-kitchen-api/auth"
+	"github.com/jd-116/klemis-kitchen-api/db"
+	"github.com/jd-116/klemis-kitchen-api/images"
+	"github.com/jd-116/klemis-kitchen-api/settings"
+	"github.com/jd-116/klemis-kitchen-api/types"
 	"github.com/jd-116/klemis-kitchen-api/upload"
 	"github.com/jd-116/klemis-kitchen-api/util"
 )
@@ -22,29 +29,26 @@ const multipartPartKey string = "file"
 
 // Routes creates a new Chi router with all of the routes for the upload,
 // at the root level
-func Routes(uploadProvider upload.Provider) *chi.Mux {
+func Routes(
+	uploadProvider upload.Provider,
+	processor *images.Processor,
+	database db.UploadProvider,
+	settingsStore *settings.Store,
+) *chi.Mux {
 	router := chi.NewRouter()
 
-	// Load the valid list of mime types from the environment
-	validMimeTypes := make(map[string]struct{})
-	if value, ok := os.LookupEnv("UPLOAD_MIME_TYPES"); ok {
-		value = strings.TrimSpace(value)
-		if value != "" {
-			validMimeTypeSlice := strings.Split(value, "|")
-			for _, m := range validMimeTypeSlice {
-				validMimeTypes[m] = struct{}{}
-			}
-		}
-	}
-
-	// Create the mime type validator
+	// Create the mime type validator,
+	// which reads the valid list of mime types each time since it can be reloaded
 	validMime := func(m string) bool {
+		validMimeTypes := settingsStore.Get().UploadMimeTypes
 		if len(validMimeTypes) == 0 {
 			return true
 		}
 
-		if _, ok := validMimeTypes[m]; ok {
-			return true
+		for _, validMimeType := range validMimeTypes {
+			if m == validMimeType {
+				return true
+			}
 		}
 
 		return false
@@ -54,15 +58,23 @@ func Routes(uploadProvider upload.Provider) *chi.Mux {
 	router.Group(func(r chi.Router) {
 		// Ensure the user has access
 		r.Use(auth.AdminAuthenticated)
-		r.Post("/", Upload(uploadProvider, validMime))
+		r.Post("/", Upload(uploadProvider, processor, database, validMime))
+		r.Post("/presign", Presign(uploadProvider, database, validMime))
+		r.Post("/complete", Complete(uploadProvider, database, validMime))
+		r.Get("/sha256/{hash}", GetByHash(database))
 	})
 	return router
 }
 
 // Upload provides a pass-through route that takes in a multi-part
 // HTTP request and uploads it to S3,
-// returning a URL that can be used to reference the image
-func Upload(uploadProvider upload.Provider, validMime func(string) bool) http.HandlerFunc {
+// returning a URL that can be used to reference the image.
+// Images that can be processed are instead uploaded as several resized JPEG variants,
+// which are returned along with the URL of the original-size variant.
+// Every uploaded file is recorded in the database
+func Upload(uploadProvider upload.Provider, processor *images.Processor, uploadRecorder db.UploadProvider,
+	validMime func(string) bool) http.HandlerFunc {
+
 	// Use a closure to inject the database provider
 	return func(w http.ResponseWriter, r *http.Request) {
 		// Limit the read size to the configured size
@@ -103,11 +115,12 @@ func Upload(uploadProvider upload.Provider, validMime func(string) bool) http.Ha
 		// Only the first 512 bytes are used to sniff the content type,
 		// so create a multi-reader to pass the file on
 		headerBuffer := make([]byte, 512)
-		_, err = uploadFile.Read(headerBuffer)
-		if err != nil {
+		n, err := io.ReadFull(uploadFile, headerBuffer)
+		if err != nil && err != io.ErrUnexpectedEOF {
 			util.Error(r, w, err)
 			return
 		}
+		headerBuffer = headerBuffer[:n]
 		headerReader := bytes.NewReader(headerBuffer)
 		fileReader := io.MultiReader(headerReader, uploadFile)
 
@@ -121,6 +134,11 @@ func Upload(uploadProvider upload.Provider, validMime func(string) bool) http.Ha
 			return
 		}
 
+		if processor.Supports(contentType) {
+			uploadImage(w, r, uploadProvider, processor, uploadRecorder, fileReader)
+			return
+		}
+
 		// Derive the file extension based on the Mime type
 		fileExtensions, err := mime.ExtensionsByType(contentType)
 		if err != nil {
@@ -136,16 +154,222 @@ func Upload(uploadProvider upload.Provider, validMime func(string) bool) http.Ha
 		fileExt := fileExtensions[0]
 
 		// Stream the file into the upload provider
-		fileURL, err := uploadProvider.Upload(r.Context(), fileReader, fileExt, contentType)
+		username, _ := auth.CurrentUser(r.Context())
+		stored, err := upload.Store(r.Context(), uploadProvider, uploadRecorder, fileReader,
+			fileExt, contentType, username, "", "", "")
+		if err != nil {
+			util.Error(r, w, err)
+			return
+		}
+
+		writeUpload(w, []types.Upload{*stored})
+	}
+}
+
+// Processes an uploaded image and uploads each of its variants,
+// writing the URLs of each to the response
+func uploadImage(w http.ResponseWriter, r *http.Request, uploadProvider upload.Provider,
+	processor *images.Processor, uploadRecorder db.UploadProvider, fileReader io.Reader) {
+
+	// The whole image is needed to decode it
+	data, err := processor.Read(fileReader)
+	if err != nil {
+		util.Error(r, w, err)
+		return
+	}
+
+	// Return the existing variants if the same image was already uploaded
+	hash := sha256.Sum256(data)
+	sourceHash := hex.EncodeToString(hash[:])
+	existing, err := upload.FindDuplicate(r.Context(), uploadRecorder, sourceHash)
+	if err != nil {
+		util.Error(r, w, err)
+		return
+	}
+	if len(existing) > 0 {
+		reused, err := upload.Reuse(r.Context(), uploadRecorder, existing)
+		if err != nil {
+			util.Error(r, w, err)
+			return
+		}
+
+		writeUpload(w, reused)
+		return
+	}
+
+	variants, err := processor.Process(data)
+	if err != nil {
+		util.Error(r, w, err)
+		return
+	}
+
+	// Put every variant in the same group
+	// so that they're only cleaned up together
+	group, err := ksuid.NewRandom()
+	if err != nil {
+		util.Error(r, w, err)
+		return
+	}
+
+	username, _ := auth.CurrentUser(r.Context())
+	stored := []types.Upload{}
+	for _, variant := range variants {
+		variantUpload, err := upload.Store(r.Context(), uploadProvider, uploadRecorder, bytes.NewReader(variant.Data),
+			".jpg", "image/jpeg", username, group.String(), variant.Name, sourceHash)
+		if err != nil {
+			util.Error(r, w, err)
+			return
+		}
+		stored = append(stored, *variantUpload)
+	}
+
+	writeUpload(w, stored)
+}
+
+// GetByHash provides a route that looks up an earlier upload
+// by the SHA-256 hash of its content, so that the same file doesn't have to be uploaded again
+func GetByHash(uploadRecorder db.UploadProvider) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		hash := strings.ToLower(chi.URLParam(r, "hash"))
+		if hash == "" {
+			util.ErrorWithCode(r, w, errors.New("the URL parameter is empty"),
+				http.StatusBadRequest)
+			return
+		}
+
+		existing, err := upload.FindDuplicate(r.Context(), uploadRecorder, hash)
+		if err != nil {
+			util.Error(r, w, err)
+			return
+		}
+		if len(existing) == 0 {
+			util.Error(r, w, db.NewNotFoundError(hash))
+			return
+		}
+
+		// The upload is about to be referenced again
+		reused, err := upload.Reuse(r.Context(), uploadRecorder, existing)
+		if err != nil {
+			util.Error(r, w, err)
+			return
+		}
+
+		writeUpload(w, reused)
+	}
+}
+
+// Writes the URL (and ID) of a single uploaded file,
+// or the URLs of each variant of an uploaded image, to the response
+func writeUpload(w http.ResponseWriter, group []types.Upload) {
+	response := map[string]interface{}{
+		"group":  group[0].Group,
+		"sha256": group[0].SHA256,
+	}
+	if len(group) == 1 && group[0].Variant == "" {
+		response["url"] = group[0].URL
+		response["id"] = group[0].ID
+	} else {
+		urls := make(map[string]string)
+		for _, variant := range group {
+			urls[variant.Variant] = variant.URL
+		}
+
+		response["url"] = urls[images.VariantOriginal]
+		response["variants"] = types.ImageVariants{
+			Thumbnail: urls[images.VariantThumbnail],
+			Medium:    urls[images.VariantMedium],
+			Original:  urls[images.VariantOriginal],
+		}
+	}
+
+	// Return the resultant URLs in a JSON object
+	jsonResponse, err := json.Marshal(response)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(jsonResponse)
+}
+
+// Presign provides a route that returns a short-lived signed URL
+// that a file can be PUT to directly, without passing through the API.
+// Once the upload finishes, it has to be registered with the complete route,
+// which only accepts the keys that were recorded here
+func Presign(uploadProvider upload.Provider, uploadRecorder db.UploadProvider,
+	validMime func(string) bool) http.HandlerFunc {
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		presigner, ok := uploadProvider.(upload.Presigner)
+		if !ok {
+			util.ErrorWithCode(r, w, errors.New("the upload backend doesn't support direct uploads"),
+				http.StatusNotImplemented)
+			return
+		}
+
+		var presignRequest types.UploadPresignRequest
+		err := json.NewDecoder(r.Body).Decode(&presignRequest)
+		if err != nil {
+			util.Error(r, w, err)
+			return
+		}
+
+		contentType := strings.TrimSpace(presignRequest.MimeType)
+		if contentType == "" {
+			util.ErrorWithCode(r, w, errors.New("upload MimeType cannot be empty"),
+				http.StatusBadRequest)
+			return
+		}
+		if contentType == "application/octet-stream" || !validMime(contentType) {
+			util.ErrorWithCode(r, w,
+				fmt.Errorf("Unsupported file upload MIME type '%s'", contentType),
+				http.StatusBadRequest)
+			return
+		}
+
+		// Derive the file extension based on the Mime type
+		fileExtensions, err := mime.ExtensionsByType(contentType)
+		if err != nil {
+			util.ErrorWithCode(r, w, err, http.StatusBadRequest)
+			return
+		}
+		if len(fileExtensions) == 0 {
+			util.ErrorWithCode(r, w,
+				fmt.Errorf("Unsupported file upload MIME type '%s'", contentType),
+				http.StatusBadRequest)
+			return
+		}
+
+		presigned, err := presigner.Presign(r.Context(), fileExtensions[0], contentType)
 		if err != nil {
 			util.Error(r, w, err)
 			return
 		}
 
-		// Return the resultant URL in a JSON object
-		jsonResponse, err := json.Marshal(map[string]interface{}{"url": fileURL})
+		username, _ := auth.CurrentUser(r.Context())
+		err = uploadRecorder.CreatePresignedUpload(r.Context(), types.PresignedUpload{
+			Key:         presigned.Name,
+			MimeType:    contentType,
+			PresignedBy: username,
+			ExpiresAt:   presigned.ExpiresAt,
+		})
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			util.Error(r, w, err)
+			return
+		}
+
+		// Return the signed request as the top-level JSON
+		jsonResponse, err := json.Marshal(types.UploadPresign{
+			Key:       presigned.Name,
+			UploadURL: presigned.UploadURL,
+			Headers:   presigned.Headers,
+			ExpiresAt: presigned.ExpiresAt,
+			URL:       presigned.URL,
+		})
+		if err != nil {
+			util.ErrorWithCode(r, w, err, http.StatusInternalServerError)
 			return
 		}
 
@@ -154,3 +378,116 @@ func Upload(uploadProvider upload.Provider, validMime func(string) bool) http.Ha
 		w.Write(jsonResponse)
 	}
 }
+
+// Complete provides a route that registers a file that was uploaded directly to storage,
+// once its size and content type have been verified.
+// Only keys that were given out by the presign route are accepted,
+// and files that fail verification are deleted.
+// The content of these files is never read, so they aren't hashed
+// and are left out of deduplication
+func Complete(uploadProvider upload.Provider, uploadRecorder db.UploadProvider,
+	validMime func(string) bool) http.HandlerFunc {
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		presigner, ok := uploadProvider.(upload.Presigner)
+		if !ok {
+			util.ErrorWithCode(r, w, errors.New("the upload backend doesn't support direct uploads"),
+				http.StatusNotImplemented)
+			return
+		}
+
+		var completeRequest types.UploadCompleteRequest
+		err := json.NewDecoder(r.Body).Decode(&completeRequest)
+		if err != nil {
+			util.Error(r, w, err)
+			return
+		}
+
+		key := strings.TrimSpace(completeRequest.Key)
+		if key == "" {
+			util.ErrorWithCode(r, w, errors.New("upload Key cannot be empty"),
+				http.StatusBadRequest)
+			return
+		}
+
+		// Make sure the file hasn't already been registered
+		_, err = uploadRecorder.GetUploadByName(r.Context(), key)
+		if err == nil {
+			util.ErrorWithCode(r, w, fmt.Errorf("upload '%s' has already been completed", key),
+				http.StatusConflict)
+			return
+		}
+		if _, ok := err.(*db.NotFoundError); !ok {
+			util.Error(r, w, err)
+			return
+		}
+
+		// Make sure the key was given out by the presign route,
+		// so that other objects in storage can't be registered or deleted
+		presigned, err := uploadRecorder.GetPresignedUpload(r.Context(), key)
+		if _, ok := err.(*db.NotFoundError); ok {
+			util.ErrorWithCode(r, w, fmt.Errorf("upload '%s' wasn't presigned", key),
+				http.StatusBadRequest)
+			return
+		}
+		if err != nil {
+			util.Error(r, w, err)
+			return
+		}
+
+		object, err := presigner.Stat(r.Context(), key)
+		if err != nil {
+			util.Error(r, w, err)
+			return
+		}
+
+		// Make sure the file is allowed, deleting it if not.
+		// The content type is sniffed the same way as files uploaded through the API,
+		// and has to match the one that the upload was signed with
+		var rejection error
+		contentType := http.DetectContentType(object.Header)
+		if object.Size > uploadProvider.MaxBytes() {
+			rejection = fmt.Errorf("uploaded file is larger than the max size of %d bytes",
+				uploadProvider.MaxBytes())
+		} else if contentType == "application/octet-stream" || !validMime(contentType) {
+			rejection = fmt.Errorf("Unsupported file upload MIME type '%s'", contentType)
+		} else if contentType != presigned.MimeType {
+			rejection = fmt.Errorf("uploaded file has MIME type '%s' instead of '%s'",
+				contentType, presigned.MimeType)
+		}
+		if rejection != nil {
+			err = uploadProvider.Delete(r.Context(), key)
+			if err != nil {
+				util.Error(r, w, err)
+				return
+			}
+
+			err = uploadRecorder.DeletePresignedUpload(r.Context(), key)
+			if err != nil {
+				util.Error(r, w, err)
+				return
+			}
+
+			util.ErrorWithCode(r, w, rejection, http.StatusBadRequest)
+			return
+		}
+
+		username, _ := auth.CurrentUser(r.Context())
+		stored, err := upload.Record(r.Context(), uploadRecorder, upload.Stored{
+			Name: object.Name,
+			URL:  object.URL,
+		}, object.Size, contentType, username, "", "", "")
+		if err != nil {
+			util.Error(r, w, err)
+			return
+		}
+
+		err = uploadRecorder.DeletePresignedUpload(r.Context(), key)
+		if err != nil {
+			util.Error(r, w, err)
+			return
+		}
+
+		writeUpload(w, []types.Upload{*stored})
+	}
+}
@@ -0,0 +1,137 @@
+package uploads
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/go-chi/chi"
+
+	"github.com/jd-116/klemis-kitchen-api/auth"
+	"github.com/jd-116/klemis-kitchen-api/types"
+	"github.com/jd-116/klemis-kitchen-api/upload"
+	"github.com/jd-116/klemis-kitchen-api/util"
+)
+
+// Routes creates a new Chi router with all of the admin routes
+// for managing uploaded files, at the root level
+func Routes(database upload.CleanerDatabase, uploadProvider upload.Provider) *chi.Mux {
+	router := chi.NewRouter()
+
+	// Admin-only routes
+	router.Group(func(r chi.Router) {
+		// Ensure the user has access
+		r.Use(auth.AdminAuthenticated)
+
+		r.Get("/", GetAll(database))
+		r.Delete("/{id}", Delete(database, uploadProvider))
+	})
+	return router
+}
+
+// GetAll gets all uploads from the database along with what references each,
+// with an optional orphaned querystring param
+// that only includes uploads where nothing in their group is referenced
+func GetAll(database upload.CleanerDatabase) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		onlyOrphaned := r.URL.Query().Get("orphaned") == "true"
+
+		uploads, err := database.GetAllUploads(r.Context())
+		if err != nil {
+			util.Error(r, w, err)
+			return
+		}
+
+		references, err := upload.FindReferences(r.Context(), database, uploads)
+		if err != nil {
+			util.Error(r, w, err)
+			return
+		}
+
+		referencedGroups := make(map[string]struct{})
+		for _, stored := range uploads {
+			if len(references[stored.ID]) > 0 {
+				referencedGroups[stored.Group] = struct{}{}
+			}
+		}
+
+		results := []types.UploadData{}
+		for _, stored := range uploads {
+			if _, ok := referencedGroups[stored.Group]; ok && onlyOrphaned {
+				continue
+			}
+
+			uploadReferences := references[stored.ID]
+			if uploadReferences == nil {
+				uploadReferences = []types.UploadReference{}
+			}
+			results = append(results, types.UploadData{
+				Upload:     stored,
+				References: uploadReferences,
+			})
+		}
+
+		// Return the list in a JSON object
+		jsonResponse, err := json.Marshal(map[string]interface{}{
+			"uploads": results,
+		})
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		w.Write(jsonResponse)
+	}
+}
+
+// Delete deletes an upload's file and its record in the database.
+// Uploads that are still referenced can only be deleted
+// with the force querystring param
+func Delete(database upload.CleanerDatabase, uploadProvider upload.Provider) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := chi.URLParam(r, "id")
+		if id == "" {
+			util.ErrorWithCode(r, w, errors.New("the URL parameter is empty"),
+				http.StatusBadRequest)
+			return
+		}
+
+		stored, err := database.GetUpload(r.Context(), id)
+		if err != nil {
+			util.Error(r, w, err)
+			return
+		}
+
+		if r.URL.Query().Get("force") != "true" {
+			references, err := upload.FindReferences(r.Context(), database, []types.Upload{*stored})
+			if err != nil {
+				util.Error(r, w, err)
+				return
+			}
+
+			if count := len(references[stored.ID]); count > 0 {
+				util.ErrorWithCode(r, w,
+					fmt.Errorf("upload '%s' is still referenced by %d documents; use force=true to delete it anyway", id, count),
+					http.StatusConflict)
+				return
+			}
+		}
+
+		err = uploadProvider.Delete(r.Context(), stored.Name)
+		if err != nil {
+			util.Error(r, w, err)
+			return
+		}
+
+		err = database.DeleteUpload(r.Context(), id)
+		if err != nil {
+			util.Error(r, w, err)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
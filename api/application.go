@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"errors"
 	"flag"
 	stdlog "log"
 	"os"
@@ -9,8 +10,7 @@ import (
 	"syscall"
 	"time"
 
-	"github.com/jd-116/klemis-kitchen-api/env"
-	"github.com/joho/godotenv"
+	"github.com/jd-116/klemis-kitchen-api/config"
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
 )
@@ -19,6 +19,8 @@ import (
 // This function blocks.
 func main() {
 	envPath := flag.String("env", "", "path to .env file")
+	configPath := flag.String("config", "", "path to YAML config file (defaults to CONFIG_FILE)")
+	printConfig := flag.Bool("print-config", false, "print the effective config (with secrets redacted) and exit")
 	logFormat := flag.String("log-format", "console", "log format (one of 'json', 'console')")
 	flag.Parse()
 
@@ -37,20 +39,30 @@ func main() {
 	stdlog.SetFlags(0)
 	stdlog.SetOutput(logger)
 
-	// Load the .env file if it is specified
-	if envPath != nil && *envPath != "" {
-		err := godotenv.Load(*envPath)
-		if err != nil {
-			logger.Fatal().Err(err).Str("env_path", *envPath).Msg("error loading .env file")
-		} else {
-			logger.Info().Str("env_path", *envPath).Msg("loaded environment variables from file")
+	// Load the config file and .env file (if they're specified) along with the environment,
+	// reporting every invalid value at once
+	loader := config.NewLoader(*configPath, *envPath)
+	cfg, err := loader.Load()
+	if *printConfig && cfg != nil {
+		printErr := cfg.Print(os.Stdout)
+		if printErr != nil {
+			logger.Fatal().Err(printErr).Msg("could not print config")
 		}
 	}
-
-	apiPort, err := env.GetIntEnv("server port", "PORT")
-	if err != nil {
-		logger.Fatal().Err(err).Msg("could not load PORT from env")
+	var validationErr *config.ValidationError
+	if errors.As(err, &validationErr) {
+		logger.Fatal().Strs("problems", validationErr.Problems).Msg("invalid configuration")
+	} else if err != nil {
+		logger.Fatal().Err(err).Msg("could not load config")
 	}
+	if *printConfig {
+		return
+	}
+	for _, path := range loader.Paths() {
+		logger.Info().Str("path", path).Msg("loaded config from file")
+	}
+
+	apiPort := cfg.Server.Port
 
 	serverCtx, cancel := context.WithCancel(context.Background())
 
@@ -64,7 +76,7 @@ func main() {
 	}()
 
 	// Initialize the API server object
-	server, err := NewAPIServer(logger)
+	server, err := NewAPIServer(logger, loader, cfg)
 	if err != nil {
 		logger.Fatal().Err(err).Msg("could not initialize API server object")
 	}
@@ -5,7 +5,6 @@ import (
 	"encoding/base64"
 	"errors"
 	"net/http"
-	"os"
 	"time"
 
 	"github.com/dgrijalva/jwt-go"
@@ -13,12 +12,12 @@ import (
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/hlog"
 
-	"github.com/jd-116/klemis-kitchen-api/env"
+	"github.com/jd-116/klemis-kitchen-api/config"
 	"github.com/jd-116/klemis-kitchen-api/types"
 	"github.com/jd-116/klemis-kitchen-api/util"
 )
 
-// JWTManager contains the secret loaded from the environment
+// JWTManager contains the secret from the configuration
 type JWTManager struct {
 	signer     jwt.SigningMethod
 	parser     *jwt.Parser
@@ -79,23 +78,15 @@ func (c *Claims) Valid() error {
 }
 
 // NewJWTManager creates a new JWTManager
-// and loads the secret from the environment
-func NewJWTManager(logger zerolog.Logger) (*JWTManager, error) {
-	jwtSecretStr, err := env.GetEnv("auth JWT secret key", "AUTH_JWT_SECRET")
-	if err != nil {
-		return nil, err
-	}
-
-	bypassAuth := false
-	bypassAuthRaw := os.Getenv("AUTH_BYPASS")
-	if bypassAuthRaw == "1" {
-		bypassAuth = true
+// with the secret from the auth configuration
+func NewJWTManager(cfg config.AuthConfig, logger zerolog.Logger) (*JWTManager, error) {
+	if cfg.Bypass {
 		logger.Warn().Msg("authentication is disabled. do not run this in production!")
 	}
 
 	// Parse the string into bytes
 	encoding := base64.StdEncoding.WithPadding(base64.StdPadding)
-	secretBytes, err := encoding.DecodeString(jwtSecretStr)
+	secretBytes, err := encoding.DecodeString(cfg.JWTSecret)
 	if err != nil {
 		return nil, err
 	}
@@ -104,7 +95,7 @@ func NewJWTManager(logger zerolog.Logger) (*JWTManager, error) {
 		signer:     jwt.GetSigningMethod("HS256"),
 		parser:     &jwt.Parser{},
 		secret:     secretBytes,
-		bypassAuth: bypassAuth,
+		bypassAuth: cfg.Bypass,
 	}, nil
 }
 
@@ -207,6 +198,21 @@ func FromContext(ctx context.Context) (*jwt.Token, *Claims, error) {
 	return token, claims, err
 }
 
+// CurrentUser gets the username and admin access of the authenticated user.
+// If authentication is bypassed, the user is treated as an admin with an empty username
+func CurrentUser(ctx context.Context) (string, bool) {
+	if value, ok := ctx.Value(BypassAuthContextKey).(bool); ok && value {
+		return "", true
+	}
+
+	_, claims, err := FromContext(ctx)
+	if err != nil || claims == nil {
+		return "", false
+	}
+
+	return claims.Username, claims.Permissions.AdminAccess
+}
+
 // authenticator sends an error response if token validation failed
 func authenticator(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
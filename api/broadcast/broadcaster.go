@@ -0,0 +1,328 @@
+package broadcast
+
+import (
+	"context"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"github.com/jd-116/klemis-kitchen-api/config"
+	"github.com/jd-116/klemis-kitchen-api/db"
+	"github.com/jd-116/klemis-kitchen-api/markdown"
+	"github.com/jd-116/klemis-kitchen-api/notify"
+	"github.com/jd-116/klemis-kitchen-api/types"
+)
+
+// Database is the subset of the database provider
+// that the broadcaster needs
+type Database interface {
+	db.AnnouncementProvider
+	db.DeviceProvider
+	db.AnnouncementDeliveryProvider
+}
+
+// Broadcaster pushes published announcements to every registered device
+// (or only the devices subscribed to the announcement's location)
+// in the background, retrying failed sends with exponential backoff
+// and recording the delivery stats of each announcement.
+// Deliveries are stored before they're queued,
+// so any that didn't fit in the queue or were interrupted by a restart
+// are picked up again from the database
+type Broadcaster struct {
+	database Database
+	client   *notify.PushClient
+	jobs     chan types.Announcement
+	stop     chan struct{}
+
+	// Cancels the context of the delivery in progress when disconnecting
+	cancel context.CancelFunc
+
+	// Config values
+	maxAttempts int
+	retryDelay  time.Duration
+
+	logger zerolog.Logger
+}
+
+// NewBroadcaster creates the broadcaster from the push configuration
+// (doesn't start goroutines)
+func NewBroadcaster(database Database, client *notify.PushClient, cfg config.PushConfig,
+	logger zerolog.Logger) *Broadcaster {
+
+	return &Broadcaster{
+		database: database,
+		client:   client,
+		jobs:     make(chan types.Announcement, cfg.QueueSize),
+		stop:     make(chan struct{}),
+
+		maxAttempts: cfg.MaxAttempts,
+		retryDelay:  time.Duration(cfg.RetryDelay),
+
+		logger: logger,
+	}
+}
+
+// Connect queues every delivery that was left unfinished
+// and starts the goroutine that delivers queued announcements
+func (b *Broadcaster) Connect(ctx context.Context) error {
+	b.requeue(ctx)
+
+	deliverCtx, cancel := context.WithCancel(context.Background())
+	b.cancel = cancel
+	go b.deliverAll(deliverCtx)
+	return nil
+}
+
+// Disconnect stops the delivery goroutine;
+// announcements still being delivered are left unfinished
+// and are resumed the next time the broadcaster connects
+func (b *Broadcaster) Disconnect(ctx context.Context) error {
+	b.cancel()
+	b.stop <- struct{}{}
+	return nil
+}
+
+// Publish queues the announcement to be pushed to devices,
+// unless it is a draft or has already been pushed.
+// If the queue is full, the delivery stays queued in the database
+// and is picked up once the queue is empty
+func (b *Broadcaster) Publish(ctx context.Context, announcement types.Announcement) error {
+	if announcement.Draft {
+		return nil
+	}
+
+	now := time.Now()
+	marked, err := b.database.MarkAnnouncementPushed(ctx, announcement.ID, now)
+	if err != nil {
+		return err
+	}
+	if !marked {
+		return nil
+	}
+
+	delivery := types.AnnouncementDelivery{
+		AnnouncementID: announcement.ID,
+		Status:         types.DeliveryQueued,
+		QueuedAt:       now,
+	}
+	err = b.database.SetAnnouncementDelivery(ctx, delivery)
+	if err != nil {
+		return err
+	}
+
+	b.enqueue(announcement)
+	return nil
+}
+
+// Adds the announcement to the queue without blocking,
+// returning false if the queue is full
+func (b *Broadcaster) enqueue(announcement types.Announcement) bool {
+	select {
+	case b.jobs <- announcement:
+		return true
+	default:
+		b.logger.
+			Warn().
+			Str("announcement_id", announcement.ID).
+			Msg("announcement push queue is full; delivering it once the queue is empty")
+		return false
+	}
+}
+
+// Queues the announcement of every unfinished delivery in the database,
+// stopping once the queue is full.
+// An announcement might end up in the queue twice,
+// but it is only delivered once since finished deliveries are skipped
+func (b *Broadcaster) requeue(ctx context.Context) {
+	deliveries, err := b.database.GetUnfinishedAnnouncementDeliveries(ctx)
+	if err != nil {
+		b.logger.
+			Error().
+			Err(err).
+			Msg("could not load unfinished announcement deliveries")
+		return
+	}
+
+	for _, delivery := range deliveries {
+		announcement, err := b.database.GetAnnouncement(ctx, delivery.AnnouncementID)
+		if err != nil {
+			b.logger.
+				Error().
+				Err(err).
+				Str("announcement_id", delivery.AnnouncementID).
+				Msg("could not load announcement of unfinished delivery")
+			continue
+		}
+
+		if !b.enqueue(*announcement) {
+			return
+		}
+	}
+}
+
+func (b *Broadcaster) deliverAll(ctx context.Context) {
+	for {
+		select {
+		case <-b.stop:
+			return
+		case announcement := <-b.jobs:
+			err := b.deliver(ctx, announcement)
+			if err != nil && ctx.Err() == nil {
+				b.logger.
+					Error().
+					Err(err).
+					Str("announcement_id", announcement.ID).
+					Msg("could not push announcement")
+			}
+		case <-time.After(b.retryDelay):
+			// Pick up any deliveries that didn't fit in the queue
+			if len(b.jobs) == 0 {
+				b.requeue(ctx)
+			}
+		}
+	}
+}
+
+// Pushes the announcement to each subscribed device,
+// resending messages that failed in a retryable way
+// until they succeed or run out of attempts.
+// If the context is cancelled, the delivery is left unfinished
+// so that it is resumed later
+func (b *Broadcaster) deliver(ctx context.Context, announcement types.Announcement) error {
+	delivery, err := b.database.GetAnnouncementDelivery(ctx, announcement.ID)
+	if err != nil {
+		return err
+	}
+	if delivery.IsFinished() {
+		return nil
+	}
+
+	// If the devices can't be loaded, the delivery stays unfinished
+	// and is tried again the next time the queue is empty
+	devices, err := b.database.GetSubscribedDevices(ctx, announcement.LocationID)
+	if err != nil {
+		return err
+	}
+
+	// A delivery that was interrupted is sent to every device again,
+	// but keeps its attempts so that it still gives up eventually
+	now := time.Now()
+	delivery.Status = types.DeliverySending
+	delivery.StartedAt = &now
+	delivery.TargetDevices = len(devices)
+	delivery.Sent = 0
+	delivery.Failed = 0
+	delivery.InvalidTokens = 0
+
+	pending := []notify.PushMessage{}
+	for _, device := range devices {
+		pending = append(pending, notify.PushMessage{
+			To:    device.Token,
+			Title: announcement.Title,
+			Body:  markdown.PlainText(announcement.Body),
+			Data: map[string]string{
+				"kind":            "announcement",
+				"announcement_id": announcement.ID,
+			},
+		})
+	}
+
+	err = b.database.SetAnnouncementDelivery(ctx, *delivery)
+	if err != nil {
+		return err
+	}
+
+	for len(pending) > 0 && delivery.Attempts < b.maxAttempts {
+		if delivery.Attempts > 0 {
+			// Wait twice as long after each failed attempt
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(b.retryDelay * time.Duration(1<<uint(delivery.Attempts-1))):
+			}
+		}
+
+		pending, err = b.attempt(ctx, delivery, pending)
+		if err != nil {
+			delivery.LastError = err.Error()
+		}
+
+		err = b.database.SetAnnouncementDelivery(ctx, *delivery)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Give up on whatever is left
+	if len(pending) > 0 {
+		delivery.Failed += len(pending)
+		return b.finish(ctx, delivery, types.DeliveryFailed)
+	}
+
+	return b.finish(ctx, delivery, types.DeliveryCompleted)
+}
+
+// Sends the pending messages once, updating the delivery stats,
+// and returns the messages that should be sent again
+func (b *Broadcaster) attempt(ctx context.Context, delivery *types.AnnouncementDelivery,
+	pending []notify.PushMessage) ([]notify.PushMessage, error) {
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Minute)
+	defer cancel()
+
+	delivery.Attempts++
+	// Messages in batches that weren't accepted get retryable tickets,
+	// so only those batches are sent again
+	tickets, sendErr := b.client.Send(ctx, pending)
+
+	retry := []notify.PushMessage{}
+	unregistered := []string{}
+	for i, ticket := range tickets {
+		switch {
+		case ticket.OK():
+			delivery.Sent++
+		case ticket.Unregistered():
+			delivery.InvalidTokens++
+			unregistered = append(unregistered, pending[i].To)
+		case ticket.Retryable():
+			retry = append(retry, pending[i])
+		default:
+			delivery.Failed++
+			delivery.LastError = ticket.Message
+		}
+	}
+
+	// Forget devices that the push service no longer knows about
+	if len(unregistered) > 0 {
+		err := b.database.DeleteDevicesByToken(ctx, unregistered)
+		if err != nil {
+			b.logger.
+				Error().
+				Err(err).
+				Int("token_count", len(unregistered)).
+				Msg("could not delete unregistered devices")
+		}
+	}
+
+	return retry, sendErr
+}
+
+// Records the final status of a delivery
+func (b *Broadcaster) finish(ctx context.Context, delivery *types.AnnouncementDelivery, status string) error {
+	now := time.Now()
+	delivery.Status = status
+	delivery.FinishedAt = &now
+
+	b.logger.
+		Info().
+		Str("announcement_id", delivery.AnnouncementID).
+		Str("status", delivery.Status).
+		Int("target_devices", delivery.TargetDevices).
+		Int("sent", delivery.Sent).
+		Int("failed", delivery.Failed).
+		Int("invalid_tokens", delivery.InvalidTokens).
+		Int("attempts", delivery.Attempts).
+		Msg("finished pushing announcement")
+
+	return b.database.SetAnnouncementDelivery(ctx, *delivery)
+}
@@ -13,7 +13,8 @@ import (
 
 	"github.com/segmentio/ksuid"
 
-	"github.com/jd-116/klemis-kitchen-api/env"
+	"github.com/jd-116/klemis-kitchen-api/config"
+	"github.com/jd-116/klemis-kitchen-api/tracing"
 )
 
 // Provider bundles together various structs
@@ -25,14 +26,9 @@ type Provider struct {
 }
 
 // NewProvider creates sa new instance of the Provider
-// and loads in options from the environment
-func NewProvider() (*Provider, error) {
-	casUrlStr, err := env.GetEnv("CAS base URL", "CAS_SERVER_URL")
-	if err != nil {
-		return nil, err
-	}
-
-	casUrl, err := url.Parse(casUrlStr)
+// from the CAS configuration
+func NewProvider(cfg config.CASConfig) (*Provider, error) {
+	casUrl, err := url.Parse(cfg.ServerURL)
 	if err != nil {
 		return nil, err
 	}
@@ -82,7 +78,10 @@ func (c *Provider) Redirect(w http.ResponseWriter, r *http.Request) error {
 
 // ServiceValidate constructs and sends the service validate request to the CAS Server,
 // parsing the body if successful
-func (c *Provider) ServiceValidate(r *http.Request, ticket string) (*Identity, error) {
+func (c *Provider) ServiceValidate(r *http.Request, ticket string) (identity *Identity, err error) {
+	ctx, span := tracing.Start(r.Context(), "cas.ServiceValidate")
+	defer func() { tracing.End(span, err) }()
+
 	// Get the original query URL without any queries
 	requestURL, err := requestURL(r)
 	if err != nil {
@@ -122,7 +121,7 @@ func (c *Provider) ServiceValidate(r *http.Request, ticket string) (*Identity, e
 	if err != nil {
 		return nil, err
 	}
-	req = req.WithContext(r.Context())
+	req = req.WithContext(ctx)
 
 	// Make the request to the CAS server
 	res, err := c.httpClient.Do(req)
@@ -166,7 +165,7 @@ func (c *Provider) ServiceValidate(r *http.Request, ticket string) (*Identity, e
 	}
 
 	// Create the identity struct and extract the fields
-	identity := Identity{}
+	identity = &Identity{}
 	assertion := samlData.Assertion
 	identity.Username = strings.TrimSpace(assertion.AttributeStatement.Subject.NameIdentifier)
 	if identity.Username == "" {
@@ -182,7 +181,7 @@ func (c *Provider) ServiceValidate(r *http.Request, ticket string) (*Identity, e
 		}
 	}
 
-	return &identity, nil
+	return identity, nil
 }
 
 // requestURL determines an absolute URL from the http.Request.
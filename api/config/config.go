@@ -0,0 +1,227 @@
+package config
+
+import (
+	"time"
+
+	"github.com/c2h5oh/datasize"
+)
+
+// Config is the complete configuration of the API.
+// Each value can be given in the config file under its yaml key
+// or overridden by its environment variable
+type Config struct {
+	Server        ServerConfig       `yaml:"server"`
+	Auth          AuthConfig         `yaml:"auth"`
+	Mongo         MongoConfig        `yaml:"mongo"`
+	Transact      TransactConfig     `yaml:"transact"`
+	CAS           CASConfig          `yaml:"cas"`
+	Tracing       TracingConfig      `yaml:"tracing"`
+	Reservations  ReservationConfig  `yaml:"reservations"`
+	Notifications NotificationConfig `yaml:"notifications"`
+	Push          PushConfig         `yaml:"push"`
+	Localization  LocalizationConfig `yaml:"localization"`
+	Upload        UploadConfig       `yaml:"upload"`
+}
+
+// ServerConfig configures the HTTP server
+type ServerConfig struct {
+	Port               int    `yaml:"port" env:"PORT"`
+	Domain             string `yaml:"domain" env:"API_SERVER_DOMAIN"`
+	CORSAllowedOrigins string `yaml:"cors_allowed_origins" env:"CORS_ALLOWED_ORIGINS"`
+}
+
+// AuthConfig configures the authentication flow and JWTs
+type AuthConfig struct {
+	SecureContinuation  bool     `yaml:"secure_continuation" env:"AUTH_SECURE_CONTINUATION"`
+	RedirectURIPrefixes []string `yaml:"redirect_uri_prefixes" env:"AUTH_REDIRECT_URI_PREFIXES"`
+	JWTSecret           string   `yaml:"jwt_secret" env:"AUTH_JWT_SECRET" secret:"true"`
+	// The number of hours after which JWTs expire, which never happens if nil
+	JWTTokenExpiresAfter *int `yaml:"jwt_token_expires_after" env:"AUTH_JWT_TOKEN_EXPIRES_AFTER"`
+	Bypass               bool `yaml:"bypass" env:"AUTH_BYPASS"`
+}
+
+// MongoConfig configures the connection to MongoDB Atlas
+type MongoConfig struct {
+	Username     string `yaml:"username" env:"MONGO_DB_USERNAME"`
+	Password     string `yaml:"password" env:"MONGO_DB_PASSWORD" secret:"true"`
+	ClusterName  string `yaml:"cluster_name" env:"MONGO_DB_CLUSTER_NAME"`
+	DatabaseName string `yaml:"database_name" env:"MONGO_DB_DATABASE_NAME"`
+}
+
+// TransactConfig configures the Transact inventory scraper
+type TransactConfig struct {
+	BaseURL                   string   `yaml:"base_url" env:"TRANSACT_BASE_URL"`
+	Tenant                    string   `yaml:"tenant" env:"TRANSACT_TENANT"`
+	Username                  string   `yaml:"username" env:"TRANSACT_USERNAME"`
+	Password                  string   `yaml:"password" env:"TRANSACT_PASSWORD" secret:"true"`
+	FetchPeriod               Duration `yaml:"fetch_period" env:"TRANSACT_FETCH_PERIOD"`
+	ReloadSessionPeriod       Duration `yaml:"reload_session_period" env:"TRANSACT_RELOAD_SESSION_PERIOD"`
+	CSVFavoriteReportName     string   `yaml:"csv_favorite_report_name" env:"TRANSACT_CSV_FAVORITE_REPORT_NAME"`
+	ReportPollPeriod          Duration `yaml:"report_poll_period" env:"TRANSACT_REPORT_POLL_PERIOD"`
+	ReportPollTimeout         Duration `yaml:"report_poll_timeout" env:"TRANSACT_REPORT_POLL_TIMEOUT"`
+	CSVReportIDColumnOffset   *int     `yaml:"csv_report_id_column_offset" env:"TRANSACT_CSV_REPORT_ID_COLUMN_OFFSET"`
+	CSVReportNameColumnOffset *int     `yaml:"csv_report_name_column_offset" env:"TRANSACT_CSV_REPORT_NAME_COLUMN_OFFSET"`
+	CSVReportQtyColumnOffset  *int     `yaml:"csv_report_qty_column_offset" env:"TRANSACT_CSV_REPORT_QTY_COLUMN_OFFSET"`
+	CSVReportMappingPath      string   `yaml:"csv_report_mapping_path" env:"TRANSACT_CSV_REPORT_MAPPING_PATH"`
+	ProfitCenterPrefix        string   `yaml:"profit_center_prefix" env:"TRANSACT_PROFIT_CENTER_PREFIX"`
+	CSVReportType             string   `yaml:"csv_report_type" env:"TRANSACT_CSV_REPORT_TYPE"`
+	// Defaults to 3 times the fetch period if zero
+	MaxCacheAge        Duration `yaml:"max_cache_age" env:"TRANSACT_MAX_CACHE_AGE"`
+	RefreshMinInterval Duration `yaml:"refresh_min_interval" env:"TRANSACT_REFRESH_MIN_INTERVAL"`
+}
+
+// CASConfig configures the single-sign-on server
+type CASConfig struct {
+	ServerURL string `yaml:"server_url" env:"CAS_SERVER_URL"`
+}
+
+// TracingConfig configures where OpenTelemetry spans are sent
+type TracingConfig struct {
+	Exporter     string `yaml:"exporter" env:"TRACING_EXPORTER"`
+	ServiceName  string `yaml:"service_name" env:"TRACING_SERVICE_NAME"`
+	OTLPEndpoint string `yaml:"otlp_endpoint" env:"TRACING_OTLP_ENDPOINT"`
+	OTLPInsecure bool   `yaml:"otlp_insecure" env:"TRACING_OTLP_INSECURE"`
+}
+
+// ReservationConfig configures the reservation manager
+type ReservationConfig struct {
+	HoldDuration      Duration `yaml:"hold_duration" env:"RESERVATION_HOLD_DURATION"`
+	ExpiryCheckPeriod Duration `yaml:"expiry_check_period" env:"RESERVATION_EXPIRY_CHECK_PERIOD"`
+	MaxActivePerUser  int      `yaml:"max_active_per_user" env:"RESERVATION_MAX_ACTIVE_PER_USER"`
+	MaxItems          int      `yaml:"max_items" env:"RESERVATION_MAX_ITEMS"`
+}
+
+// NotificationConfig configures how notifications are delivered
+type NotificationConfig struct {
+	Notifier          string   `yaml:"notifier" env:"NOTIFIER"`
+	WebhookURL        string   `yaml:"webhook_url" env:"NOTIFICATION_WEBHOOK_URL"`
+	WebhookTimeout    Duration `yaml:"webhook_timeout" env:"NOTIFICATION_WEBHOOK_TIMEOUT"`
+	QueueSize         int      `yaml:"queue_size" env:"NOTIFICATION_QUEUE_SIZE"`
+	MaxAttempts       int      `yaml:"max_attempts" env:"NOTIFICATION_MAX_ATTEMPTS"`
+	RetryDelay        Duration `yaml:"retry_delay" env:"NOTIFICATION_RETRY_DELAY"`
+	RestockRateLimit  int      `yaml:"restock_rate_limit" env:"RESTOCK_NOTIFICATION_RATE_LIMIT"`
+	RestockRateWindow Duration `yaml:"restock_rate_window" env:"RESTOCK_NOTIFICATION_RATE_WINDOW"`
+}
+
+// PushConfig configures the push service and the announcement broadcaster
+type PushConfig struct {
+	ServiceURL     string   `yaml:"service_url" env:"PUSH_SERVICE_URL"`
+	AccessToken    string   `yaml:"access_token" env:"PUSH_ACCESS_TOKEN" secret:"true"`
+	ServiceTimeout Duration `yaml:"service_timeout" env:"PUSH_SERVICE_TIMEOUT"`
+	QueueSize      int      `yaml:"queue_size" env:"PUSH_QUEUE_SIZE"`
+	MaxAttempts    int      `yaml:"max_attempts" env:"PUSH_MAX_ATTEMPTS"`
+	RetryDelay     Duration `yaml:"retry_delay" env:"PUSH_RETRY_DELAY"`
+}
+
+// LocalizationConfig configures the locales that content can be translated into
+type LocalizationConfig struct {
+	SupportedLocales []string `yaml:"supported_locales" env:"SUPPORTED_LOCALES"`
+}
+
+// UploadConfig configures where uploaded files are stored
+// and how uploaded images are processed
+type UploadConfig struct {
+	Backend             string            `yaml:"backend" env:"UPLOAD_BACKEND"`
+	MaxSize             datasize.ByteSize `yaml:"max_size" env:"UPLOAD_MAX_SIZE"`
+	MimeTypes           []string          `yaml:"mime_types" env:"UPLOAD_MIME_TYPES"`
+	AWSRegion           string            `yaml:"aws_region" env:"UPLOAD_AWS_REGION"`
+	AWSAccessKeyID      string            `yaml:"aws_access_key_id" env:"UPLOAD_AWS_ACCESS_KEY_ID"`
+	AWSSecretAccessKey  string            `yaml:"aws_secret_access_key" env:"UPLOAD_AWS_SECRET_ACCESS_KEY" secret:"true"`
+	PartSize            datasize.ByteSize `yaml:"part_size" env:"UPLOAD_PART_SIZE"`
+	S3Bucket            string            `yaml:"s3_bucket" env:"UPLOAD_S3_BUCKET"`
+	S3Endpoint          string            `yaml:"s3_endpoint" env:"UPLOAD_S3_ENDPOINT"`
+	S3ForcePathStyle    bool              `yaml:"s3_force_path_style" env:"UPLOAD_S3_FORCE_PATH_STYLE"`
+	S3PublicURL         string            `yaml:"s3_public_url" env:"UPLOAD_S3_PUBLIC_URL"`
+	PresignExpiry       Duration          `yaml:"presign_expiry" env:"UPLOAD_PRESIGN_EXPIRY"`
+	FilesystemDirectory string            `yaml:"filesystem_directory" env:"UPLOAD_FILESYSTEM_DIRECTORY"`
+	FilesystemBaseURL   string            `yaml:"filesystem_base_url" env:"UPLOAD_FILESYSTEM_BASE_URL"`
+	ImageThumbnailSize  int               `yaml:"image_thumbnail_size" env:"UPLOAD_IMAGE_THUMBNAIL_SIZE"`
+	ImageMediumSize     int               `yaml:"image_medium_size" env:"UPLOAD_IMAGE_MEDIUM_SIZE"`
+	ImageJPEGQuality    int               `yaml:"image_jpeg_quality" env:"UPLOAD_IMAGE_JPEG_QUALITY"`
+	ImageMaxPixels      int               `yaml:"image_max_pixels" env:"UPLOAD_IMAGE_MAX_PIXELS"`
+	ImageMaxSize        datasize.ByteSize `yaml:"image_max_size" env:"UPLOAD_IMAGE_MAX_SIZE"`
+	CleanupPeriod       Duration          `yaml:"cleanup_period" env:"UPLOAD_CLEANUP_PERIOD"`
+	OrphanGracePeriod   Duration          `yaml:"orphan_grace_period" env:"UPLOAD_ORPHAN_GRACE_PERIOD"`
+}
+
+// Default creates the configuration that is used for anything
+// not given in the config file or the environment.
+// This is the only place that defaults are set;
+// credentials, external resources, and the details of the Transact report have none,
+// so they always have to be given
+func Default() *Config {
+	return &Config{
+		Server: ServerConfig{
+			CORSAllowedOrigins: "*",
+		},
+		Auth: AuthConfig{
+			RedirectURIPrefixes: []string{},
+		},
+		Transact: TransactConfig{
+			RefreshMinInterval: Duration(time.Minute),
+		},
+		Tracing: TracingConfig{
+			Exporter:    "none",
+			ServiceName: "klemis-kitchen-api",
+		},
+		Reservations: ReservationConfig{
+			HoldDuration:      Duration(24 * time.Hour),
+			ExpiryCheckPeriod: Duration(time.Minute),
+			MaxActivePerUser:  1,
+			MaxItems:          10,
+		},
+		Notifications: NotificationConfig{
+			Notifier:          "log",
+			WebhookTimeout:    Duration(10 * time.Second),
+			QueueSize:         1000,
+			MaxAttempts:       5,
+			RetryDelay:        Duration(30 * time.Second),
+			RestockRateLimit:  3,
+			RestockRateWindow: Duration(24 * time.Hour),
+		},
+		Push: PushConfig{
+			ServiceURL:     "https://exp.host/--/api/v2/push/send",
+			ServiceTimeout: Duration(30 * time.Second),
+			QueueSize:      100,
+			MaxAttempts:    5,
+			RetryDelay:     Duration(30 * time.Second),
+		},
+		Localization: LocalizationConfig{
+			SupportedLocales: []string{"en", "es", "zh"},
+		},
+		Upload: UploadConfig{
+			Backend:             "s3",
+			MimeTypes:           []string{},
+			PresignExpiry:       Duration(15 * time.Minute),
+			FilesystemDirectory: "uploads",
+			FilesystemBaseURL:   "/v1/files",
+			ImageThumbnailSize:  256,
+			ImageMediumSize:     1024,
+			ImageJPEGQuality:    85,
+			ImageMaxPixels:      40000000,
+			ImageMaxSize:        25 * datasize.MB,
+			CleanupPeriod:       Duration(time.Hour),
+			OrphanGracePeriod:   Duration(24 * time.Hour),
+		},
+	}
+}
+
+// Duration is a time.Duration that is written as a string
+// (such as "10m") in the config file
+type Duration time.Duration
+
+// MarshalText implements encoding.TextMarshaler
+func (d Duration) MarshalText() ([]byte, error) {
+	return []byte(time.Duration(d).String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler
+func (d *Duration) UnmarshalText(text []byte) error {
+	parsed, err := time.ParseDuration(string(text))
+	if err != nil {
+		return err
+	}
+
+	*d = Duration(parsed)
+	return nil
+}
@@ -0,0 +1,293 @@
+package config
+
+import (
+	"encoding"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/joho/godotenv"
+	"gopkg.in/yaml.v2"
+)
+
+// Loader loads the configuration from the same sources every time,
+// so that it can be reloaded while the API is running
+type Loader struct {
+	path    string
+	envPath string
+	// The environment that the API was started with,
+	// before any configuration values were exported to it
+	environment map[string]string
+	// The path of the config file the last time it was loaded
+	loadedPath string
+}
+
+// NewLoader creates a new Loader for the config file and .env file at the given paths
+// (either of which can be empty), taking a snapshot of the current environment
+func NewLoader(path string, envPath string) *Loader {
+	environment := make(map[string]string)
+	for _, variable := range os.Environ() {
+		parts := strings.SplitN(variable, "=", 2)
+		environment[parts[0]] = parts[1]
+	}
+
+	return &Loader{
+		path:        path,
+		envPath:     envPath,
+		environment: environment,
+	}
+}
+
+// Paths gets the paths of the files that the configuration was last loaded from
+func (l *Loader) Paths() []string {
+	paths := []string{}
+	for _, path := range []string{l.envPath, l.loadedPath} {
+		if path != "" {
+			paths = append(paths, path)
+		}
+	}
+
+	return paths
+}
+
+// Load loads the configuration, starting from the defaults,
+// then applying the YAML config file (if there is one),
+// and then overriding that with any environment variables that are set and not empty.
+// Variables in the .env file are only used if they aren't in the environment already.
+// If any values are invalid, the loaded configuration is returned
+// along with a *ValidationError that lists every problem
+func (l *Loader) Load() (*Config, error) {
+	environment := make(map[string]string)
+	if l.envPath != "" {
+		values, err := godotenv.Read(l.envPath)
+		if err != nil {
+			return nil, fmt.Errorf("could not read .env file '%s': %w", l.envPath, err)
+		}
+		for variable, value := range values {
+			environment[variable] = value
+		}
+	}
+	for variable, value := range l.environment {
+		environment[variable] = value
+	}
+
+	config := Default()
+	problems := []string{}
+
+	path := l.configPath(environment)
+	l.loadedPath = path
+	if path != "" {
+		contents, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("could not read config file '%s': %w", path, err)
+		}
+
+		err = yaml.UnmarshalStrict(contents, config)
+		if typeErr, ok := err.(*yaml.TypeError); ok {
+			// The rest of the file is still decoded after a type error
+			for _, message := range typeErr.Errors {
+				problems = append(problems, fmt.Sprintf("config file: %s", message))
+			}
+		} else if err != nil {
+			return nil, fmt.Errorf("could not parse config file '%s': %w", path, err)
+		}
+	}
+
+	walk(config, func(f field) {
+		value := strings.TrimSpace(environment[f.variable])
+		if value == "" {
+			return
+		}
+
+		err := f.set(value)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("%s (%s) has an invalid value '%s': %s",
+				f.key, f.variable, value, err))
+		}
+	})
+
+	problems = append(problems, config.validate()...)
+	if len(problems) > 0 {
+		return config, &ValidationError{Problems: problems}
+	}
+
+	return config, nil
+}
+
+// Gets the path of the config file,
+// which is given by CONFIG_FILE if it wasn't given to the loader
+func (l *Loader) configPath(environment map[string]string) string {
+	if l.path != "" {
+		return l.path
+	}
+	return strings.TrimSpace(environment["CONFIG_FILE"])
+}
+
+// Change is a single configuration value that differs between two configurations
+type Change struct {
+	Key      string
+	Variable string
+	Old      string
+	New      string
+}
+
+// Diff finds every configuration value that differs between the old and new configuration.
+// The values of secrets are redacted
+func Diff(old *Config, new *Config) []Change {
+	oldValues := make(map[string]string)
+	walk(old, func(f field) {
+		oldValues[f.key] = f.get()
+	})
+
+	changes := []Change{}
+	walk(new, func(f field) {
+		change := Change{Key: f.key, Variable: f.variable, Old: oldValues[f.key], New: f.get()}
+		if change.Old == change.New {
+			return
+		}
+		if f.secret {
+			change.Old = redacted
+			change.New = redacted
+		}
+		changes = append(changes, change)
+	})
+
+	return changes
+}
+
+// Print writes the configuration to the writer as YAML,
+// with each secret replaced so that it can be shared
+func (c *Config) Print(w io.Writer) error {
+	copied := *c
+	walk(&copied, func(f field) {
+		if f.secret && f.get() != "" {
+			f.value.SetString(redacted)
+		}
+	})
+
+	contents, err := yaml.Marshal(&copied)
+	if err != nil {
+		return err
+	}
+
+	_, err = w.Write(contents)
+	return err
+}
+
+// The value that secrets are replaced with when they're shown
+const redacted = "[redacted]"
+
+// field is a single configuration value
+type field struct {
+	value    reflect.Value
+	key      string
+	variable string
+	secret   bool
+}
+
+// Calls the callback for every configuration value
+// (each field with an env tag) in the struct that the pointer points to
+func walk(pointer interface{}, callback func(field)) {
+	walkStruct(reflect.ValueOf(pointer).Elem(), "", callback)
+}
+
+func walkStruct(value reflect.Value, prefix string, callback func(field)) {
+	for i := 0; i < value.NumField(); i++ {
+		structField := value.Type().Field(i)
+		key := prefix + strings.Split(structField.Tag.Get("yaml"), ",")[0]
+
+		variable, ok := structField.Tag.Lookup("env")
+		if !ok {
+			if structField.Type.Kind() == reflect.Struct {
+				walkStruct(value.Field(i), key+".", callback)
+			}
+			continue
+		}
+
+		callback(field{
+			value:    value.Field(i),
+			key:      key,
+			variable: variable,
+			secret:   structField.Tag.Get("secret") == "true",
+		})
+	}
+}
+
+// Parses the value of an environment variable into the field.
+// Lists are separated by '|' and booleans are true if they are '1'
+func (f field) set(value string) error {
+	if unmarshaler, ok := f.value.Addr().Interface().(encoding.TextUnmarshaler); ok {
+		return unmarshaler.UnmarshalText([]byte(value))
+	}
+
+	switch f.value.Kind() {
+	case reflect.String:
+		f.value.SetString(value)
+	case reflect.Bool:
+		switch value {
+		case "1":
+			f.value.SetBool(true)
+		case "0":
+			f.value.SetBool(false)
+		default:
+			return fmt.Errorf("expected '1' or '0'")
+		}
+	case reflect.Int:
+		parsed, err := strconv.Atoi(value)
+		if err != nil {
+			return err
+		}
+		f.value.SetInt(int64(parsed))
+	case reflect.Ptr:
+		parsed, err := strconv.Atoi(value)
+		if err != nil {
+			return err
+		}
+		f.value.Set(reflect.ValueOf(&parsed))
+	case reflect.Slice:
+		items := []string{}
+		for _, item := range strings.Split(value, "|") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		f.value.Set(reflect.ValueOf(items))
+	default:
+		return fmt.Errorf("unsupported type %s", f.value.Type())
+	}
+
+	return nil
+}
+
+// Formats the field as the value of its environment variable.
+// Zero durations and nil values are left empty so that they count as unset
+func (f field) get() string {
+	switch value := f.value.Interface().(type) {
+	case Duration:
+		if value == 0 {
+			return ""
+		}
+	case *int:
+		if value == nil {
+			return ""
+		}
+		return strconv.Itoa(*value)
+	case bool:
+		if value {
+			return "1"
+		}
+		return "0"
+	case []string:
+		return strings.Join(value, "|")
+	}
+
+	if marshaler, ok := f.value.Interface().(encoding.TextMarshaler); ok {
+		text, _ := marshaler.MarshalText()
+		return string(text)
+	}
+	return fmt.Sprint(f.value.Interface())
+}
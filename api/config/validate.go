@@ -0,0 +1,212 @@
+package config
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/jd-116/klemis-kitchen-api/locale"
+)
+
+// ValidationError is returned when the configuration has problems,
+// listing all of them at once
+type ValidationError struct {
+	Problems []string
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("invalid configuration:\n- %s", strings.Join(e.Problems, "\n- "))
+}
+
+// Collects the problems found while validating the configuration
+type checker struct {
+	problems []string
+	// The environment variable of each configuration value's key
+	variables map[string]string
+}
+
+func newChecker(config *Config) *checker {
+	variables := make(map[string]string)
+	walk(config, func(f field) {
+		variables[f.key] = f.variable
+	})
+
+	return &checker{
+		problems:  []string{},
+		variables: variables,
+	}
+}
+
+// Records a problem with a configuration value if the condition doesn't hold
+func (c *checker) check(ok bool, key string, format string, args ...interface{}) {
+	if !ok {
+		c.problems = append(c.problems,
+			fmt.Sprintf("%s (%s) %s", key, c.variables[key], fmt.Sprintf(format, args...)))
+	}
+}
+
+func (c *checker) required(value string, key string) {
+	c.check(strings.TrimSpace(value) != "", key, "is required")
+}
+
+func (c *checker) positive(value int64, key string) {
+	c.check(value > 0, key, "must be greater than 0")
+}
+
+// Like positive, but reports zero (which is what an unset value is) as missing
+func (c *checker) requiredPositive(value int64, key string) {
+	if value == 0 {
+		c.check(false, key, "is required")
+		return
+	}
+	c.positive(value, key)
+}
+
+func (c *checker) url(value string, key string) {
+	if strings.TrimSpace(value) == "" {
+		c.check(false, key, "is required")
+		return
+	}
+	parsed, err := url.Parse(value)
+	c.check(err == nil && parsed.Scheme != "" && parsed.Host != "", key,
+		"must be an absolute URL, not '%s'", value)
+}
+
+// Checks a column offset, which is required but can be zero
+func (c *checker) offset(value *int, key string) {
+	if value == nil {
+		c.check(false, key, "is required")
+		return
+	}
+	c.check(*value >= 0, key, "can't be negative")
+}
+
+func (c *checker) oneOf(value string, options []string, key string) {
+	for _, option := range options {
+		if value == option {
+			return
+		}
+	}
+	c.check(false, key, "must be one of %s, not '%s'",
+		"'"+strings.Join(options, "', '")+"'", value)
+}
+
+// Checks every value of the configuration,
+// including the ones that are only needed for the selected backends
+func (config *Config) validate() []string {
+	c := newChecker(config)
+
+	server := config.Server
+	c.check(server.Port > 0 && server.Port <= 65535, "server.port",
+		"must be a port number between 1 and 65535")
+
+	auth := config.Auth
+	c.required(auth.JWTSecret, "auth.jwt_secret")
+	if auth.JWTSecret != "" {
+		_, err := base64.StdEncoding.DecodeString(auth.JWTSecret)
+		c.check(err == nil, "auth.jwt_secret", "must be base64-encoded")
+	}
+	if auth.JWTTokenExpiresAfter != nil {
+		c.positive(int64(*auth.JWTTokenExpiresAfter), "auth.jwt_token_expires_after")
+	}
+
+	mongo := config.Mongo
+	c.required(mongo.Username, "mongo.username")
+	c.required(mongo.Password, "mongo.password")
+	c.required(mongo.ClusterName, "mongo.cluster_name")
+	c.required(mongo.DatabaseName, "mongo.database_name")
+
+	transact := config.Transact
+	c.url(transact.BaseURL, "transact.base_url")
+	c.required(transact.Tenant, "transact.tenant")
+	c.required(transact.Username, "transact.username")
+	c.required(transact.Password, "transact.password")
+	c.requiredPositive(int64(transact.FetchPeriod), "transact.fetch_period")
+	c.requiredPositive(int64(transact.ReloadSessionPeriod), "transact.reload_session_period")
+	c.required(transact.CSVFavoriteReportName, "transact.csv_favorite_report_name")
+	c.requiredPositive(int64(transact.ReportPollPeriod), "transact.report_poll_period")
+	c.requiredPositive(int64(transact.ReportPollTimeout), "transact.report_poll_timeout")
+	if transact.CSVReportMappingPath == "" {
+		c.offset(transact.CSVReportIDColumnOffset, "transact.csv_report_id_column_offset")
+		c.offset(transact.CSVReportNameColumnOffset, "transact.csv_report_name_column_offset")
+		c.offset(transact.CSVReportQtyColumnOffset, "transact.csv_report_qty_column_offset")
+	} else {
+		_, err := os.Stat(transact.CSVReportMappingPath)
+		c.check(err == nil, "transact.csv_report_mapping_path",
+			"must be a file that exists, not '%s'", transact.CSVReportMappingPath)
+	}
+	c.required(transact.ProfitCenterPrefix, "transact.profit_center_prefix")
+	c.required(transact.CSVReportType, "transact.csv_report_type")
+	c.check(transact.MaxCacheAge >= 0, "transact.max_cache_age", "can't be negative")
+	c.positive(int64(transact.RefreshMinInterval), "transact.refresh_min_interval")
+
+	c.url(config.CAS.ServerURL, "cas.server_url")
+
+	tracing := config.Tracing
+	c.oneOf(tracing.Exporter, []string{"none", "stdout", "otlp"}, "tracing.exporter")
+	c.required(tracing.ServiceName, "tracing.service_name")
+
+	reservations := config.Reservations
+	c.positive(int64(reservations.HoldDuration), "reservations.hold_duration")
+	c.positive(int64(reservations.ExpiryCheckPeriod), "reservations.expiry_check_period")
+	c.positive(int64(reservations.MaxActivePerUser), "reservations.max_active_per_user")
+	c.positive(int64(reservations.MaxItems), "reservations.max_items")
+
+	notifications := config.Notifications
+	c.oneOf(notifications.Notifier, []string{"log", "webhook", "push"}, "notifications.notifier")
+	if notifications.Notifier == "webhook" {
+		c.url(notifications.WebhookURL, "notifications.webhook_url")
+	}
+	c.positive(int64(notifications.WebhookTimeout), "notifications.webhook_timeout")
+	c.positive(int64(notifications.QueueSize), "notifications.queue_size")
+	c.positive(int64(notifications.MaxAttempts), "notifications.max_attempts")
+	c.positive(int64(notifications.RetryDelay), "notifications.retry_delay")
+	c.positive(int64(notifications.RestockRateLimit), "notifications.restock_rate_limit")
+	c.positive(int64(notifications.RestockRateWindow), "notifications.restock_rate_window")
+
+	push := config.Push
+	c.url(push.ServiceURL, "push.service_url")
+	c.positive(int64(push.ServiceTimeout), "push.service_timeout")
+	c.positive(int64(push.QueueSize), "push.queue_size")
+	c.positive(int64(push.MaxAttempts), "push.max_attempts")
+	c.positive(int64(push.RetryDelay), "push.retry_delay")
+
+	for _, tag := range config.Localization.SupportedLocales {
+		c.check(locale.IsValid(strings.ToLower(tag)), "localization.supported_locales",
+			"contains the invalid locale '%s'", tag)
+	}
+
+	upload := config.Upload
+	c.oneOf(upload.Backend, []string{"s3", "filesystem"}, "upload.backend")
+	c.requiredPositive(int64(upload.MaxSize), "upload.max_size")
+	switch upload.Backend {
+	case "s3":
+		c.required(upload.AWSRegion, "upload.aws_region")
+		c.required(upload.AWSAccessKeyID, "upload.aws_access_key_id")
+		c.required(upload.AWSSecretAccessKey, "upload.aws_secret_access_key")
+		c.requiredPositive(int64(upload.PartSize), "upload.part_size")
+		c.required(upload.S3Bucket, "upload.s3_bucket")
+		if upload.S3Endpoint != "" {
+			c.url(upload.S3Endpoint, "upload.s3_endpoint")
+		}
+		if upload.S3PublicURL != "" {
+			c.url(upload.S3PublicURL, "upload.s3_public_url")
+		}
+		c.positive(int64(upload.PresignExpiry), "upload.presign_expiry")
+	case "filesystem":
+		c.required(upload.FilesystemDirectory, "upload.filesystem_directory")
+		c.required(upload.FilesystemBaseURL, "upload.filesystem_base_url")
+	}
+	c.positive(int64(upload.ImageThumbnailSize), "upload.image_thumbnail_size")
+	c.positive(int64(upload.ImageMediumSize), "upload.image_medium_size")
+	c.check(upload.ImageJPEGQuality >= 1 && upload.ImageJPEGQuality <= 100, "upload.image_jpeg_quality",
+		"must be between 1 and 100")
+	c.positive(int64(upload.ImageMaxPixels), "upload.image_max_pixels")
+	c.positive(int64(upload.ImageMaxSize), "upload.image_max_size")
+	c.positive(int64(upload.CleanupPeriod), "upload.cleanup_period")
+	c.check(upload.OrphanGracePeriod >= 0, "upload.orphan_grace_period", "can't be negative")
+
+	return c.problems
+}
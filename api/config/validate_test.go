@@ -0,0 +1,126 @@
+package config
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/c2h5oh/datasize"
+)
+
+// Creates a configuration that passes validation
+func validConfig() *Config {
+	offset := 0
+	config := Default()
+	config.Server.Port = 8080
+	config.Auth.JWTSecret = "c2VjcmV0"
+	config.Mongo = MongoConfig{Username: "user", Password: "pass", ClusterName: "cluster", DatabaseName: "db"}
+
+	transact := &config.Transact
+	transact.BaseURL = "https://transact.example.com"
+	transact.Tenant = "tenant"
+	transact.Username = "user"
+	transact.Password = "pass"
+	transact.FetchPeriod = Duration(60000000000)
+	transact.ReloadSessionPeriod = Duration(60000000000)
+	transact.CSVFavoriteReportName = "report"
+	transact.ReportPollPeriod = Duration(1000000000)
+	transact.ReportPollTimeout = Duration(60000000000)
+	transact.CSVReportIDColumnOffset = &offset
+	transact.CSVReportNameColumnOffset = &offset
+	transact.CSVReportQtyColumnOffset = &offset
+	transact.ProfitCenterPrefix = "Profit Center -"
+	transact.CSVReportType = "type"
+
+	config.CAS.ServerURL = "https://login.example.com/cas/"
+
+	upload := &config.Upload
+	upload.MaxSize = datasize.GB
+	upload.AWSRegion = "us-east-1"
+	upload.AWSAccessKeyID = "id"
+	upload.AWSSecretAccessKey = "secret"
+	upload.PartSize = 6 * datasize.MB
+	upload.S3Bucket = "bucket"
+	return config
+}
+
+func TestValidate(t *testing.T) {
+	negative := -1
+
+	tests := []struct {
+		name    string
+		modify  func(*Config)
+		problem string
+	}{
+		{"valid", func(c *Config) {}, ""},
+		{"missing port", func(c *Config) { c.Server.Port = 0 }, "server.port (PORT) must be a port number"},
+		{"missing JWT secret", func(c *Config) { c.Auth.JWTSecret = "" }, "auth.jwt_secret (AUTH_JWT_SECRET) is required"},
+		{"JWT secret not base64", func(c *Config) { c.Auth.JWTSecret = "not base64!" }, "auth.jwt_secret (AUTH_JWT_SECRET) must be base64-encoded"},
+		{"missing Transact base URL", func(c *Config) { c.Transact.BaseURL = "" }, "transact.base_url (TRANSACT_BASE_URL) is required"},
+		{"relative Transact base URL", func(c *Config) { c.Transact.BaseURL = "/transact" }, "transact.base_url (TRANSACT_BASE_URL) must be an absolute URL"},
+		{"missing fetch period", func(c *Config) { c.Transact.FetchPeriod = 0 }, "transact.fetch_period (TRANSACT_FETCH_PERIOD) is required"},
+		{"negative fetch period", func(c *Config) { c.Transact.FetchPeriod = -1 }, "transact.fetch_period (TRANSACT_FETCH_PERIOD) must be greater than 0"},
+		{"missing report type", func(c *Config) { c.Transact.CSVReportType = " " }, "transact.csv_report_type (TRANSACT_CSV_REPORT_TYPE) is required"},
+		{"missing column offset", func(c *Config) { c.Transact.CSVReportQtyColumnOffset = nil },
+			"transact.csv_report_qty_column_offset (TRANSACT_CSV_REPORT_QTY_COLUMN_OFFSET) is required"},
+		{"negative column offset", func(c *Config) { c.Transact.CSVReportIDColumnOffset = &negative },
+			"transact.csv_report_id_column_offset (TRANSACT_CSV_REPORT_ID_COLUMN_OFFSET) can't be negative"},
+		{"column offsets with mapping file", func(c *Config) {
+			c.Transact.CSVReportIDColumnOffset = nil
+			c.Transact.CSVReportMappingPath = "validate_test.go"
+		}, ""},
+		{"missing mapping file", func(c *Config) { c.Transact.CSVReportMappingPath = "missing.json" },
+			"transact.csv_report_mapping_path (TRANSACT_CSV_REPORT_MAPPING_PATH) must be a file that exists"},
+		{"missing CAS URL", func(c *Config) { c.CAS.ServerURL = "" }, "cas.server_url (CAS_SERVER_URL) is required"},
+		{"unknown tracing exporter", func(c *Config) { c.Tracing.Exporter = "jaeger" }, "tracing.exporter (TRACING_EXPORTER) must be one of 'none', 'stdout', 'otlp'"},
+		{"webhook without URL", func(c *Config) { c.Notifications.Notifier = "webhook" },
+			"notifications.webhook_url (NOTIFICATION_WEBHOOK_URL) is required"},
+		{"invalid locale", func(c *Config) { c.Localization.SupportedLocales = []string{"en", "es_MX"} },
+			"localization.supported_locales (SUPPORTED_LOCALES) contains the invalid locale 'es_MX'"},
+		{"missing max upload size", func(c *Config) { c.Upload.MaxSize = 0 }, "upload.max_size (UPLOAD_MAX_SIZE) is required"},
+		{"missing part size for s3", func(c *Config) { c.Upload.PartSize = 0 }, "upload.part_size (UPLOAD_PART_SIZE) is required"},
+		{"filesystem without S3 credentials", func(c *Config) {
+			c.Upload.Backend = "filesystem"
+			c.Upload.AWSAccessKeyID = ""
+			c.Upload.PartSize = 0
+		}, ""},
+		{"JPEG quality out of range", func(c *Config) { c.Upload.ImageJPEGQuality = 101 },
+			"upload.image_jpeg_quality (UPLOAD_IMAGE_JPEG_QUALITY) must be between 1 and 100"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			config := validConfig()
+			test.modify(config)
+			problems := config.validate()
+
+			if test.problem == "" {
+				if len(problems) > 0 {
+					t.Errorf("expected no problems, got %v", problems)
+				}
+				return
+			}
+
+			if len(problems) != 1 || !strings.HasPrefix(problems[0], test.problem) {
+				t.Errorf("expected a single problem starting with %q, got %v", test.problem, problems)
+			}
+		})
+	}
+}
+
+func TestDefaultIsMissingRequiredValues(t *testing.T) {
+	problems := Default().validate()
+
+	for _, key := range []string{"transact.base_url", "transact.fetch_period", "transact.csv_report_id_column_offset",
+		"cas.server_url", "upload.max_size", "upload.part_size"} {
+
+		found := false
+		for _, problem := range problems {
+			if strings.HasPrefix(problem, key+" ") {
+				found = true
+			}
+		}
+		if !found {
+			t.Errorf("expected a problem with %s, got %v", key, problems)
+		}
+	}
+}
@@ -2,6 +2,7 @@ package db
 
 import (
 	"context"
+	"time"
 
 	"github.com/jd-116/klemis-kitchen-api/types"
 )
@@ -10,11 +11,24 @@ import (
 type Provider interface {
 	Connect(ctx context.Context) error
 	Disconnect(ctx context.Context) error
+	Ping(ctx context.Context) error
 
 	AnnouncementProvider
 	ProductMetadataProvider
 	LocationProvider
 	MembershipProvider
+	AdjustmentProvider
+	NativeInventoryProvider
+	ReservationProvider
+	VisitProvider
+	FavoriteProvider
+	NotificationPreferencesProvider
+	DeviceProvider
+	AnnouncementDeliveryProvider
+	SynonymProvider
+	CategoryProvider
+	CanonicalProductProvider
+	UploadProvider
 }
 
 // AnnouncementProvider provides CRUD operations for type.Announcement structs
@@ -24,6 +38,7 @@ type AnnouncementProvider interface {
 	CreateAnnouncement(ctx context.Context, announcement types.Announcement) error
 	DeleteAnnouncement(ctx context.Context, id string) error
 	UpdateAnnouncement(ctx context.Context, id string, update map[string]interface{}) (*types.Announcement, error)
+	MarkAnnouncementPushed(ctx context.Context, id string, pushedAt time.Time) (bool, error)
 }
 
 // ProductMetadataProvider provides CRUD operations for type.ProductMetadata structs
@@ -52,3 +67,115 @@ type MembershipProvider interface {
 	DeleteMembership(ctx context.Context, username string) error
 	UpdateMembership(ctx context.Context, username string, update map[string]interface{}) (*types.Membership, error)
 }
+
+// AdjustmentProvider provides create, read, expire, and delete operations for type.Adjustment structs
+type AdjustmentProvider interface {
+	GetAdjustment(ctx context.Context, id string) (*types.Adjustment, error)
+	GetAllAdjustments(ctx context.Context) ([]types.Adjustment, error)
+	GetActiveAdjustments(ctx context.Context, now time.Time) ([]types.Adjustment, error)
+	GetLocationAdjustments(ctx context.Context, locationID string) ([]types.Adjustment, error)
+	CreateAdjustment(ctx context.Context, adjustment types.Adjustment) error
+	ExpireAdjustment(ctx context.Context, id string, expiredAt time.Time) error
+	DeleteAdjustment(ctx context.Context, id string) error
+}
+
+// NativeInventoryProvider provides operations for type.NativeItem structs
+// and the type.InventoryTransaction structs that change them
+type NativeInventoryProvider interface {
+	GetAllNativeItems(ctx context.Context) ([]types.NativeItem, error)
+	CreateNativeItem(ctx context.Context, item types.NativeItem) error
+	ApplyInventoryTransaction(ctx context.Context, transaction types.InventoryTransaction) (*types.InventoryTransaction, error)
+	GetInventoryTransactions(ctx context.Context, locationID string) ([]types.InventoryTransaction, error)
+}
+
+// ReservationProvider provides operations for type.Reservation structs
+type ReservationProvider interface {
+	GetReservation(ctx context.Context, id string) (*types.Reservation, error)
+	GetLocationReservations(ctx context.Context, locationID string, statuses []string) ([]types.Reservation, error)
+	GetUserReservations(ctx context.Context, username string, statuses []string) ([]types.Reservation, error)
+	GetHoldingReservations(ctx context.Context, locationID string, loadedAt time.Time) ([]types.Reservation, error)
+	CreateReservation(ctx context.Context, reservation types.Reservation) error
+	UpdateReservationStatus(ctx context.Context, id string, currentStatus string, status string) (*types.Reservation, error)
+	ExpireReservations(ctx context.Context, now time.Time) (int64, error)
+}
+
+// VisitProvider provides operations for type.Visit structs
+type VisitProvider interface {
+	CreateVisit(ctx context.Context, visit types.Visit) error
+	GetUserVisits(ctx context.Context, username string, since time.Time) ([]types.Visit, error)
+}
+
+// FavoriteProvider provides create, read, and delete operations for type.Favorite structs
+type FavoriteProvider interface {
+	GetUserFavorites(ctx context.Context, username string) ([]types.Favorite, error)
+	GetProductFavorites(ctx context.Context, productIDs []string) ([]types.Favorite, error)
+	CreateFavorite(ctx context.Context, favorite types.Favorite) error
+	DeleteFavorite(ctx context.Context, username string, id string) error
+}
+
+// NotificationPreferencesProvider provides operations for type.NotificationPreferences structs
+type NotificationPreferencesProvider interface {
+	GetNotificationPreferences(ctx context.Context, username string) (*types.NotificationPreferences, error)
+	SetNotificationPreferences(ctx context.Context, preferences types.NotificationPreferences) error
+}
+
+// DeviceProvider provides operations for type.Device structs
+type DeviceProvider interface {
+	GetUserDevices(ctx context.Context, username string) ([]types.Device, error)
+	GetSubscribedDevices(ctx context.Context, locationID string) ([]types.Device, error)
+	RegisterDevice(ctx context.Context, device types.Device) (*types.Device, error)
+	UpdateDevice(ctx context.Context, username string, id string, update map[string]interface{}) (*types.Device, error)
+	DeleteDevice(ctx context.Context, username string, id string) error
+	DeleteDevicesByToken(ctx context.Context, tokens []string) error
+}
+
+// AnnouncementDeliveryProvider provides operations for type.AnnouncementDelivery structs
+type AnnouncementDeliveryProvider interface {
+	GetAnnouncementDelivery(ctx context.Context, announcementID string) (*types.AnnouncementDelivery, error)
+	GetUnfinishedAnnouncementDeliveries(ctx context.Context) ([]types.AnnouncementDelivery, error)
+	SetAnnouncementDelivery(ctx context.Context, delivery types.AnnouncementDelivery) error
+}
+
+// SynonymProvider provides create, read, and delete operations for type.Synonym structs
+type SynonymProvider interface {
+	GetAllSynonyms(ctx context.Context) ([]types.Synonym, error)
+	CreateSynonym(ctx context.Context, synonym types.Synonym) error
+	DeleteSynonym(ctx context.Context, id string) error
+}
+
+// CategoryProvider provides CRUD operations for type.Category structs,
+// as well as assigning products to them
+type CategoryProvider interface {
+	GetCategory(ctx context.Context, id string) (*types.Category, error)
+	GetAllCategories(ctx context.Context) ([]types.Category, error)
+	CreateCategory(ctx context.Context, category types.Category) error
+	DeleteCategory(ctx context.Context, id string) error
+	UpdateCategory(ctx context.Context, id string, update map[string]interface{}) (*types.Category, error)
+	SetProductCategories(ctx context.Context, productIDs []string, categoryID *string) error
+	ClearProductCategories(ctx context.Context, categoryID string) error
+}
+
+// CanonicalProductProvider provides CRUD operations for type.CanonicalProduct structs
+type CanonicalProductProvider interface {
+	GetCanonicalProduct(ctx context.Context, id string) (*types.CanonicalProduct, error)
+	GetAllCanonicalProducts(ctx context.Context) ([]types.CanonicalProduct, error)
+	CreateCanonicalProduct(ctx context.Context, canonicalProduct types.CanonicalProduct) error
+	DeleteCanonicalProduct(ctx context.Context, id string) error
+	ReplaceCanonicalProduct(ctx context.Context, canonicalProduct types.CanonicalProduct) error
+}
+
+// UploadProvider provides create, read, touch, and delete operations for type.Upload structs,
+// along with the type.PresignedUpload structs of direct uploads that haven't been completed yet
+type UploadProvider interface {
+	GetUpload(ctx context.Context, id string) (*types.Upload, error)
+	GetUploadByName(ctx context.Context, name string) (*types.Upload, error)
+	GetUploadsByHash(ctx context.Context, hash string) ([]types.Upload, error)
+	GetAllUploads(ctx context.Context) ([]types.Upload, error)
+	CreateUpload(ctx context.Context, upload types.Upload) error
+	TouchUploads(ctx context.Context, group string, touchedAt time.Time) error
+	DeleteUpload(ctx context.Context, id string) error
+	GetPresignedUpload(ctx context.Context, key string) (*types.PresignedUpload, error)
+	GetExpiredPresignedUploads(ctx context.Context, before time.Time) ([]types.PresignedUpload, error)
+	CreatePresignedUpload(ctx context.Context, presigned types.PresignedUpload) error
+	DeletePresignedUpload(ctx context.Context, key string) error
+}
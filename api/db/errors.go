@@ -38,3 +38,25 @@ func (e *NotFoundError) Error() string {
 	return fmt.Sprintf("object with ID '%s' not found in the database",
 		e.ID)
 }
+
+// InsufficientAmountError is an error used to encode when an operation
+// would take more of a product than is available
+type InsufficientAmountError struct {
+	ID        string
+	Requested int
+	Available int
+}
+
+// NewInsufficientAmountError constructs a new InsufficientAmountError
+func NewInsufficientAmountError(id string, requested int, available int) *InsufficientAmountError {
+	return &InsufficientAmountError{
+		ID:        id,
+		Requested: requested,
+		Available: available,
+	}
+}
+
+func (e *InsufficientAmountError) Error() string {
+	return fmt.Sprintf("cannot take %d of product '%s': only %d available",
+		e.Requested, e.ID, e.Available)
+}
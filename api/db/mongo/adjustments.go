@@ -0,0 +1,152 @@
+package mongo
+
+import (
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/jd-116/klemis-kitchen-api/db"
+	"github.com/jd-116/klemis-kitchen-api/types"
+)
+
+func (p *Provider) adjustments() *mongo.Collection {
+	return p.client.Database(p.databaseName).Collection("adjustments")
+}
+
+// GetAdjustment gets a single inventory adjustment given its ID
+func (p *Provider) GetAdjustment(ctx context.Context, id string) (*types.Adjustment, error) {
+	ctx, end := track(ctx, "GetAdjustment")
+	defer end()
+
+	collection := p.adjustments()
+	result := collection.FindOne(ctx, bson.D{{Key: "id", Value: id}})
+	if result.Err() == mongo.ErrNoDocuments {
+		return nil, db.NewNotFoundError(id)
+	}
+
+	var adjustment types.Adjustment
+	err := result.Decode(&adjustment)
+	if err != nil {
+		return nil, err
+	}
+
+	return &adjustment, nil
+}
+
+// GetAllAdjustments gets a slice of all inventory adjustments in the database
+func (p *Provider) GetAllAdjustments(ctx context.Context) ([]types.Adjustment, error) {
+	ctx, end := track(ctx, "GetAllAdjustments")
+	defer end()
+
+	return p.findAdjustments(ctx, bson.D{})
+}
+
+// GetActiveAdjustments gets a slice of the inventory adjustments
+// that haven't expired by the given time
+func (p *Provider) GetActiveAdjustments(ctx context.Context, now time.Time) ([]types.Adjustment, error) {
+	ctx, end := track(ctx, "GetActiveAdjustments")
+	defer end()
+
+	return p.findAdjustments(ctx, bson.D{
+		{Key: "expired_at", Value: nil},
+		{Key: "$or", Value: bson.A{
+			bson.D{{Key: "expires_at", Value: nil}},
+			bson.D{{Key: "expires_at", Value: bson.D{{Key: "$gt", Value: now}}}},
+		}},
+	})
+}
+
+// GetLocationAdjustments gets a slice of all inventory adjustments at a single location
+func (p *Provider) GetLocationAdjustments(ctx context.Context, locationID string) ([]types.Adjustment, error) {
+	ctx, end := track(ctx, "GetLocationAdjustments")
+	defer end()
+
+	return p.findAdjustments(ctx, bson.D{{Key: "location_id", Value: locationID}})
+}
+
+func (p *Provider) findAdjustments(ctx context.Context, filter bson.D) ([]types.Adjustment, error) {
+	collection := p.adjustments()
+
+	// Sort the adjustments by their creation time (descending)
+	options := options.Find()
+	options.SetSort(bson.D{{Key: "created_at", Value: -1}})
+	cursor, err := collection.Find(ctx, filter, options)
+	if err != nil {
+		return nil, err
+	}
+
+	var adjustments []types.Adjustment
+	err = cursor.All(ctx, &adjustments)
+	if err != nil {
+		return nil, err
+	}
+
+	// Return non-nil slice so JSON serialization is nice
+	if adjustments == nil {
+		return []types.Adjustment{}, nil
+	}
+
+	return adjustments, nil
+}
+
+// CreateAdjustment attempts to insert a new inventory adjustment into the database
+func (p *Provider) CreateAdjustment(ctx context.Context, adjustment types.Adjustment) error {
+	ctx, end := track(ctx, "CreateAdjustment")
+	defer end()
+
+	collection := p.adjustments()
+	_, err := collection.InsertOne(ctx, adjustment)
+	if err != nil {
+		// Handle known cases (such as when the adjustment was duplicate)
+		if writeException, ok := err.(mongo.WriteException); ok && isDuplicate(writeException) {
+			return db.NewDuplicateIDError(adjustment.ID)
+		}
+
+		return err
+	}
+
+	return nil
+}
+
+// ExpireAdjustment marks an existing inventory adjustment as expired at the given time
+func (p *Provider) ExpireAdjustment(ctx context.Context, id string, expiredAt time.Time) error {
+	ctx, end := track(ctx, "ExpireAdjustment")
+	defer end()
+
+	collection := p.adjustments()
+	filter := bson.D{{Key: "id", Value: id}}
+	update := bson.D{{Key: "$set", Value: bson.D{
+		{Key: "expired_at", Value: expiredAt},
+	}}}
+	result, err := collection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return err
+	}
+
+	if result.MatchedCount == 0 {
+		return db.NewNotFoundError(id)
+	}
+
+	return nil
+}
+
+// DeleteAdjustment deletes (reverts) an existing inventory adjustment by its ID
+func (p *Provider) DeleteAdjustment(ctx context.Context, id string) error {
+	ctx, end := track(ctx, "DeleteAdjustment")
+	defer end()
+
+	collection := p.adjustments()
+	result, err := collection.DeleteOne(ctx, bson.D{{Key: "id", Value: id}})
+	if err != nil {
+		return err
+	}
+
+	if result.DeletedCount == 0 {
+		return db.NewNotFoundError(id)
+	}
+
+	return nil
+}
@@ -0,0 +1,126 @@
+package mongo
+
+import (
+	"context"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/jd-116/klemis-kitchen-api/db"
+	"github.com/jd-116/klemis-kitchen-api/types"
+)
+
+func (p *Provider) canonicalProducts() *mongo.Collection {
+	return p.client.Database(p.databaseName).Collection("canonicalProducts")
+}
+
+// GetCanonicalProduct gets a single canonical product given its ID
+func (p *Provider) GetCanonicalProduct(ctx context.Context, id string) (*types.CanonicalProduct, error) {
+	ctx, end := track(ctx, "GetCanonicalProduct")
+	defer end()
+
+	collection := p.canonicalProducts()
+	result := collection.FindOne(ctx, bson.D{{Key: "id", Value: id}})
+	if result.Err() == mongo.ErrNoDocuments {
+		return nil, db.NewNotFoundError(id)
+	}
+
+	var canonicalProduct types.CanonicalProduct
+	err := result.Decode(&canonicalProduct)
+	if err != nil {
+		return nil, err
+	}
+
+	return &canonicalProduct, nil
+}
+
+// GetAllCanonicalProducts gets a slice of all canonical products in the database
+func (p *Provider) GetAllCanonicalProducts(ctx context.Context) ([]types.CanonicalProduct, error) {
+	ctx, end := track(ctx, "GetAllCanonicalProducts")
+	defer end()
+
+	collection := p.canonicalProducts()
+
+	// Sort the canonical products by their name (ascending)
+	options := options.Find()
+	options.SetSort(bson.D{{Key: "name", Value: 1}})
+	cursor, err := collection.Find(ctx, bson.D{}, options)
+	if err != nil {
+		return nil, err
+	}
+
+	var canonicalProducts []types.CanonicalProduct
+	err = cursor.All(ctx, &canonicalProducts)
+	if err != nil {
+		return nil, err
+	}
+
+	// Return non-nil slice so JSON serialization is nice
+	if canonicalProducts == nil {
+		return []types.CanonicalProduct{}, nil
+	}
+
+	return canonicalProducts, nil
+}
+
+// CreateCanonicalProduct attempts to insert a new canonical product into the database
+func (p *Provider) CreateCanonicalProduct(ctx context.Context, canonicalProduct types.CanonicalProduct) error {
+	ctx, end := track(ctx, "CreateCanonicalProduct")
+	defer end()
+
+	collection := p.canonicalProducts()
+	_, err := collection.InsertOne(ctx, canonicalProduct)
+	if err != nil {
+		// Handle known cases (such as when the canonical product was duplicate)
+		if writeException, ok := err.(mongo.WriteException); ok && isDuplicate(writeException) {
+			return db.NewDuplicateIDError(canonicalProduct.ID)
+		}
+
+		return err
+	}
+
+	return nil
+}
+
+// DeleteCanonicalProduct deletes an existing canonical product by its ID
+func (p *Provider) DeleteCanonicalProduct(ctx context.Context, id string) error {
+	ctx, end := track(ctx, "DeleteCanonicalProduct")
+	defer end()
+
+	collection := p.canonicalProducts()
+	result, err := collection.DeleteOne(ctx, bson.D{{Key: "id", Value: id}})
+	if err != nil {
+		return err
+	}
+
+	if result.DeletedCount == 0 {
+		return db.NewNotFoundError(id)
+	}
+
+	return nil
+}
+
+// ReplaceCanonicalProduct replaces the name and aliases of an existing canonical product,
+// keeping its ID and creation time
+func (p *Provider) ReplaceCanonicalProduct(ctx context.Context, canonicalProduct types.CanonicalProduct) error {
+	ctx, end := track(ctx, "ReplaceCanonicalProduct")
+	defer end()
+
+	collection := p.canonicalProducts()
+	filter := bson.D{{Key: "id", Value: canonicalProduct.ID}}
+	update := bson.D{{Key: "$set", Value: bson.D{
+		{Key: "name", Value: canonicalProduct.Name},
+		{Key: "aliases", Value: canonicalProduct.Aliases},
+	}}}
+	result, err := collection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return err
+	}
+
+	if result.MatchedCount == 0 {
+		return db.NewNotFoundError(canonicalProduct.ID)
+	}
+
+	return nil
+}
@@ -0,0 +1,166 @@
+package mongo
+
+import (
+	"context"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/jd-116/klemis-kitchen-api/db"
+	"github.com/jd-116/klemis-kitchen-api/types"
+)
+
+func (p *Provider) categories() *mongo.Collection {
+	return p.client.Database(p.databaseName).Collection("categories")
+}
+
+// GetCategory gets a single category given its ID
+func (p *Provider) GetCategory(ctx context.Context, id string) (*types.Category, error) {
+	ctx, end := track(ctx, "GetCategory")
+	defer end()
+
+	collection := p.categories()
+	result := collection.FindOne(ctx, bson.D{{Key: "id", Value: id}})
+	if result.Err() == mongo.ErrNoDocuments {
+		return nil, db.NewNotFoundError(id)
+	}
+
+	var category types.Category
+	err := result.Decode(&category)
+	if err != nil {
+		return nil, err
+	}
+
+	return &category, nil
+}
+
+// GetAllCategories gets a slice of all categories in the database
+func (p *Provider) GetAllCategories(ctx context.Context) ([]types.Category, error) {
+	ctx, end := track(ctx, "GetAllCategories")
+	defer end()
+
+	collection := p.categories()
+
+	// Sort the categories by their name (ascending)
+	options := options.Find()
+	options.SetSort(bson.D{{Key: "name", Value: 1}})
+	cursor, err := collection.Find(ctx, bson.D{}, options)
+	if err != nil {
+		return nil, err
+	}
+
+	var categories []types.Category
+	err = cursor.All(ctx, &categories)
+	if err != nil {
+		return nil, err
+	}
+
+	// Return non-nil slice so JSON serialization is nice
+	if categories == nil {
+		return []types.Category{}, nil
+	}
+
+	return categories, nil
+}
+
+// CreateCategory attempts to insert a new category into the database
+func (p *Provider) CreateCategory(ctx context.Context, category types.Category) error {
+	ctx, end := track(ctx, "CreateCategory")
+	defer end()
+
+	collection := p.categories()
+	_, err := collection.InsertOne(ctx, category)
+	if err != nil {
+		// Handle known cases (such as when the category was duplicate)
+		if writeException, ok := err.(mongo.WriteException); ok && isDuplicate(writeException) {
+			return db.NewDuplicateIDError(category.ID)
+		}
+
+		return err
+	}
+
+	return nil
+}
+
+// DeleteCategory deletes an existing category by its ID
+func (p *Provider) DeleteCategory(ctx context.Context, id string) error {
+	ctx, end := track(ctx, "DeleteCategory")
+	defer end()
+
+	collection := p.categories()
+	result, err := collection.DeleteOne(ctx, bson.D{{Key: "id", Value: id}})
+	if err != nil {
+		return err
+	}
+
+	if result.DeletedCount == 0 {
+		return db.NewNotFoundError(id)
+	}
+
+	return nil
+}
+
+// UpdateCategory updates an existing category by its ID
+// and a partial document containing new fields that override current ones
+func (p *Provider) UpdateCategory(ctx context.Context, id string, update map[string]interface{}) (*types.Category, error) {
+	ctx, end := track(ctx, "UpdateCategory")
+	defer end()
+
+	// Construct the patch query from the map
+	updateDocument := bson.D{}
+	for key, value := range update {
+		updateDocument = append(updateDocument, bson.E{Key: key, Value: value})
+	}
+
+	collection := p.categories()
+	filter := bson.D{{Key: "id", Value: id}}
+	updateQuery := bson.D{{Key: "$set", Value: updateDocument}}
+	options := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	var updatedCategory types.Category
+	err := collection.FindOneAndUpdate(ctx, filter, updateQuery, options).Decode(&updatedCategory)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, db.NewNotFoundError(id)
+		}
+
+		return nil, err
+	}
+
+	return &updatedCategory, nil
+}
+
+// SetProductCategories puts each product into the category (or no category if nil),
+// creating product metadata for products that don't have any yet
+func (p *Provider) SetProductCategories(ctx context.Context, productIDs []string, categoryID *string) error {
+	ctx, end := track(ctx, "SetProductCategories")
+	defer end()
+
+	if len(productIDs) == 0 {
+		return nil
+	}
+
+	models := []mongo.WriteModel{}
+	for _, productID := range productIDs {
+		models = append(models, mongo.NewUpdateOneModel().
+			SetFilter(bson.D{{Key: "id", Value: productID}}).
+			SetUpdate(bson.D{{Key: "$set", Value: bson.D{{Key: "category_id", Value: categoryID}}}}).
+			SetUpsert(true))
+	}
+
+	collection := p.products()
+	_, err := collection.BulkWrite(ctx, models)
+	return err
+}
+
+// ClearProductCategories removes every product from the category
+func (p *Provider) ClearProductCategories(ctx context.Context, categoryID string) error {
+	ctx, end := track(ctx, "ClearProductCategories")
+	defer end()
+
+	collection := p.products()
+	_, err := collection.UpdateMany(ctx,
+		bson.D{{Key: "category_id", Value: categoryID}},
+		bson.D{{Key: "$set", Value: bson.D{{Key: "category_id", Value: nil}}}})
+	return err
+}
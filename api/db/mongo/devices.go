@@ -0,0 +1,232 @@
+package mongo
+
+import (
+	"context"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/jd-116/klemis-kitchen-api/db"
+	"github.com/jd-116/klemis-kitchen-api/types"
+)
+
+func (p *Provider) devices() *mongo.Collection {
+	return p.client.Database(p.databaseName).Collection("devices")
+}
+
+func (p *Provider) announcementDeliveries() *mongo.Collection {
+	return p.client.Database(p.databaseName).Collection("announcementDeliveries")
+}
+
+// GetUserDevices gets a slice of all devices registered by a user
+func (p *Provider) GetUserDevices(ctx context.Context, username string) ([]types.Device, error) {
+	ctx, end := track(ctx, "GetUserDevices")
+	defer end()
+
+	return p.findDevices(ctx, bson.D{{Key: "username", Value: username}})
+}
+
+// GetSubscribedDevices gets a slice of all devices subscribed to a location,
+// or every registered device if the location ID is empty
+func (p *Provider) GetSubscribedDevices(ctx context.Context, locationID string) ([]types.Device, error) {
+	ctx, end := track(ctx, "GetSubscribedDevices")
+	defer end()
+
+	filter := bson.D{}
+	if locationID != "" {
+		filter = bson.D{{Key: "location_ids", Value: locationID}}
+	}
+
+	return p.findDevices(ctx, filter)
+}
+
+func (p *Provider) findDevices(ctx context.Context, filter bson.D) ([]types.Device, error) {
+	collection := p.devices()
+	cursor, err := collection.Find(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	var devices []types.Device
+	err = cursor.All(ctx, &devices)
+	if err != nil {
+		return nil, err
+	}
+
+	// Return non-nil slice so JSON serialization is nice
+	if devices == nil {
+		return []types.Device{}, nil
+	}
+
+	return devices, nil
+}
+
+// RegisterDevice inserts a new device into the database,
+// or takes over the existing device with the same push token
+// (keeping its ID and creation time), returning the stored device
+func (p *Provider) RegisterDevice(ctx context.Context, device types.Device) (*types.Device, error) {
+	ctx, end := track(ctx, "RegisterDevice")
+	defer end()
+
+	collection := p.devices()
+	filter := bson.D{{Key: "token", Value: device.Token}}
+	updateQuery := bson.D{
+		{Key: "$set", Value: bson.D{
+			{Key: "username", Value: device.Username},
+			{Key: "platform", Value: device.Platform},
+			{Key: "location_ids", Value: device.LocationIDs},
+			{Key: "updated_at", Value: device.UpdatedAt},
+		}},
+		{Key: "$setOnInsert", Value: bson.D{
+			{Key: "id", Value: device.ID},
+			{Key: "created_at", Value: device.CreatedAt},
+		}},
+	}
+	options := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)
+	var registered types.Device
+	err := collection.FindOneAndUpdate(ctx, filter, updateQuery, options).Decode(&registered)
+	if err != nil {
+		// Handle known cases (such as when the device ID was duplicate)
+		if commandError, ok := err.(mongo.CommandError); ok && commandError.Code == 11000 {
+			return nil, db.NewDuplicateIDError(device.ID)
+		}
+		if writeException, ok := err.(mongo.WriteException); ok && isDuplicate(writeException) {
+			return nil, db.NewDuplicateIDError(device.ID)
+		}
+
+		return nil, err
+	}
+
+	return &registered, nil
+}
+
+// UpdateDevice updates one of a user's devices by its ID
+// and a partial document containing new fields that override current ones
+func (p *Provider) UpdateDevice(ctx context.Context, username string, id string,
+	update map[string]interface{}) (*types.Device, error) {
+
+	ctx, end := track(ctx, "UpdateDevice")
+	defer end()
+
+	// Construct the patch query from the map
+	updateDocument := bson.D{}
+	for key, value := range update {
+		updateDocument = append(updateDocument, bson.E{Key: key, Value: value})
+	}
+
+	collection := p.devices()
+	filter := bson.D{
+		{Key: "id", Value: id},
+		{Key: "username", Value: username},
+	}
+	updateQuery := bson.D{{Key: "$set", Value: updateDocument}}
+	options := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	var updatedDevice types.Device
+	err := collection.FindOneAndUpdate(ctx, filter, updateQuery, options).Decode(&updatedDevice)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, db.NewNotFoundError(id)
+		}
+
+		return nil, err
+	}
+
+	return &updatedDevice, nil
+}
+
+// DeleteDevice deletes one of a user's devices by its ID
+func (p *Provider) DeleteDevice(ctx context.Context, username string, id string) error {
+	ctx, end := track(ctx, "DeleteDevice")
+	defer end()
+
+	collection := p.devices()
+	result, err := collection.DeleteOne(ctx, bson.D{
+		{Key: "id", Value: id},
+		{Key: "username", Value: username},
+	})
+	if err != nil {
+		return err
+	}
+
+	if result.DeletedCount == 0 {
+		return db.NewNotFoundError(id)
+	}
+
+	return nil
+}
+
+// DeleteDevicesByToken deletes every device with one of the given push tokens,
+// such as when the push service reports that they are no longer registered
+func (p *Provider) DeleteDevicesByToken(ctx context.Context, tokens []string) error {
+	ctx, end := track(ctx, "DeleteDevicesByToken")
+	defer end()
+
+	collection := p.devices()
+	_, err := collection.DeleteMany(ctx, bson.D{{Key: "token", Value: bson.D{{Key: "$in", Value: tokens}}}})
+	return err
+}
+
+// GetAnnouncementDelivery gets the push notification delivery stats of an announcement
+func (p *Provider) GetAnnouncementDelivery(ctx context.Context, announcementID string) (*types.AnnouncementDelivery, error) {
+	ctx, end := track(ctx, "GetAnnouncementDelivery")
+	defer end()
+
+	collection := p.announcementDeliveries()
+	result := collection.FindOne(ctx, bson.D{{Key: "announcement_id", Value: announcementID}})
+	if result.Err() == mongo.ErrNoDocuments {
+		return nil, db.NewNotFoundError(announcementID)
+	}
+
+	var delivery types.AnnouncementDelivery
+	err := result.Decode(&delivery)
+	if err != nil {
+		return nil, err
+	}
+
+	return &delivery, nil
+}
+
+// GetUnfinishedAnnouncementDeliveries gets a slice of every announcement delivery
+// that is still queued or sending, such as after the API was restarted
+func (p *Provider) GetUnfinishedAnnouncementDeliveries(ctx context.Context) ([]types.AnnouncementDelivery, error) {
+	ctx, end := track(ctx, "GetUnfinishedAnnouncementDeliveries")
+	defer end()
+
+	collection := p.announcementDeliveries()
+
+	// Sort the deliveries by when they were queued (ascending),
+	// so that they're resumed in the same order
+	options := options.Find()
+	options.SetSort(bson.D{{Key: "queued_at", Value: 1}})
+	filter := bson.D{{Key: "status", Value: bson.D{{Key: "$in", Value: types.UnfinishedDeliveryStatuses}}}}
+	cursor, err := collection.Find(ctx, filter, options)
+	if err != nil {
+		return nil, err
+	}
+
+	var deliveries []types.AnnouncementDelivery
+	err = cursor.All(ctx, &deliveries)
+	if err != nil {
+		return nil, err
+	}
+
+	// Return non-nil slice so JSON serialization is nice
+	if deliveries == nil {
+		return []types.AnnouncementDelivery{}, nil
+	}
+
+	return deliveries, nil
+}
+
+// SetAnnouncementDelivery creates or replaces the push notification delivery stats of an announcement
+func (p *Provider) SetAnnouncementDelivery(ctx context.Context, delivery types.AnnouncementDelivery) error {
+	ctx, end := track(ctx, "SetAnnouncementDelivery")
+	defer end()
+
+	collection := p.announcementDeliveries()
+	options := options.Replace().SetUpsert(true)
+	_, err := collection.ReplaceOne(ctx, bson.D{{Key: "announcement_id", Value: delivery.AnnouncementID}},
+		delivery, options)
+	return err
+}
@@ -0,0 +1,134 @@
+package mongo
+
+import (
+	"context"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/jd-116/klemis-kitchen-api/db"
+	"github.com/jd-116/klemis-kitchen-api/types"
+)
+
+func (p *Provider) favorites() *mongo.Collection {
+	return p.client.Database(p.databaseName).Collection("favorites")
+}
+
+func (p *Provider) notificationPreferences() *mongo.Collection {
+	return p.client.Database(p.databaseName).Collection("notificationPreferences")
+}
+
+// GetUserFavorites gets a slice of all of a user's favorites
+func (p *Provider) GetUserFavorites(ctx context.Context, username string) ([]types.Favorite, error) {
+	ctx, end := track(ctx, "GetUserFavorites")
+	defer end()
+
+	return p.findFavorites(ctx, bson.D{{Key: "username", Value: username}})
+}
+
+// GetProductFavorites gets a slice of every user's favorites of any of the given products
+func (p *Provider) GetProductFavorites(ctx context.Context, productIDs []string) ([]types.Favorite, error) {
+	ctx, end := track(ctx, "GetProductFavorites")
+	defer end()
+
+	return p.findFavorites(ctx, bson.D{{Key: "product_id", Value: bson.D{{Key: "$in", Value: productIDs}}}})
+}
+
+func (p *Provider) findFavorites(ctx context.Context, filter bson.D) ([]types.Favorite, error) {
+	collection := p.favorites()
+
+	// Sort the favorites by their creation time (ascending)
+	options := options.Find()
+	options.SetSort(bson.D{{Key: "created_at", Value: 1}})
+	cursor, err := collection.Find(ctx, filter, options)
+	if err != nil {
+		return nil, err
+	}
+
+	var favorites []types.Favorite
+	err = cursor.All(ctx, &favorites)
+	if err != nil {
+		return nil, err
+	}
+
+	// Return non-nil slice so JSON serialization is nice
+	if favorites == nil {
+		return []types.Favorite{}, nil
+	}
+
+	return favorites, nil
+}
+
+// CreateFavorite attempts to insert a new favorite into the database
+func (p *Provider) CreateFavorite(ctx context.Context, favorite types.Favorite) error {
+	ctx, end := track(ctx, "CreateFavorite")
+	defer end()
+
+	collection := p.favorites()
+	_, err := collection.InsertOne(ctx, favorite)
+	if err != nil {
+		// Handle known cases (such as when the favorite was duplicate)
+		if writeException, ok := err.(mongo.WriteException); ok && isDuplicate(writeException) {
+			return db.NewDuplicateIDError(favorite.ID)
+		}
+
+		return err
+	}
+
+	return nil
+}
+
+// DeleteFavorite deletes one of a user's favorites by its ID
+func (p *Provider) DeleteFavorite(ctx context.Context, username string, id string) error {
+	ctx, end := track(ctx, "DeleteFavorite")
+	defer end()
+
+	collection := p.favorites()
+	result, err := collection.DeleteOne(ctx, bson.D{
+		{Key: "id", Value: id},
+		{Key: "username", Value: username},
+	})
+	if err != nil {
+		return err
+	}
+
+	if result.DeletedCount == 0 {
+		return db.NewNotFoundError(id)
+	}
+
+	return nil
+}
+
+// GetNotificationPreferences gets a user's notification preferences,
+// returning the defaults if they have never set any
+func (p *Provider) GetNotificationPreferences(ctx context.Context, username string) (*types.NotificationPreferences, error) {
+	ctx, end := track(ctx, "GetNotificationPreferences")
+	defer end()
+
+	collection := p.notificationPreferences()
+	result := collection.FindOne(ctx, bson.D{{Key: "username", Value: username}})
+	if result.Err() == mongo.ErrNoDocuments {
+		return &types.NotificationPreferences{Username: username}, nil
+	}
+
+	var preferences types.NotificationPreferences
+	err := result.Decode(&preferences)
+	if err != nil {
+		return nil, err
+	}
+
+	return &preferences, nil
+}
+
+// SetNotificationPreferences creates or replaces a user's notification preferences
+func (p *Provider) SetNotificationPreferences(ctx context.Context, preferences types.NotificationPreferences) error {
+	ctx, end := track(ctx, "SetNotificationPreferences")
+	defer end()
+
+	collection := p.notificationPreferences()
+	options := options.Replace().SetUpsert(true)
+	_, err := collection.ReplaceOne(ctx, bson.D{{Key: "username", Value: preferences.Username}},
+		preferences, options)
+	return err
+}
@@ -0,0 +1,191 @@
+package mongo
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/jd-116/klemis-kitchen-api/db"
+	"github.com/jd-116/klemis-kitchen-api/types"
+)
+
+func (p *Provider) nativeItems() *mongo.Collection {
+	return p.client.Database(p.databaseName).Collection("nativeItems")
+}
+
+func (p *Provider) inventoryTransactions() *mongo.Collection {
+	return p.client.Database(p.databaseName).Collection("inventoryTransactions")
+}
+
+// GetAllNativeItems gets a slice of all natively-managed items at every location
+func (p *Provider) GetAllNativeItems(ctx context.Context) ([]types.NativeItem, error) {
+	ctx, end := track(ctx, "GetAllNativeItems")
+	defer end()
+
+	collection := p.nativeItems()
+	cursor, err := collection.Find(ctx, bson.D{})
+	if err != nil {
+		return nil, err
+	}
+
+	var items []types.NativeItem
+	err = cursor.All(ctx, &items)
+	if err != nil {
+		return nil, err
+	}
+
+	// Return non-nil slice so JSON serialization is nice
+	if items == nil {
+		return []types.NativeItem{}, nil
+	}
+
+	return items, nil
+}
+
+// CreateNativeItem attempts to insert a new natively-managed item into the database
+func (p *Provider) CreateNativeItem(ctx context.Context, item types.NativeItem) error {
+	ctx, end := track(ctx, "CreateNativeItem")
+	defer end()
+
+	collection := p.nativeItems()
+	_, err := collection.InsertOne(ctx, item)
+	if err != nil {
+		// Handle known cases (such as when the item already exists at the location)
+		if writeException, ok := err.(mongo.WriteException); ok && isDuplicate(writeException) {
+			return db.NewDuplicateIDError(item.ProductID)
+		}
+
+		return err
+	}
+
+	return nil
+}
+
+// ApplyInventoryTransaction records a new inventory transaction
+// and applies it to the amount of the native item it refers to,
+// returning the transaction with its resulting amount filled in.
+// Stock-out transactions fail if they would make the amount negative
+func (p *Provider) ApplyInventoryTransaction(ctx context.Context,
+	transaction types.InventoryTransaction) (*types.InventoryTransaction, error) {
+
+	ctx, end := track(ctx, "ApplyInventoryTransaction")
+	defer end()
+
+	filter := bson.D{
+		{Key: "location_id", Value: transaction.LocationID},
+		{Key: "product_id", Value: transaction.ProductID},
+	}
+	var update bson.D
+	switch transaction.Kind {
+	case types.TransactionSet:
+		update = bson.D{{Key: "$set", Value: bson.D{
+			{Key: "amount", Value: transaction.Quantity},
+			{Key: "updated_at", Value: time.Now()},
+		}}}
+	case types.TransactionStockIn:
+		update = bson.D{
+			{Key: "$inc", Value: bson.D{{Key: "amount", Value: transaction.Quantity}}},
+			{Key: "$set", Value: bson.D{{Key: "updated_at", Value: time.Now()}}},
+		}
+	case types.TransactionStockOut:
+		filter = append(filter, bson.E{Key: "amount", Value: bson.D{{Key: "$gte", Value: transaction.Quantity}}})
+		update = bson.D{
+			{Key: "$inc", Value: bson.D{{Key: "amount", Value: -transaction.Quantity}}},
+			{Key: "$set", Value: bson.D{{Key: "updated_at", Value: time.Now()}}},
+		}
+	default:
+		return nil, fmt.Errorf("unknown inventory transaction kind '%s'", transaction.Kind)
+	}
+
+	// Insert the transaction first so that a duplicate ID
+	// can be retried without applying the change twice
+	transactions := p.inventoryTransactions()
+	_, err := transactions.InsertOne(ctx, transaction)
+	if err != nil {
+		if writeException, ok := err.(mongo.WriteException); ok && isDuplicate(writeException) {
+			return nil, db.NewDuplicateIDError(transaction.ID)
+		}
+
+		return nil, err
+	}
+
+	options := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	var item types.NativeItem
+	err = p.nativeItems().FindOneAndUpdate(ctx, filter, update, options).Decode(&item)
+	if err != nil {
+		// Remove the record of the transaction, since it was never applied
+		_, deleteErr := transactions.DeleteOne(ctx, bson.D{{Key: "id", Value: transaction.ID}})
+		if deleteErr != nil {
+			return nil, deleteErr
+		}
+
+		if err == mongo.ErrNoDocuments {
+			return nil, p.explainMissingItem(ctx, transaction)
+		}
+
+		return nil, err
+	}
+
+	transaction.ResultingAmount = item.Amount
+	_, err = transactions.UpdateOne(ctx,
+		bson.D{{Key: "id", Value: transaction.ID}},
+		bson.D{{Key: "$set", Value: bson.D{{Key: "resulting_amount", Value: item.Amount}}}})
+	if err != nil {
+		return nil, err
+	}
+
+	return &transaction, nil
+}
+
+// Determines why a transaction's item update matched no documents:
+// either the item doesn't exist, or there wasn't enough of it to stock out
+func (p *Provider) explainMissingItem(ctx context.Context, transaction types.InventoryTransaction) error {
+	result := p.nativeItems().FindOne(ctx, bson.D{
+		{Key: "location_id", Value: transaction.LocationID},
+		{Key: "product_id", Value: transaction.ProductID},
+	})
+	if result.Err() == mongo.ErrNoDocuments {
+		return db.NewNotFoundError(transaction.ProductID)
+	}
+
+	var item types.NativeItem
+	err := result.Decode(&item)
+	if err != nil {
+		return err
+	}
+
+	return db.NewInsufficientAmountError(transaction.ProductID, transaction.Quantity, item.Amount)
+}
+
+// GetInventoryTransactions gets a slice of all inventory transactions at a single location
+func (p *Provider) GetInventoryTransactions(ctx context.Context, locationID string) ([]types.InventoryTransaction, error) {
+	ctx, end := track(ctx, "GetInventoryTransactions")
+	defer end()
+
+	collection := p.inventoryTransactions()
+
+	// Sort the transactions by their creation time (descending)
+	options := options.Find()
+	options.SetSort(bson.D{{Key: "created_at", Value: -1}})
+	cursor, err := collection.Find(ctx, bson.D{{Key: "location_id", Value: locationID}}, options)
+	if err != nil {
+		return nil, err
+	}
+
+	var transactions []types.InventoryTransaction
+	err = cursor.All(ctx, &transactions)
+	if err != nil {
+		return nil, err
+	}
+
+	// Return non-nil slice so JSON serialization is nice
+	if transactions == nil {
+		return []types.InventoryTransaction{}, nil
+	}
+
+	return transactions, nil
+}
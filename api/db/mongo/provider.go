@@ -2,6 +2,7 @@ package mongo
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"time"
 
@@ -10,10 +11,13 @@ import (
 	"go.mongodb.org/mongo-driver/mongo/options"
 	"go.mongodb.org/mongo-driver/mongo/readpref"
 
+	"github.com/jd-116/klemis-kitchen-api/config"
 	"github.com/jd-116/klemis-kitchen-api/db"
-	"github.com/jd-116/klemis-kitchen-api/env"
+	"github.com/jd-116/klemis-kitchen-api/metrics"
+	"github.com/jd-116/klemis-kitchen-api/tracing"
 	"github.com/jd-116/klemis-kitchen-api/types"
 	"github.com/rs/zerolog"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 const (
@@ -29,35 +33,15 @@ type Provider struct {
 	client        *mongo.Client
 }
 
-// NewProvider creates a new provider and loads values in from the environment
-func NewProvider(logger zerolog.Logger) (*Provider, error) {
-	username, err := env.GetEnv("MongoDB username", "MONGO_DB_USERNAME")
-	if err != nil {
-		return nil, err
-	}
-
-	password, err := env.GetEnv("MongoDB password", "MONGO_DB_PASSWORD")
-	if err != nil {
-		return nil, err
-	}
-
-	clusterName, err := env.GetEnv("MongoDB cluster name ", "MONGO_DB_CLUSTER_NAME")
-	if err != nil {
-		return nil, err
-	}
-
-	databaseName, err := env.GetEnv("MongoDB database name ", "MONGO_DB_DATABASE_NAME")
-	if err != nil {
-		return nil, err
-	}
-
+// NewProvider creates a new provider from the MongoDB configuration
+func NewProvider(cfg config.MongoConfig, logger zerolog.Logger) (*Provider, error) {
 	connectionURI := fmt.Sprintf("mongodb+srv://%s:%s@%s.qkdgq.mongodb.net/%s?retryWrites=true&w=majority",
-		username, password, clusterName, databaseName)
+		cfg.Username, cfg.Password, cfg.ClusterName, cfg.DatabaseName)
 	return &Provider{
 		logger:        logger,
 		connectionURI: connectionURI,
-		databaseName:  databaseName,
-		clusterName:   clusterName,
+		databaseName:  cfg.DatabaseName,
+		clusterName:   cfg.ClusterName,
 		client:        nil,
 	}, nil
 }
@@ -97,6 +81,18 @@ func (p *Provider) Disconnect(ctx context.Context) error {
 	return nil
 }
 
+// Ping ensures the primary of the MongoDB server is still reachable
+func (p *Provider) Ping(ctx context.Context) error {
+	ctx, end := track(ctx, "Ping")
+	defer end()
+
+	if p.client == nil {
+		return errors.New("not connected to the database")
+	}
+
+	return p.client.Ping(ctx, readpref.Primary())
+}
+
 // Create anything needed for the database,
 // like indices
 func (p *Provider) initialize(ctx context.Context) error {
@@ -138,6 +134,180 @@ func (p *Provider) initialize(ctx context.Context) error {
 		return err
 	}
 
+	_, err = p.adjustments().Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys:    bson.M{"id": 1},
+			Options: options.Index().SetUnique(true),
+		},
+		{
+			Keys: bson.M{"location_id": 1},
+		},
+		{
+			Keys: bson.M{"expires_at": 1},
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = p.nativeItems().Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "location_id", Value: 1}, {Key: "product_id", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = p.inventoryTransactions().Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys:    bson.M{"id": 1},
+			Options: options.Index().SetUnique(true),
+		},
+		{
+			Keys: bson.M{"location_id": 1},
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = p.reservations().Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys:    bson.M{"id": 1},
+			Options: options.Index().SetUnique(true),
+		},
+		{
+			Keys: bson.D{{Key: "location_id", Value: 1}, {Key: "status", Value: 1}},
+		},
+		{
+			Keys: bson.D{{Key: "username", Value: 1}, {Key: "status", Value: 1}},
+		},
+		{
+			Keys: bson.D{{Key: "status", Value: 1}, {Key: "expires_at", Value: 1}},
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = p.visits().Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys:    bson.M{"id": 1},
+			Options: options.Index().SetUnique(true),
+		},
+		{
+			Keys: bson.D{{Key: "username", Value: 1}, {Key: "created_at", Value: 1}},
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = p.favorites().Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys:    bson.M{"id": 1},
+			Options: options.Index().SetUnique(true),
+		},
+		{
+			Keys: bson.M{"username": 1},
+		},
+		{
+			Keys: bson.M{"product_id": 1},
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = p.notificationPreferences().Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.M{"username": 1},
+		Options: options.Index().SetUnique(true),
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = p.devices().Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys:    bson.M{"id": 1},
+			Options: options.Index().SetUnique(true),
+		},
+		{
+			Keys:    bson.M{"token": 1},
+			Options: options.Index().SetUnique(true),
+		},
+		{
+			Keys: bson.M{"username": 1},
+		},
+		{
+			Keys: bson.M{"location_ids": 1},
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = p.announcementDeliveries().Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.M{"announcement_id": 1},
+		Options: options.Index().SetUnique(true),
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = p.synonyms().Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.M{"id": 1},
+		Options: options.Index().SetUnique(true),
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = p.categories().Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.M{"id": 1},
+		Options: options.Index().SetUnique(true),
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = p.canonicalProducts().Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.M{"id": 1},
+		Options: options.Index().SetUnique(true),
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = p.uploads().Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys:    bson.M{"id": 1},
+			Options: options.Index().SetUnique(true),
+		},
+		{
+			Keys: bson.M{"name": 1},
+		},
+		{
+			Keys: bson.M{"sha256": 1},
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = p.presignedUploads().Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys:    bson.M{"key": 1},
+			Options: options.Index().SetUnique(true),
+		},
+		{
+			Keys: bson.M{"expires_at": 1},
+		},
+	})
+	if err != nil {
+		return err
+	}
+
 	return nil
 }
 
@@ -159,6 +329,9 @@ func (p *Provider) memberships() *mongo.Collection {
 
 // GetAnnouncement gets a single announcement given its ID
 func (p *Provider) GetAnnouncement(ctx context.Context, id string) (*types.Announcement, error) {
+	ctx, end := track(ctx, "GetAnnouncement")
+	defer end()
+
 	collection := p.announcements()
 	result := collection.FindOne(ctx, bson.D{{Key: "id", Value: id}})
 	if result.Err() == mongo.ErrNoDocuments {
@@ -176,6 +349,9 @@ func (p *Provider) GetAnnouncement(ctx context.Context, id string) (*types.Annou
 
 // GetProduct gets a single product metadata object given its ID
 func (p *Provider) GetProduct(ctx context.Context, id string) (*types.ProductMetadata, error) {
+	ctx, end := track(ctx, "GetProduct")
+	defer end()
+
 	collection := p.products()
 	result := collection.FindOne(ctx, bson.D{{Key: "id", Value: id}})
 	if result.Err() == mongo.ErrNoDocuments {
@@ -193,6 +369,9 @@ func (p *Provider) GetProduct(ctx context.Context, id string) (*types.ProductMet
 
 // GetLocation gets a single location given its ID
 func (p *Provider) GetLocation(ctx context.Context, id string) (*types.Location, error) {
+	ctx, end := track(ctx, "GetLocation")
+	defer end()
+
 	collection := p.locations()
 	result := collection.FindOne(ctx, bson.D{{Key: "id", Value: id}})
 	if result.Err() == mongo.ErrNoDocuments {
@@ -210,6 +389,9 @@ func (p *Provider) GetLocation(ctx context.Context, id string) (*types.Location,
 
 // GetMembership gets a single membership given its username
 func (p *Provider) GetMembership(ctx context.Context, username string) (*types.Membership, error) {
+	ctx, end := track(ctx, "GetMembership")
+	defer end()
+
 	collection := p.memberships()
 	result := collection.FindOne(ctx, bson.D{{Key: "username", Value: username}})
 	if result.Err() == mongo.ErrNoDocuments {
@@ -227,6 +409,9 @@ func (p *Provider) GetMembership(ctx context.Context, username string) (*types.M
 
 // GetAllAnnouncements gets a slice of all announcements in the database
 func (p *Provider) GetAllAnnouncements(ctx context.Context) ([]types.Announcement, error) {
+	ctx, end := track(ctx, "GetAllAnnouncements")
+	defer end()
+
 	collection := p.announcements()
 
 	// Sort the announcements by their timestamp (descending)
@@ -253,6 +438,9 @@ func (p *Provider) GetAllAnnouncements(ctx context.Context) ([]types.Announcemen
 
 // GetAllProducts gets a slice of all product metadata objects in the database
 func (p *Provider) GetAllProducts(ctx context.Context) ([]types.ProductMetadata, error) {
+	ctx, end := track(ctx, "GetAllProducts")
+	defer end()
+
 	collection := p.products()
 
 	options := options.Find()
@@ -278,6 +466,9 @@ func (p *Provider) GetAllProducts(ctx context.Context) ([]types.ProductMetadata,
 
 // GetAllLocations gets a slice of all locations in the database
 func (p *Provider) GetAllLocations(ctx context.Context) ([]types.Location, error) {
+	ctx, end := track(ctx, "GetAllLocations")
+	defer end()
+
 	collection := p.locations()
 
 	options := options.Find()
@@ -303,6 +494,9 @@ func (p *Provider) GetAllLocations(ctx context.Context) ([]types.Location, error
 
 // GetAllMemberships gets a slice of all memberships in the database
 func (p *Provider) GetAllMemberships(ctx context.Context) ([]types.Membership, error) {
+	ctx, end := track(ctx, "GetAllMemberships")
+	defer end()
+
 	collection := p.memberships()
 
 	options := options.Find()
@@ -328,6 +522,9 @@ func (p *Provider) GetAllMemberships(ctx context.Context) ([]types.Membership, e
 
 // CreateAnnouncement attempts to insert a new announcement into the database
 func (p *Provider) CreateAnnouncement(ctx context.Context, announcement types.Announcement) error {
+	ctx, end := track(ctx, "CreateAnnouncement")
+	defer end()
+
 	collection := p.announcements()
 	_, err := collection.InsertOne(ctx, announcement)
 	if err != nil {
@@ -344,6 +541,9 @@ func (p *Provider) CreateAnnouncement(ctx context.Context, announcement types.An
 
 // CreateProduct attempts to insert a new product metadata object into the database
 func (p *Provider) CreateProduct(ctx context.Context, product types.ProductMetadata) error {
+	ctx, end := track(ctx, "CreateProduct")
+	defer end()
+
 	collection := p.products()
 	_, err := collection.InsertOne(ctx, product)
 	if err != nil {
@@ -360,6 +560,9 @@ func (p *Provider) CreateProduct(ctx context.Context, product types.ProductMetad
 
 // CreateLocation attempts to insert a new location into the database
 func (p *Provider) CreateLocation(ctx context.Context, location types.Location) error {
+	ctx, end := track(ctx, "CreateLocation")
+	defer end()
+
 	collection := p.locations()
 	_, err := collection.InsertOne(ctx, location)
 	if err != nil {
@@ -376,6 +579,9 @@ func (p *Provider) CreateLocation(ctx context.Context, location types.Location)
 
 // CreateLocation attempts to insert a new membership into the database
 func (p *Provider) CreateMembership(ctx context.Context, membership types.Membership) error {
+	ctx, end := track(ctx, "CreateMembership")
+	defer end()
+
 	collection := p.memberships()
 	_, err := collection.InsertOne(ctx, membership)
 	if err != nil {
@@ -390,6 +596,21 @@ func (p *Provider) CreateMembership(ctx context.Context, membership types.Member
 	return nil
 }
 
+// Starts tracking a single provider method with a span,
+// returning a function that records its latency and ends the span
+// (meant to be deferred at the start of the method)
+func track(ctx context.Context, method string) (context.Context, func()) {
+	start := time.Now()
+	ctx, span := tracing.Start(ctx, "mongo."+method,
+		attribute.String("db.system", "mongodb"),
+		attribute.String("db.operation", method))
+
+	return ctx, func() {
+		metrics.DBOperationDuration.WithLabelValues(method).Observe(time.Since(start).Seconds())
+		span.End()
+	}
+}
+
 // Detects if the given write exception is caused by (in part)
 // by a duplicate key error
 func isDuplicate(writeException mongo.WriteException) bool {
@@ -404,6 +625,9 @@ func isDuplicate(writeException mongo.WriteException) bool {
 
 // DeleteAnnouncement deletes an existing announcement by its ID
 func (p *Provider) DeleteAnnouncement(ctx context.Context, id string) error {
+	ctx, end := track(ctx, "DeleteAnnouncement")
+	defer end()
+
 	collection := p.announcements()
 	result, err := collection.DeleteOne(ctx, bson.D{{Key: "id", Value: id}})
 	if err != nil {
@@ -419,6 +643,9 @@ func (p *Provider) DeleteAnnouncement(ctx context.Context, id string) error {
 
 // DeleteProduct deletes an existing product metadata object by its ID
 func (p *Provider) DeleteProduct(ctx context.Context, id string) error {
+	ctx, end := track(ctx, "DeleteProduct")
+	defer end()
+
 	collection := p.products()
 	result, err := collection.DeleteOne(ctx, bson.D{{Key: "id", Value: id}})
 	if err != nil {
@@ -434,6 +661,9 @@ func (p *Provider) DeleteProduct(ctx context.Context, id string) error {
 
 // DeleteLocation deletes an existing location by its ID
 func (p *Provider) DeleteLocation(ctx context.Context, id string) error {
+	ctx, end := track(ctx, "DeleteLocation")
+	defer end()
+
 	collection := p.locations()
 	result, err := collection.DeleteOne(ctx, bson.D{{Key: "id", Value: id}})
 	if err != nil {
@@ -449,6 +679,9 @@ func (p *Provider) DeleteLocation(ctx context.Context, id string) error {
 
 // DeleteLocation deletes an existing location by its username
 func (p *Provider) DeleteMembership(ctx context.Context, username string) error {
+	ctx, end := track(ctx, "DeleteMembership")
+	defer end()
+
 	collection := p.memberships()
 	result, err := collection.DeleteOne(ctx, bson.D{{Key: "username", Value: username}})
 	if err != nil {
@@ -465,6 +698,9 @@ func (p *Provider) DeleteMembership(ctx context.Context, username string) error
 // UpdateAnnouncement updates an existing announcement by its ID
 // and a partial document containing new fields that override current ones
 func (p *Provider) UpdateAnnouncement(ctx context.Context, id string, update map[string]interface{}) (*types.Announcement, error) {
+	ctx, end := track(ctx, "UpdateAnnouncement")
+	defer end()
+
 	// Construct the patch query from the map
 	updateDocument := bson.D{}
 	for key, value := range update {
@@ -485,9 +721,32 @@ func (p *Provider) UpdateAnnouncement(ctx context.Context, id string, update map
 	return &updatedAnnouncement, nil
 }
 
+// MarkAnnouncementPushed records that an announcement's push notifications were sent,
+// returning false if they already had been
+func (p *Provider) MarkAnnouncementPushed(ctx context.Context, id string, pushedAt time.Time) (bool, error) {
+	ctx, end := track(ctx, "MarkAnnouncementPushed")
+	defer end()
+
+	collection := p.announcements()
+	filter := bson.D{
+		{Key: "id", Value: id},
+		{Key: "pushed_at", Value: nil},
+	}
+	updateQuery := bson.D{{Key: "$set", Value: bson.D{{Key: "pushed_at", Value: pushedAt}}}}
+	result, err := collection.UpdateOne(ctx, filter, updateQuery)
+	if err != nil {
+		return false, err
+	}
+
+	return result.ModifiedCount == 1, nil
+}
+
 // UpdateProduct updates an existing product metadata object by its ID
 // and a partial document containing new fields that override current ones
 func (p *Provider) UpdateProduct(ctx context.Context, id string, update map[string]interface{}) (*types.ProductMetadata, error) {
+	ctx, end := track(ctx, "UpdateProduct")
+	defer end()
+
 	// Construct the patch query from the map
 	updateDocument := bson.D{}
 	for key, value := range update {
@@ -511,6 +770,9 @@ func (p *Provider) UpdateProduct(ctx context.Context, id string, update map[stri
 // UpdateLocation updates an existing location by its ID
 // and a partial document containing new fields that override current ones
 func (p *Provider) UpdateLocation(ctx context.Context, id string, update map[string]interface{}) (*types.Location, error) {
+	ctx, end := track(ctx, "UpdateLocation")
+	defer end()
+
 	// Construct the patch query from the map
 	updateDocument := bson.D{}
 	for key, value := range update {
@@ -534,6 +796,9 @@ func (p *Provider) UpdateLocation(ctx context.Context, id string, update map[str
 // UpdateLocation updates an existing membership by its username
 // and a partial document containing new fields that override current ones
 func (p *Provider) UpdateMembership(ctx context.Context, username string, update map[string]interface{}) (*types.Membership, error) {
+	ctx, end := track(ctx, "UpdateMembership")
+	defer end()
+
 	// Construct the patch query from the map
 	updateDocument := bson.D{}
 	for key, value := range update {
@@ -0,0 +1,185 @@
+package mongo
+
+import (
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/jd-116/klemis-kitchen-api/db"
+	"github.com/jd-116/klemis-kitchen-api/types"
+)
+
+func (p *Provider) reservations() *mongo.Collection {
+	return p.client.Database(p.databaseName).Collection("reservations")
+}
+
+// GetReservation gets a single reservation given its ID
+func (p *Provider) GetReservation(ctx context.Context, id string) (*types.Reservation, error) {
+	ctx, end := track(ctx, "GetReservation")
+	defer end()
+
+	collection := p.reservations()
+	result := collection.FindOne(ctx, bson.D{{Key: "id", Value: id}})
+	if result.Err() == mongo.ErrNoDocuments {
+		return nil, db.NewNotFoundError(id)
+	}
+
+	var reservation types.Reservation
+	err := result.Decode(&reservation)
+	if err != nil {
+		return nil, err
+	}
+
+	return &reservation, nil
+}
+
+// GetLocationReservations gets a slice of all reservations at a location
+// that have one of the given statuses (or any status if none are given)
+func (p *Provider) GetLocationReservations(ctx context.Context, locationID string,
+	statuses []string) ([]types.Reservation, error) {
+
+	ctx, end := track(ctx, "GetLocationReservations")
+	defer end()
+
+	return p.findReservations(ctx, bson.D{{Key: "location_id", Value: locationID}}, statuses)
+}
+
+// GetUserReservations gets a slice of all reservations made by a user
+// that have one of the given statuses (or any status if none are given)
+func (p *Provider) GetUserReservations(ctx context.Context, username string,
+	statuses []string) ([]types.Reservation, error) {
+
+	ctx, end := track(ctx, "GetUserReservations")
+	defer end()
+
+	return p.findReservations(ctx, bson.D{{Key: "username", Value: username}}, statuses)
+}
+
+// GetHoldingReservations gets a slice of all reservations at a location
+// that still hold their items, given when the location's inventory was last loaded:
+// active reservations and ones that were picked up since then
+func (p *Provider) GetHoldingReservations(ctx context.Context, locationID string,
+	loadedAt time.Time) ([]types.Reservation, error) {
+
+	ctx, end := track(ctx, "GetHoldingReservations")
+	defer end()
+
+	filter := bson.D{
+		{Key: "location_id", Value: locationID},
+		{Key: "$or", Value: bson.A{
+			bson.D{{Key: "status", Value: bson.D{{Key: "$in", Value: types.ActiveReservationStatuses}}}},
+			bson.D{
+				{Key: "status", Value: types.ReservationPickedUp},
+				{Key: "updated_at", Value: bson.D{{Key: "$gte", Value: loadedAt}}},
+			},
+		}},
+	}
+	return p.findReservations(ctx, filter, nil)
+}
+
+func (p *Provider) findReservations(ctx context.Context, filter bson.D,
+	statuses []string) ([]types.Reservation, error) {
+
+	if len(statuses) > 0 {
+		filter = append(filter, bson.E{Key: "status", Value: bson.D{{Key: "$in", Value: statuses}}})
+	}
+
+	collection := p.reservations()
+
+	// Sort the reservations by their creation time (ascending),
+	// so that they're in the order they should be fulfilled
+	options := options.Find()
+	options.SetSort(bson.D{{Key: "created_at", Value: 1}})
+	cursor, err := collection.Find(ctx, filter, options)
+	if err != nil {
+		return nil, err
+	}
+
+	var reservations []types.Reservation
+	err = cursor.All(ctx, &reservations)
+	if err != nil {
+		return nil, err
+	}
+
+	// Return non-nil slice so JSON serialization is nice
+	if reservations == nil {
+		return []types.Reservation{}, nil
+	}
+
+	return reservations, nil
+}
+
+// CreateReservation attempts to insert a new reservation into the database
+func (p *Provider) CreateReservation(ctx context.Context, reservation types.Reservation) error {
+	ctx, end := track(ctx, "CreateReservation")
+	defer end()
+
+	collection := p.reservations()
+	_, err := collection.InsertOne(ctx, reservation)
+	if err != nil {
+		// Handle known cases (such as when the reservation was duplicate)
+		if writeException, ok := err.(mongo.WriteException); ok && isDuplicate(writeException) {
+			return db.NewDuplicateIDError(reservation.ID)
+		}
+
+		return err
+	}
+
+	return nil
+}
+
+// UpdateReservationStatus moves a reservation to a new status,
+// only if it is still in the expected current status
+// (otherwise, a NotFoundError is returned)
+func (p *Provider) UpdateReservationStatus(ctx context.Context, id string, currentStatus string,
+	status string) (*types.Reservation, error) {
+
+	ctx, end := track(ctx, "UpdateReservationStatus")
+	defer end()
+
+	collection := p.reservations()
+	filter := bson.D{{Key: "id", Value: id}, {Key: "status", Value: currentStatus}}
+	updateQuery := bson.D{{Key: "$set", Value: bson.D{
+		{Key: "status", Value: status},
+		{Key: "updated_at", Value: time.Now()},
+	}}}
+	options := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	var updatedReservation types.Reservation
+	err := collection.FindOneAndUpdate(ctx, filter, updateQuery, options).Decode(&updatedReservation)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, db.NewNotFoundError(id)
+		}
+
+		return nil, err
+	}
+
+	return &updatedReservation, nil
+}
+
+// ExpireReservations moves every active reservation
+// whose expiry time has passed to the expired status,
+// returning the number of reservations that expired
+func (p *Provider) ExpireReservations(ctx context.Context, now time.Time) (int64, error) {
+	ctx, end := track(ctx, "ExpireReservations")
+	defer end()
+
+	collection := p.reservations()
+	filter := bson.D{
+		{Key: "status", Value: bson.D{{Key: "$in", Value: types.ActiveReservationStatuses}}},
+		{Key: "expires_at", Value: bson.D{{Key: "$lte", Value: now}}},
+	}
+	updateQuery := bson.D{{Key: "$set", Value: bson.D{
+		{Key: "status", Value: types.ReservationExpired},
+		{Key: "updated_at", Value: now},
+	}}}
+	result, err := collection.UpdateMany(ctx, filter, updateQuery)
+	if err != nil {
+		return 0, err
+	}
+
+	return result.ModifiedCount, nil
+}
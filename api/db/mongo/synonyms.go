@@ -0,0 +1,82 @@
+package mongo
+
+import (
+	"context"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/jd-116/klemis-kitchen-api/db"
+	"github.com/jd-116/klemis-kitchen-api/types"
+)
+
+func (p *Provider) synonyms() *mongo.Collection {
+	return p.client.Database(p.databaseName).Collection("synonyms")
+}
+
+// GetAllSynonyms gets a slice of all search synonym groups in the database
+func (p *Provider) GetAllSynonyms(ctx context.Context) ([]types.Synonym, error) {
+	ctx, end := track(ctx, "GetAllSynonyms")
+	defer end()
+
+	collection := p.synonyms()
+
+	// Sort the synonyms by their creation time (ascending)
+	options := options.Find()
+	options.SetSort(bson.D{{Key: "created_at", Value: 1}})
+	cursor, err := collection.Find(ctx, bson.D{}, options)
+	if err != nil {
+		return nil, err
+	}
+
+	var synonyms []types.Synonym
+	err = cursor.All(ctx, &synonyms)
+	if err != nil {
+		return nil, err
+	}
+
+	// Return non-nil slice so JSON serialization is nice
+	if synonyms == nil {
+		return []types.Synonym{}, nil
+	}
+
+	return synonyms, nil
+}
+
+// CreateSynonym attempts to insert a new search synonym group into the database
+func (p *Provider) CreateSynonym(ctx context.Context, synonym types.Synonym) error {
+	ctx, end := track(ctx, "CreateSynonym")
+	defer end()
+
+	collection := p.synonyms()
+	_, err := collection.InsertOne(ctx, synonym)
+	if err != nil {
+		// Handle known cases (such as when the synonym was duplicate)
+		if writeException, ok := err.(mongo.WriteException); ok && isDuplicate(writeException) {
+			return db.NewDuplicateIDError(synonym.ID)
+		}
+
+		return err
+	}
+
+	return nil
+}
+
+// DeleteSynonym deletes an existing search synonym group by its ID
+func (p *Provider) DeleteSynonym(ctx context.Context, id string) error {
+	ctx, end := track(ctx, "DeleteSynonym")
+	defer end()
+
+	collection := p.synonyms()
+	result, err := collection.DeleteOne(ctx, bson.D{{Key: "id", Value: id}})
+	if err != nil {
+		return err
+	}
+
+	if result.DeletedCount == 0 {
+		return db.NewNotFoundError(id)
+	}
+
+	return nil
+}
@@ -0,0 +1,256 @@
+package mongo
+
+import (
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/jd-116/klemis-kitchen-api/db"
+	"github.com/jd-116/klemis-kitchen-api/types"
+)
+
+func (p *Provider) uploads() *mongo.Collection {
+	return p.client.Database(p.databaseName).Collection("uploads")
+}
+
+func (p *Provider) presignedUploads() *mongo.Collection {
+	return p.client.Database(p.databaseName).Collection("presignedUploads")
+}
+
+// GetUpload gets a single upload given its ID
+func (p *Provider) GetUpload(ctx context.Context, id string) (*types.Upload, error) {
+	ctx, end := track(ctx, "GetUpload")
+	defer end()
+
+	collection := p.uploads()
+	result := collection.FindOne(ctx, bson.D{{Key: "id", Value: id}})
+	if result.Err() == mongo.ErrNoDocuments {
+		return nil, db.NewNotFoundError(id)
+	}
+
+	var upload types.Upload
+	err := result.Decode(&upload)
+	if err != nil {
+		return nil, err
+	}
+
+	return &upload, nil
+}
+
+// GetUploadByName gets a single upload given the name it was stored under
+func (p *Provider) GetUploadByName(ctx context.Context, name string) (*types.Upload, error) {
+	ctx, end := track(ctx, "GetUploadByName")
+	defer end()
+
+	collection := p.uploads()
+	result := collection.FindOne(ctx, bson.D{{Key: "name", Value: name}})
+	if result.Err() == mongo.ErrNoDocuments {
+		return nil, db.NewNotFoundError(name)
+	}
+
+	var upload types.Upload
+	err := result.Decode(&upload)
+	if err != nil {
+		return nil, err
+	}
+
+	return &upload, nil
+}
+
+// GetUploadsByHash gets a slice of all uploads
+// whose content has the given SHA-256 hash (oldest first)
+func (p *Provider) GetUploadsByHash(ctx context.Context, hash string) ([]types.Upload, error) {
+	ctx, end := track(ctx, "GetUploadsByHash")
+	defer end()
+
+	collection := p.uploads()
+
+	// Sort the uploads by their upload time (ascending)
+	options := options.Find()
+	options.SetSort(bson.D{{Key: "uploaded_at", Value: 1}})
+	cursor, err := collection.Find(ctx, bson.D{{Key: "sha256", Value: hash}}, options)
+	if err != nil {
+		return nil, err
+	}
+
+	var uploads []types.Upload
+	err = cursor.All(ctx, &uploads)
+	if err != nil {
+		return nil, err
+	}
+
+	// Return non-nil slice so JSON serialization is nice
+	if uploads == nil {
+		return []types.Upload{}, nil
+	}
+
+	return uploads, nil
+}
+
+// GetAllUploads gets a slice of all uploads in the database
+func (p *Provider) GetAllUploads(ctx context.Context) ([]types.Upload, error) {
+	ctx, end := track(ctx, "GetAllUploads")
+	defer end()
+
+	collection := p.uploads()
+
+	// Sort the uploads by their upload time (descending)
+	options := options.Find()
+	options.SetSort(bson.D{{Key: "uploaded_at", Value: -1}})
+	cursor, err := collection.Find(ctx, bson.D{}, options)
+	if err != nil {
+		return nil, err
+	}
+
+	var uploads []types.Upload
+	err = cursor.All(ctx, &uploads)
+	if err != nil {
+		return nil, err
+	}
+
+	// Return non-nil slice so JSON serialization is nice
+	if uploads == nil {
+		return []types.Upload{}, nil
+	}
+
+	return uploads, nil
+}
+
+// CreateUpload attempts to insert a new upload into the database
+func (p *Provider) CreateUpload(ctx context.Context, upload types.Upload) error {
+	ctx, end := track(ctx, "CreateUpload")
+	defer end()
+
+	collection := p.uploads()
+	_, err := collection.InsertOne(ctx, upload)
+	if err != nil {
+		// Handle known cases (such as when the upload was duplicate)
+		if writeException, ok := err.(mongo.WriteException); ok && isDuplicate(writeException) {
+			return db.NewDuplicateIDError(upload.ID)
+		}
+
+		return err
+	}
+
+	return nil
+}
+
+// TouchUploads records that every upload in the group was just returned
+// for an upload of the same content
+func (p *Provider) TouchUploads(ctx context.Context, group string, touchedAt time.Time) error {
+	ctx, end := track(ctx, "TouchUploads")
+	defer end()
+
+	collection := p.uploads()
+	filter := bson.D{{Key: "group", Value: group}}
+	update := bson.D{{Key: "$set", Value: bson.D{
+		{Key: "touched_at", Value: touchedAt},
+	}}}
+	_, err := collection.UpdateMany(ctx, filter, update)
+	return err
+}
+
+// DeleteUpload deletes an existing upload by its ID
+func (p *Provider) DeleteUpload(ctx context.Context, id string) error {
+	ctx, end := track(ctx, "DeleteUpload")
+	defer end()
+
+	collection := p.uploads()
+	result, err := collection.DeleteOne(ctx, bson.D{{Key: "id", Value: id}})
+	if err != nil {
+		return err
+	}
+
+	if result.DeletedCount == 0 {
+		return db.NewNotFoundError(id)
+	}
+
+	return nil
+}
+
+// GetPresignedUpload gets a single presigned upload given its object key
+func (p *Provider) GetPresignedUpload(ctx context.Context, key string) (*types.PresignedUpload, error) {
+	ctx, end := track(ctx, "GetPresignedUpload")
+	defer end()
+
+	collection := p.presignedUploads()
+	result := collection.FindOne(ctx, bson.D{{Key: "key", Value: key}})
+	if result.Err() == mongo.ErrNoDocuments {
+		return nil, db.NewNotFoundError(key)
+	}
+
+	var presigned types.PresignedUpload
+	err := result.Decode(&presigned)
+	if err != nil {
+		return nil, err
+	}
+
+	return &presigned, nil
+}
+
+// GetExpiredPresignedUploads gets a slice of all presigned uploads
+// whose signed requests expired before the given time
+func (p *Provider) GetExpiredPresignedUploads(ctx context.Context, before time.Time) ([]types.PresignedUpload, error) {
+	ctx, end := track(ctx, "GetExpiredPresignedUploads")
+	defer end()
+
+	collection := p.presignedUploads()
+	filter := bson.D{{Key: "expires_at", Value: bson.D{{Key: "$lt", Value: before}}}}
+	cursor, err := collection.Find(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	var presigned []types.PresignedUpload
+	err = cursor.All(ctx, &presigned)
+	if err != nil {
+		return nil, err
+	}
+
+	// Return non-nil slice so JSON serialization is nice
+	if presigned == nil {
+		return []types.PresignedUpload{}, nil
+	}
+
+	return presigned, nil
+}
+
+// CreatePresignedUpload attempts to insert a new presigned upload into the database
+func (p *Provider) CreatePresignedUpload(ctx context.Context, presigned types.PresignedUpload) error {
+	ctx, end := track(ctx, "CreatePresignedUpload")
+	defer end()
+
+	collection := p.presignedUploads()
+	_, err := collection.InsertOne(ctx, presigned)
+	if err != nil {
+		// Handle known cases (such as when the key was duplicate)
+		if writeException, ok := err.(mongo.WriteException); ok && isDuplicate(writeException) {
+			return db.NewDuplicateIDError(presigned.Key)
+		}
+
+		return err
+	}
+
+	return nil
+}
+
+// DeletePresignedUpload deletes an existing presigned upload by its object key
+func (p *Provider) DeletePresignedUpload(ctx context.Context, key string) error {
+	ctx, end := track(ctx, "DeletePresignedUpload")
+	defer end()
+
+	collection := p.presignedUploads()
+	result, err := collection.DeleteOne(ctx, bson.D{{Key: "key", Value: key}})
+	if err != nil {
+		return err
+	}
+
+	if result.DeletedCount == 0 {
+		return db.NewNotFoundError(key)
+	}
+
+	return nil
+}
@@ -0,0 +1,69 @@
+package mongo
+
+import (
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/jd-116/klemis-kitchen-api/db"
+	"github.com/jd-116/klemis-kitchen-api/types"
+)
+
+func (p *Provider) visits() *mongo.Collection {
+	return p.client.Database(p.databaseName).Collection("visits")
+}
+
+// CreateVisit attempts to insert a new visit into the database
+func (p *Provider) CreateVisit(ctx context.Context, visit types.Visit) error {
+	ctx, end := track(ctx, "CreateVisit")
+	defer end()
+
+	collection := p.visits()
+	_, err := collection.InsertOne(ctx, visit)
+	if err != nil {
+		// Handle known cases (such as when the visit was duplicate)
+		if writeException, ok := err.(mongo.WriteException); ok && isDuplicate(writeException) {
+			return db.NewDuplicateIDError(visit.ID)
+		}
+
+		return err
+	}
+
+	return nil
+}
+
+// GetUserVisits gets a slice of all visits made by a user at or after the given time
+func (p *Provider) GetUserVisits(ctx context.Context, username string, since time.Time) ([]types.Visit, error) {
+	ctx, end := track(ctx, "GetUserVisits")
+	defer end()
+
+	collection := p.visits()
+	filter := bson.D{
+		{Key: "username", Value: username},
+		{Key: "created_at", Value: bson.D{{Key: "$gte", Value: since}}},
+	}
+
+	// Sort the visits by their creation time (ascending)
+	options := options.Find()
+	options.SetSort(bson.D{{Key: "created_at", Value: 1}})
+	cursor, err := collection.Find(ctx, filter, options)
+	if err != nil {
+		return nil, err
+	}
+
+	var visits []types.Visit
+	err = cursor.All(ctx, &visits)
+	if err != nil {
+		return nil, err
+	}
+
+	// Return non-nil slice so JSON serialization is nice
+	if visits == nil {
+		return []types.Visit{}, nil
+	}
+
+	return visits, nil
+}
@@ -0,0 +1,242 @@
+package favorites
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"github.com/jd-116/klemis-kitchen-api/config"
+	"github.com/jd-116/klemis-kitchen-api/db"
+	"github.com/jd-116/klemis-kitchen-api/notify"
+	"github.com/jd-116/klemis-kitchen-api/products"
+)
+
+// Database is the subset of the database provider
+// that the favorites watcher needs
+type Database interface {
+	db.LocationProvider
+	db.FavoriteProvider
+	db.NotificationPreferencesProvider
+	db.CanonicalProductProvider
+}
+
+// Watcher listens for products coming back in stock
+// and queues a notification for each user who favorited them.
+// Each user gets at most one notification per cache reload,
+// and at most a configured number of notifications per window
+type Watcher struct {
+	database Database
+	products products.RestockSource
+	queue    *notify.Queue
+	restocks chan []products.Restock
+	stop     chan struct{}
+
+	// Config values
+	rateLimit  int
+	rateWindow time.Duration
+
+	// Times of each user's recent restock notifications, for rate limiting
+	sentLock sync.Mutex
+	sent     map[string][]time.Time
+	logger   zerolog.Logger
+}
+
+// NewWatcher creates the watcher,
+// rate limiting the notifications it sends by the notification configuration
+// (doesn't start goroutines)
+func NewWatcher(database Database, restockSource products.RestockSource, queue *notify.Queue,
+	cfg config.NotificationConfig, logger zerolog.Logger) *Watcher {
+
+	return &Watcher{
+		database: database,
+		products: restockSource,
+		queue:    queue,
+		restocks: make(chan []products.Restock, 16),
+		stop:     make(chan struct{}),
+
+		rateLimit:  cfg.RestockRateLimit,
+		rateWindow: time.Duration(cfg.RestockRateWindow),
+
+		sent:   make(map[string][]time.Time),
+		logger: logger,
+	}
+}
+
+// Connect starts listening for restocks
+func (w *Watcher) Connect(ctx context.Context) error {
+	w.products.OnRestock(w.onRestock)
+	go w.watch()
+	return nil
+}
+
+// Disconnect stops handling restocks
+func (w *Watcher) Disconnect(ctx context.Context) error {
+	w.stop <- struct{}{}
+	return nil
+}
+
+// Called while the cache is being loaded, so it hands the restocks off
+// instead of handling them inline
+func (w *Watcher) onRestock(restocks []products.Restock) {
+	select {
+	case w.restocks <- restocks:
+	default:
+		w.logger.
+			Warn().
+			Int("restock_count", len(restocks)).
+			Msg("restock notification backlog is full; dropping restocks")
+	}
+}
+
+func (w *Watcher) watch() {
+	for {
+		select {
+		case <-w.stop:
+			return
+		case restocks := <-w.restocks:
+			w.tryHandle(restocks)
+		}
+	}
+}
+
+func (w *Watcher) tryHandle(restocks []products.Restock) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	err := w.handle(ctx, restocks)
+	if err != nil {
+		w.logger.
+			Error().
+			Err(err).
+			Int("restock_count", len(restocks)).
+			Msg("could not send restock notifications")
+	}
+}
+
+// Finds every user who favorited a restocked product (at that location or any location),
+// or the canonical product it was merged into,
+// and queues a single notification for each of them
+func (w *Watcher) handle(ctx context.Context, restocks []products.Restock) error {
+	// Restocks are keyed by inventory identifier, while favorites use location IDs
+	locations, err := w.database.GetAllLocations(ctx)
+	if err != nil {
+		return err
+	}
+	locationIDs := make(map[string][]string)
+	locationNames := make(map[string]string)
+	for _, location := range locations {
+		identifier := location.InventoryIdentifier()
+		locationIDs[identifier] = append(locationIDs[identifier], location.ID)
+		locationNames[location.ID] = location.Name
+	}
+
+	canonicalProducts, err := w.database.GetAllCanonicalProducts(ctx)
+	if err != nil {
+		return err
+	}
+	aliases := products.NewAliases(canonicalProducts, locations)
+
+	productIDs := []string{}
+	byProduct := make(map[string][]products.Restock)
+	addRestock := func(productID string, restock products.Restock) {
+		if _, ok := byProduct[productID]; !ok {
+			productIDs = append(productIDs, productID)
+		}
+		byProduct[productID] = append(byProduct[productID], restock)
+	}
+	for _, restock := range restocks {
+		addRestock(restock.ProductID, restock)
+
+		// Restocks use the product IDs from the inventory,
+		// while favorites can also use the canonical product shown in product lists
+		if canonicalProduct, ok := aliases.Resolve(restock.Location, restock.ProductID); ok {
+			restock.Name = canonicalProduct.Name
+			addRestock(canonicalProduct.ID, restock)
+		}
+	}
+
+	favorites, err := w.database.GetProductFavorites(ctx, productIDs)
+	if err != nil {
+		return err
+	}
+
+	// Collect the restocked products (with where they're back) for each user
+	usernames := []string{}
+	matches := make(map[string]map[string]bool)
+	for _, favorite := range favorites {
+		for _, restock := range byProduct[favorite.ProductID] {
+			for _, locationID := range locationIDs[restock.Location] {
+				if favorite.LocationID != "" && favorite.LocationID != locationID {
+					continue
+				}
+
+				if _, ok := matches[favorite.Username]; !ok {
+					usernames = append(usernames, favorite.Username)
+					matches[favorite.Username] = make(map[string]bool)
+				}
+				matches[favorite.Username][fmt.Sprintf("%s at %s", restock.Name, locationNames[locationID])] = true
+			}
+		}
+	}
+
+	for _, username := range usernames {
+		preferences, err := w.database.GetNotificationPreferences(ctx, username)
+		if err != nil {
+			return err
+		}
+		if preferences.RestockOptOut {
+			continue
+		}
+
+		if !w.allow(username, time.Now()) {
+			w.logger.
+				Debug().
+				Str("username", username).
+				Msg("skipping restock notification for rate-limited user")
+			continue
+		}
+
+		descriptions := []string{}
+		for description := range matches[username] {
+			descriptions = append(descriptions, description)
+		}
+		sort.Strings(descriptions)
+
+		w.queue.Enqueue(notify.Notification{
+			Username: username,
+			Kind:     notify.KindRestock,
+			Title:    "Your favorites are back in stock",
+			Body:     "Back in stock: " + strings.Join(descriptions, ", "),
+		})
+	}
+
+	return nil
+}
+
+// Determines whether the user can be sent another notification,
+// recording it if so
+func (w *Watcher) allow(username string, now time.Time) bool {
+	w.sentLock.Lock()
+	defer w.sentLock.Unlock()
+
+	// Forget notifications that have left the window
+	recent := []time.Time{}
+	for _, sentAt := range w.sent[username] {
+		if now.Sub(sentAt) < w.rateWindow {
+			recent = append(recent, sentAt)
+		}
+	}
+
+	if len(recent) >= w.rateLimit {
+		w.sent[username] = recent
+		return false
+	}
+
+	w.sent[username] = append(recent, now)
+	return true
+}
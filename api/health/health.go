@@ -0,0 +1,97 @@
+package health
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Check is a single named check against a downstream component
+// that returns a non-nil error if the component is unhealthy
+type Check struct {
+	Name  string
+	Check func(ctx context.Context) error
+}
+
+// ComponentStatus is the result of the most recent check of a single component
+type ComponentStatus struct {
+	Name          string     `json:"name"`
+	Healthy       bool       `json:"healthy"`
+	Latency       string     `json:"latency"`
+	LastCheckedAt time.Time  `json:"last_checked_at"`
+	LastError     *string    `json:"last_error"`
+	LastErrorAt   *time.Time `json:"last_error_at"`
+}
+
+// Checker runs a set of component checks
+// and keeps track of the last error seen for each of them
+type Checker struct {
+	checks  []Check
+	timeout time.Duration
+
+	lock     sync.Mutex
+	statuses map[string]ComponentStatus
+}
+
+// NewChecker creates a new Checker for the given checks,
+// where each check is cancelled after the timeout elapses
+func NewChecker(timeout time.Duration, checks ...Check) *Checker {
+	return &Checker{
+		checks:   checks,
+		timeout:  timeout,
+		statuses: make(map[string]ComponentStatus),
+	}
+}
+
+// Run runs all checks concurrently,
+// returning the status of each component (in the order the checks were given)
+// and whether all of them are healthy
+func (c *Checker) Run(ctx context.Context) ([]ComponentStatus, bool) {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	results := make([]ComponentStatus, len(c.checks))
+	var wg sync.WaitGroup
+	for i, check := range c.checks {
+		wg.Add(1)
+		go func(i int, check Check) {
+			defer wg.Done()
+			results[i] = c.run(ctx, check)
+		}(i, check)
+	}
+	wg.Wait()
+
+	healthy := true
+	for _, result := range results {
+		if !result.Healthy {
+			healthy = false
+		}
+	}
+
+	return results, healthy
+}
+
+// Runs a single check and records its outcome
+func (c *Checker) run(ctx context.Context, check Check) ComponentStatus {
+	start := time.Now()
+	err := check.Check(ctx)
+	latency := time.Since(start)
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	// Carry over the last error from the previous status if this check succeeded
+	status := c.statuses[check.Name]
+	status.Name = check.Name
+	status.Healthy = err == nil
+	status.Latency = latency.String()
+	status.LastCheckedAt = start
+	if err != nil {
+		message := err.Error()
+		status.LastError = &message
+		status.LastErrorAt = &start
+	}
+
+	c.statuses[check.Name] = status
+	return status
+}
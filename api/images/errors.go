@@ -0,0 +1,20 @@
+package images
+
+import "fmt"
+
+// InvalidImageError is an error used to encode when an uploaded image
+// can't be processed, such as when it's corrupt or too large
+type InvalidImageError struct {
+	Reason string
+}
+
+// NewInvalidImageError constructs a new InvalidImageError
+func NewInvalidImageError(reason string) *InvalidImageError {
+	return &InvalidImageError{
+		Reason: reason,
+	}
+}
+
+func (e *InvalidImageError) Error() string {
+	return fmt.Sprintf("invalid image: %s", e.Reason)
+}
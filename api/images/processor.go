@@ -0,0 +1,142 @@
+package images
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/jpeg"
+	_ "image/png" // Register the PNG decoder
+	"io"
+	"io/ioutil"
+
+	"github.com/c2h5oh/datasize"
+
+	"github.com/jd-116/klemis-kitchen-api/config"
+)
+
+// Names of each variant produced for an image
+const (
+	VariantThumbnail = "thumbnail"
+	VariantMedium    = "medium"
+	VariantOriginal  = "original"
+)
+
+// Variant is a single processed version of an image,
+// encoded as a JPEG.
+// WebP isn't produced since there is no pure Go WebP encoder
+// (golang.org/x/image only decodes it), and libwebp would need cgo
+type Variant struct {
+	Name   string
+	Data   []byte
+	Width  int
+	Height int
+}
+
+// Processor turns uploaded images into several resized variants.
+// Each variant is auto-rotated according to the image's EXIF orientation
+// and re-encoded without any of the original metadata (such as EXIF location data)
+type Processor struct {
+	thumbnailSize int
+	mediumSize    int
+	jpegQuality   int
+	maxPixels     int
+	maxSize       datasize.ByteSize
+}
+
+// NewProcessor creates a new image processor from the upload configuration
+func NewProcessor(cfg config.UploadConfig) *Processor {
+	return &Processor{
+		thumbnailSize: cfg.ImageThumbnailSize,
+		mediumSize:    cfg.ImageMediumSize,
+		jpegQuality:   cfg.ImageJPEGQuality,
+		maxPixels:     cfg.ImageMaxPixels,
+		maxSize:       cfg.ImageMaxSize,
+	}
+}
+
+// Supports determines whether images of the given MIME type can be processed
+func (p *Processor) Supports(mime string) bool {
+	return mime == "image/jpeg" || mime == "image/png"
+}
+
+// Read reads a whole image into memory so that it can be processed,
+// stopping as soon as it goes over the maximum image file size
+func (p *Processor) Read(reader io.Reader) ([]byte, error) {
+	data, err := ioutil.ReadAll(io.LimitReader(reader, int64(p.maxSize)+1))
+	if err != nil {
+		return nil, err
+	}
+	if uint64(len(data)) > p.maxSize.Bytes() {
+		return nil, NewInvalidImageError(fmt.Sprintf("the file is larger than the maximum of %s",
+			p.maxSize.HumanReadable()))
+	}
+
+	return data, nil
+}
+
+// Process decodes the image and produces each of its variants:
+// a thumbnail and a medium version that fit within a square of the configured sizes,
+// and the original size (which are never scaled up)
+func (p *Processor) Process(data []byte) ([]Variant, error) {
+	// Check the dimensions before decoding
+	// so that huge images don't use up all of the memory
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, NewInvalidImageError(err.Error())
+	}
+	if config.Width*config.Height > p.maxPixels {
+		return nil, NewInvalidImageError(fmt.Sprintf("%dx%d is larger than the maximum of %d pixels",
+			config.Width, config.Height, p.maxPixels))
+	}
+
+	decoded, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, NewInvalidImageError(err.Error())
+	}
+
+	// Flatten any transparency onto a white background, since JPEGs can't have any,
+	// and then turn the image upright
+	original := flatten(decoded)
+	original = orient(original, exifOrientation(data))
+
+	variants := []Variant{}
+	sizes := []struct {
+		name    string
+		maxSize int
+	}{
+		{VariantThumbnail, p.thumbnailSize},
+		{VariantMedium, p.mediumSize},
+		{VariantOriginal, 0},
+	}
+	for _, size := range sizes {
+		resized := original
+		if size.maxSize > 0 {
+			resized = fit(original, size.maxSize)
+		}
+
+		var buffer bytes.Buffer
+		err := jpeg.Encode(&buffer, resized, &jpeg.Options{Quality: p.jpegQuality})
+		if err != nil {
+			return nil, err
+		}
+
+		variants = append(variants, Variant{
+			Name:   size.name,
+			Data:   buffer.Bytes(),
+			Width:  resized.Bounds().Dx(),
+			Height: resized.Bounds().Dy(),
+		})
+	}
+
+	return variants, nil
+}
+
+// Draws the image onto an opaque white background
+func flatten(img image.Image) *image.RGBA {
+	bounds := img.Bounds()
+	flattened := image.NewRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	draw.Draw(flattened, flattened.Bounds(), image.White, image.Point{}, draw.Src)
+	draw.Draw(flattened, flattened.Bounds(), img, bounds.Min, draw.Over)
+	return flattened
+}
@@ -0,0 +1,38 @@
+package images
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/c2h5oh/datasize"
+)
+
+func TestProcessorRead(t *testing.T) {
+	processor := &Processor{maxSize: 10 * datasize.B}
+
+	tests := []struct {
+		size  int
+		valid bool
+	}{
+		{0, true},
+		{10, true},
+		{11, false},
+		{1000, false},
+	}
+
+	for _, test := range tests {
+		data, err := processor.Read(bytes.NewReader(make([]byte, test.size)))
+		if !test.valid {
+			if _, ok := err.(*InvalidImageError); !ok {
+				t.Errorf("Read() of %d bytes error = %v, expected an InvalidImageError", test.size, err)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("Read() of %d bytes returned an error: %v", test.size, err)
+		} else if len(data) != test.size {
+			t.Errorf("Read() of %d bytes read %d bytes", test.size, len(data))
+		}
+	}
+}
@@ -0,0 +1,179 @@
+package images
+
+import (
+	"encoding/binary"
+	"image"
+)
+
+// Finds the EXIF orientation (1-8) of a JPEG image,
+// or 1 (upright) if it doesn't have one
+func exifOrientation(data []byte) int {
+	// Make sure the data is a JPEG
+	if len(data) < 4 || data[0] != 0xFF || data[1] != 0xD8 {
+		return 1
+	}
+
+	// Walk the segments until the EXIF (APP1) segment is found,
+	// stopping at the start of the image data
+	offset := 2
+	for offset+4 <= len(data) {
+		if data[offset] != 0xFF {
+			return 1
+		}
+		marker := data[offset+1]
+		length := int(binary.BigEndian.Uint16(data[offset+2 : offset+4]))
+		if marker == 0xDA || length < 2 || offset+2+length > len(data) {
+			return 1
+		}
+
+		segment := data[offset+4 : offset+2+length]
+		if marker == 0xE1 && len(segment) >= 6 && string(segment[:6]) == "Exif\x00\x00" {
+			return tiffOrientation(segment[6:])
+		}
+
+		offset += 2 + length
+	}
+
+	return 1
+}
+
+// Finds the orientation tag in the first IFD of a TIFF header
+func tiffOrientation(tiff []byte) int {
+	if len(tiff) < 8 {
+		return 1
+	}
+
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return 1
+	}
+
+	ifdOffset := int(order.Uint32(tiff[4:8]))
+	if ifdOffset < 8 || ifdOffset+2 > len(tiff) {
+		return 1
+	}
+
+	entryCount := int(order.Uint16(tiff[ifdOffset : ifdOffset+2]))
+	for i := 0; i < entryCount; i++ {
+		entry := ifdOffset + 2 + i*12
+		if entry+12 > len(tiff) {
+			return 1
+		}
+
+		if order.Uint16(tiff[entry:entry+2]) == 0x0112 {
+			orientation := int(order.Uint16(tiff[entry+8 : entry+10]))
+			if orientation < 1 || orientation > 8 {
+				return 1
+			}
+			return orientation
+		}
+	}
+
+	return 1
+}
+
+// Rotates and flips the image so that it's upright,
+// given its EXIF orientation
+func orient(img *image.RGBA, orientation int) *image.RGBA {
+	if orientation == 1 {
+		return img
+	}
+
+	width, height := img.Bounds().Dx(), img.Bounds().Dy()
+	dstWidth, dstHeight := width, height
+	if orientation >= 5 {
+		dstWidth, dstHeight = height, width
+	}
+
+	// Find the source pixel for each destination pixel
+	source := func(x int, y int) (int, int) {
+		switch orientation {
+		case 2:
+			return width - 1 - x, y
+		case 3:
+			return width - 1 - x, height - 1 - y
+		case 4:
+			return x, height - 1 - y
+		case 5:
+			return y, x
+		case 6:
+			return y, height - 1 - x
+		case 7:
+			return width - 1 - y, height - 1 - x
+		default:
+			return width - 1 - y, x
+		}
+	}
+
+	oriented := image.NewRGBA(image.Rect(0, 0, dstWidth, dstHeight))
+	for y := 0; y < dstHeight; y++ {
+		for x := 0; x < dstWidth; x++ {
+			srcX, srcY := source(x, y)
+			srcOffset := img.PixOffset(srcX, srcY)
+			dstOffset := oriented.PixOffset(x, y)
+			copy(oriented.Pix[dstOffset:dstOffset+4], img.Pix[srcOffset:srcOffset+4])
+		}
+	}
+
+	return oriented
+}
+
+// Scales the image down (keeping its aspect ratio) so that it fits within
+// a square of the given size, averaging the source pixels covered by each new pixel.
+// Images that already fit are returned as-is
+func fit(img *image.RGBA, maxSize int) *image.RGBA {
+	width, height := img.Bounds().Dx(), img.Bounds().Dy()
+	if width <= maxSize && height <= maxSize {
+		return img
+	}
+
+	dstWidth, dstHeight := maxSize, maxSize
+	if width > height {
+		dstHeight = max(1, height*maxSize/width)
+	} else {
+		dstWidth = max(1, width*maxSize/height)
+	}
+
+	resized := image.NewRGBA(image.Rect(0, 0, dstWidth, dstHeight))
+	for y := 0; y < dstHeight; y++ {
+		srcY0 := y * height / dstHeight
+		srcY1 := max(srcY0+1, (y+1)*height/dstHeight)
+		for x := 0; x < dstWidth; x++ {
+			srcX0 := x * width / dstWidth
+			srcX1 := max(srcX0+1, (x+1)*width/dstWidth)
+
+			var r, g, b, a, count int
+			for srcY := srcY0; srcY < srcY1; srcY++ {
+				offset := img.PixOffset(srcX0, srcY)
+				for srcX := srcX0; srcX < srcX1; srcX++ {
+					r += int(img.Pix[offset])
+					g += int(img.Pix[offset+1])
+					b += int(img.Pix[offset+2])
+					a += int(img.Pix[offset+3])
+					offset += 4
+					count++
+				}
+			}
+
+			dstOffset := resized.PixOffset(x, y)
+			resized.Pix[dstOffset] = uint8(r / count)
+			resized.Pix[dstOffset+1] = uint8(g / count)
+			resized.Pix[dstOffset+2] = uint8(b / count)
+			resized.Pix[dstOffset+3] = uint8(a / count)
+		}
+	}
+
+	return resized
+}
+
+func max(a int, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
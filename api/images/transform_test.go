@@ -0,0 +1,111 @@
+package images
+
+import (
+	"encoding/binary"
+	"image"
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+// Builds the start of a JPEG with an EXIF segment
+// whose first IFD only has the orientation tag
+func exifJPEG(order binary.ByteOrder, orientation uint16) []byte {
+	tiff := make([]byte, 8+2+12)
+	if order == binary.LittleEndian {
+		copy(tiff, "II")
+	} else {
+		copy(tiff, "MM")
+	}
+	order.PutUint16(tiff[2:4], 42)
+	order.PutUint32(tiff[4:8], 8)
+	order.PutUint16(tiff[8:10], 1)
+	order.PutUint16(tiff[10:12], 0x0112)
+	order.PutUint16(tiff[12:14], 3)
+	order.PutUint32(tiff[14:18], 1)
+	order.PutUint16(tiff[18:20], orientation)
+
+	segment := append([]byte("Exif\x00\x00"), tiff...)
+	data := []byte{0xFF, 0xD8, 0xFF, 0xE1, 0, 0}
+	binary.BigEndian.PutUint16(data[4:6], uint16(len(segment)+2))
+	data = append(data, segment...)
+	return append(data, 0xFF, 0xDA, 0, 2)
+}
+
+func TestExifOrientation(t *testing.T) {
+	tests := []struct {
+		name     string
+		data     []byte
+		expected int
+	}{
+		{"little endian", exifJPEG(binary.LittleEndian, 6), 6},
+		{"big endian", exifJPEG(binary.BigEndian, 8), 8},
+		{"upright", exifJPEG(binary.LittleEndian, 1), 1},
+		{"out of range", exifJPEG(binary.LittleEndian, 9), 1},
+		{"zero", exifJPEG(binary.BigEndian, 0), 1},
+		{"truncated", exifJPEG(binary.LittleEndian, 6)[:20], 1},
+		{"no EXIF", []byte{0xFF, 0xD8, 0xFF, 0xDA, 0, 2}, 1},
+		{"not a JPEG", []byte("\x89PNG\r\n\x1a\n"), 1},
+		{"empty", []byte{}, 1},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if actual := exifOrientation(test.data); actual != test.expected {
+				t.Errorf("exifOrientation() = %d, expected %d", actual, test.expected)
+			}
+		})
+	}
+}
+
+func TestOrient(t *testing.T) {
+	// A 3x2 image whose pixels are numbered in their red channel:
+	//   0 1 2
+	//   3 4 5
+	source := image.NewRGBA(image.Rect(0, 0, 3, 2))
+	for y := 0; y < 2; y++ {
+		for x := 0; x < 3; x++ {
+			source.Pix[source.PixOffset(x, y)] = uint8(y*3 + x)
+		}
+	}
+
+	tests := []struct {
+		orientation int
+		expected    [][]uint8
+	}{
+		{1, [][]uint8{{0, 1, 2}, {3, 4, 5}}},
+		// Mirrored horizontally
+		{2, [][]uint8{{2, 1, 0}, {5, 4, 3}}},
+		// Rotated 180 degrees
+		{3, [][]uint8{{5, 4, 3}, {2, 1, 0}}},
+		// Mirrored vertically
+		{4, [][]uint8{{3, 4, 5}, {0, 1, 2}}},
+		// Transposed
+		{5, [][]uint8{{0, 3}, {1, 4}, {2, 5}}},
+		// Rotated 90 degrees clockwise
+		{6, [][]uint8{{3, 0}, {4, 1}, {5, 2}}},
+		// Transversed
+		{7, [][]uint8{{5, 2}, {4, 1}, {3, 0}}},
+		// Rotated 90 degrees counterclockwise
+		{8, [][]uint8{{2, 5}, {1, 4}, {0, 3}}},
+	}
+
+	for _, test := range tests {
+		t.Run(strconv.Itoa(test.orientation), func(t *testing.T) {
+			oriented := orient(source, test.orientation)
+
+			actual := [][]uint8{}
+			for y := 0; y < oriented.Bounds().Dy(); y++ {
+				row := []uint8{}
+				for x := 0; x < oriented.Bounds().Dx(); x++ {
+					row = append(row, oriented.Pix[oriented.PixOffset(x, y)])
+				}
+				actual = append(actual, row)
+			}
+
+			if !reflect.DeepEqual(actual, test.expected) {
+				t.Errorf("orient(%d) = %v, expected %v", test.orientation, actual, test.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,316 @@
+package limits
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/segmentio/ksuid"
+
+	"github.com/jd-116/klemis-kitchen-api/db"
+	"github.com/jd-116/klemis-kitchen-api/products"
+	"github.com/jd-116/klemis-kitchen-api/types"
+)
+
+// Database is the subset of the database provider
+// that the limit enforcer needs
+type Database interface {
+	db.LocationProvider
+	db.ProductMetadataProvider
+	db.VisitProvider
+	db.CanonicalProductProvider
+}
+
+// Enforcer checks users out of locations,
+// making sure that each visit stays within the per-location limits
+// (items per visit and visits per week)
+// and the per-product limits (amount per visit and per week).
+// Products that were merged into a canonical product
+// count towards the limits of the canonical product.
+// Weeks start on Monday at midnight in the server's local time zone
+type Enforcer struct {
+	database Database
+
+	// Held while validating and recording a visit
+	// so that two concurrent checkouts can't both use the last of a limit
+	checkoutLock sync.Mutex
+	logger       zerolog.Logger
+}
+
+// NewEnforcer creates the enforcer
+func NewEnforcer(database Database, logger zerolog.Logger) *Enforcer {
+	return &Enforcer{
+		database: database,
+		logger:   logger,
+	}
+}
+
+// WeekStart gets the start of the week (Monday at midnight) containing the given time
+func WeekStart(t time.Time) time.Time {
+	daysSinceMonday := (int(t.Weekday()) + 6) % 7
+	year, month, day := t.AddDate(0, 0, -daysSinceMonday).Date()
+	return time.Date(year, month, day, 0, 0, 0, 0, t.Location())
+}
+
+// Usage gets the user's usage of every location and limited product this week
+func (e *Enforcer) Usage(ctx context.Context, username string) (*types.Usage, error) {
+	weekStart := WeekStart(time.Now())
+	loaded, err := e.load(ctx, username, weekStart)
+	if err != nil {
+		return nil, err
+	}
+
+	return loaded.usage(username, weekStart), nil
+}
+
+// Checkout validates a visit against the limits and records it,
+// returning the visit and the user's remaining usage
+func (e *Enforcer) Checkout(ctx context.Context, location types.Location, username string,
+	items []types.VisitItem, recordedBy string) (*types.Visit, *types.Usage, error) {
+
+	e.checkoutLock.Lock()
+	defer e.checkoutLock.Unlock()
+
+	now := time.Now()
+	weekStart := WeekStart(now)
+	loaded, err := e.load(ctx, username, weekStart)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Combine duplicate products, including aliases of the same canonical product
+	quantities := make(map[string]int)
+	order := []string{}
+	total := 0
+	for _, item := range items {
+		productID := loaded.aliases.CanonicalID(location.InventoryIdentifier(), item.ProductID)
+		if _, ok := quantities[productID]; !ok {
+			order = append(order, productID)
+		}
+		quantities[productID] += item.Quantity
+		total += item.Quantity
+	}
+
+	usage := loaded.usage(username, weekStart)
+	violations := []string{}
+
+	// Check the per-location limits
+	if location.MaxItemsPerVisit != nil && total > *location.MaxItemsPerVisit {
+		violations = append(violations, fmt.Sprintf("at most %d items can be taken per visit (requested %d)",
+			*location.MaxItemsPerVisit, total))
+	}
+	for _, locationUsage := range usage.Locations {
+		if locationUsage.LocationID == location.ID && locationUsage.VisitsRemaining != nil &&
+			*locationUsage.VisitsRemaining <= 0 {
+			violations = append(violations, fmt.Sprintf("at most %d visits are allowed per week",
+				*locationUsage.MaxVisitsPerWeek))
+		}
+	}
+
+	// Check the per-product limits
+	productUsages := make(map[string]types.ProductUsage)
+	for _, productUsage := range usage.Products {
+		productUsages[productUsage.ProductID] = productUsage
+	}
+	for _, productID := range order {
+		productUsage, ok := productUsages[productID]
+		if !ok {
+			continue
+		}
+
+		quantity := quantities[productID]
+		if productUsage.MaxPerVisit != nil && quantity > *productUsage.MaxPerVisit {
+			violations = append(violations, fmt.Sprintf("at most %d of product '%s' can be taken per visit (requested %d)",
+				*productUsage.MaxPerVisit, productID, quantity))
+		}
+		if productUsage.WeekRemaining != nil && quantity > *productUsage.WeekRemaining {
+			violations = append(violations, fmt.Sprintf("only %d more of product '%s' can be taken this week (requested %d)",
+				*productUsage.WeekRemaining, productID, quantity))
+		}
+	}
+
+	if len(violations) > 0 {
+		return nil, nil, NewExceededError(violations)
+	}
+
+	visit := types.Visit{
+		LocationID: location.ID,
+		Username:   username,
+		Items:      []types.VisitItem{},
+		RecordedBy: recordedBy,
+		CreatedAt:  now,
+	}
+	for _, productID := range order {
+		visit.Items = append(visit.Items, types.VisitItem{
+			ProductID: productID,
+			Quantity:  quantities[productID],
+		})
+	}
+
+	// Generate globally unique IDs for the visit
+	for {
+		rand, err := ksuid.NewRandom()
+		if err != nil {
+			return nil, nil, err
+		}
+
+		visit.ID = rand.String()
+
+		err = e.database.CreateVisit(ctx, visit)
+		if err != nil {
+			// If the error was a duplicate ID; try again
+			if _, ok := err.(*db.DuplicateIDError); ok {
+				continue
+			}
+
+			return nil, nil, err
+		}
+
+		break
+	}
+
+	e.logger.
+		Info().
+		Str("visit_id", visit.ID).
+		Str("location_id", location.ID).
+		Int("item_count", total).
+		Msg("recorded checkout visit")
+
+	// Include the new visit in the remaining usage
+	loaded.visits = append(loaded.visits, visit)
+	return &visit, loaded.usage(username, weekStart), nil
+}
+
+// Everything needed to determine a user's usage
+type usageData struct {
+	locations       []types.Location
+	productMetadata []types.ProductMetadata
+	visits          []types.Visit
+	aliases         *products.Aliases
+}
+
+// Loads everything needed to determine a user's usage
+func (e *Enforcer) load(ctx context.Context, username string, weekStart time.Time) (*usageData, error) {
+	locations, err := e.database.GetAllLocations(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	productMetadata, err := e.database.GetAllProducts(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	visits, err := e.database.GetUserVisits(ctx, username, weekStart)
+	if err != nil {
+		return nil, err
+	}
+
+	canonicalProducts, err := e.database.GetAllCanonicalProducts(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return &usageData{
+		locations:       locations,
+		productMetadata: productMetadata,
+		visits:          visits,
+		aliases:         products.NewAliases(canonicalProducts, locations),
+	}, nil
+}
+
+// Computes a user's usage of every location
+// and of every product that either has limits or was taken this week.
+// Visited products are counted under the canonical product they were merged into (if any)
+func (d *usageData) usage(username string, weekStart time.Time) *types.Usage {
+	identifiers := make(map[string]string)
+	for _, location := range d.locations {
+		identifiers[location.ID] = location.InventoryIdentifier()
+	}
+
+	visitCounts := make(map[string]int)
+	productCounts := make(map[string]int)
+	for _, visit := range d.visits {
+		visitCounts[visit.LocationID]++
+		for _, item := range visit.Items {
+			productCounts[d.aliases.CanonicalID(identifiers[visit.LocationID], item.ProductID)] += item.Quantity
+		}
+	}
+
+	usage := &types.Usage{
+		Username:  username,
+		WeekStart: weekStart,
+		Locations: []types.LocationUsage{},
+		Products:  []types.ProductUsage{},
+	}
+
+	for _, location := range d.locations {
+		locationUsage := types.LocationUsage{
+			LocationID:       location.ID,
+			Name:             location.Name,
+			VisitsThisWeek:   visitCounts[location.ID],
+			MaxVisitsPerWeek: location.MaxVisitsPerWeek,
+			MaxItemsPerVisit: location.MaxItemsPerVisit,
+		}
+		if location.MaxVisitsPerWeek != nil {
+			locationUsage.VisitsRemaining = remaining(*location.MaxVisitsPerWeek, locationUsage.VisitsThisWeek)
+		}
+		usage.Locations = append(usage.Locations, locationUsage)
+	}
+
+	productUsages := make(map[string]types.ProductUsage)
+	for productID, count := range productCounts {
+		productUsages[productID] = types.ProductUsage{
+			ProductID:    productID,
+			UsedThisWeek: count,
+		}
+	}
+	for _, metadata := range d.productMetadata {
+		if metadata.MaxPerVisit == nil && metadata.MaxPerWeek == nil {
+			continue
+		}
+
+		productUsage := types.ProductUsage{
+			ProductID:    metadata.ID,
+			UsedThisWeek: productCounts[metadata.ID],
+			MaxPerWeek:   metadata.MaxPerWeek,
+			MaxPerVisit:  metadata.MaxPerVisit,
+		}
+		if metadata.MaxPerWeek != nil {
+			productUsage.WeekRemaining = remaining(*metadata.MaxPerWeek, productUsage.UsedThisWeek)
+		}
+		productUsages[metadata.ID] = productUsage
+	}
+
+	for _, productUsage := range productUsages {
+		usage.Products = append(usage.Products, productUsage)
+	}
+	sort.Slice(usage.Products, func(i, j int) bool {
+		return usage.Products[i].ProductID < usage.Products[j].ProductID
+	})
+
+	return usage
+}
+
+func remaining(max int, used int) *int {
+	left := max - used
+	if left < 0 {
+		left = 0
+	}
+
+	return &left
+}
+
+// ValidLimit determines whether a limit decoded from a partial JSON update
+// is either null (no limit) or a non-negative whole number
+func ValidLimit(value interface{}) bool {
+	if value == nil {
+		return true
+	}
+
+	number, ok := value.(float64)
+	return ok && number >= 0 && number == float64(int(number))
+}
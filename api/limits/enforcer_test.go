@@ -0,0 +1,87 @@
+package limits
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/jd-116/klemis-kitchen-api/products"
+	"github.com/jd-116/klemis-kitchen-api/types"
+)
+
+func TestValidLimit(t *testing.T) {
+	tests := []struct {
+		json     string
+		expected bool
+	}{
+		{`null`, true},
+		{`0`, true},
+		{`3`, true},
+		{`3.0`, true},
+		{`-1`, false},
+		{`2.5`, false},
+		{`"3"`, false},
+		{`true`, false},
+		{`[3]`, false},
+		{`{"max": 3}`, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.json, func(t *testing.T) {
+			// Decode the value the same way as a partial JSON update
+			var value interface{}
+			err := json.Unmarshal([]byte(test.json), &value)
+			if err != nil {
+				t.Fatalf("could not decode test value: %v", err)
+			}
+
+			if actual := ValidLimit(value); actual != test.expected {
+				t.Errorf("ValidLimit(%s) = %v, expected %v", test.json, actual, test.expected)
+			}
+		})
+	}
+}
+
+// Regression test for visits of aliases not counting towards
+// the limits of the canonical product they were merged into
+func TestUsageCountsAliasesAsCanonical(t *testing.T) {
+	maxPerWeek := 5
+	locations := []types.Location{{ID: "north", TransactIdentifier: "NORTH"}}
+	d := &usageData{
+		locations: locations,
+		productMetadata: []types.ProductMetadata{
+			{ID: "canonical-milk", MaxPerWeek: &maxPerWeek},
+		},
+		visits: []types.Visit{{
+			LocationID: "north",
+			Items: []types.VisitItem{
+				{ProductID: "1001", Quantity: 1},
+				{ProductID: "1002", Quantity: 2},
+				{ProductID: "3000", Quantity: 4},
+			},
+		}},
+		aliases: products.NewAliases([]types.CanonicalProduct{{
+			ID: "canonical-milk",
+			Aliases: []types.ProductAlias{
+				{LocationID: "north", ProductID: "1001"},
+				{LocationID: "north", ProductID: "1002"},
+			},
+		}}, locations),
+	}
+
+	used := make(map[string]types.ProductUsage)
+	for _, productUsage := range d.usage("user", time.Now()).Products {
+		used[productUsage.ProductID] = productUsage
+	}
+
+	milk, ok := used["canonical-milk"]
+	if !ok || milk.UsedThisWeek != 3 || milk.WeekRemaining == nil || *milk.WeekRemaining != 2 {
+		t.Errorf("canonical-milk usage = %+v, expected 3 used and 2 remaining", milk)
+	}
+	if _, ok := used["1001"]; ok {
+		t.Errorf("alias 1001 has its own usage, expected it to count as canonical-milk")
+	}
+	if used["3000"].UsedThisWeek != 4 {
+		t.Errorf("3000 usage = %+v, expected 4 used", used["3000"])
+	}
+}
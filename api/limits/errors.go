@@ -0,0 +1,24 @@
+package limits
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ExceededError is an error used to encode when a checkout
+// would go over one or more of the visit or item limits
+type ExceededError struct {
+	Violations []string
+}
+
+// NewExceededError constructs a new ExceededError
+func NewExceededError(violations []string) *ExceededError {
+	return &ExceededError{
+		Violations: violations,
+	}
+}
+
+func (e *ExceededError) Error() string {
+	return fmt.Sprintf("checkout exceeds limits: %s",
+		strings.Join(e.Violations, "; "))
+}
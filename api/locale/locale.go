@@ -0,0 +1,182 @@
+package locale
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Default is the locale that content is written in,
+// which is used whenever a translation is missing
+const Default = "en"
+
+var localePattern = regexp.MustCompile(`^[a-z]{2,3}(-[a-z0-9]{2,8})*$`)
+
+type contextKey struct{}
+
+// Negotiator picks the locale that each request's content is shown in
+// out of the supported locales
+type Negotiator struct {
+	supported []string
+}
+
+// NewNegotiator creates a new locale negotiator for the supported locales
+// (which the default locale is always added to)
+func NewNegotiator(locales []string) (*Negotiator, error) {
+	supported := []string{Default}
+	for _, tag := range locales {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if tag == "" || tag == Default {
+			continue
+		}
+		if !IsValid(tag) {
+			return nil, fmt.Errorf("invalid supported locale '%s'", tag)
+		}
+
+		supported = append(supported, tag)
+	}
+
+	return &Negotiator{
+		supported: supported,
+	}, nil
+}
+
+// IsValid determines whether a (lowercase) language tag is well-formed,
+// such as "es" or "zh-hant"
+func IsValid(tag string) bool {
+	return localePattern.MatchString(tag)
+}
+
+// Translations gets every supported locale other than the default one,
+// which are the locales that content can be translated into
+func (n *Negotiator) Translations() []string {
+	return append([]string{}, n.supported[1:]...)
+}
+
+// IsTranslation determines whether content can be translated into a locale
+func (n *Negotiator) IsTranslation(tag string) bool {
+	for _, supported := range n.supported[1:] {
+		if supported == tag {
+			return true
+		}
+	}
+
+	return false
+}
+
+// CheckTranslations makes sure that content is only translated into supported locales,
+// given the locales of each translation
+func (n *Negotiator) CheckTranslations(tags []string) error {
+	for _, tag := range tags {
+		if !n.IsTranslation(tag) {
+			return fmt.Errorf("translations must be into one of the supported locales %v, not '%s'",
+				n.Translations(), tag)
+		}
+	}
+
+	return nil
+}
+
+// Middleware determines the locale of the request and attaches it to the request context.
+// The lang querystring param takes precedence over the Accept-Language header
+func (n *Negotiator) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tag := n.Negotiate(r)
+		w.Header().Set("Content-Language", tag)
+		w.Header().Add("Vary", "Accept-Language")
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), contextKey{}, tag)))
+	})
+}
+
+// Negotiate determines the locale of a request,
+// falling back to the default locale if none of the requested ones are supported
+func (n *Negotiator) Negotiate(r *http.Request) string {
+	if tag, ok := n.match(r.URL.Query().Get("lang")); ok {
+		return tag
+	}
+
+	for _, tag := range parseAcceptLanguage(r.Header.Get("Accept-Language")) {
+		if supported, ok := n.match(tag); ok {
+			return supported
+		}
+	}
+
+	return Default
+}
+
+// Matches a language tag against the supported locales,
+// first exactly and then by its primary language (such as "es" for "es-MX")
+func (n *Negotiator) match(tag string) (string, bool) {
+	tag = strings.ToLower(strings.TrimSpace(tag))
+	if tag == "" {
+		return "", false
+	}
+
+	primary := strings.SplitN(tag, "-", 2)[0]
+	for _, candidate := range []string{tag, primary} {
+		for _, supported := range n.supported {
+			if supported == candidate {
+				return supported, true
+			}
+		}
+	}
+
+	return "", false
+}
+
+// FromContext gets the locale of the current request,
+// which is the default locale if it wasn't negotiated
+func FromContext(ctx context.Context) string {
+	if tag, ok := ctx.Value(contextKey{}).(string); ok {
+		return tag
+	}
+
+	return Default
+}
+
+// Parses the language tags of an Accept-Language header,
+// in order of descending preference (leaving out any with q=0)
+func parseAcceptLanguage(header string) []string {
+	type weightedTag struct {
+		tag    string
+		weight float64
+	}
+
+	weighted := []weightedTag{}
+	for _, part := range strings.Split(header, ",") {
+		fields := strings.Split(part, ";")
+		tag := strings.TrimSpace(fields[0])
+		if tag == "" || tag == "*" {
+			continue
+		}
+
+		weight := 1.0
+		for _, param := range fields[1:] {
+			param = strings.TrimSpace(param)
+			if strings.HasPrefix(param, "q=") {
+				if parsed, err := strconv.ParseFloat(strings.TrimPrefix(param, "q="), 64); err == nil {
+					weight = parsed
+				}
+			}
+		}
+		if weight <= 0 {
+			continue
+		}
+
+		weighted = append(weighted, weightedTag{tag: tag, weight: weight})
+	}
+
+	sort.SliceStable(weighted, func(i, j int) bool {
+		return weighted[i].weight > weighted[j].weight
+	})
+
+	tags := []string{}
+	for _, w := range weighted {
+		tags = append(tags, w.tag)
+	}
+	return tags
+}
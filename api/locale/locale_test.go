@@ -0,0 +1,88 @@
+package locale
+
+import (
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestNegotiate(t *testing.T) {
+	negotiator := &Negotiator{supported: []string{Default, "es", "zh-hant"}}
+
+	tests := []struct {
+		name           string
+		query          string
+		acceptLanguage string
+		expected       string
+	}{
+		{"nothing requested", "", "", Default},
+		{"lang param", "?lang=es", "", "es"},
+		{"lang param over header", "?lang=es", "zh-Hant", "es"},
+		{"unsupported lang param", "?lang=fr", "es", "es"},
+		{"header", "", "es-MX,es;q=0.9", "es"},
+		{"primary language", "", "es-MX", "es"},
+		{"exact regional match", "", "zh-Hant-TW;q=0.5, zh-hant", "zh-hant"},
+		{"preference order", "", "fr;q=0.9, es;q=0.8, en;q=0.7", "es"},
+		{"refused locale", "", "es;q=0, en", Default},
+		{"wildcard", "", "*", Default},
+		{"nothing supported", "", "fr, de", Default},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/"+test.query, nil)
+			if test.acceptLanguage != "" {
+				r.Header.Set("Accept-Language", test.acceptLanguage)
+			}
+
+			if actual := negotiator.Negotiate(r); actual != test.expected {
+				t.Errorf("expected %s, got %s", test.expected, actual)
+			}
+		})
+	}
+}
+
+func TestParseAcceptLanguage(t *testing.T) {
+	tests := []struct {
+		header   string
+		expected []string
+	}{
+		{"", []string{}},
+		{"en", []string{"en"}},
+		{"da, en-GB;q=0.8, en;q=0.7", []string{"da", "en-GB", "en"}},
+		{"en;q=0.5, fr", []string{"fr", "en"}},
+		{"en;q=bad, fr;q=0.9", []string{"en", "fr"}},
+		{"*, es;q=0", []string{}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.header, func(t *testing.T) {
+			if actual := parseAcceptLanguage(test.header); !reflect.DeepEqual(actual, test.expected) {
+				t.Errorf("expected %v, got %v", test.expected, actual)
+			}
+		})
+	}
+}
+
+func TestIsValid(t *testing.T) {
+	tests := []struct {
+		tag      string
+		expected bool
+	}{
+		{"es", true},
+		{"zh-hant", true},
+		{"es-419", true},
+		{"ES", false},
+		{"e", false},
+		{"es_mx", false},
+		{"", false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.tag, func(t *testing.T) {
+			if actual := IsValid(test.tag); actual != test.expected {
+				t.Errorf("expected %t, got %t", test.expected, actual)
+			}
+		})
+	}
+}
@@ -0,0 +1,290 @@
+package markdown
+
+import (
+	"fmt"
+	"html"
+	"net/url"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// The subset of Markdown that is supported:
+// paragraphs (with single line breaks kept), headings (#, ##, ###),
+// unordered (-, *, +) and ordered (1.) lists,
+// **bold**, *italic* (or _italic_), `code`, and [links](https://example.com).
+// Raw HTML is never passed through; it's shown as text instead
+var (
+	headingPattern       = regexp.MustCompile(`^(#{1,3})\s+(.*)$`)
+	unorderedItemPattern = regexp.MustCompile(`^\s*[-*+]\s+(.*)$`)
+	orderedItemPattern   = regexp.MustCompile(`^\s*\d+[.)]\s+(.*)$`)
+)
+
+// Characters that can be escaped with a backslash to show them literally
+const escapable = "\\`*_[]()#+-.!"
+
+// Sanitize normalizes a Markdown body before it's stored,
+// removing control characters (other than newlines and tabs)
+// and surrounding whitespace
+func Sanitize(source string) string {
+	source = strings.ReplaceAll(source, "\r\n", "\n")
+	source = strings.ReplaceAll(source, "\r", "\n")
+	source = strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) && r != '\n' && r != '\t' {
+			return -1
+		}
+		return r
+	}, source)
+	return strings.TrimSpace(source)
+}
+
+// Render renders a Markdown body to HTML that is safe to embed in a page
+func Render(source string) string {
+	return render(source, false)
+}
+
+// PlainText strips the formatting from a Markdown body,
+// such as for push notifications that can't show it
+func PlainText(source string) string {
+	return render(source, true)
+}
+
+// SafeURL determines whether a URL can be linked to,
+// which has to be absolute and use the http, https, or mailto scheme
+func SafeURL(rawURL string) bool {
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return false
+	}
+
+	switch strings.ToLower(parsed.Scheme) {
+	case "http", "https":
+		return parsed.Host != ""
+	case "mailto":
+		return parsed.Opaque != ""
+	default:
+		return false
+	}
+}
+
+// Renders each block of the source,
+// either as HTML or as plain text
+func render(source string, plain bool) string {
+	var output strings.Builder
+	paragraph := []string{}
+	list := ""
+
+	closeParagraph := func() {
+		if len(paragraph) == 0 {
+			return
+		}
+
+		rendered := make([]string, len(paragraph))
+		for i, line := range paragraph {
+			rendered[i] = inline(strings.TrimSpace(line), plain, true)
+		}
+		if plain {
+			output.WriteString(strings.Join(rendered, "\n") + "\n\n")
+		} else {
+			output.WriteString("<p>" + strings.Join(rendered, "<br>\n") + "</p>\n")
+		}
+		paragraph = []string{}
+	}
+	closeList := func() {
+		if list == "" {
+			return
+		}
+
+		if plain {
+			output.WriteString("\n")
+		} else {
+			output.WriteString("</" + list + ">\n")
+		}
+		list = ""
+	}
+	openList := func(tag string) {
+		closeParagraph()
+		if list == tag {
+			return
+		}
+
+		closeList()
+		list = tag
+		if !plain {
+			output.WriteString("<" + tag + ">\n")
+		}
+	}
+
+	for _, line := range strings.Split(Sanitize(source), "\n") {
+		if strings.TrimSpace(line) == "" {
+			closeParagraph()
+			closeList()
+			continue
+		}
+
+		if match := headingPattern.FindStringSubmatch(line); match != nil {
+			closeParagraph()
+			closeList()
+			text := inline(strings.TrimSpace(match[2]), plain, true)
+			if plain {
+				output.WriteString(text + "\n\n")
+			} else {
+				output.WriteString(fmt.Sprintf("<h%d>%s</h%d>\n", len(match[1]), text, len(match[1])))
+			}
+			continue
+		}
+
+		if match := unorderedItemPattern.FindStringSubmatch(line); match != nil {
+			openList("ul")
+			item(&output, "- ", match[1], plain)
+			continue
+		}
+
+		if match := orderedItemPattern.FindStringSubmatch(line); match != nil {
+			openList("ol")
+			number := strings.TrimSpace(line[:len(line)-len(match[1])])
+			item(&output, number+" ", match[1], plain)
+			continue
+		}
+
+		closeList()
+		paragraph = append(paragraph, line)
+	}
+
+	closeParagraph()
+	closeList()
+	return strings.TrimSpace(output.String())
+}
+
+// Renders a single list item,
+// keeping its marker when rendering plain text
+func item(output *strings.Builder, marker string, text string, plain bool) {
+	rendered := inline(strings.TrimSpace(text), plain, true)
+	if plain {
+		output.WriteString(marker + rendered + "\n")
+	} else {
+		output.WriteString("<li>" + rendered + "</li>\n")
+	}
+}
+
+// Renders the inline formatting of a line of text.
+// Links can't be nested inside of other links
+func inline(text string, plain bool, links bool) string {
+	escape := html.EscapeString
+	if plain {
+		escape = func(s string) string { return s }
+	}
+	wrap := func(tag string, inner string) string {
+		if plain {
+			return inner
+		}
+		return "<" + tag + ">" + inner + "</" + tag + ">"
+	}
+
+	var output strings.Builder
+	for i := 0; i < len(text); {
+		rest := text[i:]
+
+		switch {
+		case rest[0] == '\\' && len(rest) > 1 && strings.IndexByte(escapable, rest[1]) != -1:
+			output.WriteString(escape(rest[1:2]))
+			i += 2
+			continue
+
+		case rest[0] == '`':
+			if end := strings.IndexByte(rest[1:], '`'); end > 0 {
+				output.WriteString(wrap("code", escape(rest[1:end+1])))
+				i += end + 2
+				continue
+			}
+
+		case rest[0] == '[' && links:
+			if label, target, length, ok := parseLink(rest); ok {
+				renderedLabel := inline(label, plain, false)
+				if plain || !SafeURL(target) {
+					output.WriteString(renderedLabel)
+				} else {
+					output.WriteString(fmt.Sprintf(`<a href="%s" rel="noopener noreferrer nofollow">%s</a>`,
+						html.EscapeString(target), renderedLabel))
+				}
+				i += length
+				continue
+			}
+
+		case strings.HasPrefix(rest, "**") || strings.HasPrefix(rest, "__"):
+			if end := strings.Index(rest[2:], rest[:2]); end > 0 {
+				output.WriteString(wrap("strong", inline(rest[2:end+2], plain, links)))
+				i += end + 4
+				continue
+			}
+
+		case rest[0] == '*' || (rest[0] == '_' && (i == 0 || !isWordByte(text[i-1]))):
+			if end := closingEmphasis(rest); end > 0 {
+				output.WriteString(wrap("em", inline(rest[1:end], plain, links)))
+				i += end + 1
+				continue
+			}
+		}
+
+		output.WriteString(escape(rest[:1]))
+		i++
+	}
+
+	return output.String()
+}
+
+// Parses a [label](target) link at the start of the text,
+// returning the length of the whole link
+func parseLink(text string) (string, string, int, bool) {
+	labelEnd := strings.Index(text, "](")
+	if labelEnd <= 1 {
+		return "", "", 0, false
+	}
+	// Parentheses inside of the target have to be balanced
+	targetEnd := -1
+	depth := 0
+	for i, c := range text[labelEnd+2:] {
+		if c == '(' {
+			depth++
+		} else if c == ')' {
+			if depth == 0 {
+				targetEnd = i
+				break
+			}
+			depth--
+		}
+	}
+	if targetEnd <= 0 {
+		return "", "", 0, false
+	}
+
+	label := text[1:labelEnd]
+	target := strings.TrimSpace(text[labelEnd+2 : labelEnd+2+targetEnd])
+	return label, target, labelEnd + 3 + targetEnd, true
+}
+
+// Finds the closing delimiter of *emphasis* or _emphasis_,
+// which can't be right inside of the delimiters
+// (and for underscores, can't be followed by a letter or number)
+func closingEmphasis(text string) int {
+	delimiter := text[0]
+	if len(text) < 3 || text[1] == ' ' || text[1] == delimiter {
+		return -1
+	}
+
+	for end := 2; end < len(text); end++ {
+		if text[end] != delimiter || text[end-1] == ' ' {
+			continue
+		}
+		if delimiter == '_' && end+1 < len(text) && isWordByte(text[end+1]) {
+			continue
+		}
+		return end
+	}
+
+	return -1
+}
+
+func isWordByte(b byte) bool {
+	return b == '_' || b >= '0' && b <= '9' || b >= 'a' && b <= 'z' || b >= 'A' && b <= 'Z'
+}
@@ -0,0 +1,86 @@
+package markdown
+
+import "testing"
+
+func TestRender(t *testing.T) {
+	tests := []struct {
+		name     string
+		source   string
+		expected string
+	}{
+		{"paragraph", "hello\nworld", "<p>hello<br>\nworld</p>"},
+		{"heading", "## Hours", "<h2>Hours</h2>"},
+		{"emphasis", "**bold** and *italic* and _also_", "<p><strong>bold</strong> and <em>italic</em> and <em>also</em></p>"},
+		{"snake case", "snake_case_name", "<p>snake_case_name</p>"},
+		{"code", "`a < b`", "<p><code>a &lt; b</code></p>"},
+		{"unordered list", "- one\n* two", "<ul>\n<li>one</li>\n<li>two</li>\n</ul>"},
+		{"ordered list", "1. one\n2. two", "<ol>\n<li>one</li>\n<li>two</li>\n</ol>"},
+		{"raw html", "<script>alert(1)</script>", "<p>&lt;script&gt;alert(1)&lt;/script&gt;</p>"},
+		{"attribute quotes", `"quoted" & 'single'`, "<p>&#34;quoted&#34; &amp; &#39;single&#39;</p>"},
+		{"backslash escape", `\*not italic\*`, "<p>*not italic*</p>"},
+		{"link", "[menu](https://example.com/a?b=1&c=2)",
+			`<p><a href="https://example.com/a?b=1&amp;c=2" rel="noopener noreferrer nofollow">menu</a></p>`},
+		{"javascript link", "[click](javascript:alert(1))", "<p>click</p>"},
+		{"link with quote", `[x](https://example.com/"onmouseover="a)`,
+			`<p><a href="https://example.com/&#34;onmouseover=&#34;a" rel="noopener noreferrer nofollow">x</a></p>`},
+		{"formatted link", "[**menu**](https://example.com)",
+			`<p><a href="https://example.com" rel="noopener noreferrer nofollow"><strong>menu</strong></a></p>`},
+		{"control characters", "a\x00b\r\nc", "<p>ab<br>\nc</p>"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if actual := Render(test.source); actual != test.expected {
+				t.Errorf("expected %q, got %q", test.expected, actual)
+			}
+		})
+	}
+}
+
+func TestPlainText(t *testing.T) {
+	tests := []struct {
+		name     string
+		source   string
+		expected string
+	}{
+		{"formatting", "# Open\n**Now** serving `soup`", "Open\n\nNow serving soup"},
+		{"link", "[menu](https://example.com)", "menu"},
+		{"raw html", "<b>hi</b>", "<b>hi</b>"},
+		{"list", "- one\n- two", "- one\n- two"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if actual := PlainText(test.source); actual != test.expected {
+				t.Errorf("expected %q, got %q", test.expected, actual)
+			}
+		})
+	}
+}
+
+func TestSafeURL(t *testing.T) {
+	tests := []struct {
+		url      string
+		expected bool
+	}{
+		{"https://example.com", true},
+		{"HTTP://example.com/path", true},
+		{"mailto:kitchen@example.com", true},
+		{"javascript:alert(1)", false},
+		{"JavaScript:alert(1)", false},
+		{"data:text/html,<script>alert(1)</script>", false},
+		{"//example.com", false},
+		{"/relative/path", false},
+		{"https://", false},
+		{"mailto:", false},
+		{"", false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.url, func(t *testing.T) {
+			if actual := SafeURL(test.url); actual != test.expected {
+				t.Errorf("expected %t, got %t", test.expected, actual)
+			}
+		})
+	}
+}
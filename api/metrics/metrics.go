@@ -0,0 +1,147 @@
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/go-chi/chi/middleware"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "klemis"
+
+var (
+	// HTTPRequests counts handled HTTP requests by route pattern and status code
+	HTTPRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "http",
+		Name:      "requests_total",
+		Help:      "Number of HTTP requests handled, by method, chi route pattern, and status code",
+	}, []string{"method", "route", "status"})
+
+	// HTTPRequestDuration tracks the latency of handled HTTP requests by route pattern
+	HTTPRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "http",
+		Name:      "request_duration_seconds",
+		Help:      "Latency of HTTP requests, by method and chi route pattern",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route"})
+
+	// TransactFetchDuration tracks how long each full fetch of the Transact inventory report takes
+	TransactFetchDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "transact",
+		Name:      "fetch_duration_seconds",
+		Help:      "Time taken to generate, download, and parse the Transact inventory report, by result",
+		Buckets:   []float64{1, 5, 10, 30, 60, 120, 300, 600},
+	}, []string{"result"})
+
+	// TransactReportPolls counts each poll made while waiting for a report to be generated
+	TransactReportPolls = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "transact",
+		Name:      "report_polls_total",
+		Help:      "Number of times the Transact API was polled for report readiness, by outcome",
+	}, []string{"outcome"})
+
+	// TransactReportRows records the number of rows in the most recent report,
+	// both in total and those that were imported into the cache
+	TransactReportRows = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "transact",
+		Name:      "report_rows",
+		Help:      "Number of rows in the most recently fetched Transact report, by kind ('raw' or 'imported')",
+	}, []string{"kind"})
+
+	// TransactSessionReloads counts Transact session reload attempts by outcome
+	TransactSessionReloads = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "transact",
+		Name:      "session_reloads_total",
+		Help:      "Number of Transact session reload attempts, by outcome",
+	}, []string{"outcome"})
+
+	// DBOperationDuration tracks the latency of each database provider method
+	DBOperationDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "db",
+		Name:      "operation_duration_seconds",
+		Help:      "Latency of database operations, by provider method",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method"})
+)
+
+// Handler returns the HTTP handler that exposes all registered metrics
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
+
+// Outcome converts an error into the label value used for outcomes
+func Outcome(err error) string {
+	if err != nil {
+		return "failure"
+	}
+
+	return "success"
+}
+
+// TrackCacheAge registers gauges that report whether the given cache has been loaded
+// and how long ago it was loaded.
+// This should only be called once
+func TrackCacheAge(loadedAt func() (time.Time, bool)) {
+	promauto.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "transact",
+		Name:      "cache_loaded",
+		Help:      "Whether the partial product cache has been loaded at least once (1) or not (0)",
+	}, func() float64 {
+		if _, loaded := loadedAt(); loaded {
+			return 1
+		}
+		return 0
+	})
+
+	promauto.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "transact",
+		Name:      "cache_age_seconds",
+		Help:      "Time since the partial product cache was last loaded (0 if it never was)",
+	}, func() float64 {
+		if at, loaded := loadedAt(); loaded {
+			return time.Since(at).Seconds()
+		}
+		return 0
+	})
+}
+
+// Middleware records the count and latency of each HTTP request,
+// labelled with the chi route pattern that handled it
+// (so that URL parameters don't each create their own series)
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+		next.ServeHTTP(ww, r)
+
+		// The route pattern is only complete once routing has finished
+		route := "unmatched"
+		if routeContext := chi.RouteContext(r.Context()); routeContext != nil {
+			if pattern := routeContext.RoutePattern(); pattern != "" {
+				route = pattern
+			}
+		}
+
+		status := ww.Status()
+		if status == 0 {
+			status = http.StatusOK
+		}
+
+		HTTPRequests.WithLabelValues(r.Method, route, strconv.Itoa(status)).Inc()
+		HTTPRequestDuration.WithLabelValues(r.Method, route).Observe(time.Since(start).Seconds())
+	})
+}
@@ -0,0 +1,30 @@
+package notify
+
+import (
+	"context"
+
+	"github.com/rs/zerolog"
+)
+
+// LogNotifier is a notifier that only logs each notification,
+// useful for development and for deployments without a delivery service
+type LogNotifier struct {
+	logger zerolog.Logger
+}
+
+// NewLogNotifier creates the notifier
+func NewLogNotifier(logger zerolog.Logger) *LogNotifier {
+	return &LogNotifier{logger: logger}
+}
+
+// Notify logs the notification
+func (n *LogNotifier) Notify(ctx context.Context, notification Notification) error {
+	n.logger.
+		Info().
+		Str("username", notification.Username).
+		Str("kind", notification.Kind).
+		Str("title", notification.Title).
+		Str("body", notification.Body).
+		Msg("notification")
+	return nil
+}
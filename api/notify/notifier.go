@@ -0,0 +1,56 @@
+package notify
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"github.com/jd-116/klemis-kitchen-api/config"
+	"github.com/jd-116/klemis-kitchen-api/db"
+)
+
+// Notification is a single message to deliver to a single user
+type Notification struct {
+	Username  string            `json:"username"`
+	Kind      string            `json:"kind"`
+	Title     string            `json:"title"`
+	Body      string            `json:"body"`
+	Data      map[string]string `json:"data,omitempty"`
+	CreatedAt time.Time         `json:"created_at"`
+
+	// How many times delivery has been attempted
+	attempts int
+
+	// The device tokens that failed on the last attempt;
+	// nil means every device of the user
+	retryTokens []string
+}
+
+// Kinds of notifications
+const (
+	KindRestock = "restock"
+)
+
+// Notifier represents a way of delivering notifications to users
+type Notifier interface {
+	Notify(ctx context.Context, notification Notification) error
+}
+
+// NewNotifier creates the notifier selected in the notification configuration:
+// either "log", "webhook", or "push"
+func NewNotifier(database db.DeviceProvider, pushClient *PushClient, cfg config.NotificationConfig,
+	logger zerolog.Logger) (Notifier, error) {
+
+	switch kind := cfg.Notifier; kind {
+	case "log":
+		return NewLogNotifier(logger), nil
+	case "webhook":
+		return NewWebhookNotifier(cfg), nil
+	case "push":
+		return NewPushNotifier(database, pushClient), nil
+	default:
+		return nil, fmt.Errorf("unknown notifier '%s' ('NOTIFIER'); expected 'log', 'webhook', or 'push'", kind)
+	}
+}
@@ -0,0 +1,262 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/jd-116/klemis-kitchen-api/config"
+	"github.com/jd-116/klemis-kitchen-api/db"
+	"github.com/jd-116/klemis-kitchen-api/tracing"
+)
+
+// The most messages the push service accepts in a single request
+const maxPushBatchSize = 100
+
+// Ticket errors that the push service reports for individual messages
+const (
+	pushErrorDeviceNotRegistered = "DeviceNotRegistered"
+	pushErrorMessageRateExceeded = "MessageRateExceeded"
+
+	// Not reported by the push service; marks the messages of a batch
+	// that the service didn't accept at all
+	pushErrorBatchFailed = "BatchFailed"
+)
+
+// PushMessage is a single push notification to a single device,
+// in the format of the Expo push API
+type PushMessage struct {
+	To    string            `json:"to"`
+	Title string            `json:"title"`
+	Body  string            `json:"body"`
+	Data  map[string]string `json:"data,omitempty"`
+	Sound string            `json:"sound,omitempty"`
+}
+
+// PushTicket is the push service's result for a single message
+type PushTicket struct {
+	Status  string `json:"status"`
+	ID      string `json:"id,omitempty"`
+	Message string `json:"message,omitempty"`
+	Details struct {
+		Error string `json:"error,omitempty"`
+	} `json:"details"`
+}
+
+// OK determines whether the message was accepted
+func (t PushTicket) OK() bool {
+	return t.Status == "ok"
+}
+
+// Unregistered determines whether the message failed
+// because the device's token is no longer valid
+func (t PushTicket) Unregistered() bool {
+	return t.Details.Error == pushErrorDeviceNotRegistered
+}
+
+// Retryable determines whether the message failed in a way
+// that may succeed if sent again later
+func (t PushTicket) Retryable() bool {
+	return t.Details.Error == pushErrorMessageRateExceeded || t.Details.Error == pushErrorBatchFailed
+}
+
+// Creates the ticket of a message whose batch wasn't accepted
+func failedBatchTicket(err error) PushTicket {
+	ticket := PushTicket{Status: "error", Message: err.Error()}
+	ticket.Details.Error = pushErrorBatchFailed
+	return ticket
+}
+
+// PushClient sends push messages to an Expo/FCM-style HTTP push service,
+// which by default is the Expo push API
+// but can be pointed at any compatible service (such as a local stub)
+type PushClient struct {
+	url         string
+	accessToken string
+	httpClient  *http.Client
+}
+
+// NewPushClient creates the client from the push configuration
+func NewPushClient(cfg config.PushConfig) *PushClient {
+	return &PushClient{
+		url:         cfg.ServiceURL,
+		accessToken: cfg.AccessToken,
+		httpClient:  &http.Client{Timeout: time.Duration(cfg.ServiceTimeout)},
+	}
+}
+
+// Send sends the messages in batches, returning one ticket per message (in order)
+// even if some batches fail.
+// The messages of a batch that wasn't accepted at all get retryable tickets,
+// and the last such batch's error is returned
+func (c *PushClient) Send(ctx context.Context, messages []PushMessage) ([]PushTicket, error) {
+	tickets := []PushTicket{}
+	var sendErr error
+	for start := 0; start < len(messages); start += maxPushBatchSize {
+		end := start + maxPushBatchSize
+		if end > len(messages) {
+			end = len(messages)
+		}
+
+		batchTickets, err := c.sendBatch(ctx, messages[start:end])
+		if err != nil {
+			sendErr = err
+			batchTickets = make([]PushTicket, end-start)
+			for i := range batchTickets {
+				batchTickets[i] = failedBatchTicket(err)
+			}
+		}
+		tickets = append(tickets, batchTickets...)
+	}
+
+	return tickets, sendErr
+}
+
+func (c *PushClient) sendBatch(ctx context.Context, messages []PushMessage) (tickets []PushTicket, err error) {
+	ctx, span := tracing.Start(ctx, "notify.Push")
+	defer func() { tracing.End(span, err) }()
+
+	body, err := json.Marshal(messages)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, c.url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+	if c.accessToken != "" {
+		req.Header.Set("Authorization", "Bearer "+c.accessToken)
+	}
+
+	res, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		return nil, fmt.Errorf("push service responded with status %d", res.StatusCode)
+	}
+
+	var response struct {
+		Data []PushTicket `json:"data"`
+	}
+	err = json.NewDecoder(res.Body).Decode(&response)
+	if err != nil {
+		return nil, err
+	}
+	if len(response.Data) != len(messages) {
+		return nil, fmt.Errorf("push service returned %d tickets for %d messages",
+			len(response.Data), len(messages))
+	}
+
+	return response.Data, nil
+}
+
+// PushNotifier is a notifier that sends each notification
+// to every device the user has registered
+type PushNotifier struct {
+	database db.DeviceProvider
+	client   *PushClient
+}
+
+// NewPushNotifier creates the notifier
+func NewPushNotifier(database db.DeviceProvider, client *PushClient) *PushNotifier {
+	return &PushNotifier{
+		database: database,
+		client:   client,
+	}
+}
+
+// Notify sends the notification to the user's devices,
+// forgetting any devices whose tokens are no longer registered.
+// If some messages fail, it returns a RetryError
+// so that only their devices are retried
+func (n *PushNotifier) Notify(ctx context.Context, notification Notification) error {
+	devices, err := n.database.GetUserDevices(ctx, notification.Username)
+	if err != nil {
+		return err
+	}
+
+	// A retried notification is only sent to the devices that failed before
+	var retrying map[string]struct{}
+	if notification.retryTokens != nil {
+		retrying = make(map[string]struct{})
+		for _, token := range notification.retryTokens {
+			retrying[token] = struct{}{}
+		}
+	}
+
+	data := map[string]string{"kind": notification.Kind}
+	for key, value := range notification.Data {
+		data[key] = value
+	}
+
+	messages := []PushMessage{}
+	for _, device := range devices {
+		if retrying != nil {
+			if _, ok := retrying[device.Token]; !ok {
+				continue
+			}
+		}
+
+		messages = append(messages, PushMessage{
+			To:    device.Token,
+			Title: notification.Title,
+			Body:  notification.Body,
+			Data:  data,
+		})
+	}
+	if len(messages) == 0 {
+		return nil
+	}
+
+	tickets, sendErr := n.client.Send(ctx, messages)
+
+	unregistered := []string{}
+	failed := []string{}
+	for i, ticket := range tickets {
+		if ticket.Unregistered() {
+			unregistered = append(unregistered, messages[i].To)
+		} else if !ticket.OK() {
+			failed = append(failed, messages[i].To)
+			if sendErr == nil {
+				sendErr = fmt.Errorf("push service rejected a message: %s", ticket.Message)
+			}
+		}
+	}
+
+	if len(unregistered) > 0 {
+		err = n.database.DeleteDevicesByToken(ctx, unregistered)
+		if err != nil {
+			return err
+		}
+	}
+
+	if len(failed) > 0 {
+		return &RetryError{Tokens: failed, Err: sendErr}
+	}
+	return nil
+}
+
+// RetryError is returned by a notifier when only some of a notification's devices failed,
+// so that retrying it only sends to those devices
+type RetryError struct {
+	Tokens []string
+	Err    error
+}
+
+func (e *RetryError) Error() string {
+	return fmt.Sprintf("could not notify %d devices: %v", len(e.Tokens), e.Err)
+}
+
+func (e *RetryError) Unwrap() error {
+	return e.Err
+}
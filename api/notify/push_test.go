@@ -0,0 +1,57 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/jd-116/klemis-kitchen-api/config"
+)
+
+func TestPushClientSend(t *testing.T) {
+	// Fails the second batch and accepts the rest
+	batches := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		batches++
+		if batches == 2 {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+
+		var messages []PushMessage
+		json.NewDecoder(r.Body).Decode(&messages)
+		tickets := []PushTicket{}
+		for range messages {
+			tickets = append(tickets, PushTicket{Status: "ok"})
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"data": tickets})
+	}))
+	defer server.Close()
+
+	client := NewPushClient(config.PushConfig{ServiceURL: server.URL})
+	messages := []PushMessage{}
+	for i := 0; i < 2*maxPushBatchSize+10; i++ {
+		messages = append(messages, PushMessage{To: fmt.Sprintf("token-%d", i)})
+	}
+
+	tickets, err := client.Send(context.Background(), messages)
+	if err == nil {
+		t.Error("expected the failed batch's error")
+	}
+	if len(tickets) != len(messages) {
+		t.Fatalf("expected %d tickets, got %d", len(messages), len(tickets))
+	}
+
+	for i, ticket := range tickets {
+		failedBatch := i >= maxPushBatchSize && i < 2*maxPushBatchSize
+		if failedBatch && (ticket.OK() || !ticket.Retryable()) {
+			t.Errorf("ticket %d: expected a retryable failure, got %+v", i, ticket)
+		}
+		if !failedBatch && !ticket.OK() {
+			t.Errorf("ticket %d: expected ok, got %+v", i, ticket)
+		}
+	}
+}
@@ -0,0 +1,165 @@
+package notify
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"github.com/jd-116/klemis-kitchen-api/config"
+)
+
+// Queue delivers notifications through a notifier in the background,
+// so that whatever produces them never waits on delivery.
+// Failed deliveries are retried with exponential backoff
+type Queue struct {
+	notifier      Notifier
+	notifications chan Notification
+	stop          chan struct{}
+
+	// Config values
+	maxAttempts int
+	retryDelay  time.Duration
+
+	// Timers of the failed notifications waiting to be retried,
+	// which are stopped when disconnecting
+	retriesLock sync.Mutex
+	retries     map[*time.Timer]struct{}
+	stopped     bool
+
+	logger zerolog.Logger
+}
+
+// NewQueue creates the queue from the notification configuration
+// (doesn't start goroutines)
+func NewQueue(notifier Notifier, cfg config.NotificationConfig, logger zerolog.Logger) *Queue {
+	return &Queue{
+		notifier:      notifier,
+		notifications: make(chan Notification, cfg.QueueSize),
+		stop:          make(chan struct{}),
+
+		maxAttempts: cfg.MaxAttempts,
+		retryDelay:  time.Duration(cfg.RetryDelay),
+
+		retries: make(map[*time.Timer]struct{}),
+		logger:  logger,
+	}
+}
+
+// Connect starts the goroutine that delivers queued notifications
+func (q *Queue) Connect(ctx context.Context) error {
+	go q.deliverAll()
+	return nil
+}
+
+// Disconnect stops the delivery goroutine;
+// notifications still in the queue or waiting to be retried are dropped
+func (q *Queue) Disconnect(ctx context.Context) error {
+	q.retriesLock.Lock()
+	q.stopped = true
+	for timer := range q.retries {
+		timer.Stop()
+	}
+	q.retries = make(map[*time.Timer]struct{})
+	q.retriesLock.Unlock()
+
+	q.stop <- struct{}{}
+	return nil
+}
+
+// Enqueue adds a notification to the queue without blocking,
+// returning false if the queue is full and the notification was dropped
+func (q *Queue) Enqueue(notification Notification) bool {
+	if notification.CreatedAt.IsZero() {
+		notification.CreatedAt = time.Now()
+	}
+
+	select {
+	case q.notifications <- notification:
+		return true
+	default:
+		q.logger.
+			Warn().
+			Str("username", notification.Username).
+			Str("kind", notification.Kind).
+			Msg("notification queue is full; dropping notification")
+		return false
+	}
+}
+
+func (q *Queue) deliverAll() {
+	for {
+		select {
+		case <-q.stop:
+			return
+		case notification := <-q.notifications:
+			q.deliver(notification)
+		}
+	}
+}
+
+func (q *Queue) deliver(notification Notification) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	notification.attempts++
+	err := q.notifier.Notify(ctx, notification)
+	if err == nil {
+		return
+	}
+
+	if notification.attempts >= q.maxAttempts {
+		q.logger.
+			Error().
+			Err(err).
+			Str("username", notification.Username).
+			Str("kind", notification.Kind).
+			Int("attempts", notification.attempts).
+			Msg("could not deliver notification; giving up")
+		return
+	}
+
+	// Only retry the devices that failed
+	var retryErr *RetryError
+	if errors.As(err, &retryErr) {
+		notification.retryTokens = retryErr.Tokens
+	}
+
+	// Wait twice as long after each failed attempt
+	delay := q.retryDelay * time.Duration(1<<uint(notification.attempts-1))
+	q.logger.
+		Warn().
+		Err(err).
+		Str("username", notification.Username).
+		Str("kind", notification.Kind).
+		Int("attempts", notification.attempts).
+		Dur("retry_in", delay).
+		Msg("could not deliver notification; retrying")
+	q.retry(notification, delay)
+}
+
+// Enqueues the notification again after the delay,
+// unless the queue is disconnected first
+func (q *Queue) retry(notification Notification, delay time.Duration) {
+	q.retriesLock.Lock()
+	defer q.retriesLock.Unlock()
+
+	if q.stopped {
+		return
+	}
+
+	var timer *time.Timer
+	timer = time.AfterFunc(delay, func() {
+		q.retriesLock.Lock()
+		_, pending := q.retries[timer]
+		delete(q.retries, timer)
+		q.retriesLock.Unlock()
+
+		if pending {
+			q.Enqueue(notification)
+		}
+	})
+	q.retries[timer] = struct{}{}
+}
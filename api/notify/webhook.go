@@ -0,0 +1,59 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/jd-116/klemis-kitchen-api/config"
+	"github.com/jd-116/klemis-kitchen-api/tracing"
+)
+
+// WebhookNotifier is a notifier that POSTs each notification as JSON
+// to a URL, leaving the actual delivery to whatever service receives it
+type WebhookNotifier struct {
+	url        string
+	httpClient *http.Client
+}
+
+// NewWebhookNotifier creates the notifier from the notification configuration
+func NewWebhookNotifier(cfg config.NotificationConfig) *WebhookNotifier {
+	return &WebhookNotifier{
+		url:        cfg.WebhookURL,
+		httpClient: &http.Client{Timeout: time.Duration(cfg.WebhookTimeout)},
+	}
+}
+
+// Notify sends the notification to the webhook,
+// failing if it doesn't respond with a 2xx status code
+func (n *WebhookNotifier) Notify(ctx context.Context, notification Notification) (err error) {
+	ctx, span := tracing.Start(ctx, "notify.Webhook")
+	defer func() { tracing.End(span, err) }()
+
+	body, err := json.Marshal(notification)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, n.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "application/json")
+
+	res, err := n.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		return fmt.Errorf("notification webhook responded with status %d", res.StatusCode)
+	}
+
+	return nil
+}
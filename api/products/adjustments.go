@@ -0,0 +1,79 @@
+package products
+
+import (
+	"context"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"github.com/jd-116/klemis-kitchen-api/db"
+	"github.com/jd-116/klemis-kitchen-api/types"
+)
+
+// AdjustmentSource provides everything needed to resolve the adjustments
+// at each location into the location identifiers used by partial product providers
+type AdjustmentSource interface {
+	db.AdjustmentProvider
+	db.LocationProvider
+}
+
+// SyncAdjustments loads the manual adjustments that haven't expired
+// from the database into the provider.
+// This should be called on startup and after any adjustment is created or deleted
+func SyncAdjustments(ctx context.Context, source AdjustmentSource, provider AdjustableProvider) error {
+	locations, err := source.GetAllLocations(ctx)
+	if err != nil {
+		return err
+	}
+
+	adjustments, err := source.GetActiveAdjustments(ctx, time.Now())
+	if err != nil {
+		return err
+	}
+
+	// Adjustments are stored by location ID,
+	// but the provider is keyed by inventory identifier
+	identifiers := make(map[string]string)
+	for _, location := range locations {
+		identifiers[location.ID] = location.InventoryIdentifier()
+	}
+
+	byIdentifier := make(map[string][]types.Adjustment)
+	for _, adjustment := range adjustments {
+		if identifier, ok := identifiers[adjustment.LocationID]; ok {
+			byIdentifier[identifier] = append(byIdentifier[identifier], adjustment)
+		}
+	}
+
+	provider.LoadAdjustments(byIdentifier)
+	return nil
+}
+
+// PersistExpiredAdjustments creates an expiry listener
+// that saves when each adjustment was expired,
+// so that it stays expired even if Transact's amount returns to its base amount.
+// Failures are only logged, since the adjustment is retried on the next load
+func PersistExpiredAdjustments(database db.AdjustmentProvider, logger zerolog.Logger) ExpiryListener {
+	return func(adjustments []types.Adjustment) {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+		defer cancel()
+
+		for _, adjustment := range adjustments {
+			err := database.ExpireAdjustment(ctx, adjustment.ID, *adjustment.ExpiredAt)
+			if err != nil {
+				logger.
+					Error().
+					Err(err).
+					Str("adjustment_id", adjustment.ID).
+					Msg("could not save the expiry of an inventory adjustment")
+				continue
+			}
+
+			logger.
+				Info().
+				Str("adjustment_id", adjustment.ID).
+				Str("product_id", adjustment.ProductID).
+				Msg("expired inventory adjustment after Transact changed")
+		}
+	}
+}
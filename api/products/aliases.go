@@ -0,0 +1,191 @@
+package products
+
+import (
+	"context"
+
+	"github.com/jd-116/klemis-kitchen-api/db"
+	"github.com/jd-116/klemis-kitchen-api/types"
+)
+
+// AliasSource provides everything needed to resolve the aliases of canonical products
+// into the location identifiers used by partial product providers
+type AliasSource interface {
+	db.CanonicalProductProvider
+	db.LocationProvider
+}
+
+// Aliases resolves the product IDs at each location
+// to the canonical products that they were merged into
+type Aliases struct {
+	canonicalProducts map[string]*types.CanonicalProduct
+	// Location identifier -> aliased product ID -> canonical product
+	byLocation map[string]map[string]*types.CanonicalProduct
+	// Canonical product ID -> location identifier -> aliased product IDs
+	byCanonical map[string]map[string][]string
+}
+
+// LoadAliases loads all canonical products from the database
+// and resolves their aliases
+func LoadAliases(ctx context.Context, source AliasSource) (*Aliases, error) {
+	locations, err := source.GetAllLocations(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	canonicalProducts, err := source.GetAllCanonicalProducts(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewAliases(canonicalProducts, locations), nil
+}
+
+// NewAliases resolves the aliases of the given canonical products.
+// Aliases at locations that no longer exist are ignored
+func NewAliases(canonicalProducts []types.CanonicalProduct, locations []types.Location) *Aliases {
+	// Aliases are stored by location ID,
+	// but the provider is keyed by inventory identifier
+	identifiers := make(map[string]string)
+	for _, location := range locations {
+		identifiers[location.ID] = location.InventoryIdentifier()
+	}
+
+	aliases := &Aliases{
+		canonicalProducts: make(map[string]*types.CanonicalProduct),
+		byLocation:        make(map[string]map[string]*types.CanonicalProduct),
+		byCanonical:       make(map[string]map[string][]string),
+	}
+	for i := range canonicalProducts {
+		canonicalProduct := &canonicalProducts[i]
+		aliases.canonicalProducts[canonicalProduct.ID] = canonicalProduct
+		aliases.byCanonical[canonicalProduct.ID] = make(map[string][]string)
+
+		for _, alias := range canonicalProduct.Aliases {
+			identifier, ok := identifiers[alias.LocationID]
+			if !ok {
+				continue
+			}
+
+			if _, ok := aliases.byLocation[identifier]; !ok {
+				aliases.byLocation[identifier] = make(map[string]*types.CanonicalProduct)
+			}
+			aliases.byLocation[identifier][alias.ProductID] = canonicalProduct
+			aliases.byCanonical[canonicalProduct.ID][identifier] = append(
+				aliases.byCanonical[canonicalProduct.ID][identifier], alias.ProductID)
+		}
+	}
+
+	return aliases
+}
+
+// Canonical gets the canonical product with the given ID,
+// or false as the second value if there isn't one
+func (a *Aliases) Canonical(id string) (*types.CanonicalProduct, bool) {
+	canonicalProduct, ok := a.canonicalProducts[id]
+	return canonicalProduct, ok
+}
+
+// Resolve gets the canonical product that the product ID at the location identifier
+// was merged into, or false as the second value if it isn't aliased there
+func (a *Aliases) Resolve(location string, id string) (*types.CanonicalProduct, bool) {
+	canonicalProduct, ok := a.byLocation[location][id]
+	return canonicalProduct, ok
+}
+
+// Has determines whether the canonical product has any aliases at the location identifier
+func (a *Aliases) Has(location string, canonicalID string) bool {
+	return len(a.byCanonical[canonicalID][location]) > 0
+}
+
+// Members gets the product IDs in the partial products provider
+// that the ID refers to at the location identifier:
+// the IDs of its aliases there if it's a canonical product
+// (which might be none), or else just the ID itself
+func (a *Aliases) Members(location string, id string) []string {
+	if _, ok := a.canonicalProducts[id]; ok {
+		return a.byCanonical[id][location]
+	}
+
+	return []string{id}
+}
+
+// CanonicalID gets the ID that the product ID at the location identifier is listed under:
+// the ID of the canonical product it was merged into, or else the ID itself.
+// Canonical product IDs are returned as-is
+func (a *Aliases) CanonicalID(location string, id string) string {
+	if canonicalProduct, ok := a.byLocation[location][id]; ok {
+		return canonicalProduct.ID
+	}
+
+	return id
+}
+
+// Merge replaces every aliased product at the location identifier
+// with its canonical product, adding up the amounts of aliases
+// that are merged into the same canonical product
+// and keeping their names from Transact.
+// Products keep the order of their first appearance
+func (a *Aliases) Merge(location string, partialProducts []PartialProduct) []PartialProduct {
+	locationAliases := a.byLocation[location]
+	if len(locationAliases) == 0 {
+		return partialProducts
+	}
+
+	merged := []PartialProduct{}
+	positions := make(map[string]int)
+	for _, partialProduct := range partialProducts {
+		if canonicalProduct, ok := locationAliases[partialProduct.ID]; ok {
+			partialProduct.TransactNames = []string{partialProduct.Name}
+			partialProduct.ID = canonicalProduct.ID
+			partialProduct.Name = canonicalProduct.Name
+		}
+
+		if position, ok := positions[partialProduct.ID]; ok {
+			merged[position].Amount += partialProduct.Amount
+			merged[position].TransactNames = append(merged[position].TransactNames, partialProduct.TransactNames...)
+			continue
+		}
+
+		positions[partialProduct.ID] = len(merged)
+		merged = append(merged, partialProduct)
+	}
+
+	return merged
+}
+
+// GetProduct gets a single partial product from the given location with the given ID,
+// where the ID can be a canonical product
+// (adding up the amounts of each of its aliases at the location)
+func (a *Aliases) GetProduct(provider PartialProductProvider, location string, id string) (*PartialProduct, error) {
+	canonicalProduct, ok := a.canonicalProducts[id]
+	if !ok {
+		return provider.GetProduct(location, id)
+	}
+
+	result := &PartialProduct{
+		ID:            canonicalProduct.ID,
+		Name:          canonicalProduct.Name,
+		TransactNames: []string{},
+	}
+	found := false
+	for _, productID := range a.Members(location, id) {
+		partialProduct, err := provider.GetProduct(location, productID)
+		if err != nil {
+			if _, ok := err.(*PartialProductNotFoundError); ok {
+				continue
+			}
+
+			return nil, err
+		}
+
+		found = true
+		result.Amount += partialProduct.Amount
+		result.TransactNames = append(result.TransactNames, partialProduct.Name)
+	}
+
+	if !found {
+		return nil, NewPartialProductNotFoundError(location, id)
+	}
+
+	return result, nil
+}
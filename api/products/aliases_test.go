@@ -0,0 +1,129 @@
+package products
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/jd-116/klemis-kitchen-api/types"
+)
+
+func newTestAliases() *Aliases {
+	locations := []types.Location{
+		{ID: "north", TransactIdentifier: "NORTH"},
+		{ID: "south", TransactIdentifier: "SOUTH"},
+	}
+	canonicalProducts := []types.CanonicalProduct{{
+		ID:   "canonical-milk",
+		Name: "Milk",
+		Aliases: []types.ProductAlias{
+			{LocationID: "north", ProductID: "1001"},
+			{LocationID: "north", ProductID: "1002"},
+			{LocationID: "south", ProductID: "2001"},
+		},
+	}}
+
+	return NewAliases(canonicalProducts, locations)
+}
+
+func TestAliasesMembers(t *testing.T) {
+	aliases := newTestAliases()
+
+	tests := []struct {
+		location string
+		id       string
+		expected []string
+	}{
+		{"NORTH", "canonical-milk", []string{"1001", "1002"}},
+		{"SOUTH", "canonical-milk", []string{"2001"}},
+		{"EAST", "canonical-milk", nil},
+		{"NORTH", "1001", []string{"1001"}},
+		{"NORTH", "3000", []string{"3000"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.location+"/"+test.id, func(t *testing.T) {
+			if actual := aliases.Members(test.location, test.id); !reflect.DeepEqual(actual, test.expected) {
+				t.Errorf("Members() = %v, expected %v", actual, test.expected)
+			}
+		})
+	}
+}
+
+func TestAliasesCanonicalID(t *testing.T) {
+	aliases := newTestAliases()
+
+	tests := []struct {
+		location string
+		id       string
+		expected string
+	}{
+		{"NORTH", "1001", "canonical-milk"},
+		{"NORTH", "1002", "canonical-milk"},
+		{"SOUTH", "2001", "canonical-milk"},
+		// Aliases only apply at their own location
+		{"SOUTH", "1001", "1001"},
+		{"NORTH", "canonical-milk", "canonical-milk"},
+		{"NORTH", "3000", "3000"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.location+"/"+test.id, func(t *testing.T) {
+			if actual := aliases.CanonicalID(test.location, test.id); actual != test.expected {
+				t.Errorf("CanonicalID() = '%s', expected '%s'", actual, test.expected)
+			}
+		})
+	}
+}
+
+// Regression test for canonical IDs from product lists
+// not being found in the inventory by the write paths
+func TestAliasesGetProductByCanonicalID(t *testing.T) {
+	aliases := newTestAliases()
+	cache := &Cache{}
+	cache.Load(map[string]map[string]PartialProduct{
+		"NORTH": {
+			"1001": {ID: "1001", Name: "MILK 1% GAL", Amount: 2},
+			"1002": {ID: "1002", Name: "MILK 2% GAL", Amount: 3},
+			"3000": {ID: "3000", Name: "BREAD", Amount: 4},
+		},
+	})
+
+	partialProduct, err := aliases.GetProduct(cache, "NORTH", "canonical-milk")
+	if err != nil {
+		t.Fatalf("GetProduct() returned an error: %v", err)
+	}
+	if partialProduct.ID != "canonical-milk" || partialProduct.Name != "Milk" || partialProduct.Amount != 5 {
+		t.Errorf("GetProduct() = %+v, expected canonical-milk named Milk with 5", partialProduct)
+	}
+	if name := partialProduct.TransactName(); name != "MILK 1% GAL / MILK 2% GAL" {
+		t.Errorf("TransactName() = '%s', expected the names from Transact", name)
+	}
+
+	_, err = aliases.GetProduct(cache, "NORTH", "canonical-eggs")
+	if _, ok := err.(*PartialProductNotFoundError); !ok {
+		t.Errorf("GetProduct() error = %v, expected a PartialProductNotFoundError", err)
+	}
+}
+
+func TestAliasesMergeKeepsTransactNames(t *testing.T) {
+	aliases := newTestAliases()
+
+	merged := aliases.Merge("NORTH", []PartialProduct{
+		{ID: "1001", Name: "MILK 1% GAL", Amount: 2},
+		{ID: "3000", Name: "BREAD", Amount: 4},
+		{ID: "1002", Name: "MILK 2% GAL", Amount: 3},
+	})
+
+	if len(merged) != 2 {
+		t.Fatalf("Merge() returned %d products, expected 2", len(merged))
+	}
+	if merged[0].ID != "canonical-milk" || merged[0].Name != "Milk" || merged[0].Amount != 5 {
+		t.Errorf("merged[0] = %+v, expected canonical-milk named Milk with 5", merged[0])
+	}
+	if name := merged[0].TransactName(); name != "MILK 1% GAL / MILK 2% GAL" {
+		t.Errorf("merged[0].TransactName() = '%s', expected the names from Transact", name)
+	}
+	if name := merged[1].TransactName(); name != "BREAD" {
+		t.Errorf("merged[1].TransactName() = '%s', expected 'BREAD'", name)
+	}
+}
@@ -2,6 +2,9 @@ package products
 
 import (
 	"sync"
+	"time"
+
+	"github.com/jd-116/klemis-kitchen-api/types"
 )
 
 // Cache represents a cache of Partial Products
@@ -9,21 +12,83 @@ import (
 type Cache struct {
 	sync.Mutex
 	loaded          bool
+	loadedAt        time.Time
 	locations       []string
 	partialProducts map[string]map[string]PartialProduct
+
+	// Manual adjustments, keyed by location identifier and then product ID,
+	// that are merged into the amounts returned from reads
+	adjustments map[string]map[string][]types.Adjustment
+
+	// Called after each load with the products that came back in stock
+	restockListeners []RestockListener
+
+	// Called after every load
+	loadListeners []LoadListener
+
+	// Called with the adjustments that stopped applying because Transact changed
+	expiryListeners []ExpiryListener
+}
+
+// Restock is a product that was out of stock (or missing) at a location
+// in one load of a cache and in stock in the next
+type Restock struct {
+	Location  string
+	ProductID string
+	Name      string
+	Amount    int
+}
+
+// RestockListener is called with every restock found while loading a cache
+type RestockListener func(restocks []Restock)
+
+// LoadListener is called after every load of a cache
+type LoadListener func()
+
+// ExpiryListener is called with the "until next Transact change" adjustments
+// that were just marked as expired, so that their expiry can be saved
+type ExpiryListener func(adjustments []types.Adjustment)
+
+// LoadSource represents a products provider
+// that can report when its products are reloaded
+type LoadSource interface {
+	OnLoad(listener LoadListener)
+}
+
+// RestockSource represents a products provider
+// that can report when products come back in stock
+type RestockSource interface {
+	OnRestock(listener RestockListener)
+}
+
+// ExpirySource represents a products provider
+// that can report when manual adjustments expire because Transact changed
+type ExpirySource interface {
+	OnAdjustmentsExpired(listener ExpiryListener)
 }
 
 // Load loads a cache from the source products map,
 // marking it as ready.
+// Any registered load listeners are then called (without the lock held),
+// followed by the restock listeners
+// with the products that went from out of stock to in stock
+// and the expiry listeners with the adjustments that stopped applying.
+// Restocks are only detected between two loads
+// and are based on the amounts before manual adjustments.
 //
 // Note: uses passed in map as the inner map;
 // the passed in map cannot be reused by the caller afterwards
 func (c *Cache) Load(partialProducts map[string]map[string]PartialProduct) {
 	c.Lock()
-	defer c.Unlock()
+
+	var restocks []Restock
+	if c.loaded {
+		restocks = findRestocks(c.partialProducts, partialProducts)
+	}
 
 	// Mark as loaded and load the map
 	c.loaded = true
+	c.loadedAt = time.Now()
 	c.partialProducts = partialProducts
 
 	// Build the location identifiers slice
@@ -32,6 +97,192 @@ func (c *Cache) Load(partialProducts map[string]map[string]PartialProduct) {
 		locations = append(locations, location)
 	}
 	c.locations = locations
+
+	expired := c.expireAdjustments()
+	restockListeners := c.restockListeners
+	loadListeners := c.loadListeners
+	expiryListeners := c.expiryListeners
+	c.Unlock()
+
+	for _, listener := range loadListeners {
+		listener()
+	}
+	if len(restocks) > 0 {
+		for _, listener := range restockListeners {
+			listener(restocks)
+		}
+	}
+	if len(expired) > 0 {
+		for _, listener := range expiryListeners {
+			listener(expired)
+		}
+	}
+}
+
+// OnLoad registers a listener that is called after every load
+func (c *Cache) OnLoad(listener LoadListener) {
+	c.Lock()
+	defer c.Unlock()
+
+	c.loadListeners = append(c.loadListeners, listener)
+}
+
+// OnRestock registers a listener that is called after each load
+// that brought at least one product back in stock
+func (c *Cache) OnRestock(listener RestockListener) {
+	c.Lock()
+	defer c.Unlock()
+
+	c.restockListeners = append(c.restockListeners, listener)
+}
+
+// OnAdjustmentsExpired registers a listener that is called
+// whenever adjustments that last until the next Transact change are expired
+func (c *Cache) OnAdjustmentsExpired(listener ExpiryListener) {
+	c.Lock()
+	defer c.Unlock()
+
+	c.expiryListeners = append(c.expiryListeners, listener)
+}
+
+// Finds every product that has a positive amount in the new products map
+// but was out of stock in the old one.
+// Products missing from a location that was already known count as out of stock,
+// while locations that are entirely new are skipped
+func findRestocks(previous map[string]map[string]PartialProduct,
+	current map[string]map[string]PartialProduct) []Restock {
+
+	restocks := []Restock{}
+	for location, locationProducts := range current {
+		previousProducts, ok := previous[location]
+		if !ok {
+			continue
+		}
+
+		for id, partialProduct := range locationProducts {
+			if partialProduct.Amount <= 0 {
+				continue
+			}
+
+			if previousProduct, ok := previousProducts[id]; !ok || previousProduct.Amount <= 0 {
+				restocks = append(restocks, Restock{
+					Location:  location,
+					ProductID: id,
+					Name:      partialProduct.Name,
+					Amount:    partialProduct.Amount,
+				})
+			}
+		}
+	}
+
+	return restocks
+}
+
+// LoadAdjustments replaces all manual adjustments in the cache,
+// given a map of location identifier -> adjustments at that location.
+// If the cache is already loaded, any adjustments that Transact has changed since
+// are expired and passed to the expiry listeners
+func (c *Cache) LoadAdjustments(adjustments map[string][]types.Adjustment) {
+	c.Lock()
+
+	byProduct := make(map[string]map[string][]types.Adjustment)
+	for location, locationAdjustments := range adjustments {
+		byProduct[location] = make(map[string][]types.Adjustment)
+		for _, adjustment := range locationAdjustments {
+			byProduct[location][adjustment.ProductID] = append(byProduct[location][adjustment.ProductID], adjustment)
+		}
+	}
+	c.adjustments = byProduct
+
+	var expired []types.Adjustment
+	if c.loaded {
+		expired = c.expireAdjustments()
+	}
+	expiryListeners := c.expiryListeners
+	c.Unlock()
+
+	if len(expired) > 0 {
+		for _, listener := range expiryListeners {
+			listener(expired)
+		}
+	}
+}
+
+// Marks every adjustment that lasts until the next Transact change as expired
+// if the loaded amount of its product is different from its base amount,
+// returning the newly expired adjustments.
+// Products missing from a loaded location count as out of stock,
+// while locations that aren't in this cache are skipped.
+// The lock must be held
+func (c *Cache) expireAdjustments() []types.Adjustment {
+	now := time.Now()
+	expired := []types.Adjustment{}
+	for location, locationAdjustments := range c.adjustments {
+		locationProducts, ok := c.partialProducts[location]
+		if !ok {
+			continue
+		}
+
+		for id, productAdjustments := range locationAdjustments {
+			amount := 0
+			if partialProduct, ok := locationProducts[id]; ok {
+				amount = partialProduct.Amount
+			}
+
+			for i := range productAdjustments {
+				if productAdjustments[i].ChangedBy(amount) {
+					expiredAt := now
+					productAdjustments[i].ExpiredAt = &expiredAt
+					expired = append(expired, productAdjustments[i])
+				}
+			}
+		}
+	}
+
+	return expired
+}
+
+// Applies any active adjustments to the partial product's amount,
+// never letting it go below zero.
+// The lock must be held
+func (c *Cache) adjust(location string, partialProduct PartialProduct, now time.Time) PartialProduct {
+	for _, adjustment := range c.adjustments[location][partialProduct.ID] {
+		if adjustment.AppliesTo(now) {
+			partialProduct.Amount += adjustment.Delta
+		}
+	}
+
+	if partialProduct.Amount < 0 {
+		partialProduct.Amount = 0
+	}
+
+	return partialProduct
+}
+
+// LoadedAt gets the time that the cache was last loaded,
+// or false as the second value if it has never been loaded
+func (c *Cache) LoadedAt() (time.Time, bool) {
+	c.Lock()
+	defer c.Unlock()
+
+	return c.loadedAt, c.loaded
+}
+
+// LocationLoadedAt gets the time that the cache was last loaded
+// if it has the given location identifier
+func (c *Cache) LocationLoadedAt(location string) (time.Time, error) {
+	c.Lock()
+	defer c.Unlock()
+
+	if !c.loaded {
+		return time.Time{}, NewCacheNotInitializedError("get the load time of location from the Transact API")
+	}
+
+	if _, ok := c.partialProducts[location]; ok {
+		return c.loadedAt, nil
+	}
+
+	return time.Time{}, NewLocationNotFoundError(location)
 }
 
 // GetAllLocations gets all location identifiers
@@ -57,9 +308,10 @@ func (c *Cache) GetAllProducts(location string) ([]PartialProduct, error) {
 
 	if locationProducts, ok := c.partialProducts[location]; ok {
 		// Construct a slice of all products at that location
+		now := time.Now()
 		partialProducts := []PartialProduct{}
 		for _, value := range locationProducts {
-			partialProducts = append(partialProducts, value)
+			partialProducts = append(partialProducts, c.adjust(location, value, now))
 		}
 
 		return partialProducts, nil
@@ -79,6 +331,28 @@ func (c *Cache) GetProduct(location string, id string) (*PartialProduct, error)
 
 	if locationProducts, ok := c.partialProducts[location]; ok {
 		// Attempt to find the given partial product in this location
+		if partialProduct, ok := locationProducts[id]; ok {
+			adjusted := c.adjust(location, partialProduct, time.Now())
+			return &adjusted, nil
+		}
+
+		return nil, NewPartialProductNotFoundError(location, id)
+	}
+
+	return nil, NewLocationNotFoundError(location)
+}
+
+// GetUnadjustedProduct gets a single partial product from the given location with the given ID,
+// without merging in any manual adjustments
+func (c *Cache) GetUnadjustedProduct(location string, id string) (*PartialProduct, error) {
+	c.Lock()
+	defer c.Unlock()
+
+	if !c.loaded {
+		return nil, NewCacheNotInitializedError("get product at location from the Transact API")
+	}
+
+	if locationProducts, ok := c.partialProducts[location]; ok {
 		if partialProduct, ok := locationProducts[id]; ok {
 			return &partialProduct, nil
 		}
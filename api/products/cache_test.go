@@ -0,0 +1,78 @@
+package products
+
+import (
+	"testing"
+
+	"github.com/jd-116/klemis-kitchen-api/types"
+)
+
+func loadAmount(c *Cache, amount int) {
+	c.Load(map[string]map[string]PartialProduct{
+		"location": {"product": {Name: "Product", ID: "product", Amount: amount}},
+	})
+}
+
+func adjustedAmount(t *testing.T, c *Cache) int {
+	partialProduct, err := c.GetProduct("location", "product")
+	if err != nil {
+		t.Fatalf("GetProduct() returned an error: %v", err)
+	}
+	return partialProduct.Amount
+}
+
+func TestCacheUntilChangeAdjustmentStaysExpired(t *testing.T) {
+	c := &Cache{}
+	var expired []types.Adjustment
+	c.OnAdjustmentsExpired(func(adjustments []types.Adjustment) {
+		expired = append(expired, adjustments...)
+	})
+
+	loadAmount(c, 3)
+	c.LoadAdjustments(map[string][]types.Adjustment{
+		"location": {{ID: "adjustment", ProductID: "product", Delta: -2, UntilTransactChange: true, BaseAmount: 3}},
+	})
+
+	steps := []struct {
+		amount          int
+		expectedAmount  int
+		expectedExpired int
+	}{
+		{3, 1, 0},
+		{5, 5, 1},
+		// Returning to the base amount doesn't bring the adjustment back
+		{3, 3, 1},
+	}
+
+	for i, step := range steps {
+		if i > 0 {
+			loadAmount(c, step.amount)
+		}
+		if actual := adjustedAmount(t, c); actual != step.expectedAmount {
+			t.Errorf("step %d: amount = %d, expected %d", i, actual, step.expectedAmount)
+		}
+		if len(expired) != step.expectedExpired {
+			t.Errorf("step %d: %d adjustments expired, expected %d", i, len(expired), step.expectedExpired)
+		}
+	}
+}
+
+func TestCacheLoadAdjustmentsExpiresChanged(t *testing.T) {
+	c := &Cache{}
+	var expired []types.Adjustment
+	c.OnAdjustmentsExpired(func(adjustments []types.Adjustment) {
+		expired = append(expired, adjustments...)
+	})
+
+	loadAmount(c, 5)
+	c.LoadAdjustments(map[string][]types.Adjustment{
+		"location": {{ID: "adjustment", ProductID: "product", Delta: -2, UntilTransactChange: true, BaseAmount: 3}},
+		"other":    {{ID: "elsewhere", ProductID: "product", Delta: -2, UntilTransactChange: true, BaseAmount: 3}},
+	})
+
+	if len(expired) != 1 || expired[0].ID != "adjustment" || expired[0].ExpiredAt == nil {
+		t.Fatalf("expired = %+v, expected only the adjustment at the loaded location", expired)
+	}
+	if actual := adjustedAmount(t, c); actual != 5 {
+		t.Errorf("amount = %d, expected 5", actual)
+	}
+}
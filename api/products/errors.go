@@ -1,6 +1,9 @@
 package products
 
-import "fmt"
+import (
+	"fmt"
+	"time"
+)
 
 // CacheNotInitializedError is an error used to encode when the cache has not been initialized
 type CacheNotInitializedError struct {
@@ -53,3 +56,38 @@ func (e *PartialProductNotFoundError) Error() string {
 	return fmt.Sprintf("product with identifier '%s' at location '%s' not found in the Transact API cache",
 		e.ID, e.Location)
 }
+
+// RefreshRateLimitedError is an error used to encode when a manual refresh
+// was requested too soon after the previous one
+type RefreshRateLimitedError struct {
+	RetryAfter time.Duration
+}
+
+// NewRefreshRateLimitedError constructs a new RefreshRateLimitedError
+func NewRefreshRateLimitedError(retryAfter time.Duration) *RefreshRateLimitedError {
+	return &RefreshRateLimitedError{
+		RetryAfter: retryAfter,
+	}
+}
+
+func (e *RefreshRateLimitedError) Error() string {
+	return fmt.Sprintf("a refresh was requested too recently; try again in %s",
+		e.RetryAfter.Round(time.Second))
+}
+
+// RefreshJobNotFoundError is an error used to encode when a refresh job isn't found
+type RefreshJobNotFoundError struct {
+	ID string
+}
+
+// NewRefreshJobNotFoundError constructs a new RefreshJobNotFoundError
+func NewRefreshJobNotFoundError(id string) *RefreshJobNotFoundError {
+	return &RefreshJobNotFoundError{
+		ID: id,
+	}
+}
+
+func (e *RefreshJobNotFoundError) Error() string {
+	return fmt.Sprintf("refresh job with ID '%s' not found (it may have expired)",
+		e.ID)
+}
@@ -0,0 +1,177 @@
+package products
+
+import (
+	"context"
+	"time"
+
+	"github.com/jd-116/klemis-kitchen-api/types"
+)
+
+// MultiProvider combines several providers that each own a disjoint set of locations,
+// such as Transact-backed and natively-managed locations,
+// routing each read to the provider that has the location
+type MultiProvider struct {
+	providers []Provider
+}
+
+// NewMultiProvider creates a provider that reads from each of the given providers,
+// in order
+func NewMultiProvider(providers ...Provider) *MultiProvider {
+	return &MultiProvider{providers: providers}
+}
+
+// Connect connects each provider in order
+func (m *MultiProvider) Connect(ctx context.Context) error {
+	for _, provider := range m.providers {
+		err := provider.Connect(ctx)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Disconnect disconnects each provider in order
+func (m *MultiProvider) Disconnect(ctx context.Context) error {
+	for _, provider := range m.providers {
+		err := provider.Disconnect(ctx)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// GetAllLocations gets all location identifiers from every provider
+// that has been initialized
+func (m *MultiProvider) GetAllLocations() ([]string, error) {
+	var firstErr error
+	initialized := false
+	locations := []string{}
+	for _, provider := range m.providers {
+		providerLocations, err := provider.GetAllLocations()
+		if err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+
+		initialized = true
+		locations = append(locations, providerLocations...)
+	}
+
+	if !initialized && firstErr != nil {
+		return nil, firstErr
+	}
+
+	return locations, nil
+}
+
+// GetAllProducts gets all products for the given location identifier
+// from the first provider that has the location
+func (m *MultiProvider) GetAllProducts(location string) ([]PartialProduct, error) {
+	var result []PartialProduct
+	err := m.route(func(provider Provider) error {
+		partialProducts, err := provider.GetAllProducts(location)
+		result = partialProducts
+		return err
+	})
+
+	return result, err
+}
+
+// GetProduct gets a single partial product from the given location with the given ID
+// from the first provider that has the location
+func (m *MultiProvider) GetProduct(location string, id string) (*PartialProduct, error) {
+	var result *PartialProduct
+	err := m.route(func(provider Provider) error {
+		partialProduct, err := provider.GetProduct(location, id)
+		result = partialProduct
+		return err
+	})
+
+	return result, err
+}
+
+// LocationLoadedAt gets the time that the location was last loaded
+// from the first provider that has the location
+func (m *MultiProvider) LocationLoadedAt(location string) (time.Time, error) {
+	var result time.Time
+	err := m.route(func(provider Provider) error {
+		loadedAt, err := provider.LocationLoadedAt(location)
+		result = loadedAt
+		return err
+	})
+
+	return result, err
+}
+
+// GetUnadjustedProduct gets a single partial product without any manual adjustments
+// from the first provider that has the location
+func (m *MultiProvider) GetUnadjustedProduct(location string, id string) (*PartialProduct, error) {
+	var result *PartialProduct
+	err := m.route(func(provider Provider) error {
+		partialProduct, err := provider.GetUnadjustedProduct(location, id)
+		result = partialProduct
+		return err
+	})
+
+	return result, err
+}
+
+// LoadAdjustments gives the adjustments to every provider
+// (each only applies the ones at its own locations)
+func (m *MultiProvider) LoadAdjustments(adjustments map[string][]types.Adjustment) {
+	for _, provider := range m.providers {
+		provider.LoadAdjustments(adjustments)
+	}
+}
+
+// OnRestock registers the listener with every provider
+func (m *MultiProvider) OnRestock(listener RestockListener) {
+	for _, provider := range m.providers {
+		provider.OnRestock(listener)
+	}
+}
+
+// OnAdjustmentsExpired registers the listener with every provider
+func (m *MultiProvider) OnAdjustmentsExpired(listener ExpiryListener) {
+	for _, provider := range m.providers {
+		provider.OnAdjustmentsExpired(listener)
+	}
+}
+
+// OnLoad registers the listener with every provider
+func (m *MultiProvider) OnLoad(listener LoadListener) {
+	for _, provider := range m.providers {
+		provider.OnLoad(listener)
+	}
+}
+
+// Tries the read against each provider until one has the location.
+// If none do, the most relevant error is returned:
+// an uninitialized cache is preferred over a missing location,
+// since the location may appear once that cache loads
+func (m *MultiProvider) route(read func(provider Provider) error) error {
+	var lastErr error
+	for _, provider := range m.providers {
+		err := read(provider)
+		switch err.(type) {
+		case nil:
+			return nil
+		case *LocationNotFoundError:
+			if lastErr == nil {
+				lastErr = err
+			}
+		case *CacheNotInitializedError:
+			lastErr = err
+		default:
+			return err
+		}
+	}
+
+	return lastErr
+}
@@ -0,0 +1,130 @@
+package native
+
+import (
+	"context"
+	"errors"
+
+	"github.com/rs/zerolog"
+
+	"github.com/jd-116/klemis-kitchen-api/db"
+	"github.com/jd-116/klemis-kitchen-api/products"
+	"github.com/jd-116/klemis-kitchen-api/types"
+)
+
+// Database is the subset of the database provider
+// that the native inventory provider needs
+type Database interface {
+	db.LocationProvider
+	db.NativeInventoryProvider
+}
+
+// Provider is an item provider for locations whose inventory is managed
+// in the database instead of coming from Transact.
+// Reads are served from a cache that is reloaded after every change
+type Provider struct {
+	database Database
+	*products.Cache
+	logger zerolog.Logger
+}
+
+// NewProvider creates the provider
+// (doesn't load anything from the database)
+func NewProvider(database Database, logger zerolog.Logger) *Provider {
+	return &Provider{
+		database: database,
+		Cache:    &products.Cache{},
+		logger:   logger,
+	}
+}
+
+// Connect loads the current native inventory into the cache
+// (the database must already be connected)
+func (p *Provider) Connect(ctx context.Context) error {
+	return p.Reload(ctx)
+}
+
+// Disconnect does nothing, since the provider has no background work
+func (p *Provider) Disconnect(ctx context.Context) error {
+	return nil
+}
+
+// Reload loads every native location and its items from the database into the cache.
+// Native locations are keyed by their location ID
+func (p *Provider) Reload(ctx context.Context) error {
+	locations, err := p.database.GetAllLocations(ctx)
+	if err != nil {
+		return err
+	}
+
+	items, err := p.database.GetAllNativeItems(ctx)
+	if err != nil {
+		return err
+	}
+
+	// Include native locations without any items,
+	// so that they're found (and empty) instead of missing
+	productsMap := make(map[string]map[string]products.PartialProduct)
+	for _, location := range locations {
+		if location.IsNative() {
+			productsMap[location.ID] = make(map[string]products.PartialProduct)
+		}
+	}
+
+	for _, item := range items {
+		if locationProducts, ok := productsMap[item.LocationID]; ok {
+			locationProducts[item.ProductID] = products.PartialProduct{
+				Name:   item.Name,
+				ID:     item.ProductID,
+				Amount: item.Amount,
+			}
+		}
+	}
+
+	p.Cache.Load(productsMap)
+
+	p.logger.
+		Debug().
+		Int("location_count", len(productsMap)).
+		Int("item_count", len(items)).
+		Msg("reloaded native inventory cache")
+
+	return nil
+}
+
+// CreateItem adds a new item to a native location
+func (p *Provider) CreateItem(ctx context.Context, location types.Location, item types.NativeItem) error {
+	if !location.IsNative() {
+		return errors.New("items can only be created at locations with native inventory")
+	}
+
+	item.LocationID = location.ID
+	err := p.database.CreateNativeItem(ctx, item)
+	if err != nil {
+		return err
+	}
+
+	return p.Reload(ctx)
+}
+
+// RecordTransaction applies a stock-in, stock-out, or set transaction
+// to an item at a native location
+func (p *Provider) RecordTransaction(ctx context.Context, location types.Location,
+	transaction types.InventoryTransaction) (*types.InventoryTransaction, error) {
+
+	if !location.IsNative() {
+		return nil, errors.New("transactions can only be recorded at locations with native inventory")
+	}
+
+	transaction.LocationID = location.ID
+	applied, err := p.database.ApplyInventoryTransaction(ctx, transaction)
+	if err != nil {
+		return nil, err
+	}
+
+	err = p.Reload(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return applied, nil
+}
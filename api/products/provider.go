@@ -1,6 +1,12 @@
 package products
 
-import "context"
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/jd-116/klemis-kitchen-api/types"
+)
 
 // Provider represents a Transact API provider
 type Provider interface {
@@ -8,6 +14,10 @@ type Provider interface {
 	Disconnect(ctx context.Context) error
 
 	PartialProductProvider
+	AdjustableProvider
+	RestockSource
+	ExpirySource
+	LoadSource
 }
 
 // PartialProductProvider represents a partial products provider implementation
@@ -15,6 +25,14 @@ type PartialProductProvider interface {
 	GetAllLocations() ([]string, error)
 	GetAllProducts(location string) ([]PartialProduct, error)
 	GetProduct(location string, id string) (*PartialProduct, error)
+	LocationLoadedAt(location string) (time.Time, error)
+}
+
+// AdjustableProvider represents a partial products provider
+// whose amounts can have manual adjustments layered over them
+type AdjustableProvider interface {
+	LoadAdjustments(adjustments map[string][]types.Adjustment)
+	GetUnadjustedProduct(location string, id string) (*PartialProduct, error)
 }
 
 // PartialProduct represents a partial product that has been retrieved from the Transact API
@@ -22,4 +40,27 @@ type PartialProduct struct {
 	Name   string
 	ID     string
 	Amount int
+
+	// The names in Transact of the products that were merged into this one,
+	// if it is a canonical product
+	TransactNames []string
+}
+
+// TransactName gets the name of the product in Transact,
+// joining the names of every product merged into a canonical product
+func (p *PartialProduct) TransactName() string {
+	if p.TransactNames == nil {
+		return p.Name
+	}
+
+	names := []string{}
+	seen := make(map[string]bool)
+	for _, name := range p.TransactNames {
+		if !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+
+	return strings.Join(names, " / ")
 }
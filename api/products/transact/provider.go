@@ -2,119 +2,66 @@ package transact
 
 import (
 	"context"
-	"strconv"
-	"strings"
+	"errors"
+	"fmt"
+	"sync"
 	"time"
 
 	"github.com/hako/durafmt"
 	"github.com/rs/zerolog"
+	"go.opentelemetry.io/otel/attribute"
 
-	"github.com/jd-116/klemis-kitchen-api/env"
+	"github.com/jd-116/klemis-kitchen-api/config"
+	"github.com/jd-116/klemis-kitchen-api/metrics"
 	"github.com/jd-116/klemis-kitchen-api/products"
+	"github.com/jd-116/klemis-kitchen-api/settings"
+	"github.com/jd-116/klemis-kitchen-api/tracing"
 )
 
 // Provider bundles together a stateful item provider via the Transact API,
 // including an active session with the API
 // and a cache in front of it
 //
-// Safe to copy and keep multiple references
+// Holds locks, so it must not be copied; use it through a pointer
 type Provider struct {
 	stopFetch         chan struct{}
 	stopReloadSession chan struct{}
 
 	// Config values
-	fetchPeriod                   time.Duration
-	reloadSessionPeriod           time.Duration
-	csvReportName                 string
-	reportPollPeriod              time.Duration
-	reportPollTimeout             time.Duration
-	csvReportIDColumnOffset       int
-	csvReportNameColumnOffset     int
-	csvReportQuantityColumnOffset int
-	profitCenterPrefix            string
-	reportType                    string
+	settings            *settings.Store
+	reloadSessionPeriod time.Duration
+	csvReportName       string
+	reportPollPeriod    time.Duration
+	reportPollTimeout   time.Duration
+	reportMapping       *ReportMapping
+	profitCenterPrefix  string
+	reportType          string
+	// Zero if the max age should follow the fetch period
+	maxCacheAge        time.Duration
+	refreshMinInterval time.Duration
+
+	refreshJobs refreshJobs
+
+	lastReportLock sync.Mutex
+	lastReport     *ParseReport
 
 	*Scraper
 	*products.Cache
 	logger zerolog.Logger
 }
 
-// NewProvider loads values from the environment
-// and creates the provider
+// NewProvider creates the provider from the Transact configuration,
+// reading the fetch period from the settings store instead
+// since it can be reloaded
 // (doesn't involve authentication or start goroutines)
-func NewProvider(logger zerolog.Logger) (*Provider, error) {
-	baseURL, err := env.GetEnv("Transact base URL", "TRANSACT_BASE_URL")
-	if err != nil {
-		return nil, err
-	}
-
-	tenant, err := env.GetEnv("Transact tenant", "TRANSACT_TENANT")
-	if err != nil {
-		return nil, err
-	}
-
-	username, err := env.GetEnv("Transact username", "TRANSACT_USERNAME")
-	if err != nil {
-		return nil, err
-	}
-
-	password, err := env.GetEnv("Transact password", "TRANSACT_PASSWORD")
-	if err != nil {
-		return nil, err
-	}
-
-	fetchPeriod, err := env.GetDurationEnv("Transact API fetch period", "TRANSACT_FETCH_PERIOD")
-	if err != nil {
-		return nil, err
-	}
-
-	reloadSessionPeriod, err := env.GetDurationEnv("Transact API reload session period", "TRANSACT_RELOAD_SESSION_PERIOD")
-	if err != nil {
-		return nil, err
-	}
-
-	csvReportName, err := env.GetEnv("Transact CSV favorite report name", "TRANSACT_CSV_FAVORITE_REPORT_NAME")
-	if err != nil {
-		return nil, err
-	}
-
-	reportPollPeriod, err := env.GetDurationEnv("Transact API report poll period", "TRANSACT_REPORT_POLL_PERIOD")
-	if err != nil {
-		return nil, err
-	}
-
-	reportPollTimeout, err := env.GetDurationEnv("Transact API report poll timeout", "TRANSACT_REPORT_POLL_TIMEOUT")
-	if err != nil {
-		return nil, err
-	}
-
-	csvReportIDColumnOffset, err := env.GetIntEnv("Transact CSV report ID column offset from 'Profit Center - '", "TRANSACT_CSV_REPORT_ID_COLUMN_OFFSET")
-	if err != nil {
-		return nil, err
-	}
-
-	csvReportNameColumnOffset, err := env.GetIntEnv("Transact CSV report name column offset from 'Profit Center - '", "TRANSACT_CSV_REPORT_NAME_COLUMN_OFFSET")
-	if err != nil {
-		return nil, err
-	}
-
-	csvReportQuantityColumnOffset, err := env.GetIntEnv("Transact CSV report quantity column offset from 'Profit Center - '", "TRANSACT_CSV_REPORT_QTY_COLUMN_OFFSET")
-	if err != nil {
-		return nil, err
-	}
-
-	profitCenterPrefix, err := env.GetEnv("Transact CSV report profit center prefix", "TRANSACT_PROFIT_CENTER_PREFIX")
-	if err != nil {
-		return nil, err
-	}
-
-	reportType, err := env.GetEnv("Transact CSV report __type value", "TRANSACT_CSV_REPORT_TYPE")
+func NewProvider(cfg config.TransactConfig, settingsStore *settings.Store, logger zerolog.Logger) (*Provider, error) {
+	reportMapping, err := loadReportMapping(cfg)
 	if err != nil {
 		return nil, err
 	}
 
 	// Create the scraper
-	scraper, err := NewScraper(baseURL, tenant, username, password, logger)
+	scraper, err := NewScraper(cfg.BaseURL, cfg.Tenant, cfg.Username, cfg.Password, logger)
 	if err != nil {
 		return nil, err
 	}
@@ -123,16 +70,16 @@ func NewProvider(logger zerolog.Logger) (*Provider, error) {
 		stopFetch:         make(chan struct{}),
 		stopReloadSession: make(chan struct{}),
 
-		fetchPeriod:                   fetchPeriod,
-		reloadSessionPeriod:           reloadSessionPeriod,
-		csvReportName:                 csvReportName,
-		reportPollPeriod:              reportPollPeriod,
-		reportPollTimeout:             reportPollTimeout,
-		csvReportIDColumnOffset:       csvReportIDColumnOffset,
-		csvReportNameColumnOffset:     csvReportNameColumnOffset,
-		csvReportQuantityColumnOffset: csvReportQuantityColumnOffset,
-		profitCenterPrefix:            profitCenterPrefix,
-		reportType:                    reportType,
+		settings:            settingsStore,
+		reloadSessionPeriod: time.Duration(cfg.ReloadSessionPeriod),
+		csvReportName:       cfg.CSVFavoriteReportName,
+		reportPollPeriod:    time.Duration(cfg.ReportPollPeriod),
+		reportPollTimeout:   time.Duration(cfg.ReportPollTimeout),
+		reportMapping:       reportMapping,
+		profitCenterPrefix:  cfg.ProfitCenterPrefix,
+		reportType:          cfg.CSVReportType,
+		maxCacheAge:         time.Duration(cfg.MaxCacheAge),
+		refreshMinInterval:  time.Duration(cfg.RefreshMinInterval),
 
 		Scraper: scraper,
 		Cache:   &products.Cache{},
@@ -144,7 +91,7 @@ func NewProvider(logger zerolog.Logger) (*Provider, error) {
 // and starts goroutines to periodically re-authenticate/fetch
 func (p *Provider) Connect(ctx context.Context) error {
 	// Load the session
-	err := p.Scraper.ReloadSession()
+	err := p.Scraper.ReloadSession(context.Background())
 	if err != nil {
 		return err
 	}
@@ -156,121 +103,140 @@ func (p *Provider) Connect(ctx context.Context) error {
 	return nil
 }
 
+// CheckCache determines whether the partial product cache has been loaded
+// and is recent enough to serve requests from
+func (p *Provider) CheckCache(ctx context.Context) error {
+	loadedAt, loaded := p.Cache.LoadedAt()
+	if !loaded {
+		return errors.New("partial product cache has not been loaded yet")
+	}
+
+	maxCacheAge := p.maxCacheAge
+	if maxCacheAge == 0 {
+		maxCacheAge = 3 * p.settings.Get().TransactFetchPeriod
+	}
+
+	age := time.Since(loadedAt)
+	if age > maxCacheAge {
+		return fmt.Errorf("partial product cache is stale (last loaded %s ago)",
+			durafmt.Parse(age).LimitFirstN(2).String())
+	}
+
+	return nil
+}
+
+// CheckSession determines whether the most recent attempt
+// to load the Transact API session succeeded
+func (p *Provider) CheckSession(ctx context.Context) error {
+	loadedAt, err := p.Scraper.SessionStatus()
+	if err != nil {
+		return fmt.Errorf("last Transact session reload failed: %w", err)
+	}
+	if loadedAt.IsZero() {
+		return errors.New("Transact session has not been loaded yet")
+	}
+
+	return nil
+}
+
 // Periodically fetches from the API
-// and stores the data into the cache
+// and stores the data into the cache.
+// When the fetch period is reloaded, the next fetch is rescheduled
+// relative to the last one
 func (p *Provider) periodFetch() {
-	humanDuration := durafmt.Parse(p.fetchPeriod).LimitFirstN(2).String()
-	p.tryFetch(humanDuration)
+	fetchPeriod := p.settings.Get().TransactFetchPeriod
+	p.logger.
+		Info().
+		Str("interval", durafmt.Parse(fetchPeriod).LimitFirstN(2).String()).
+		Msg("started timer to fetch Transact API partial product cache")
+	p.periodicRefresh()
+	lastFetch := time.Now()
 	for {
+		changed := p.settings.Changed()
 		select {
 		case <-p.stopFetch:
 			return
-		case <-time.After(p.fetchPeriod):
-			p.tryFetch(humanDuration)
+		case <-changed:
+			if period := p.settings.Get().TransactFetchPeriod; period != fetchPeriod {
+				fetchPeriod = period
+				p.logger.
+					Info().
+					Str("interval", durafmt.Parse(fetchPeriod).LimitFirstN(2).String()).
+					Msg("changed timer to fetch Transact API partial product cache")
+			}
+		case <-time.After(time.Until(lastFetch.Add(fetchPeriod))):
+			p.periodicRefresh()
+			lastFetch = time.Now()
 		}
 	}
 }
 
 // Attempts to fetch and reload the cache,
-// printing out an error if it occurs
-func (p *Provider) tryFetch(delayUntilNext string) {
+// printing out an error if it occurs.
+// Should only be called from a refresh job
+func (p *Provider) tryFetch(jobID string, trigger string) (*ParseReport, error) {
 	p.logger.
 		Info().
+		Str("job_id", jobID).
+		Str("trigger", trigger).
 		Msg("started to fetch Transact API partial product cache")
+	start := time.Now()
+	ctx, span := tracing.Start(context.Background(), "transact.fetch",
+		attribute.String("job_id", jobID),
+		attribute.String("trigger", trigger))
+	defer span.End()
 
 	// Fetch a list of partial products from the Transact API via a report
-	reportRows, err := p.Scraper.GetInventoryCSV(p.csvReportName,
+	reportRows, err := p.Scraper.GetInventoryCSV(ctx, p.csvReportName,
 		p.reportPollPeriod, p.reportPollTimeout, p.reportType)
 	if err != nil {
+		metrics.TransactFetchDuration.WithLabelValues(metrics.Outcome(err)).Observe(time.Since(start).Seconds())
+		tracing.RecordError(span, err)
+
 		// Report error,
 		// but continue the goroutine
 		p.logger.
 			Error().
 			Err(err).
+			Str("job_id", jobID).
 			Msg("an error occurred while fetching Transact API partial product cache")
-		return
+		return nil, err
 	}
 
-	// Parse each CSV row individually -- there are no headers :)
-	productsMap := make(map[string]map[string]products.PartialProduct)
-	totalLoaded := 0
-	for _, csvRow := range reportRows {
-		result := p.parseCSVRow(csvRow)
-		if result == nil {
-			continue
-		}
-
-		// Initialize the inner map if needed
-		location := result.LocationIdentifier
-		if _, ok := productsMap[location]; !ok {
-			productsMap[location] = make(map[string]products.PartialProduct)
-		}
+	// Parse each CSV row according to the report mapping
+	productsMap, report := p.reportMapping.Parse(reportRows, p.profitCenterPrefix)
+	p.lastReportLock.Lock()
+	p.lastReport = report
+	p.lastReportLock.Unlock()
 
-		// Load the product into the cache
-		productsMap[location][result.PartialProduct.ID] = result.PartialProduct
-		totalLoaded++
-	}
+	metrics.TransactFetchDuration.WithLabelValues(metrics.Outcome(nil)).Observe(time.Since(start).Seconds())
+	metrics.TransactReportRows.WithLabelValues("raw").Set(float64(report.RawRowCount))
+	metrics.TransactReportRows.WithLabelValues("imported").Set(float64(report.ImportedRowCount))
 
 	p.logger.
 		Info().
-		Int("raw_row_count", len(reportRows)).
-		Int("imported_row_count", totalLoaded).
-		Str("delay_until_next", delayUntilNext).
+		Int("raw_row_count", report.RawRowCount).
+		Int("imported_row_count", report.ImportedRowCount).
+		Interface("rejection_counts", report.RejectionCounts).
+		Str("job_id", jobID).
 		Msg("reloaded Transact API partial product cache")
 
 	// Load the products into the cache
 	p.Cache.Load(productsMap)
-}
 
-type parseResult struct {
-	products.PartialProduct
-	LocationIdentifier string
+	return report, nil
 }
 
-func (p *Provider) parseCSVRow(row []string) *parseResult {
-	// Scan each cell until it sees the profit center prefix
-	for i, cell := range row {
-		if strings.HasPrefix(cell, p.profitCenterPrefix) {
-			locName := strings.TrimSpace(strings.TrimPrefix(cell, p.profitCenterPrefix))
-
-			// Ensure array accesses are within bounds
-			if i+p.csvReportNameColumnOffset >= len(row) ||
-				i+p.csvReportIDColumnOffset >= len(row) ||
-				i+p.csvReportQuantityColumnOffset >= len(row) {
-				return nil
-			}
+// LastReport gets the parse report of the most recently fetched CSV report
+func (p *Provider) LastReport() (*ParseReport, error) {
+	p.lastReportLock.Lock()
+	defer p.lastReportLock.Unlock()
 
-			name := row[i+p.csvReportNameColumnOffset]
-			id := row[i+p.csvReportIDColumnOffset]
-			if name == "" || id == "" {
-				return nil
-			}
-
-			amountRaw := row[i+p.csvReportQuantityColumnOffset]
-			amount, err := strconv.Atoi(amountRaw)
-			if err != nil {
-				return nil
-			}
-
-			// Don't let negative item amounts get past parsing
-			if amount < 0 {
-				amount = 0
-			}
-
-			product := products.PartialProduct{
-				Name:   name,
-				ID:     id,
-				Amount: amount,
-			}
-
-			return &parseResult{
-				PartialProduct:     product,
-				LocationIdentifier: locName,
-			}
-		}
+	if p.lastReport == nil {
+		return nil, products.NewCacheNotInitializedError("get the last Transact report")
 	}
 
-	return nil
+	return p.lastReport, nil
 }
 
 // Periodically reloads the session
@@ -285,7 +251,7 @@ func (p *Provider) periodReloadSession() {
 		case <-p.stopReloadSession:
 			return
 		case <-time.After(p.reloadSessionPeriod):
-			err := p.Scraper.ReloadSession()
+			err := p.Scraper.ReloadSession(context.Background())
 			if err != nil {
 				// Report error,
 				// but continue the goroutine
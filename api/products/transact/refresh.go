@@ -0,0 +1,174 @@
+package transact
+
+import (
+	"sync"
+	"time"
+
+	"github.com/segmentio/ksuid"
+
+	"github.com/jd-116/klemis-kitchen-api/products"
+)
+
+// Statuses that a refresh job can be in
+const (
+	RefreshRunning   = "running"
+	RefreshSucceeded = "succeeded"
+	RefreshFailed    = "failed"
+)
+
+// What caused a refresh job to be started
+const (
+	TriggerPeriodic = "periodic"
+	TriggerManual   = "manual"
+)
+
+// maxRetainedJobs is the number of finished refresh jobs kept around for polling
+const maxRetainedJobs = 20
+
+// RefreshJob is a single fetch of the Transact inventory report into the cache
+type RefreshJob struct {
+	ID               string         `json:"id"`
+	Trigger          string         `json:"trigger"`
+	Status           string         `json:"status"`
+	StartedAt        time.Time      `json:"started_at"`
+	FinishedAt       *time.Time     `json:"finished_at"`
+	Error            *string        `json:"error"`
+	RawRowCount      *int           `json:"raw_row_count"`
+	ImportedRowCount *int           `json:"imported_row_count"`
+	RejectionCounts  map[string]int `json:"rejection_counts,omitempty"`
+
+	done chan struct{}
+}
+
+// refreshJobs tracks the currently running refresh job
+// and the most recently finished ones
+type refreshJobs struct {
+	sync.Mutex
+	current         *RefreshJob
+	finished        []*RefreshJob
+	lastManualStart time.Time
+}
+
+// TriggerRefresh starts a manual refresh of the cache,
+// returning the job that can be polled for its status.
+// If a refresh (manual or periodic) is already running,
+// then that job is returned instead of starting another one.
+// Manual refreshes that would start a new job are rate-limited
+// to one every TRANSACT_REFRESH_MIN_INTERVAL
+func (p *Provider) TriggerRefresh() (RefreshJob, bool, error) {
+	p.refreshJobs.Lock()
+	defer p.refreshJobs.Unlock()
+
+	if current := p.refreshJobs.current; current != nil {
+		return *current, false, nil
+	}
+
+	if !p.refreshJobs.lastManualStart.IsZero() {
+		if elapsed := time.Since(p.refreshJobs.lastManualStart); elapsed < p.refreshMinInterval {
+			return RefreshJob{}, false, products.NewRefreshRateLimitedError(p.refreshMinInterval - elapsed)
+		}
+	}
+
+	job, err := p.startRefresh(TriggerManual)
+	if err != nil {
+		return RefreshJob{}, false, err
+	}
+	p.refreshJobs.lastManualStart = job.StartedAt
+
+	return *job, true, nil
+}
+
+// GetRefreshJob gets the current status of a single refresh job by its ID
+func (p *Provider) GetRefreshJob(id string) (RefreshJob, error) {
+	p.refreshJobs.Lock()
+	defer p.refreshJobs.Unlock()
+
+	if current := p.refreshJobs.current; current != nil && current.ID == id {
+		return *current, nil
+	}
+	for _, job := range p.refreshJobs.finished {
+		if job.ID == id {
+			return *job, nil
+		}
+	}
+
+	return RefreshJob{}, products.NewRefreshJobNotFoundError(id)
+}
+
+// Runs a periodic refresh and waits for it to finish.
+// If a refresh is already running, this waits for that one instead
+func (p *Provider) periodicRefresh() {
+	p.refreshJobs.Lock()
+	job := p.refreshJobs.current
+	if job == nil {
+		var err error
+		job, err = p.startRefresh(TriggerPeriodic)
+		if err != nil {
+			p.refreshJobs.Unlock()
+			p.logger.
+				Error().
+				Err(err).
+				Msg("could not start periodic refresh of Transact API partial product cache")
+			return
+		}
+	} else {
+		p.logger.
+			Info().
+			Str("job_id", job.ID).
+			Msg("waiting on in-progress refresh instead of starting periodic refresh")
+	}
+	p.refreshJobs.Unlock()
+
+	<-job.done
+}
+
+// Starts a new refresh job in the background.
+// The refresh jobs lock must be held
+func (p *Provider) startRefresh(trigger string) (*RefreshJob, error) {
+	id, err := ksuid.NewRandom()
+	if err != nil {
+		return nil, err
+	}
+
+	job := &RefreshJob{
+		ID:        id.String(),
+		Trigger:   trigger,
+		Status:    RefreshRunning,
+		StartedAt: time.Now(),
+		done:      make(chan struct{}),
+	}
+	p.refreshJobs.current = job
+
+	go func() {
+		report, err := p.tryFetch(job.ID, trigger)
+		p.finishRefresh(job, report, err)
+	}()
+
+	return job, nil
+}
+
+// Records the outcome of a refresh job and retains it for polling
+func (p *Provider) finishRefresh(job *RefreshJob, report *ParseReport, err error) {
+	p.refreshJobs.Lock()
+	defer p.refreshJobs.Unlock()
+
+	finishedAt := time.Now()
+	job.FinishedAt = &finishedAt
+	if err != nil {
+		message := err.Error()
+		job.Status = RefreshFailed
+		job.Error = &message
+	} else {
+		job.Status = RefreshSucceeded
+		job.RawRowCount = &report.RawRowCount
+		job.ImportedRowCount = &report.ImportedRowCount
+		job.RejectionCounts = report.RejectionCounts
+	}
+
+	p.refreshJobs.current = nil
+	p.refreshJobs.finished = append(p.refreshJobs.finished, job)
+	if len(p.refreshJobs.finished) > maxRetainedJobs {
+		p.refreshJobs.finished = p.refreshJobs.finished[len(p.refreshJobs.finished)-maxRetainedJobs:]
+	}
+	close(job.done)
+}
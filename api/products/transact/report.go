@@ -0,0 +1,306 @@
+package transact
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jd-116/klemis-kitchen-api/config"
+	"github.com/jd-116/klemis-kitchen-api/products"
+)
+
+// Reasons that a report row can be rejected for
+const (
+	RejectedHeader         = "header"
+	RejectedNoProfitCenter = "no_profit_center"
+	RejectedOutOfBounds    = "out_of_bounds"
+	RejectedMissingID      = "missing_id"
+	RejectedMissingName    = "missing_name"
+	RejectedBadQuantity    = "bad_quantity"
+)
+
+// maxRecordedRejections is the number of individual row rejections
+// kept in each ParseReport (all rejections are still counted)
+const maxRecordedRejections = 100
+
+// ReportMapping declares how to find each column in the CSV inventory report
+type ReportMapping struct {
+	ID       ReportColumn `json:"id"`
+	Name     ReportColumn `json:"name"`
+	Quantity ReportColumn `json:"quantity"`
+}
+
+// ReportColumn locates a single column in the CSV inventory report.
+// If the header is given and a header row containing it is found in the report,
+// then the column is found by its header.
+// Otherwise, it falls back to the 0-based offset
+// relative to the cell that indicates the profit center
+type ReportColumn struct {
+	Header string `json:"header"`
+	Offset *int   `json:"offset"`
+}
+
+// ResolvedColumn describes how a column was located in a single report
+type ResolvedColumn struct {
+	Source string `json:"source"`
+	Index  *int   `json:"index,omitempty"`
+	Offset *int   `json:"offset,omitempty"`
+}
+
+// RowRejection describes a single row that wasn't imported
+type RowRejection struct {
+	Row    int    `json:"row"`
+	Reason string `json:"reason"`
+	Detail string `json:"detail"`
+}
+
+// ParseReport summarizes the outcome of parsing a single CSV inventory report
+type ParseReport struct {
+	ParsedAt         time.Time                 `json:"parsed_at"`
+	RawRowCount      int                       `json:"raw_row_count"`
+	ImportedRowCount int                       `json:"imported_row_count"`
+	HeaderRow        *int                      `json:"header_row"`
+	Columns          map[string]ResolvedColumn `json:"columns"`
+	RejectionCounts  map[string]int            `json:"rejection_counts"`
+	Rejections       []RowRejection            `json:"rejections"`
+}
+
+func (r *ParseReport) reject(row int, reason string, detail string) {
+	r.RejectionCounts[reason]++
+	if len(r.Rejections) < maxRecordedRejections {
+		r.Rejections = append(r.Rejections, RowRejection{Row: row, Reason: reason, Detail: detail})
+	}
+}
+
+// loadReportMapping loads the report mapping from the JSON file
+// at the configured mapping path if there is one,
+// otherwise building an offset-only mapping from the configured column offsets
+func loadReportMapping(cfg config.TransactConfig) (*ReportMapping, error) {
+	if path := cfg.CSVReportMappingPath; path != "" {
+		file, err := os.Open(path)
+		if err != nil {
+			return nil, fmt.Errorf("could not open Transact CSV report mapping file: %w", err)
+		}
+		defer file.Close()
+
+		var mapping ReportMapping
+		decoder := json.NewDecoder(file)
+		decoder.DisallowUnknownFields()
+		err = decoder.Decode(&mapping)
+		if err != nil {
+			return nil, fmt.Errorf("could not parse Transact CSV report mapping file '%s': %w", path, err)
+		}
+
+		err = mapping.validate()
+		if err != nil {
+			return nil, fmt.Errorf("invalid Transact CSV report mapping file '%s': %w", path, err)
+		}
+
+		return &mapping, nil
+	}
+
+	// The offsets are copied so that the mapping doesn't share them with the configuration
+	idOffset := *cfg.CSVReportIDColumnOffset
+	nameOffset := *cfg.CSVReportNameColumnOffset
+	quantityOffset := *cfg.CSVReportQtyColumnOffset
+	return &ReportMapping{
+		ID:       ReportColumn{Offset: &idOffset},
+		Name:     ReportColumn{Offset: &nameOffset},
+		Quantity: ReportColumn{Offset: &quantityOffset},
+	}, nil
+}
+
+// Ensures each column can be located somehow
+func (m *ReportMapping) validate() error {
+	problems := []string{}
+	for name, column := range m.columns() {
+		if strings.TrimSpace(column.Header) == "" && column.Offset == nil {
+			problems = append(problems, fmt.Sprintf("column '%s' needs a header or an offset", name))
+		}
+		if column.Offset != nil && *column.Offset < 0 {
+			problems = append(problems, fmt.Sprintf("column '%s' has a negative offset", name))
+		}
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("%s", strings.Join(problems, "; "))
+	}
+
+	return nil
+}
+
+func (m *ReportMapping) columns() map[string]ReportColumn {
+	return map[string]ReportColumn{
+		"id":       m.ID,
+		"name":     m.Name,
+		"quantity": m.Quantity,
+	}
+}
+
+// parseResult is a single parsed row of the report
+type parseResult struct {
+	products.PartialProduct
+	LocationIdentifier string
+}
+
+// Parse parses each CSV row of the report into a map of
+// location identifier -> product ID -> partial product,
+// reporting the reason that each skipped row was rejected
+func (m *ReportMapping) Parse(rows [][]string, profitCenterPrefix string) (map[string]map[string]products.PartialProduct, *ParseReport) {
+	report := &ParseReport{
+		ParsedAt:        time.Now(),
+		RawRowCount:     len(rows),
+		Columns:         make(map[string]ResolvedColumn),
+		RejectionCounts: make(map[string]int),
+		Rejections:      []RowRejection{},
+	}
+
+	// Look for a header row that contains every configured header
+	headerRow, headerIndices := m.findHeaders(rows)
+	if headerRow != -1 {
+		report.HeaderRow = &headerRow
+	}
+	for name, column := range m.columns() {
+		if index, ok := headerIndices[name]; ok {
+			index := index
+			report.Columns[name] = ResolvedColumn{Source: "header", Index: &index}
+		} else if column.Offset != nil {
+			report.Columns[name] = ResolvedColumn{Source: "offset", Offset: column.Offset}
+		} else {
+			report.Columns[name] = ResolvedColumn{Source: "unresolved"}
+		}
+	}
+
+	productsMap := make(map[string]map[string]products.PartialProduct)
+	for i, row := range rows {
+		if i == headerRow {
+			report.reject(i, RejectedHeader, "")
+			continue
+		}
+
+		result, reason, detail := m.parseRow(row, profitCenterPrefix, headerIndices)
+		if result == nil {
+			report.reject(i, reason, detail)
+			continue
+		}
+
+		// Initialize the inner map if needed
+		location := result.LocationIdentifier
+		if _, ok := productsMap[location]; !ok {
+			productsMap[location] = make(map[string]products.PartialProduct)
+		}
+
+		productsMap[location][result.PartialProduct.ID] = result.PartialProduct
+		report.ImportedRowCount++
+	}
+
+	return productsMap, report
+}
+
+// Finds the first row that contains every configured header,
+// returning its index (or -1 if none was found)
+// and the index of each column within it
+func (m *ReportMapping) findHeaders(rows [][]string) (int, map[string]int) {
+	wanted := make(map[string]string)
+	for name, column := range m.columns() {
+		if header := strings.TrimSpace(column.Header); header != "" {
+			wanted[name] = strings.ToLower(header)
+		}
+	}
+	if len(wanted) == 0 {
+		return -1, map[string]int{}
+	}
+
+	for i, row := range rows {
+		indices := make(map[string]int)
+		for j, cell := range row {
+			cell = strings.ToLower(strings.TrimSpace(cell))
+			for name, header := range wanted {
+				if _, found := indices[name]; !found && cell == header {
+					indices[name] = j
+				}
+			}
+		}
+
+		if len(indices) == len(wanted) {
+			return i, indices
+		}
+	}
+
+	return -1, map[string]int{}
+}
+
+// Parses a single row, returning the reason (and a human-readable detail)
+// if it was rejected
+func (m *ReportMapping) parseRow(row []string, profitCenterPrefix string,
+	headerIndices map[string]int) (*parseResult, string, string) {
+
+	// Scan each cell until it sees the profit center prefix
+	profitCenterIndex := -1
+	for i, cell := range row {
+		if strings.HasPrefix(cell, profitCenterPrefix) {
+			profitCenterIndex = i
+			break
+		}
+	}
+	if profitCenterIndex == -1 {
+		return nil, RejectedNoProfitCenter, ""
+	}
+	locName := strings.TrimSpace(strings.TrimPrefix(row[profitCenterIndex], profitCenterPrefix))
+
+	// Resolve the cell for each column, ensuring array accesses are within bounds
+	cell := func(name string, column ReportColumn) (string, bool) {
+		index := -1
+		if headerIndex, ok := headerIndices[name]; ok {
+			index = headerIndex
+		} else if column.Offset != nil {
+			index = profitCenterIndex + *column.Offset
+		}
+		if index < 0 || index >= len(row) {
+			return "", false
+		}
+		return row[index], true
+	}
+
+	name, ok := cell("name", m.Name)
+	if !ok {
+		return nil, RejectedOutOfBounds, "name column is out of bounds"
+	}
+	id, ok := cell("id", m.ID)
+	if !ok {
+		return nil, RejectedOutOfBounds, "ID column is out of bounds"
+	}
+	amountRaw, ok := cell("quantity", m.Quantity)
+	if !ok {
+		return nil, RejectedOutOfBounds, "quantity column is out of bounds"
+	}
+
+	if strings.TrimSpace(id) == "" {
+		return nil, RejectedMissingID, ""
+	}
+	if strings.TrimSpace(name) == "" {
+		return nil, RejectedMissingName, fmt.Sprintf("product '%s' has no name", id)
+	}
+
+	amount, err := strconv.Atoi(strings.TrimSpace(amountRaw))
+	if err != nil {
+		return nil, RejectedBadQuantity, fmt.Sprintf("product '%s' has quantity '%s'", id, amountRaw)
+	}
+
+	// Don't let negative item amounts get past parsing
+	if amount < 0 {
+		amount = 0
+	}
+
+	return &parseResult{
+		PartialProduct: products.PartialProduct{
+			Name:   name,
+			ID:     id,
+			Amount: amount,
+		},
+		LocationIdentifier: locName,
+	}, "", ""
+}
@@ -0,0 +1,247 @@
+package transact
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/jd-116/klemis-kitchen-api/products"
+)
+
+const testProfitCenterPrefix = "Profit Center -"
+
+func offset(value int) *int {
+	return &value
+}
+
+// Creates a mapping that only uses offsets from the profit center cell
+func offsetMapping() *ReportMapping {
+	return &ReportMapping{
+		ID:       ReportColumn{Offset: offset(1)},
+		Name:     ReportColumn{Offset: offset(2)},
+		Quantity: ReportColumn{Offset: offset(3)},
+	}
+}
+
+func TestParseRows(t *testing.T) {
+	tests := []struct {
+		name     string
+		row      []string
+		location string
+		expected *products.PartialProduct
+		reason   string
+	}{
+		{"imported", []string{"Profit Center - Klemis", "101", "Chips", "5"}, "Klemis",
+			&products.PartialProduct{ID: "101", Name: "Chips", Amount: 5}, ""},
+		{"profit center after other cells", []string{"", "x", "Profit Center -West ", "102", "Soda", " 12 "}, "West",
+			&products.PartialProduct{ID: "102", Name: "Soda", Amount: 12}, ""},
+		{"negative quantity", []string{"Profit Center - Klemis", "103", "Gum", "-3"}, "Klemis",
+			&products.PartialProduct{ID: "103", Name: "Gum", Amount: 0}, ""},
+		{"no profit center", []string{"Total", "", "", "40"}, "", nil, RejectedNoProfitCenter},
+		{"empty row", []string{}, "", nil, RejectedNoProfitCenter},
+		{"short row", []string{"Profit Center - Klemis", "104", "Cookie"}, "", nil, RejectedOutOfBounds},
+		{"missing ID", []string{"Profit Center - Klemis", " ", "Cookie", "1"}, "", nil, RejectedMissingID},
+		{"missing name", []string{"Profit Center - Klemis", "105", "", "1"}, "", nil, RejectedMissingName},
+		{"bad quantity", []string{"Profit Center - Klemis", "106", "Bar", "lots"}, "", nil, RejectedBadQuantity},
+		{"fractional quantity", []string{"Profit Center - Klemis", "107", "Bar", "1.5"}, "", nil, RejectedBadQuantity},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			productsMap, report := offsetMapping().Parse([][]string{test.row}, testProfitCenterPrefix)
+			if report.RawRowCount != 1 {
+				t.Errorf("expected 1 raw row, got %d", report.RawRowCount)
+			}
+
+			if test.expected == nil {
+				if len(productsMap) != 0 || report.ImportedRowCount != 0 {
+					t.Errorf("expected the row to be rejected, got %v", productsMap)
+				}
+				if report.RejectionCounts[test.reason] != 1 || len(report.Rejections) != 1 ||
+					report.Rejections[0].Reason != test.reason || report.Rejections[0].Row != 0 {
+					t.Errorf("expected a single %s rejection, got %v", test.reason, report.Rejections)
+				}
+				return
+			}
+
+			actual, ok := productsMap[test.location][test.expected.ID]
+			if !ok || actual.Name != test.expected.Name || actual.Amount != test.expected.Amount {
+				t.Errorf("expected %+v at %q, got %v", *test.expected, test.location, productsMap)
+			}
+			if report.ImportedRowCount != 1 || len(report.Rejections) != 0 {
+				t.Errorf("expected the row to be imported, got rejections %v", report.Rejections)
+			}
+		})
+	}
+}
+
+func TestParseHeaders(t *testing.T) {
+	rows := [][]string{
+		{"Inventory Report", "", "", ""},
+		{"Location", " ITEM ID", "Description", "On Hand"},
+		{"Profit Center - Klemis", "201", "Gum", "7"},
+		{"Profit Center - West", "202", "Mints"},
+	}
+
+	tests := []struct {
+		name       string
+		mapping    *ReportMapping
+		headerRow  *int
+		sources    map[string]string
+		imported   map[string]string
+		rejections map[string]int
+	}{
+		{
+			name: "headers found",
+			mapping: &ReportMapping{
+				ID:       ReportColumn{Header: "Item ID"},
+				Name:     ReportColumn{Header: "description"},
+				Quantity: ReportColumn{Header: "on hand", Offset: offset(5)},
+			},
+			headerRow: offset(1),
+			sources:   map[string]string{"id": "header", "name": "header", "quantity": "header"},
+			imported:  map[string]string{"Klemis": "201"},
+			rejections: map[string]int{
+				RejectedNoProfitCenter: 1,
+				RejectedHeader:         1,
+				RejectedOutOfBounds:    1,
+			},
+		},
+		{
+			name: "missing header falls back to offsets",
+			mapping: &ReportMapping{
+				ID:       ReportColumn{Header: "Item ID", Offset: offset(1)},
+				Name:     ReportColumn{Header: "Item Name", Offset: offset(2)},
+				Quantity: ReportColumn{Offset: offset(3)},
+			},
+			sources:  map[string]string{"id": "offset", "name": "offset", "quantity": "offset"},
+			imported: map[string]string{"Klemis": "201"},
+			rejections: map[string]int{
+				RejectedNoProfitCenter: 2,
+				RejectedOutOfBounds:    1,
+			},
+		},
+		{
+			name: "unresolved column",
+			mapping: &ReportMapping{
+				ID:       ReportColumn{Offset: offset(1)},
+				Name:     ReportColumn{Header: "Item Name"},
+				Quantity: ReportColumn{Offset: offset(3)},
+			},
+			sources:  map[string]string{"id": "offset", "name": "unresolved", "quantity": "offset"},
+			imported: map[string]string{},
+			rejections: map[string]int{
+				RejectedNoProfitCenter: 2,
+				RejectedOutOfBounds:    2,
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			productsMap, report := test.mapping.Parse(rows, testProfitCenterPrefix)
+
+			if (report.HeaderRow == nil) != (test.headerRow == nil) ||
+				(report.HeaderRow != nil && *report.HeaderRow != *test.headerRow) {
+				t.Errorf("expected header row %v, got %v", test.headerRow, report.HeaderRow)
+			}
+
+			for name, source := range test.sources {
+				if actual := report.Columns[name].Source; actual != source {
+					t.Errorf("expected column %s to come from %s, got %s", name, source, actual)
+				}
+			}
+
+			imported := 0
+			for location, id := range test.imported {
+				if _, ok := productsMap[location][id]; !ok {
+					t.Errorf("expected product %s at %s, got %v", id, location, productsMap)
+				}
+				imported++
+			}
+			if report.ImportedRowCount != imported {
+				t.Errorf("expected %d imported rows, got %d", imported, report.ImportedRowCount)
+			}
+
+			for reason, count := range test.rejections {
+				if actual := report.RejectionCounts[reason]; actual != count {
+					t.Errorf("expected %d %s rejections, got %d", count, reason, actual)
+				}
+			}
+			if len(report.RejectionCounts) != len(test.rejections) {
+				t.Errorf("expected rejections %v, got %v", test.rejections, report.RejectionCounts)
+			}
+		})
+	}
+}
+
+func TestParseHeaderColumnIndex(t *testing.T) {
+	mapping := &ReportMapping{
+		ID:       ReportColumn{Header: "ID"},
+		Name:     ReportColumn{Header: "Name"},
+		Quantity: ReportColumn{Header: "Qty"},
+	}
+	rows := [][]string{
+		{"Qty", "Name", "ID", "Location"},
+		{"3", "Chips", "301", "Profit Center - Klemis"},
+	}
+
+	productsMap, report := mapping.Parse(rows, testProfitCenterPrefix)
+	actual := productsMap["Klemis"]["301"]
+	if actual.Name != "Chips" || actual.Amount != 3 {
+		t.Errorf("expected Chips with 3 left, got %+v", actual)
+	}
+	if index := report.Columns["quantity"].Index; index == nil || *index != 0 {
+		t.Errorf("expected the quantity column at index 0, got %v", index)
+	}
+}
+
+func TestParseRecordsLimitedRejections(t *testing.T) {
+	rows := [][]string{}
+	for i := 0; i < maxRecordedRejections+50; i++ {
+		rows = append(rows, []string{fmt.Sprintf("Subtotal %d", i)})
+	}
+
+	_, report := offsetMapping().Parse(rows, testProfitCenterPrefix)
+	if count := report.RejectionCounts[RejectedNoProfitCenter]; count != len(rows) {
+		t.Errorf("expected %d rejections to be counted, got %d", len(rows), count)
+	}
+	if len(report.Rejections) != maxRecordedRejections {
+		t.Errorf("expected %d rejections to be recorded, got %d", maxRecordedRejections, len(report.Rejections))
+	}
+}
+
+func TestReportMappingValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		mapping ReportMapping
+		valid   bool
+	}{
+		{"offsets", *offsetMapping(), true},
+		{"headers", ReportMapping{
+			ID:       ReportColumn{Header: "ID"},
+			Name:     ReportColumn{Header: "Name"},
+			Quantity: ReportColumn{Header: "Qty"},
+		}, true},
+		{"blank header", ReportMapping{
+			ID:       ReportColumn{Header: " "},
+			Name:     ReportColumn{Header: "Name"},
+			Quantity: ReportColumn{Header: "Qty"},
+		}, false},
+		{"negative offset", ReportMapping{
+			ID:       ReportColumn{Header: "ID", Offset: offset(-1)},
+			Name:     ReportColumn{Header: "Name"},
+			Quantity: ReportColumn{Header: "Qty"},
+		}, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := test.mapping.validate()
+			if test.valid && err != nil {
+				t.Errorf("expected the mapping to be valid, got %v", err)
+			} else if !test.valid && err == nil {
+				t.Error("expected the mapping to be invalid")
+			}
+		})
+	}
+}
@@ -2,6 +2,7 @@ package transact
 
 import (
 	"bytes"
+	"context"
 	"encoding/csv"
 	"encoding/gob"
 	"encoding/json"
@@ -19,6 +20,9 @@ import (
 	"github.com/hako/durafmt"
 	"github.com/rs/zerolog"
 	"golang.org/x/net/html"
+
+	"github.com/jd-116/klemis-kitchen-api/metrics"
+	"github.com/jd-116/klemis-kitchen-api/tracing"
 )
 
 // Scraper struct to hold authentication state
@@ -31,8 +35,17 @@ type Scraper struct {
 	username      string
 	password      string
 	authToken     string
+	// Held for the entire process of generating and downloading a report
+	// (so that only one report is ever being generated at a time)
+	// and while the session is reloaded
 	sync.Mutex
 	logger zerolog.Logger
+
+	// Session state that can be read without waiting on the main lock,
+	// which is held for the entire duration of report generation
+	statusLock      sync.RWMutex
+	sessionLoadedAt time.Time
+	sessionErr      error
 }
 
 // NewScraper creates a new instance of the scraper
@@ -55,17 +68,33 @@ func NewScraper(
 		username: username,
 		password: password,
 		client: &http.Client{
-			Jar: jar,
+			Jar:       jar,
+			Transport: tracing.Transport("transact", nil),
 		},
 		logger: logger,
 	}, nil
 }
 
 // ReloadSession reloads the session on the scraper
-func (s *Scraper) ReloadSession() error {
+func (s *Scraper) ReloadSession(ctx context.Context) (err error) {
 	s.Lock()
 	defer s.Unlock()
 
+	ctx, span := tracing.Start(ctx, "transact.reload_session")
+
+	// Record the outcome of the reload once it finishes
+	defer func() {
+		tracing.End(span, err)
+		metrics.TransactSessionReloads.WithLabelValues(metrics.Outcome(err)).Inc()
+
+		s.statusLock.Lock()
+		defer s.statusLock.Unlock()
+		s.sessionErr = err
+		if err == nil {
+			s.sessionLoadedAt = time.Now()
+		}
+	}()
+
 	s.logger.Info().Msg("reloading Transact session")
 
 	// Clear the state
@@ -82,20 +111,20 @@ func (s *Scraper) ReloadSession() error {
 	s.client.Jar = jar
 
 	// Get the new client version
-	clientVersion, err := s.getClientVersion()
+	clientVersion, err := s.getClientVersion(ctx)
 	if err != nil {
 		return err
 	}
 	s.ClientVersion = clientVersion
 
 	// Obtain a new session cookie
-	err = s.getSessionCookie()
+	err = s.getSessionCookie(ctx)
 	if err != nil {
 		return err
 	}
 
 	// Obtain a new authentication token
-	authToken, err := s.getAuthenticationToken()
+	authToken, err := s.getAuthenticationToken(ctx)
 	if err != nil {
 		return err
 	}
@@ -109,14 +138,26 @@ func (s *Scraper) ReloadSession() error {
 	return nil
 }
 
+// SessionStatus gets the time that the session was last successfully loaded
+// (which is zero if it never was)
+// and the error from the most recent reload attempt, if it failed
+func (s *Scraper) SessionStatus() (time.Time, error) {
+	s.statusLock.RLock()
+	defer s.statusLock.RUnlock()
+
+	return s.sessionLoadedAt, s.sessionErr
+}
+
 // GetInventoryCSV is a goroutine that goes through the process
 // of getting the inventory CSV via a report.
 // Returns each CSV row as a string slice
-func (s *Scraper) GetInventoryCSV(csvReportName string, pollPeriod time.Duration,
+func (s *Scraper) GetInventoryCSV(ctx context.Context, csvReportName string, pollPeriod time.Duration,
 	pollTimeout time.Duration, reportType string) ([][]string, error) {
+	s.Lock()
+	defer s.Unlock()
 
 	// Get all favorite reports and then match the desired one
-	allFavoriteReports, err := s.getFavoriteReports()
+	allFavoriteReports, err := s.getFavoriteReports(ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -136,13 +177,13 @@ func (s *Scraper) GetInventoryCSV(csvReportName string, pollPeriod time.Duration
 	}
 
 	// Now, submit the request to generate the report
-	reportFileName, err := s.submitAndWait(matchedReport, reportType, pollPeriod, pollTimeout)
+	reportFileName, err := s.submitAndWait(ctx, matchedReport, reportType, pollPeriod, pollTimeout)
 	if err != nil {
 		return nil, err
 	}
 
 	// Download the report
-	reportContents, err := s.downloadReport(reportFileName)
+	reportContents, err := s.downloadReport(ctx, reportFileName)
 	if err != nil {
 		return nil, err
 	}
@@ -176,13 +217,11 @@ func (s *Scraper) logFavoriteReportNames(allFavoriteReports []map[string]interfa
 }
 
 // Submits a report generation request and polls until it is done,
-// returning the filename to the resultant report when done
-func (s *Scraper) submitAndWait(report map[string]interface{}, reportType string,
+// returning the filename to the resultant report when done.
+// The scraper lock must be held
+func (s *Scraper) submitAndWait(ctx context.Context, report map[string]interface{}, reportType string,
 	pollPeriod time.Duration, pollTimeout time.Duration) (string, error) {
 
-	s.Lock()
-	defer s.Unlock()
-
 	reportNameRaw, ok := report["name"]
 	if !ok {
 		return "", errors.New("no 'name' field found on report")
@@ -202,7 +241,7 @@ func (s *Scraper) submitAndWait(report map[string]interface{}, reportType string
 	}
 	reportID := int(reportIDFloat)
 
-	err := s.submitReport(report, reportType, reportID, reportName)
+	err := s.submitReport(ctx, report, reportType, reportID, reportName)
 	if err != nil {
 		return "", err
 	}
@@ -217,26 +256,27 @@ func (s *Scraper) submitAndWait(report map[string]interface{}, reportType string
 			return "", fmt.Errorf("report polling for report with name '%s' timed out after %s",
 				reportName, timeoutHumanDuration)
 		case <-time.After(pollPeriod):
-			reportFile, err := s.isReportReady(reportID, reportName)
+			reportFile, err := s.isReportReady(ctx, reportID, reportName)
 			if err != nil {
+				metrics.TransactReportPolls.WithLabelValues("error").Inc()
 				return "", err
 			}
 
 			if reportFile == nil {
 				// Poll again after delay
+				metrics.TransactReportPolls.WithLabelValues("not_ready").Inc()
 				continue
 			}
 
+			metrics.TransactReportPolls.WithLabelValues("ready").Inc()
 			return *reportFile, nil
 		}
 	}
 }
 
-// downloadReport downloads a report file from the Transact API
-func (s *Scraper) downloadReport(reportName string) (string, error) {
-	s.Lock()
-	defer s.Unlock()
-
+// downloadReport downloads a report file from the Transact API.
+// The scraper lock must be held
+func (s *Scraper) downloadReport(ctx context.Context, reportName string) (string, error) {
 	// Construct the report URL by escaping each path segment,
 	// but not the slashes between them
 	filename := "QuadPoint POS/" + reportName
@@ -257,7 +297,7 @@ func (s *Scraper) downloadReport(reportName string) (string, error) {
 		Str("report_name", reportName).
 		Msg("downloading report file from Transact")
 
-	req, err := http.NewRequest(method, url, nil)
+	req, err := http.NewRequestWithContext(ctx, method, url, nil)
 	if err != nil {
 		return "", err
 	}
@@ -301,7 +341,7 @@ type isReportReadyResult struct {
 
 // isReportReady polls the Transact API to determine if the report is ready for downloading.
 // If it is, it returns the filename from the API, which can be used with downloadReport
-func (s *Scraper) isReportReady(reportID int, reportName string) (*string, error) {
+func (s *Scraper) isReportReady(ctx context.Context, reportID int, reportName string) (*string, error) {
 	// Create the isReportReady body JSON
 	isReportReadyRequestBody, err := json.Marshal(isReportReadyBody{ScheduleID: reportID})
 	if err != nil {
@@ -320,7 +360,7 @@ func (s *Scraper) isReportReady(reportID int, reportName string) (*string, error
 		Str("report_name", reportName).
 		Msg("polling the Transact API to determine if report is ready")
 
-	req, err := http.NewRequest(method, url, bytes.NewReader(isReportReadyRequestBody))
+	req, err := http.NewRequestWithContext(ctx, method, url, bytes.NewReader(isReportReadyRequestBody))
 	if err != nil {
 		return nil, err
 	}
@@ -375,11 +415,9 @@ type favoriteReportsResult struct {
 	Items []map[string]interface{} `json:"RootResults"`
 }
 
-// getFavoriteReports gets all favorite reports
-func (s *Scraper) getFavoriteReports() ([]map[string]interface{}, error) {
-	s.Lock()
-	defer s.Unlock()
-
+// getFavoriteReports gets all favorite reports.
+// The scraper lock must be held
+func (s *Scraper) getFavoriteReports(ctx context.Context) ([]map[string]interface{}, error) {
 	url := s.baseURL + "/QPWebOffice-Web-QuadPointDomain.svc/JSON/GetFavorites"
 	method := "GET"
 
@@ -389,7 +427,7 @@ func (s *Scraper) getFavoriteReports() ([]map[string]interface{}, error) {
 		Str("method", method).
 		Msg("getting all favorite reports")
 
-	req, err := http.NewRequest(method, url, nil)
+	req, err := http.NewRequestWithContext(ctx, method, url, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -448,7 +486,7 @@ func deepCopyMap(m map[string]interface{}) (map[string]interface{}, error) {
 // submitReport sends the given report definition to the API
 // and requests it be generated.
 // Note: s.Mutex should be locked before calling this function
-func (s *Scraper) submitReport(reportDefinition map[string]interface{},
+func (s *Scraper) submitReport(ctx context.Context, reportDefinition map[string]interface{},
 	reportType string, reportID int, reportName string) error {
 
 	// Create the submit report body JSON
@@ -493,7 +531,7 @@ func (s *Scraper) submitReport(reportDefinition map[string]interface{},
 		Str("report_type", reportType).
 		Msg("requesting report to be generated")
 
-	req, err := http.NewRequest(method, url, bytes.NewReader(submitReportRequestBody))
+	req, err := http.NewRequestWithContext(ctx, method, url, bytes.NewReader(submitReportRequestBody))
 	if err != nil {
 		return err
 	}
@@ -524,7 +562,7 @@ func (s *Scraper) submitReport(reportDefinition map[string]interface{},
 		Str("report_type", reportType).
 		Msg("finalizing report request")
 
-	req, err = http.NewRequest(method, url, nil)
+	req, err = http.NewRequestWithContext(ctx, method, url, nil)
 	if err != nil {
 		return err
 	}
@@ -551,7 +589,7 @@ func (s *Scraper) submitReport(reportDefinition map[string]interface{},
 
 // Attempts to obtain a new session cookie from the Transact API,
 // and if successful, stores it in the cookie jar contained within the Scraper
-func (s *Scraper) getSessionCookie() error {
+func (s *Scraper) getSessionCookie(ctx context.Context) error {
 	url := s.baseURL + "/QPWebOffice-Web-AuthenticationService.svc/JSON/LoggedIn"
 	method := "POST"
 	payload := strings.NewReader("{}")
@@ -563,7 +601,7 @@ func (s *Scraper) getSessionCookie() error {
 		Str("body", "{}").
 		Msg("getting new Transact session cookie")
 
-	req, err := http.NewRequest(method, url, payload)
+	req, err := http.NewRequestWithContext(ctx, method, url, payload)
 	if err != nil {
 		return err
 	}
@@ -591,7 +629,7 @@ func (s *Scraper) getSessionCookie() error {
 }
 
 // Attempts to get the client version to use
-func (s *Scraper) getClientVersion() (string, error) {
+func (s *Scraper) getClientVersion(ctx context.Context) (string, error) {
 
 	url := s.baseURL + "/?tenant=" + s.tenant
 	method := "GET"
@@ -602,7 +640,7 @@ func (s *Scraper) getClientVersion() (string, error) {
 		Str("method", method).
 		Msg("getting current Transact client version")
 
-	req, err := http.NewRequest(method, url, nil)
+	req, err := http.NewRequestWithContext(ctx, method, url, nil)
 	if err != nil {
 		return "", err
 	}
@@ -661,7 +699,7 @@ func collectText(n *html.Node, buf *bytes.Buffer) {
 
 // Attempts to get a new authentication token by sending a request to the login route
 // using the current session cookie
-func (s *Scraper) getAuthenticationToken() (string, error) {
+func (s *Scraper) getAuthenticationToken(ctx context.Context) (string, error) {
 	url := s.baseURL + "/QPWebOffice-Web-AuthenticationService.svc/JSON/Authenticate"
 	method := "POST"
 
@@ -686,7 +724,7 @@ func (s *Scraper) getAuthenticationToken() (string, error) {
 		return "", nil
 	}
 
-	req, err := http.NewRequest(method, url, bytes.NewReader(payload))
+	req, err := http.NewRequestWithContext(ctx, method, url, bytes.NewReader(payload))
 	if err != nil {
 		return "", err
 	}
@@ -0,0 +1,43 @@
+package reservations
+
+import "fmt"
+
+// LimitExceededError is an error used to encode when a user
+// has gone over one of the per-user reservation limits
+type LimitExceededError struct {
+	Limit string
+	Max   int
+}
+
+// NewLimitExceededError constructs a new LimitExceededError
+func NewLimitExceededError(limit string, max int) *LimitExceededError {
+	return &LimitExceededError{
+		Limit: limit,
+		Max:   max,
+	}
+}
+
+func (e *LimitExceededError) Error() string {
+	return fmt.Sprintf("reservation limit exceeded: at most %d %s",
+		e.Max, e.Limit)
+}
+
+// InvalidTransitionError is an error used to encode when a reservation
+// can't be moved from its current status to the requested one
+type InvalidTransitionError struct {
+	From string
+	To   string
+}
+
+// NewInvalidTransitionError constructs a new InvalidTransitionError
+func NewInvalidTransitionError(from string, to string) *InvalidTransitionError {
+	return &InvalidTransitionError{
+		From: from,
+		To:   to,
+	}
+}
+
+func (e *InvalidTransitionError) Error() string {
+	return fmt.Sprintf("cannot move a reservation from '%s' to '%s'",
+		e.From, e.To)
+}
@@ -0,0 +1,313 @@
+package reservations
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/hako/durafmt"
+	"github.com/rs/zerolog"
+	"github.com/segmentio/ksuid"
+
+	"github.com/jd-116/klemis-kitchen-api/config"
+	"github.com/jd-116/klemis-kitchen-api/db"
+	"github.com/jd-116/klemis-kitchen-api/products"
+	"github.com/jd-116/klemis-kitchen-api/types"
+)
+
+// Database is the subset of the database provider
+// that the reservation manager needs
+type Database interface {
+	db.ReservationProvider
+	db.CanonicalProductProvider
+}
+
+// Which statuses each status can be moved to by an admin
+// (expiry is handled by the background job)
+var transitions = map[string][]string{
+	types.ReservationPending: {types.ReservationReady, types.ReservationPickedUp},
+	types.ReservationReady:   {types.ReservationPickedUp},
+}
+
+// Manager creates reservations against the current inventory
+// and periodically expires ones that were never picked up.
+//
+// Reservations are checked against each other while holding a lock in the manager,
+// so the API must only run as a single instance
+// (otherwise two instances could both reserve the last of an item)
+type Manager struct {
+	database Database
+	products products.PartialProductProvider
+	stop     chan struct{}
+
+	// Config values
+	holdDuration      time.Duration
+	expiryCheckPeriod time.Duration
+	maxActivePerUser  int
+	maxItems          int
+
+	// Held while validating and creating a reservation
+	// so that two reservations can't both claim the last of an item
+	createLock sync.Mutex
+	logger     zerolog.Logger
+}
+
+// NewManager creates the manager from the reservation configuration
+// (doesn't start goroutines)
+func NewManager(database Database, products products.PartialProductProvider,
+	cfg config.ReservationConfig, logger zerolog.Logger) *Manager {
+
+	return &Manager{
+		database: database,
+		products: products,
+		stop:     make(chan struct{}),
+
+		holdDuration:      time.Duration(cfg.HoldDuration),
+		expiryCheckPeriod: time.Duration(cfg.ExpiryCheckPeriod),
+		maxActivePerUser:  cfg.MaxActivePerUser,
+		maxItems:          cfg.MaxItems,
+
+		logger: logger,
+	}
+}
+
+// Connect starts the goroutine that periodically expires reservations
+func (m *Manager) Connect(ctx context.Context) error {
+	go m.periodExpire()
+	return nil
+}
+
+// Disconnect stops the expiry goroutine
+func (m *Manager) Disconnect(ctx context.Context) error {
+	m.stop <- struct{}{}
+	return nil
+}
+
+// Create validates and creates a new reservation for the user at the location.
+// Items must already have non-empty product IDs and positive quantities,
+// and are stored under the canonical product they were merged into (if any).
+// Each item must be available after subtracting the items held
+// by every other active reservation at the location
+func (m *Manager) Create(ctx context.Context, location types.Location, username string,
+	items []types.ReservationItem) (*types.Reservation, error) {
+
+	aliases, err := m.loadAliases(ctx, location)
+	if err != nil {
+		return nil, err
+	}
+
+	// Combine duplicate products and check the per-reservation limit
+	quantities := make(map[string]int)
+	order := []string{}
+	total := 0
+	for _, item := range items {
+		productID := aliases.CanonicalID(location.InventoryIdentifier(), item.ProductID)
+		if _, ok := quantities[productID]; !ok {
+			order = append(order, productID)
+		}
+		quantities[productID] += item.Quantity
+		total += item.Quantity
+	}
+	if total > m.maxItems {
+		return nil, NewLimitExceededError("items per reservation", m.maxItems)
+	}
+
+	m.createLock.Lock()
+	defer m.createLock.Unlock()
+
+	// Check the per-user limit
+	userReservations, err := m.database.GetUserReservations(ctx, username, types.ActiveReservationStatuses)
+	if err != nil {
+		return nil, err
+	}
+	if len(userReservations) >= m.maxActivePerUser {
+		return nil, NewLimitExceededError("active reservations per user", m.maxActivePerUser)
+	}
+
+	// Check that every item is still available
+	held, err := m.holds(ctx, location, aliases)
+	if err != nil {
+		return nil, err
+	}
+	for _, productID := range order {
+		partialProduct, err := aliases.GetProduct(m.products, location.InventoryIdentifier(), productID)
+		if err != nil {
+			return nil, err
+		}
+
+		available := partialProduct.Amount - held[productID]
+		if available < quantities[productID] {
+			if available < 0 {
+				available = 0
+			}
+			return nil, db.NewInsufficientAmountError(productID, quantities[productID], available)
+		}
+	}
+
+	now := time.Now()
+	reservation := types.Reservation{
+		LocationID: location.ID,
+		Username:   username,
+		Items:      []types.ReservationItem{},
+		Status:     types.ReservationPending,
+		CreatedAt:  now,
+		UpdatedAt:  now,
+		ExpiresAt:  now.Add(m.holdDuration),
+	}
+	for _, productID := range order {
+		reservation.Items = append(reservation.Items, types.ReservationItem{
+			ProductID: productID,
+			Quantity:  quantities[productID],
+		})
+	}
+
+	// Generate globally unique IDs for the reservation
+	for {
+		rand, err := ksuid.NewRandom()
+		if err != nil {
+			return nil, err
+		}
+
+		reservation.ID = rand.String()
+
+		err = m.database.CreateReservation(ctx, reservation)
+		if err != nil {
+			// If the error was a duplicate ID; try again
+			if _, ok := err.(*db.DuplicateIDError); ok {
+				continue
+			}
+
+			return nil, err
+		}
+
+		return &reservation, nil
+	}
+}
+
+// Holds gets the total quantity of each product held
+// by reservations at the location:
+// active reservations and ones picked up since the inventory was last loaded.
+// Products are keyed by the canonical product they were merged into (if any)
+func (m *Manager) Holds(ctx context.Context, location types.Location) (map[string]int, error) {
+	aliases, err := m.loadAliases(ctx, location)
+	if err != nil {
+		return nil, err
+	}
+
+	return m.holds(ctx, location, aliases)
+}
+
+func (m *Manager) holds(ctx context.Context, location types.Location,
+	aliases *products.Aliases) (map[string]int, error) {
+
+	loadedAt, err := m.products.LocationLoadedAt(location.InventoryIdentifier())
+	if err != nil {
+		return nil, err
+	}
+
+	reservations, err := m.database.GetHoldingReservations(ctx, location.ID, loadedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	held := make(map[string]int)
+	for _, reservation := range reservations {
+		if !reservation.HoldsItems(loadedAt) {
+			continue
+		}
+
+		for _, item := range reservation.Items {
+			held[aliases.CanonicalID(location.InventoryIdentifier(), item.ProductID)] += item.Quantity
+		}
+	}
+
+	return held, nil
+}
+
+// Loads the aliases of canonical products at the location
+func (m *Manager) loadAliases(ctx context.Context, location types.Location) (*products.Aliases, error) {
+	canonicalProducts, err := m.database.GetAllCanonicalProducts(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return products.NewAliases(canonicalProducts, []types.Location{location}), nil
+}
+
+// Transition moves a reservation at the location to a new status
+func (m *Manager) Transition(ctx context.Context, locationID string, id string,
+	status string) (*types.Reservation, error) {
+
+	reservation, err := m.database.GetReservation(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if reservation.LocationID != locationID {
+		return nil, db.NewNotFoundError(id)
+	}
+
+	allowed := false
+	for _, next := range transitions[reservation.Status] {
+		if next == status {
+			allowed = true
+			break
+		}
+	}
+	if !allowed {
+		return nil, NewInvalidTransitionError(reservation.Status, status)
+	}
+
+	updated, err := m.database.UpdateReservationStatus(ctx, id, reservation.Status, status)
+	if err != nil {
+		// The reservation changed (likely expired) after it was read
+		if _, ok := err.(*db.NotFoundError); ok {
+			return nil, NewInvalidTransitionError(reservation.Status, status)
+		}
+
+		return nil, err
+	}
+
+	return updated, nil
+}
+
+// Periodically expires reservations that have passed their expiry time
+func (m *Manager) periodExpire() {
+	humanDuration := durafmt.Parse(m.expiryCheckPeriod).LimitFirstN(2).String()
+	m.logger.
+		Info().
+		Str("interval", humanDuration).
+		Msg("started timer to expire reservations")
+	for {
+		select {
+		case <-m.stop:
+			return
+		case <-time.After(m.expiryCheckPeriod):
+			m.tryExpire()
+		}
+	}
+}
+
+// Attempts to expire reservations,
+// printing out an error if it occurs
+func (m *Manager) tryExpire() {
+	ctx, cancel := context.WithTimeout(context.Background(), m.expiryCheckPeriod)
+	defer cancel()
+
+	count, err := m.database.ExpireReservations(ctx, time.Now())
+	if err != nil {
+		// Report error,
+		// but continue the goroutine
+		m.logger.
+			Error().
+			Err(err).
+			Msg("an error occurred while expiring reservations")
+		return
+	}
+
+	if count > 0 {
+		m.logger.
+			Info().
+			Int64("expired_count", count).
+			Msg("expired reservations")
+	}
+}
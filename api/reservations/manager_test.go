@@ -0,0 +1,256 @@
+package reservations
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"github.com/jd-116/klemis-kitchen-api/db"
+	"github.com/jd-116/klemis-kitchen-api/products"
+	"github.com/jd-116/klemis-kitchen-api/types"
+)
+
+// Stores reservations in memory,
+// returning every reservation at a location as holding
+// so that the manager has to filter them itself
+type fakeDatabase struct {
+	Database
+	reservations      map[string]types.Reservation
+	canonicalProducts []types.CanonicalProduct
+}
+
+func (f *fakeDatabase) GetAllCanonicalProducts(ctx context.Context) ([]types.CanonicalProduct, error) {
+	return f.canonicalProducts, nil
+}
+
+func (f *fakeDatabase) GetReservation(ctx context.Context, id string) (*types.Reservation, error) {
+	reservation, ok := f.reservations[id]
+	if !ok {
+		return nil, db.NewNotFoundError(id)
+	}
+	return &reservation, nil
+}
+
+func (f *fakeDatabase) GetHoldingReservations(ctx context.Context, locationID string,
+	loadedAt time.Time) ([]types.Reservation, error) {
+
+	reservations := []types.Reservation{}
+	for _, reservation := range f.reservations {
+		if reservation.LocationID == locationID {
+			reservations = append(reservations, reservation)
+		}
+	}
+	return reservations, nil
+}
+
+func (f *fakeDatabase) GetUserReservations(ctx context.Context, username string,
+	statuses []string) ([]types.Reservation, error) {
+
+	return []types.Reservation{}, nil
+}
+
+func (f *fakeDatabase) CreateReservation(ctx context.Context, reservation types.Reservation) error {
+	f.reservations[reservation.ID] = reservation
+	return nil
+}
+
+func (f *fakeDatabase) UpdateReservationStatus(ctx context.Context, id string, currentStatus string,
+	status string) (*types.Reservation, error) {
+
+	reservation, ok := f.reservations[id]
+	if !ok || reservation.Status != currentStatus {
+		return nil, db.NewNotFoundError(id)
+	}
+	reservation.Status = status
+	reservation.UpdatedAt = time.Now()
+	f.reservations[id] = reservation
+	return &reservation, nil
+}
+
+func newTestManager(reservations ...types.Reservation) (*Manager, *products.Cache) {
+	database := &fakeDatabase{reservations: make(map[string]types.Reservation)}
+	for _, reservation := range reservations {
+		database.reservations[reservation.ID] = reservation
+	}
+
+	cache := &products.Cache{}
+	return &Manager{
+		database: database,
+		products: cache,
+		logger:   zerolog.Nop(),
+
+		holdDuration:     time.Hour,
+		maxActivePerUser: 10,
+		maxItems:         10,
+	}, cache
+}
+
+func TestManagerTransition(t *testing.T) {
+	tests := []struct {
+		from    string
+		to      string
+		allowed bool
+	}{
+		{types.ReservationPending, types.ReservationReady, true},
+		{types.ReservationPending, types.ReservationPickedUp, true},
+		{types.ReservationPending, types.ReservationExpired, false},
+		{types.ReservationPending, types.ReservationPending, false},
+		{types.ReservationReady, types.ReservationPickedUp, true},
+		{types.ReservationReady, types.ReservationPending, false},
+		{types.ReservationReady, types.ReservationExpired, false},
+		{types.ReservationPickedUp, types.ReservationReady, false},
+		{types.ReservationExpired, types.ReservationReady, false},
+		{types.ReservationExpired, types.ReservationPickedUp, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.from+" to "+test.to, func(t *testing.T) {
+			manager, _ := newTestManager(types.Reservation{
+				ID:         "reservation",
+				LocationID: "location",
+				Status:     test.from,
+			})
+
+			updated, err := manager.Transition(context.Background(), "location", "reservation", test.to)
+			if !test.allowed {
+				if _, ok := err.(*InvalidTransitionError); !ok {
+					t.Fatalf("Transition() error = %v, expected an InvalidTransitionError", err)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("Transition() returned an error: %v", err)
+			}
+			if updated.Status != test.to {
+				t.Errorf("status = '%s', expected '%s'", updated.Status, test.to)
+			}
+		})
+	}
+}
+
+func TestManagerTransitionOtherLocation(t *testing.T) {
+	manager, _ := newTestManager(types.Reservation{
+		ID:         "reservation",
+		LocationID: "location",
+		Status:     types.ReservationPending,
+	})
+
+	_, err := manager.Transition(context.Background(), "other", "reservation", types.ReservationReady)
+	if _, ok := err.(*db.NotFoundError); !ok {
+		t.Fatalf("Transition() error = %v, expected a NotFoundError", err)
+	}
+}
+
+func TestManagerHolds(t *testing.T) {
+	location := types.Location{ID: "location", InventorySource: types.InventorySourceNative}
+	item := func(quantity int) []types.ReservationItem {
+		return []types.ReservationItem{{ProductID: "product", Quantity: quantity}}
+	}
+
+	manager, cache := newTestManager()
+	cache.Load(map[string]map[string]products.PartialProduct{
+		"location": {"product": {ID: "product", Amount: 10}},
+	})
+	loadedAt, _ := cache.LocationLoadedAt("location")
+
+	tests := []struct {
+		name        string
+		reservation types.Reservation
+		held        int
+	}{
+		{"pending", types.Reservation{Status: types.ReservationPending, Items: item(1)}, 1},
+		{"ready", types.Reservation{Status: types.ReservationReady, Items: item(2)}, 2},
+		{"picked up after load", types.Reservation{Status: types.ReservationPickedUp,
+			UpdatedAt: loadedAt.Add(time.Minute), Items: item(3)}, 3},
+		{"picked up before load", types.Reservation{Status: types.ReservationPickedUp,
+			UpdatedAt: loadedAt.Add(-time.Minute), Items: item(4)}, 0},
+		{"expired", types.Reservation{Status: types.ReservationExpired, Items: item(5)}, 0},
+		{"other location", types.Reservation{LocationID: "other", Status: types.ReservationPending,
+			Items: item(6)}, 0},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			reservation := test.reservation
+			reservation.ID = "reservation"
+			if reservation.LocationID == "" {
+				reservation.LocationID = location.ID
+			}
+			manager.database.(*fakeDatabase).reservations = map[string]types.Reservation{
+				reservation.ID: reservation,
+			}
+
+			held, err := manager.Holds(context.Background(), location)
+			if err != nil {
+				t.Fatalf("Holds() returned an error: %v", err)
+			}
+			if held["product"] != test.held {
+				t.Errorf("held = %d, expected %d", held["product"], test.held)
+			}
+		})
+	}
+}
+
+// Regression test for reserving a canonical product ID from a product list,
+// which used to be looked up directly in the inventory and not found
+func TestManagerCreateCanonicalProduct(t *testing.T) {
+	location := types.Location{ID: "location", InventorySource: types.InventorySourceNative}
+
+	manager, cache := newTestManager(types.Reservation{
+		ID:         "existing",
+		LocationID: location.ID,
+		Status:     types.ReservationPending,
+		Items:      []types.ReservationItem{{ProductID: "1001", Quantity: 2}},
+	})
+	manager.database.(*fakeDatabase).canonicalProducts = []types.CanonicalProduct{{
+		ID:   "canonical-milk",
+		Name: "Milk",
+		Aliases: []types.ProductAlias{
+			{LocationID: location.ID, ProductID: "1001"},
+			{LocationID: location.ID, ProductID: "1002"},
+		},
+	}}
+	cache.Load(map[string]map[string]products.PartialProduct{
+		"location": {
+			"1001": {ID: "1001", Amount: 2},
+			"1002": {ID: "1002", Amount: 3},
+		},
+	})
+
+	tests := []struct {
+		name      string
+		items     []types.ReservationItem
+		available bool
+	}{
+		{"canonical ID", []types.ReservationItem{{ProductID: "canonical-milk", Quantity: 3}}, true},
+		// The existing reservation of an alias is held against the canonical product
+		{"more than available", []types.ReservationItem{{ProductID: "canonical-milk", Quantity: 4}}, false},
+		{"alias and canonical ID combined", []types.ReservationItem{
+			{ProductID: "1002", Quantity: 2},
+			{ProductID: "canonical-milk", Quantity: 2},
+		}, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			reservation, err := manager.Create(context.Background(), location, "user", test.items)
+			if !test.available {
+				if _, ok := err.(*db.InsufficientAmountError); !ok {
+					t.Fatalf("Create() error = %v, expected an InsufficientAmountError", err)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("Create() returned an error: %v", err)
+			}
+			if len(reservation.Items) != 1 || reservation.Items[0].ProductID != "canonical-milk" {
+				t.Errorf("items = %+v, expected them under canonical-milk", reservation.Items)
+			}
+			delete(manager.database.(*fakeDatabase).reservations, reservation.ID)
+		})
+	}
+}
@@ -0,0 +1,267 @@
+package search
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/lithammer/fuzzysearch/fuzzy"
+)
+
+// Weights given to each way a query token can match a product
+const (
+	weightExactName    = 100.0
+	weightNamePrefix   = 50.0
+	weightNameToken    = 10.0
+	weightInitials     = 8.0
+	weightTokenPrefix  = 6.0
+	weightMetadata     = 3.0
+	weightMetadataPart = 1.0
+	weightFuzzyName    = 1.0
+
+	// Matches through a synonym score slightly lower than direct matches
+	synonymDiscount = 0.9
+)
+
+// Document is the searchable text of a single product
+type Document struct {
+	ID string
+	// Every name the product has at any location
+	Names []string
+	// Other text about the product, such as its product metadata
+	Text []string
+}
+
+// Index is an immutable search index over product documents
+// and groups of equivalent search terms
+type Index struct {
+	documents map[string]*indexedDocument
+	synonyms  [][]string
+}
+
+type indexedDocument struct {
+	names         []string
+	nameTokens    map[string]struct{}
+	initials      []string
+	metadataWords map[string]struct{}
+}
+
+// NewIndex builds an index from the product documents and synonym groups
+func NewIndex(documents []Document, synonyms [][]string) *Index {
+	index := &Index{
+		documents: make(map[string]*indexedDocument),
+		synonyms:  [][]string{},
+	}
+
+	for _, document := range documents {
+		indexed := &indexedDocument{
+			nameTokens:    make(map[string]struct{}),
+			metadataWords: make(map[string]struct{}),
+		}
+		for _, name := range document.Names {
+			tokens := Tokenize(name)
+			indexed.names = append(indexed.names, strings.Join(tokens, " "))
+			initials := ""
+			for _, token := range tokens {
+				indexed.nameTokens[token] = struct{}{}
+
+				// Leave out sizes and counts (such as "16oz")
+				// so that "pb" matches "PEANUT BUTTER 16OZ"
+				first := []rune(token)[0]
+				if !unicode.IsDigit(first) {
+					initials += string(first)
+				}
+			}
+			indexed.initials = append(indexed.initials, initials)
+		}
+		for _, text := range document.Text {
+			for _, token := range Tokenize(text) {
+				indexed.metadataWords[token] = struct{}{}
+			}
+		}
+		index.documents[document.ID] = indexed
+	}
+
+	for _, group := range synonyms {
+		normalized := []string{}
+		for _, term := range group {
+			if phrase := strings.Join(Tokenize(term), " "); phrase != "" {
+				normalized = append(normalized, phrase)
+			}
+		}
+		if len(normalized) > 1 {
+			index.synonyms = append(index.synonyms, normalized)
+		}
+	}
+
+	return index
+}
+
+// Tokenize splits text into lowercase words made of letters and digits
+func Tokenize(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// Search scores every product that matches the query,
+// returning a map of product ID to a positive relevance score
+func (i *Index) Search(query string) map[string]float64 {
+	scores := make(map[string]float64)
+	phrases := i.expand(query)
+	if len(phrases) == 0 {
+		return scores
+	}
+
+	for id, document := range i.documents {
+		best := 0.0
+		for _, phrase := range phrases {
+			score := document.score(phrase.tokens, !phrase.synonym) * phrase.weight
+			if score > best {
+				best = score
+			}
+		}
+
+		if best > 0 {
+			scores[id] = best
+		}
+	}
+
+	return scores
+}
+
+// Rank orders the product IDs by descending score,
+// breaking ties by the given names (and then IDs) in ascending order
+func Rank(scores map[string]float64, names map[string]string) []string {
+	ids := []string{}
+	for id := range scores {
+		ids = append(ids, id)
+	}
+
+	sort.Slice(ids, func(a, b int) bool {
+		if scores[ids[a]] != scores[ids[b]] {
+			return scores[ids[a]] > scores[ids[b]]
+		}
+		if names[ids[a]] != names[ids[b]] {
+			return names[ids[a]] < names[ids[b]]
+		}
+		return ids[a] < ids[b]
+	})
+
+	return ids
+}
+
+type phrase struct {
+	tokens  []string
+	weight  float64
+	synonym bool
+}
+
+// Expands the query into itself plus every phrasing made by replacing
+// a synonym (either the whole query or a run of its tokens) with its equivalents
+func (i *Index) expand(query string) []phrase {
+	tokens := Tokenize(query)
+	if len(tokens) == 0 {
+		return nil
+	}
+
+	phrases := []phrase{{tokens: tokens, weight: 1}}
+	seen := map[string]struct{}{strings.Join(tokens, " "): {}}
+	joined := " " + strings.Join(tokens, " ") + " "
+	for _, group := range i.synonyms {
+		for _, term := range group {
+			if !strings.Contains(joined, " "+term+" ") {
+				continue
+			}
+
+			for _, equivalent := range group {
+				if equivalent == term {
+					continue
+				}
+
+				replaced := strings.TrimSpace(strings.Replace(joined, " "+term+" ", " "+equivalent+" ", 1))
+				if _, ok := seen[replaced]; ok {
+					continue
+				}
+				seen[replaced] = struct{}{}
+				phrases = append(phrases, phrase{
+					tokens:  strings.Fields(replaced),
+					weight:  synonymDiscount,
+					synonym: true,
+				})
+			}
+		}
+	}
+
+	return phrases
+}
+
+// Scores the document against the query tokens.
+// Every token has to match something for the document to score,
+// except that a fuzzy match of the whole query against a name always counts.
+// Initials are only matched for what the user typed,
+// since a synonym's short form would match unrelated products
+func (d *indexedDocument) score(tokens []string, matchInitials bool) float64 {
+	query := strings.Join(tokens, " ")
+	score := 0.0
+	for _, name := range d.names {
+		if name == query {
+			score += weightExactName
+			break
+		} else if strings.HasPrefix(name, query) {
+			score += weightNamePrefix
+			break
+		}
+	}
+
+	for _, token := range tokens {
+		tokenScore := d.scoreToken(token, matchInitials)
+		if tokenScore == 0 {
+			return d.fuzzyScore(query)
+		}
+		score += tokenScore
+	}
+
+	return score
+}
+
+func (d *indexedDocument) scoreToken(token string, matchInitials bool) float64 {
+	if _, ok := d.nameTokens[token]; ok {
+		return weightNameToken
+	}
+
+	for _, initials := range d.initials {
+		if matchInitials && len(token) > 1 && initials == token {
+			return weightInitials
+		}
+	}
+
+	for nameToken := range d.nameTokens {
+		if strings.HasPrefix(nameToken, token) {
+			return weightTokenPrefix
+		}
+	}
+
+	if _, ok := d.metadataWords[token]; ok {
+		return weightMetadata
+	}
+
+	for word := range d.metadataWords {
+		if strings.HasPrefix(word, token) {
+			return weightMetadataPart
+		}
+	}
+
+	return 0
+}
+
+// Keeps the behavior of the previous fuzzy search as the lowest-ranked fallback
+func (d *indexedDocument) fuzzyScore(query string) float64 {
+	for _, name := range d.names {
+		if fuzzy.MatchNormalized(query, name) {
+			return weightFuzzyName
+		}
+	}
+
+	return 0
+}
@@ -0,0 +1,136 @@
+package search
+
+import (
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func testIndex() *Index {
+	return NewIndex([]Document{
+		{ID: "peanut-butter", Names: []string{"PEANUT BUTTER 16OZ"}, Text: []string{"Creamy spread"}},
+		{ID: "pbj", Names: []string{"PB&J Sandwich"}},
+		{ID: "cola", Names: []string{"Coca-Cola 12oz"}, Text: []string{"Soda pop"}},
+		{ID: "chips", Names: []string{"Potato Chips", "Lays Chips"}},
+	}, [][]string{
+		{"Soda", "pop", "COKE"},
+		{"chocolate chip", "cc"},
+		{"unused"},
+	})
+}
+
+func TestTokenize(t *testing.T) {
+	tests := []struct {
+		text     string
+		expected []string
+	}{
+		{"PB&J Sandwich", []string{"pb", "j", "sandwich"}},
+		{"  Coca-Cola 12oz ", []string{"coca", "cola", "12oz"}},
+		{"Jalapeño", []string{"jalapeño"}},
+		{"--", []string{}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.text, func(t *testing.T) {
+			actual := Tokenize(test.text)
+			if len(actual) != len(test.expected) || (len(actual) > 0 && !reflect.DeepEqual(actual, test.expected)) {
+				t.Errorf("expected %v, got %v", test.expected, actual)
+			}
+		})
+	}
+}
+
+func TestSearch(t *testing.T) {
+	index := testIndex()
+
+	tests := []struct {
+		name     string
+		query    string
+		id       string
+		expected float64
+	}{
+		{"exact name", "Potato Chips", "chips", weightExactName + 2*weightNameToken},
+		{"exact other name", "lays chips", "chips", weightExactName + 2*weightNameToken},
+		{"name prefix", "potato", "chips", weightNamePrefix + weightNameToken},
+		{"name token", "chips", "chips", weightNameToken},
+		{"initials", "pb", "peanut-butter", weightInitials},
+		{"initials skip sizes", "PB", "peanut-butter", weightInitials},
+		{"name token over initials", "pb", "pbj", weightNamePrefix + weightNameToken},
+		{"token prefix", "butt", "peanut-butter", weightTokenPrefix},
+		{"metadata", "creamy", "peanut-butter", weightMetadata},
+		{"metadata prefix", "cream", "peanut-butter", weightMetadataPart},
+		{"every token matches", "butter creamy", "peanut-butter", weightNameToken + weightMetadata},
+		{"fuzzy fallback", "ptto", "chips", weightFuzzyName},
+		{"unmatched token", "butter xyz", "peanut-butter", 0},
+		{"synonym", "coke", "cola", weightMetadata * synonymDiscount},
+		{"initials direct", "cc", "cola", weightInitials},
+		{"no initials through synonyms", "chocolate chip", "cola", weightFuzzyName * synonymDiscount},
+		{"empty", " & ", "chips", 0},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			actual := index.Search(test.query)[test.id]
+			if math.Abs(actual-test.expected) > 1e-9 {
+				t.Errorf("expected %s to score %v, got %v", test.id, test.expected, actual)
+			}
+		})
+	}
+}
+
+func TestSearchOnlyReturnsMatches(t *testing.T) {
+	scores := testIndex().Search("chips")
+	if len(scores) != 1 {
+		t.Errorf("expected only chips to match, got %v", scores)
+	}
+}
+
+func TestExpand(t *testing.T) {
+	index := testIndex()
+
+	tests := []struct {
+		name     string
+		query    string
+		expected []string
+	}{
+		{"no synonyms", "potato chips", []string{"potato chips"}},
+		{"single token", "diet coke", []string{"diet coke", "diet soda", "diet pop"}},
+		{"multiple tokens", "Chocolate Chip cookie", []string{"chocolate chip cookie", "cc cookie"}},
+		{"partial token", "cokes", []string{"cokes"}},
+		{"empty", "", nil},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			phrases := index.expand(test.query)
+
+			var actual []string
+			for i, phrase := range phrases {
+				actual = append(actual, strings.Join(phrase.tokens, " "))
+
+				expectedWeight := synonymDiscount
+				if i == 0 {
+					expectedWeight = 1
+				}
+				if phrase.weight != expectedWeight || phrase.synonym != (i > 0) {
+					t.Errorf("phrase %q: unexpected weight %v or synonym %v", actual[i], phrase.weight, phrase.synonym)
+				}
+			}
+
+			if !reflect.DeepEqual(actual, test.expected) {
+				t.Errorf("expected %v, got %v", test.expected, actual)
+			}
+		})
+	}
+}
+
+func TestRank(t *testing.T) {
+	scores := map[string]float64{"a": 5, "b": 10, "c": 5, "d": 5}
+	names := map[string]string{"a": "Zebra", "b": "Yam", "c": "Apple", "d": "Zebra"}
+
+	expected := []string{"b", "c", "a", "d"}
+	if actual := Rank(scores, names); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected %v, got %v", expected, actual)
+	}
+}
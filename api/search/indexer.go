@@ -0,0 +1,234 @@
+package search
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"github.com/jd-116/klemis-kitchen-api/db"
+	"github.com/jd-116/klemis-kitchen-api/products"
+)
+
+// Database is the subset of the database provider
+// that the indexer needs
+type Database interface {
+	db.ProductMetadataProvider
+	db.SynonymProvider
+	products.AliasSource
+}
+
+// Products is the subset of the products provider
+// that the indexer needs
+type Products interface {
+	products.PartialProductProvider
+	products.LoadSource
+}
+
+// Indexer keeps a search index of every product in the products provider,
+// rebuilding it in the background whenever the products are reloaded
+// or the synonyms, canonical products, or product metadata change
+type Indexer struct {
+	database Database
+	products Products
+	rebuild  chan struct{}
+	stop     chan struct{}
+
+	indexLock sync.RWMutex
+	index     *Index
+	logger    zerolog.Logger
+}
+
+// NewIndexer creates the indexer with an empty index
+// (doesn't start goroutines)
+func NewIndexer(database Database, products Products, logger zerolog.Logger) *Indexer {
+	return &Indexer{
+		database: database,
+		products: products,
+		rebuild:  make(chan struct{}, 1),
+		stop:     make(chan struct{}),
+		index:    NewIndex(nil, nil),
+		logger:   logger,
+	}
+}
+
+// Connect builds the initial index and starts rebuilding it after each products reload
+// (the database must already be connected)
+func (i *Indexer) Connect(ctx context.Context) error {
+	i.products.OnLoad(i.Refresh)
+	go i.rebuildAll()
+	return i.Rebuild(ctx)
+}
+
+// Disconnect stops rebuilding the index
+func (i *Indexer) Disconnect(ctx context.Context) error {
+	i.stop <- struct{}{}
+	return nil
+}
+
+// Search scores every product that matches the query,
+// returning a map of product ID to a positive relevance score
+func (i *Indexer) Search(query string) map[string]float64 {
+	i.indexLock.RLock()
+	index := i.index
+	i.indexLock.RUnlock()
+
+	return index.Search(query)
+}
+
+// Refresh schedules a rebuild of the index without waiting for it.
+// Several refreshes before the rebuild starts result in a single rebuild
+func (i *Indexer) Refresh() {
+	select {
+	case i.rebuild <- struct{}{}:
+	default:
+	}
+}
+
+func (i *Indexer) rebuildAll() {
+	for {
+		select {
+		case <-i.stop:
+			return
+		case <-i.rebuild:
+			i.tryRebuild()
+		}
+	}
+}
+
+func (i *Indexer) tryRebuild() {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	err := i.Rebuild(ctx)
+	if err != nil {
+		i.logger.
+			Error().
+			Err(err).
+			Msg("could not rebuild the product search index")
+	}
+}
+
+// Rebuild builds a new index from the current products, product metadata, and synonyms.
+// Aliased products are indexed as their canonical product, under both names.
+// Products that haven't been loaded yet are left out until the next rebuild
+func (i *Indexer) Rebuild(ctx context.Context) error {
+	start := time.Now()
+	aliases, err := products.LoadAliases(ctx, i.database)
+	if err != nil {
+		return err
+	}
+
+	documents := make(map[string]*Document)
+	locations, err := i.products.GetAllLocations()
+	if err != nil {
+		switch err.(type) {
+		case *products.CacheNotInitializedError:
+			locations = []string{}
+		default:
+			return err
+		}
+	}
+
+	for _, location := range locations {
+		partialProducts, err := i.products.GetAllProducts(location)
+		if err != nil {
+			return err
+		}
+
+		for _, partialProduct := range partialProducts {
+			id := partialProduct.ID
+			names := []string{partialProduct.Name}
+			if canonicalProduct, ok := aliases.Resolve(location, id); ok {
+				id = canonicalProduct.ID
+				names = []string{canonicalProduct.Name, partialProduct.Name}
+			}
+
+			document, ok := documents[id]
+			if !ok {
+				document = &Document{ID: id}
+				documents[id] = document
+			}
+			for _, name := range names {
+				if !contains(document.Names, name) {
+					document.Names = append(document.Names, name)
+				}
+			}
+		}
+	}
+
+	productMetadata, err := i.database.GetAllProducts(ctx)
+	if err != nil {
+		return err
+	}
+	for _, metadata := range productMetadata {
+		document, ok := documents[metadata.ID]
+		if !ok {
+			continue
+		}
+
+		if metadata.DisplayName != nil && *metadata.DisplayName != "" && !contains(document.Names, *metadata.DisplayName) {
+			document.Names = append(document.Names, *metadata.DisplayName)
+		}
+		if metadata.Description != nil {
+			document.Text = append(document.Text, *metadata.Description)
+		}
+		if metadata.Unit != nil {
+			document.Text = append(document.Text, *metadata.Unit)
+		}
+		if metadata.Nutrition != nil {
+			document.Text = append(document.Text, *metadata.Nutrition)
+		}
+
+		// Translations can be searched for too
+		for _, translation := range metadata.Translations {
+			if translation.Name != nil && *translation.Name != "" && !contains(document.Names, *translation.Name) {
+				document.Names = append(document.Names, *translation.Name)
+			}
+			if translation.Description != nil && *translation.Description != "" {
+				document.Text = append(document.Text, *translation.Description)
+			}
+			if translation.Nutrition != nil && *translation.Nutrition != "" {
+				document.Text = append(document.Text, *translation.Nutrition)
+			}
+		}
+	}
+
+	synonyms, err := i.database.GetAllSynonyms(ctx)
+	if err != nil {
+		return err
+	}
+	groups := [][]string{}
+	for _, synonym := range synonyms {
+		groups = append(groups, synonym.Terms)
+	}
+
+	indexDocuments := []Document{}
+	for _, document := range documents {
+		indexDocuments = append(indexDocuments, *document)
+	}
+	index := NewIndex(indexDocuments, groups)
+
+	i.indexLock.Lock()
+	i.index = index
+	i.indexLock.Unlock()
+
+	i.logger.
+		Debug().
+		Int("document_count", len(indexDocuments)).
+		Int("synonym_count", len(groups)).
+		Dur("duration", time.Since(start)).
+		Msg("rebuilt the product search index")
+	return nil
+}
+
+func contains(values []string, value string) bool {
+	for _, existing := range values {
+		if existing == value {
+			return true
+		}
+	}
+
+	return false
+}
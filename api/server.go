@@ -4,7 +4,7 @@ import (
 	"context"
 	"fmt"
 	"net/http"
-	"os"
+	"sync/atomic"
 	"time"
 
 	"github.com/go-chi/chi"
@@ -17,14 +17,41 @@ import (
 
 	"github.com/jd-116/klemis-kitchen-api/api/announcements"
 	apiAuth "github.com/jd-116/klemis-kitchen-api/api/auth"
+	"github.com/jd-116/klemis-kitchen-api/api/canonical"
+	"github.com/jd-116/klemis-kitchen-api/api/categories"
+	"github.com/jd-116/klemis-kitchen-api/api/files"
+	apiHealth "github.com/jd-116/klemis-kitchen-api/api/health"
+	"github.com/jd-116/klemis-kitchen-api/api/inventory"
 	"github.com/jd-116/klemis-kitchen-api/api/locations"
+	"github.com/jd-116/klemis-kitchen-api/api/me"
 	"github.com/jd-116/klemis-kitchen-api/api/memberships"
 	apiProducts "github.com/jd-116/klemis-kitchen-api/api/products"
+	apiSearch "github.com/jd-116/klemis-kitchen-api/api/search"
+	apiTransact "github.com/jd-116/klemis-kitchen-api/api/transact"
+	"github.com/jd-116/klemis-kitchen-api/api/translations"
 	apiUpload "github.com/jd-116/klemis-kitchen-api/api/upload"
+	"github.com/jd-116/klemis-kitchen-api/api/uploads"
 	"github.com/jd-116/klemis-kitchen-api/auth"
+	"github.com/jd-116/klemis-kitchen-api/broadcast"
 	"github.com/jd-116/klemis-kitchen-api/cas"
+	"github.com/jd-116/klemis-kitchen-api/config"
 	"github.com/jd-116/klemis-kitchen-api/db/mongo"
+	"github.com/jd-116/klemis-kitchen-api/favorites"
+	"github.com/jd-116/klemis-kitchen-api/health"
+	"github.com/jd-116/klemis-kitchen-api/images"
+	"github.com/jd-116/klemis-kitchen-api/limits"
+	"github.com/jd-116/klemis-kitchen-api/locale"
+	"github.com/jd-116/klemis-kitchen-api/metrics"
+	"github.com/jd-116/klemis-kitchen-api/notify"
+	"github.com/jd-116/klemis-kitchen-api/products"
+	"github.com/jd-116/klemis-kitchen-api/products/native"
 	"github.com/jd-116/klemis-kitchen-api/products/transact"
+	"github.com/jd-116/klemis-kitchen-api/reservations"
+	"github.com/jd-116/klemis-kitchen-api/search"
+	"github.com/jd-116/klemis-kitchen-api/settings"
+	"github.com/jd-116/klemis-kitchen-api/tracing"
+	"github.com/jd-116/klemis-kitchen-api/upload"
+	"github.com/jd-116/klemis-kitchen-api/upload/filesystem"
 	"github.com/jd-116/klemis-kitchen-api/upload/s3"
 )
 
@@ -33,60 +60,169 @@ import (
 // a lifecycle of initialization, connection, and disconnection
 type APIServer struct {
 	itemProvider   *transact.Provider
+	nativeProvider *native.Provider
+	products       *products.MultiProvider
+	reservations   *reservations.Manager
+	limits         *limits.Enforcer
+	notifications  *notify.Queue
+	favorites      *favorites.Watcher
+	broadcaster    *broadcast.Broadcaster
+	search         *search.Indexer
 	dbProvider     *mongo.Provider
 	casProvider    *cas.Provider
 	jwtManager     *auth.JWTManager
-	uploadProvider *s3.Provider
+	uploadProvider upload.Provider
+	images         *images.Processor
+	uploadCleaner  *upload.Cleaner
+	locales        *locale.Negotiator
+	healthChecker  *health.Checker
+	tracing        *tracing.Provider
+	settings       *settings.Store
+	reloader       *settings.Reloader
+	config         *config.Config
 	logger         zerolog.Logger
 }
 
 // NewAPIServer initializes the struct and all constituent components
-func NewAPIServer(logger zerolog.Logger) (*APIServer, error) {
+func NewAPIServer(logger zerolog.Logger, loader *config.Loader, cfg *config.Config) (*APIServer, error) {
+	// Initialize the store of the settings that can be reloaded while running
+	settingsStore := settings.NewStore(settings.FromConfig(cfg))
+	reloader := settings.NewReloader(loader, cfg, settingsStore, logger)
+
+	// Initialize the tracing exporter
+	tracingProvider, err := tracing.NewProvider(cfg.Tracing, logger)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not initialize tracing provider")
+	}
+
 	// Initialize the Transact scraper
-	itemProvider, err := transact.NewProvider(logger)
+	itemProvider, err := transact.NewProvider(cfg.Transact, settingsStore, logger)
 	if err != nil {
 		return nil, errors.Wrap(err, "could not initialize Transact scraper")
 	}
 
 	// Initialize the MongoDB handler
-	dbProvider, err := mongo.NewProvider(logger)
+	dbProvider, err := mongo.NewProvider(cfg.Mongo, logger)
 	if err != nil {
 		return nil, errors.Wrap(err, "could not initialize MongoDB handler")
 	}
 
+	// Initialize the native inventory provider,
+	// and serve both it and Transact through a single products provider
+	nativeProvider := native.NewProvider(dbProvider, logger)
+	productsProvider := products.NewMultiProvider(nativeProvider, itemProvider)
+
+	// Initialize the product search index
+	searchIndexer := search.NewIndexer(dbProvider, productsProvider, logger)
+
+	// Initialize the reservation manager
+	reservationManager := reservations.NewManager(dbProvider, productsProvider, cfg.Reservations, logger)
+
+	// Initialize the per-user visit and item limit enforcer
+	limitEnforcer := limits.NewEnforcer(dbProvider, logger)
+
+	// Initialize the notification queue
+	// and the watcher that notifies users when their favorites are restocked
+	pushClient := notify.NewPushClient(cfg.Push)
+	notifier, err := notify.NewNotifier(dbProvider, pushClient, cfg.Notifications, logger)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not initialize notifier")
+	}
+	notificationQueue := notify.NewQueue(notifier, cfg.Notifications, logger)
+	favoritesWatcher := favorites.NewWatcher(dbProvider, productsProvider, notificationQueue, cfg.Notifications, logger)
+
+	// Initialize the broadcaster that pushes announcements to devices
+	broadcaster := broadcast.NewBroadcaster(dbProvider, pushClient, cfg.Push, logger)
+
 	// Initialize the CAS provider
-	casProvider, err := cas.NewProvider()
+	casProvider, err := cas.NewProvider(cfg.CAS)
 	if err != nil {
 		return nil, errors.Wrap(err, "could not initialize CAS provider")
 	}
 
 	// Initialize the JWT manager
-	jwtManager, err := auth.NewJWTManager(logger)
+	jwtManager, err := auth.NewJWTManager(cfg.Auth, logger)
 	if err != nil {
 		return nil, errors.Wrap(err, "could not initialize JWT manager")
 	}
 
-	// Initialize the S3 handler
-	uploadProvider, err := s3.NewProvider(logger)
+	// Initialize the upload handler selected in the upload configuration:
+	// either "s3" or "filesystem"
+	var uploadProvider upload.Provider
+	switch uploadBackend := cfg.Upload.Backend; uploadBackend {
+	case "s3":
+		uploadProvider, err = s3.NewProvider(cfg.Upload, logger)
+		if err != nil {
+			return nil, errors.Wrap(err, "could not initialize S3 handler")
+		}
+	case "filesystem":
+		uploadProvider, err = filesystem.NewProvider(cfg.Upload, logger)
+		if err != nil {
+			return nil, errors.Wrap(err, "could not initialize filesystem upload handler")
+		}
+	default:
+		return nil, fmt.Errorf("unknown upload backend '%s' ('UPLOAD_BACKEND'); expected 's3' or 'filesystem'", uploadBackend)
+	}
+
+	// Initialize the cleaner that deletes uploads nothing references
+	uploadCleaner := upload.NewCleaner(dbProvider, uploadProvider, cfg.Upload, logger)
+
+	// Initialize the processor that resizes uploaded images
+	imageProcessor := images.NewProcessor(cfg.Upload)
+
+	// Initialize the negotiator that picks the language of translated content
+	locales, err := locale.NewNegotiator(cfg.Localization.SupportedLocales)
 	if err != nil {
-		return nil, errors.Wrap(err, "could not initialize S3 handler")
+		return nil, errors.Wrap(err, "could not initialize locale negotiator")
 	}
 
+	// Report the age of the product cache as a metric
+	metrics.TrackCacheAge(itemProvider.Cache.LoadedAt)
+
+	// Initialize the readiness checks for each downstream component
+	healthChecker := health.NewChecker(5*time.Second,
+		health.Check{Name: "mongo", Check: dbProvider.Ping},
+		health.Check{Name: "transact_cache", Check: itemProvider.CheckCache},
+		health.Check{Name: "transact_session", Check: itemProvider.CheckSession},
+	)
+
 	return &APIServer{
 		itemProvider:   itemProvider,
+		nativeProvider: nativeProvider,
+		products:       productsProvider,
+		reservations:   reservationManager,
+		limits:         limitEnforcer,
+		notifications:  notificationQueue,
+		favorites:      favoritesWatcher,
+		broadcaster:    broadcaster,
+		search:         searchIndexer,
 		dbProvider:     dbProvider,
 		casProvider:    casProvider,
 		jwtManager:     jwtManager,
 		uploadProvider: uploadProvider,
+		images:         imageProcessor,
+		uploadCleaner:  uploadCleaner,
+		locales:        locales,
+		healthChecker:  healthChecker,
+		tracing:        tracingProvider,
+		settings:       settingsStore,
+		reloader:       reloader,
+		config:         cfg,
 		logger:         logger,
 	}, nil
 }
 
 // Connect initializes the struct and all constituent components
 func (a *APIServer) Connect(ctx context.Context) error {
+	// Install the tracing exporter first so that spans during startup are captured
+	err := a.tracing.Connect(ctx)
+	if err != nil {
+		return errors.Wrap(err, "could not initialize tracing")
+	}
+
 	// Start the Transact scraper goroutines
 	a.logger.Info().Msg("initializing Transact API connector")
-	err := a.itemProvider.Connect(ctx)
+	err = a.itemProvider.Connect(ctx)
 	if err != nil {
 		return errors.Wrap(err, "could not authenticate with the Transact API")
 	}
@@ -103,12 +239,99 @@ func (a *APIServer) Connect(ctx context.Context) error {
 	}
 	a.logger.Info().Msg("successfully connected to and pinged the database")
 
+	// Load the inventory of locations that don't use Transact
+	err = a.nativeProvider.Connect(ctx)
+	if err != nil {
+		return errors.Wrap(err, "could not load native inventory")
+	}
+
+	// Layer any manual inventory adjustments over the inventory data,
+	// saving whenever Transact changes expire one
+	a.products.OnAdjustmentsExpired(products.PersistExpiredAdjustments(a.dbProvider, a.logger))
+	err = products.SyncAdjustments(ctx, a.dbProvider, a.products)
+	if err != nil {
+		return errors.Wrap(err, "could not load inventory adjustments")
+	}
+
+	// Build the product search index and keep it up to date
+	err = a.search.Connect(ctx)
+	if err != nil {
+		return errors.Wrap(err, "could not build the product search index")
+	}
+
+	// Start expiring reservations that were never picked up
+	err = a.reservations.Connect(ctx)
+	if err != nil {
+		return errors.Wrap(err, "could not start reservation expiry")
+	}
+
+	// Start delivering notifications, including when favorites are restocked
+	err = a.notifications.Connect(ctx)
+	if err != nil {
+		return errors.Wrap(err, "could not start notification delivery")
+	}
+	err = a.favorites.Connect(ctx)
+	if err != nil {
+		return errors.Wrap(err, "could not start watching for restocked favorites")
+	}
+	err = a.broadcaster.Connect(ctx)
+	if err != nil {
+		return errors.Wrap(err, "could not start pushing announcements")
+	}
+
+	// Start cleaning up uploads that nothing references
+	err = a.uploadCleaner.Connect(ctx)
+	if err != nil {
+		return errors.Wrap(err, "could not start cleaning up orphaned uploads")
+	}
+
+	// Start reloading settings when the configuration changes
+	err = a.reloader.Connect(ctx)
+	if err != nil {
+		return errors.Wrap(err, "could not start watching for configuration changes")
+	}
+
 	return nil
 }
 
 // Disconnect initializes the struct and all constituent components
 func (a *APIServer) Disconnect(ctx context.Context) error {
-	err := a.dbProvider.Disconnect(ctx)
+	err := a.reloader.Disconnect(ctx)
+	if err != nil {
+		return errors.Wrap(err, "could not stop watching for configuration changes")
+	}
+
+	err = a.uploadCleaner.Disconnect(ctx)
+	if err != nil {
+		return errors.Wrap(err, "could not stop cleaning up orphaned uploads")
+	}
+
+	err = a.search.Disconnect(ctx)
+	if err != nil {
+		return errors.Wrap(err, "could not stop rebuilding the product search index")
+	}
+
+	err = a.broadcaster.Disconnect(ctx)
+	if err != nil {
+		return errors.Wrap(err, "could not stop pushing announcements")
+	}
+
+	err = a.favorites.Disconnect(ctx)
+	if err != nil {
+		return errors.Wrap(err, "could not stop watching for restocked favorites")
+	}
+
+	err = a.notifications.Disconnect(ctx)
+	if err != nil {
+		return errors.Wrap(err, "could not stop notification delivery")
+	}
+
+	err = a.reservations.Disconnect(ctx)
+	if err != nil {
+		return errors.Wrap(err, "could not stop reservation expiry")
+	}
+
+	err = a.dbProvider.Disconnect(ctx)
 	if err != nil {
 		return errors.Wrap(err, "could not disconnect from the database")
 	}
@@ -120,6 +343,11 @@ func (a *APIServer) Disconnect(ctx context.Context) error {
 	}
 	a.logger.Info().Msg("disconnected from the Transact API")
 
+	err = a.tracing.Disconnect(ctx)
+	if err != nil {
+		return errors.Wrap(err, "could not flush remaining spans")
+	}
+
 	return nil
 }
 
@@ -175,25 +403,33 @@ func (a *APIServer) routes() *chi.Mux {
 				Msg("handled HTTP request")
 		}),
 		hlog.RequestIDHandler("req_id", "X-Request-Id"), // Attach a unique request ID to each incoming request
-		middleware.RedirectSlashes,                      // Redirect slashes to no slash URL versions
-		render.SetContentType(render.ContentTypeJSON),   // Set content-type headers to application/json
-		middleware.Compress(5),                          // Compress results, mostly gzipping assets and json
-		middleware.NoCache,                              // Prevent clients from caching the results
-		a.corsMiddleware(),                              // Create cors middleware from go-chi/cors
+		tracing.Middleware,                            // Create a server span for each request, including its ID
+		metrics.Middleware,                            // Record request counts and latencies per route
+		middleware.RedirectSlashes,                    // Redirect slashes to no slash URL versions
+		render.SetContentType(render.ContentTypeJSON), // Set content-type headers to application/json
+		middleware.Compress(5),                        // Compress results, mostly gzipping assets and json
+		middleware.NoCache,                            // Prevent clients from caching the results
+		a.corsMiddleware(),                            // Create cors middleware from go-chi/cors
+		a.locales.Middleware,                          // Pick the language of translated content
 	)
 
 	// ==============================
 	// Add all routes to the API here
 	// ==============================
+	// Prometheus metrics are exposed outside of the versioned API
+	router.Handle("/metrics", metrics.Handler())
+
 	router.Route("/v1", func(r chi.Router) {
 		// Public routes
 		r.Group(func(r chi.Router) {
-			// Can be used for health checks
-			r.Get("/health", func(w http.ResponseWriter, r *http.Request) {
-				w.WriteHeader(204)
-			})
+			// Can be used for liveness/readiness checks
+			r.Mount("/health", apiHealth.Routes(a.healthChecker, a.jwtManager))
+			r.Mount("/auth", apiAuth.Routes(a.casProvider, a.dbProvider, a.jwtManager, a.settings, a.config))
 
-			r.Mount("/auth", apiAuth.Routes(a.casProvider, a.dbProvider, a.jwtManager))
+			// Uploaded files are only served by the API when they aren't stored elsewhere
+			if fileSource, ok := a.uploadProvider.(upload.FileSource); ok {
+				r.Mount("/files", files.Routes(fileSource))
+			}
 		})
 
 		// Protected routes
@@ -204,30 +440,60 @@ func (a *APIServer) routes() *chi.Mux {
 			// if needed, use auth.AdminAuthenticator to use Permissions.AdminAccess
 			r.Use(a.jwtManager.Authenticated())
 
-			r.Mount("/announcements", announcements.Routes(a.dbProvider))
-			r.Mount("/products", apiProducts.Routes(a.dbProvider, a.itemProvider))
-			r.Mount("/locations", locations.Routes(a.dbProvider, a.itemProvider))
+			r.Mount("/announcements", announcements.Routes(a.dbProvider, a.broadcaster, a.uploadProvider, a.locales))
+			r.Mount("/categories", categories.Routes(a.dbProvider, a.products))
+			r.Mount("/products", apiProducts.Routes(a.dbProvider, a.products, a.search, a.locales))
+			r.Mount("/locations", locations.Routes(a.dbProvider, a.products, a.nativeProvider, a.reservations, a.limits, a.search))
 			r.Mount("/memberships", memberships.Routes(a.dbProvider))
-			r.Mount("/upload", apiUpload.Routes(a.uploadProvider))
+			r.Mount("/me", me.Routes(a.dbProvider, a.limits))
+			r.Mount("/upload", apiUpload.Routes(a.uploadProvider, a.images, a.dbProvider, a.settings))
+			r.Mount("/uploads", uploads.Routes(a.dbProvider, a.uploadProvider))
+
+			// Admin tools
+			r.Route("/admin", func(r chi.Router) {
+				r.Mount("/inventory", inventory.Routes(a.itemProvider))
+				r.Mount("/transact", apiTransact.Routes(a.itemProvider, a.dbProvider))
+				r.Mount("/search", apiSearch.Routes(a.dbProvider, a.search))
+				r.Mount("/canonical-products", canonical.Routes(a.dbProvider, a.search))
+				r.Mount("/translations", translations.Routes(a.dbProvider, a.products, a.locales))
+			})
 		})
 	})
 
 	return router
 }
 
+// Creates the CORS middleware from go-chi/cors,
+// which is recreated whenever the allowed origins are reloaded
 func (a *APIServer) corsMiddleware() func(http.Handler) http.Handler {
-	// See if the CORS_ALLOWED_ORIGINS environment variable was set
-	allowedOrigins := "*"
-	if value, ok := os.LookupEnv("CORS_ALLOWED_ORIGINS"); ok {
-		allowedOrigins = value
-	}
-
-	return cors.Handler(cors.Options{
-		AllowedOrigins:   []string{allowedOrigins},
-		AllowedMethods:   []string{"GET", "POST", "PATCH", "DELETE", "OPTIONS"},
-		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type"},
-		ExposedHeaders:   []string{},
-		AllowCredentials: false,
-		MaxAge:           300,
-	})
+	newCors := func(allowedOrigins string) *cors.Cors {
+		return cors.New(cors.Options{
+			AllowedOrigins:   []string{allowedOrigins},
+			AllowedMethods:   []string{"GET", "POST", "PATCH", "DELETE", "OPTIONS"},
+			AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type"},
+			ExposedHeaders:   []string{},
+			AllowCredentials: false,
+			MaxAge:           300,
+		})
+	}
+
+	type corsHandler struct {
+		allowedOrigins string
+		cors           *cors.Cors
+	}
+	var current atomic.Value
+	allowedOrigins := a.settings.Get().CORSAllowedOrigins
+	current.Store(corsHandler{allowedOrigins: allowedOrigins, cors: newCors(allowedOrigins)})
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			handler := current.Load().(corsHandler)
+			if allowedOrigins := a.settings.Get().CORSAllowedOrigins; allowedOrigins != handler.allowedOrigins {
+				handler = corsHandler{allowedOrigins: allowedOrigins, cors: newCors(allowedOrigins)}
+				current.Store(handler)
+			}
+
+			handler.cors.Handler(next).ServeHTTP(w, r)
+		})
+	}
 }
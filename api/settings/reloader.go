@@ -0,0 +1,150 @@
+package settings
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"github.com/jd-116/klemis-kitchen-api/config"
+)
+
+// The period to wait between checking whether the config files were modified
+const watchPeriod = 5 * time.Second
+
+// Reloader reloads the configuration when the API receives SIGHUP
+// or when one of the files it was loaded from is modified,
+// updating the settings store with the new settings.
+// Other configuration values only take effect after a restart
+type Reloader struct {
+	loader   *config.Loader
+	config   *config.Config
+	store    *Store
+	modTimes map[string]time.Time
+	signals  chan os.Signal
+	stop     chan struct{}
+	logger   zerolog.Logger
+}
+
+// NewReloader creates a new reloader for the configuration
+// that was already loaded by the loader
+// (doesn't start goroutines)
+func NewReloader(loader *config.Loader, cfg *config.Config, store *Store, logger zerolog.Logger) *Reloader {
+	return &Reloader{
+		loader:   loader,
+		config:   cfg,
+		store:    store,
+		modTimes: make(map[string]time.Time),
+		signals:  make(chan os.Signal, 1),
+		stop:     make(chan struct{}),
+		logger:   logger,
+	}
+}
+
+// Connect starts listening for SIGHUP
+// and starts the goroutine that watches the config files
+func (r *Reloader) Connect(ctx context.Context) error {
+	r.filesModified()
+	signal.Notify(r.signals, syscall.SIGHUP)
+	go r.watch()
+	return nil
+}
+
+// Disconnect stops listening for SIGHUP and stops the watch goroutine
+func (r *Reloader) Disconnect(ctx context.Context) error {
+	signal.Stop(r.signals)
+	r.stop <- struct{}{}
+	return nil
+}
+
+// Waits for SIGHUP or for the config files to be modified
+// and reloads the configuration
+func (r *Reloader) watch() {
+	r.logger.
+		Info().
+		Strs("paths", r.loader.Paths()).
+		Msg("watching config files for changes (reload with SIGHUP)")
+	for {
+		select {
+		case <-r.stop:
+			return
+		case <-r.signals:
+			r.tryReload("signal")
+		case <-time.After(watchPeriod):
+			if r.filesModified() {
+				r.tryReload("file")
+			}
+		}
+	}
+}
+
+// Determines whether any of the config files were modified since the last check
+func (r *Reloader) filesModified() bool {
+	modified := false
+	for _, path := range r.loader.Paths() {
+		info, err := os.Stat(path)
+		if err != nil {
+			// The file might be in the middle of being replaced,
+			// so check it again next time
+			continue
+		}
+
+		if previous, ok := r.modTimes[path]; ok && !info.ModTime().Equal(previous) {
+			modified = true
+		}
+		r.modTimes[path] = info.ModTime()
+	}
+
+	return modified
+}
+
+// Attempts to reload the configuration,
+// keeping the current settings if the new configuration is invalid
+func (r *Reloader) tryReload(trigger string) {
+	cfg, err := r.loader.Load()
+	if err != nil {
+		var validationErr *config.ValidationError
+		if errors.As(err, &validationErr) {
+			r.logger.
+				Error().
+				Str("trigger", trigger).
+				Strs("problems", validationErr.Problems).
+				Msg("reloaded configuration is invalid; keeping the current settings")
+		} else {
+			r.logger.
+				Error().
+				Err(err).
+				Str("trigger", trigger).
+				Msg("could not reload configuration; keeping the current settings")
+		}
+		return
+	}
+
+	changes := config.Diff(r.config, cfg)
+	for _, change := range changes {
+		event := r.logger.Info()
+		message := "reloaded setting"
+		if _, ok := reloadableKeys[change.Key]; !ok {
+			event = r.logger.Warn()
+			message = "changed setting can't be reloaded and will take effect after a restart"
+		}
+		event.
+			Str("key", change.Key).
+			Str("variable", change.Variable).
+			Str("old", change.Old).
+			Str("new", change.New).
+			Msg(message)
+	}
+	r.logger.
+		Info().
+		Str("trigger", trigger).
+		Int("change_count", len(changes)).
+		Msg("reloaded configuration")
+
+	r.config = cfg
+	r.store.Set(FromConfig(cfg))
+}
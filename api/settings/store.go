@@ -0,0 +1,80 @@
+package settings
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/jd-116/klemis-kitchen-api/config"
+)
+
+// Settings are the configuration values that can be changed
+// while the API is running, without restarting it
+type Settings struct {
+	CORSAllowedOrigins      string
+	AuthRedirectURIPrefixes []string
+	UploadMimeTypes         []string
+	TransactFetchPeriod     time.Duration
+}
+
+// The config keys of the values in Settings,
+// which take effect when the configuration is reloaded
+var reloadableKeys = map[string]struct{}{
+	"server.cors_allowed_origins": {},
+	"auth.redirect_uri_prefixes":  {},
+	"upload.mime_types":           {},
+	"transact.fetch_period":       {},
+}
+
+// FromConfig gets the settings out of the configuration
+func FromConfig(cfg *config.Config) Settings {
+	return Settings{
+		CORSAllowedOrigins:      cfg.Server.CORSAllowedOrigins,
+		AuthRedirectURIPrefixes: cfg.Auth.RedirectURIPrefixes,
+		UploadMimeTypes:         cfg.Upload.MimeTypes,
+		TransactFetchPeriod:     time.Duration(cfg.Transact.FetchPeriod),
+	}
+}
+
+// Store holds the current settings,
+// which can be read from any goroutine
+type Store struct {
+	current atomic.Value
+
+	changedLock sync.Mutex
+	changed     chan struct{}
+}
+
+// NewStore creates a new settings store
+func NewStore(initial Settings) *Store {
+	s := &Store{
+		changed: make(chan struct{}),
+	}
+	s.current.Store(initial)
+	return s
+}
+
+// Get gets the current settings.
+// The slices in them are shared, so they shouldn't be modified
+func (s *Store) Get() Settings {
+	return s.current.Load().(Settings)
+}
+
+// Changed gets a channel that is closed the next time the settings change
+func (s *Store) Changed() <-chan struct{} {
+	s.changedLock.Lock()
+	defer s.changedLock.Unlock()
+
+	return s.changed
+}
+
+// Set replaces the current settings,
+// notifying everything waiting on them to change
+func (s *Store) Set(settings Settings) {
+	s.changedLock.Lock()
+	defer s.changedLock.Unlock()
+
+	s.current.Store(settings)
+	close(s.changed)
+	s.changed = make(chan struct{})
+}
@@ -0,0 +1,206 @@
+package tracing
+
+import (
+	"context"
+	"net/http"
+	"os"
+
+	"github.com/go-chi/chi"
+	"github.com/go-chi/chi/middleware"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/hlog"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.4.0"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/jd-116/klemis-kitchen-api/config"
+)
+
+const tracerName = "github.com/jd-116/klemis-kitchen-api"
+
+// Provider configures the global OpenTelemetry tracer provider
+// to send spans to the exporter chosen in the configuration.
+// If no exporter is configured, the global no-op tracer provider is left in place
+type Provider struct {
+	exporter    string
+	serviceName string
+	// The exporter uses its default of localhost:4318 if empty
+	otlpEndpoint   string
+	otlpInsecure   bool
+	tracerProvider *sdktrace.TracerProvider
+	logger         zerolog.Logger
+}
+
+// NewProvider creates a new Provider from the tracing configuration
+func NewProvider(cfg config.TracingConfig, logger zerolog.Logger) (*Provider, error) {
+	return &Provider{
+		exporter:     cfg.Exporter,
+		serviceName:  cfg.ServiceName,
+		otlpEndpoint: cfg.OTLPEndpoint,
+		otlpInsecure: cfg.OTLPInsecure,
+		logger:       logger,
+	}, nil
+}
+
+// Connect creates the configured exporter
+// and installs it as the global tracer provider
+func (p *Provider) Connect(ctx context.Context) error {
+	var exporter sdktrace.SpanExporter
+	switch p.exporter {
+	case "none":
+		p.logger.Info().Msg("tracing is disabled")
+		return nil
+	case "stdout":
+		stdoutExporter, err := stdouttrace.New(stdouttrace.WithWriter(os.Stdout))
+		if err != nil {
+			return err
+		}
+		exporter = stdoutExporter
+	case "otlp":
+		options := []otlptracehttp.Option{}
+		if p.otlpEndpoint != "" {
+			options = append(options, otlptracehttp.WithEndpoint(p.otlpEndpoint))
+		}
+		if p.otlpInsecure {
+			options = append(options, otlptracehttp.WithInsecure())
+		}
+		otlpExporter, err := otlptracehttp.New(ctx, options...)
+		if err != nil {
+			return err
+		}
+		exporter = otlpExporter
+	}
+
+	p.tracerProvider = sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(resource.NewWithAttributes(
+			semconv.SchemaURL,
+			semconv.ServiceNameKey.String(p.serviceName),
+		)),
+	)
+	otel.SetTracerProvider(p.tracerProvider)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+
+	p.logger.
+		Info().
+		Str("exporter", p.exporter).
+		Str("service_name", p.serviceName).
+		Msg("initialized tracing")
+
+	return nil
+}
+
+// Disconnect flushes any buffered spans and shuts down the exporter
+func (p *Provider) Disconnect(ctx context.Context) error {
+	if p.tracerProvider == nil {
+		return nil
+	}
+
+	return p.tracerProvider.Shutdown(ctx)
+}
+
+// Start creates a new span as a child of any span in the given context
+func Start(ctx context.Context, name string, attributes ...attribute.KeyValue) (context.Context, trace.Span) {
+	return otel.Tracer(tracerName).Start(ctx, name, trace.WithAttributes(attributes...))
+}
+
+// RecordError records the given error on the span (if not nil)
+// and marks the span as failed
+func RecordError(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+}
+
+// End records the given error on the span (if not nil) and ends it
+func End(span trace.Span, err error) {
+	RecordError(span, err)
+	span.End()
+}
+
+// Middleware creates a server span for each HTTP request,
+// named after the chi route pattern that handled it
+// and including the request ID attached by hlog.RequestIDHandler
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+		ctx, span := otel.Tracer(tracerName).Start(ctx, r.Method,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				semconv.HTTPMethodKey.String(r.Method),
+				semconv.HTTPTargetKey.String(r.URL.Path),
+			))
+		defer span.End()
+
+		if requestID, ok := hlog.IDFromRequest(r); ok {
+			span.SetAttributes(attribute.String("request_id", requestID.String()))
+		}
+
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+		next.ServeHTTP(ww, r.WithContext(ctx))
+
+		// The route pattern is only complete once routing has finished
+		if routeContext := chi.RouteContext(r.Context()); routeContext != nil {
+			if pattern := routeContext.RoutePattern(); pattern != "" {
+				span.SetName(r.Method + " " + pattern)
+				span.SetAttributes(semconv.HTTPRouteKey.String(pattern))
+			}
+		}
+
+		status := ww.Status()
+		if status == 0 {
+			status = http.StatusOK
+		}
+		span.SetAttributes(semconv.HTTPStatusCodeKey.Int(status))
+		if status >= http.StatusInternalServerError {
+			span.SetStatus(codes.Error, http.StatusText(status))
+		}
+	})
+}
+
+// Transport wraps an HTTP round tripper so that each outgoing request
+// creates a client span with the given name prefix.
+// Query strings are never recorded, since they can contain credentials
+func Transport(prefix string, base http.RoundTripper) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+
+	return &transport{prefix: prefix, base: base}
+}
+
+type transport struct {
+	prefix string
+	base   http.RoundTripper
+}
+
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx, span := otel.Tracer(tracerName).Start(req.Context(), t.prefix+" "+req.Method,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			semconv.HTTPMethodKey.String(req.Method),
+			semconv.HTTPHostKey.String(req.URL.Host),
+			semconv.HTTPTargetKey.String(req.URL.Path),
+		))
+	defer span.End()
+
+	res, err := t.base.RoundTrip(req.WithContext(ctx))
+	if err != nil {
+		RecordError(span, err)
+		return nil, err
+	}
+
+	span.SetAttributes(semconv.HTTPStatusCodeKey.Int(res.StatusCode))
+	if res.StatusCode >= http.StatusBadRequest {
+		span.SetStatus(codes.Error, http.StatusText(res.StatusCode))
+	}
+	return res, nil
+}
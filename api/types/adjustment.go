@@ -0,0 +1,63 @@
+package types
+
+import "time"
+
+// Adjustment is the document stored in MongoDB for a single manual,
+// signed change to the amount of a product at a location,
+// which is layered on top of the amount reported by Transact
+type Adjustment struct {
+	ID         string    `json:"id" bson:"id"`
+	LocationID string    `json:"location_id" bson:"location_id"`
+	ProductID  string    `json:"product_id" bson:"product_id"`
+	Delta      int       `json:"delta" bson:"delta"`
+	Reason     string    `json:"reason" bson:"reason"`
+	CreatedBy  string    `json:"created_by" bson:"created_by"`
+	CreatedAt  time.Time `json:"created_at" bson:"created_at"`
+	// If set, the adjustment stops applying after this time
+	ExpiresAt *time.Time `json:"expires_at" bson:"expires_at"`
+	// If set, the adjustment stops applying as soon as Transact reports
+	// an amount for the product other than BaseAmount
+	UntilTransactChange bool `json:"until_transact_change" bson:"until_transact_change"`
+	BaseAmount          int  `json:"base_amount" bson:"base_amount"`
+	// Set once Transact first reports a different amount
+	// for an adjustment that only lasts until the next Transact change,
+	// after which it never applies again
+	ExpiredAt *time.Time `json:"expired_at" bson:"expired_at"`
+}
+
+// AppliesTo determines whether the adjustment still applies at the given time
+func (a *Adjustment) AppliesTo(now time.Time) bool {
+	if a.ExpiredAt != nil {
+		return false
+	}
+
+	if a.ExpiresAt != nil && !now.Before(*a.ExpiresAt) {
+		return false
+	}
+
+	return true
+}
+
+// ChangedBy determines whether the given unadjusted amount from Transact
+// ends an adjustment that only lasts until the next Transact change
+// and that hasn't already been expired
+func (a *Adjustment) ChangedBy(amount int) bool {
+	return a.UntilTransactChange && a.ExpiredAt == nil && amount != a.BaseAmount
+}
+
+// AdjustmentCreate is supplied through the dashboard and converted into
+// an Adjustment
+type AdjustmentCreate struct {
+	ProductID           string     `json:"product_id"`
+	Delta               int        `json:"delta"`
+	Reason              string     `json:"reason"`
+	ExpiresAt           *time.Time `json:"expires_at"`
+	UntilTransactChange bool       `json:"until_transact_change"`
+}
+
+// AdjustmentStatus is the external representation of an adjustment,
+// including whether it currently applies
+type AdjustmentStatus struct {
+	Adjustment
+	Active bool `json:"active"`
+}
@@ -0,0 +1,59 @@
+package types
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAdjustmentAppliesTo(t *testing.T) {
+	now := time.Date(2021, time.March, 1, 12, 0, 0, 0, time.UTC)
+	before := now.Add(-time.Minute)
+	after := now.Add(time.Minute)
+
+	tests := []struct {
+		name       string
+		adjustment Adjustment
+		expected   bool
+	}{
+		{"no expiry", Adjustment{}, true},
+		{"expires later", Adjustment{ExpiresAt: &after}, true},
+		{"expires now", Adjustment{ExpiresAt: &now}, false},
+		{"already expired", Adjustment{ExpiresAt: &before}, false},
+		{"until change", Adjustment{UntilTransactChange: true, BaseAmount: 3}, true},
+		{"until change expired", Adjustment{UntilTransactChange: true, BaseAmount: 3, ExpiredAt: &before}, false},
+		{"expired before expiry time", Adjustment{ExpiresAt: &after, ExpiredAt: &before}, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if actual := test.adjustment.AppliesTo(now); actual != test.expected {
+				t.Errorf("AppliesTo() = %v, expected %v", actual, test.expected)
+			}
+		})
+	}
+}
+
+func TestAdjustmentChangedBy(t *testing.T) {
+	expiredAt := time.Date(2021, time.March, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name       string
+		adjustment Adjustment
+		amount     int
+		expected   bool
+	}{
+		{"not until change", Adjustment{BaseAmount: 3}, 5, false},
+		{"same amount", Adjustment{UntilTransactChange: true, BaseAmount: 3}, 3, false},
+		{"different amount", Adjustment{UntilTransactChange: true, BaseAmount: 3}, 5, true},
+		{"out of stock", Adjustment{UntilTransactChange: true, BaseAmount: 3}, 0, true},
+		{"already expired", Adjustment{UntilTransactChange: true, BaseAmount: 3, ExpiredAt: &expiredAt}, 5, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if actual := test.adjustment.ChangedBy(test.amount); actual != test.expected {
+				t.Errorf("ChangedBy(%d) = %v, expected %v", test.amount, actual, test.expected)
+			}
+		})
+	}
+}
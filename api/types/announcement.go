@@ -2,18 +2,66 @@ package types
 
 import "time"
 
-// Announcement is the document stored in MongoDB for a single announcement
+// Announcement is the document stored in MongoDB for a single announcement.
+// Drafts are only visible to admins and aren't pushed to devices until published.
+// Announcements with a location ID are only pushed to devices subscribed to that location.
+// The body is Markdown, which is rendered to HTML in responses
 type Announcement struct {
-	ID        string    `json:"id" bson:"id"`
-	Title     string    `json:"title" bson:"title"`
-	Body      string    `json:"body" bson:"body"`
-	Timestamp time.Time `json:"timestamp" bson:"timestamp"`
+	ID         string     `json:"id" bson:"id"`
+	Title      string     `json:"title" bson:"title"`
+	Body       string     `json:"body" bson:"body"`
+	BodyHTML   string     `json:"body_html" bson:"-"`
+	Timestamp  time.Time  `json:"timestamp" bson:"timestamp"`
+	Draft      bool       `json:"draft" bson:"draft"`
+	LocationID string     `json:"location_id" bson:"location_id"`
+	PushedAt   *time.Time `json:"pushed_at" bson:"pushed_at"`
+	// URLs of uploaded images (the first of which is shown as a banner)
+	Images []string           `json:"images" bson:"images"`
+	Links  []AnnouncementLink `json:"links" bson:"links"`
+	// Translated titles and bodies by locale,
+	// which are shown instead of the originals in responses (only listed for admins)
+	Translations map[string]AnnouncementTranslation `json:"translations,omitempty" bson:"translations"`
+}
+
+// AnnouncementTranslation is the title and body of an announcement in another locale
+// (either of which can be left empty to use the original)
+type AnnouncementTranslation struct {
+	Title string `json:"title" bson:"title"`
+	Body  string `json:"body" bson:"body"`
+}
+
+// Localize replaces the announcement's title and body
+// with their translations into a locale, if there are any
+func (a *Announcement) Localize(locale string) {
+	translation, ok := a.Translations[locale]
+	if !ok {
+		return
+	}
+
+	if translation.Title != "" {
+		a.Title = translation.Title
+	}
+	if translation.Body != "" {
+		a.Body = translation.Body
+	}
+}
+
+// AnnouncementLink is a single call-to-action link shown with an announcement
+type AnnouncementLink struct {
+	Label string `json:"label" bson:"label"`
+	URL   string `json:"url" bson:"url"`
 }
 
 // AnnouncementCreate is supplied through the dashboard and converted into
 // an Announcement
 type AnnouncementCreate struct {
-	Title     string    `json:"title" bson:"title"`
-	Body      string    `json:"body" bson:"body"`
-	Timestamp time.Time `json:"timestamp" bson:"timestamp"`
+	Title      string             `json:"title" bson:"title"`
+	Body       string             `json:"body" bson:"body"`
+	Timestamp  time.Time          `json:"timestamp" bson:"timestamp"`
+	Draft      bool               `json:"draft" bson:"draft"`
+	LocationID string             `json:"location_id" bson:"location_id"`
+	Images     []string           `json:"images" bson:"images"`
+	Links      []AnnouncementLink `json:"links" bson:"links"`
+
+	Translations map[string]AnnouncementTranslation `json:"translations" bson:"translations"`
 }
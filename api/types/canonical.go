@@ -0,0 +1,28 @@
+package types
+
+import "time"
+
+// CanonicalProduct is the document stored in MongoDB for a single product
+// that is stocked under different IDs at different locations.
+// Each of its aliases is shown as the canonical product instead,
+// which shares a single ProductMetadata record under the canonical product's ID
+type CanonicalProduct struct {
+	ID        string         `json:"id" bson:"id"`
+	Name      string         `json:"name" bson:"name"`
+	Aliases   []ProductAlias `json:"aliases" bson:"aliases"`
+	CreatedAt time.Time      `json:"created_at" bson:"created_at"`
+}
+
+// ProductAlias is a single product ID at a single location
+// that is merged into a canonical product
+type ProductAlias struct {
+	LocationID string `json:"location_id" bson:"location_id"`
+	ProductID  string `json:"product_id" bson:"product_id"`
+}
+
+// CanonicalProductCreate is supplied through the dashboard and converted into
+// a CanonicalProduct (also used to replace an existing one)
+type CanonicalProductCreate struct {
+	Name    string         `json:"name"`
+	Aliases []ProductAlias `json:"aliases"`
+}
@@ -0,0 +1,55 @@
+package types
+
+import "time"
+
+// Category is the document stored in MongoDB for a single category of products,
+// such as canned goods or produce
+type Category struct {
+	ID        string    `json:"id" bson:"id"`
+	Name      string    `json:"name" bson:"name"`
+	CreatedAt time.Time `json:"created_at" bson:"created_at"`
+}
+
+// CategoryCreate is supplied through the dashboard and converted into
+// a Category
+type CategoryCreate struct {
+	Name string `json:"name"`
+}
+
+// CategoryAssignment is supplied through the dashboard
+// to put every product whose name matches a pattern into a category.
+// Patterns are case-insensitive and can use '*' for any run of characters
+// and '?' for any single character
+type CategoryAssignment struct {
+	Pattern string `json:"pattern"`
+	DryRun  bool   `json:"dry_run"`
+}
+
+// CategoryAssignmentMatch is a single product matched by a category assignment
+type CategoryAssignmentMatch struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+// LocationCategory is a category of products at a single location,
+// with how many of its products are there
+type LocationCategory struct {
+	ID           *string `json:"id"`
+	Name         string  `json:"name"`
+	ItemCount    int     `json:"item_count"`
+	InStockCount int     `json:"in_stock_count"`
+}
+
+// UncategorizedFilter is the category filter that matches products without a category
+const UncategorizedFilter = "none"
+
+// InCategory determines whether the product metadata matches a category filter,
+// which is either a category ID or UncategorizedFilter.
+// Products without any metadata are uncategorized
+func (p *ProductMetadata) InCategory(category string) bool {
+	if p == nil || p.CategoryID == nil {
+		return category == UncategorizedFilter
+	}
+
+	return *p.CategoryID == category
+}
@@ -0,0 +1,63 @@
+package types
+
+import "time"
+
+// Device is the document stored in MongoDB for a single device
+// that a user registered to receive push notifications.
+// Each push token belongs to at most one device
+type Device struct {
+	ID          string    `json:"id" bson:"id"`
+	Username    string    `json:"username" bson:"username"`
+	Token       string    `json:"token" bson:"token"`
+	Platform    string    `json:"platform" bson:"platform"`
+	LocationIDs []string  `json:"location_ids" bson:"location_ids"`
+	CreatedAt   time.Time `json:"created_at" bson:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at" bson:"updated_at"`
+}
+
+// DeviceCreate is supplied by the app when registering a device
+// and converted into a Device.
+// Location IDs are the locations whose announcements the device is subscribed to
+type DeviceCreate struct {
+	Token       string   `json:"token"`
+	Platform    string   `json:"platform"`
+	LocationIDs []string `json:"location_ids"`
+}
+
+// DeviceUpdate is supplied by the app to change which locations a device is subscribed to
+type DeviceUpdate struct {
+	LocationIDs []string `json:"location_ids"`
+}
+
+// Delivery statuses for an announcement's push notifications
+const (
+	DeliveryQueued    = "queued"
+	DeliverySending   = "sending"
+	DeliveryCompleted = "completed"
+	DeliveryFailed    = "failed"
+)
+
+// UnfinishedDeliveryStatuses are the statuses of deliveries
+// that still have to be (or are being) pushed
+var UnfinishedDeliveryStatuses = []string{DeliveryQueued, DeliverySending}
+
+// AnnouncementDelivery is the document stored in MongoDB
+// with the push notification delivery stats of a single announcement
+type AnnouncementDelivery struct {
+	AnnouncementID string     `json:"announcement_id" bson:"announcement_id"`
+	Status         string     `json:"status" bson:"status"`
+	TargetDevices  int        `json:"target_devices" bson:"target_devices"`
+	Sent           int        `json:"sent" bson:"sent"`
+	Failed         int        `json:"failed" bson:"failed"`
+	InvalidTokens  int        `json:"invalid_tokens" bson:"invalid_tokens"`
+	Attempts       int        `json:"attempts" bson:"attempts"`
+	LastError      string     `json:"last_error,omitempty" bson:"last_error"`
+	QueuedAt       time.Time  `json:"queued_at" bson:"queued_at"`
+	StartedAt      *time.Time `json:"started_at" bson:"started_at"`
+	FinishedAt     *time.Time `json:"finished_at" bson:"finished_at"`
+}
+
+// IsFinished determines whether the delivery has completed or failed
+func (d *AnnouncementDelivery) IsFinished() bool {
+	return d.Status == DeliveryCompleted || d.Status == DeliveryFailed
+}
@@ -0,0 +1,30 @@
+package types
+
+import "time"
+
+// Favorite is the document stored in MongoDB for a product
+// that a user wants to hear about when it comes back in stock.
+// An empty location ID means the product at any location
+type Favorite struct {
+	ID         string    `json:"id" bson:"id"`
+	Username   string    `json:"username" bson:"username"`
+	ProductID  string    `json:"product_id" bson:"product_id"`
+	LocationID string    `json:"location_id" bson:"location_id"`
+	CreatedAt  time.Time `json:"created_at" bson:"created_at"`
+}
+
+// FavoriteCreate is supplied by a user when adding a favorite
+// and converted into a Favorite
+type FavoriteCreate struct {
+	ProductID  string `json:"product_id"`
+	LocationID string `json:"location_id"`
+}
+
+// NotificationPreferences is the document stored in MongoDB
+// for which notifications a user has opted out of.
+// Users without a document receive every notification
+type NotificationPreferences struct {
+	Username      string    `json:"username" bson:"username"`
+	RestockOptOut bool      `json:"restock_opt_out" bson:"restock_opt_out"`
+	UpdatedAt     time.Time `json:"updated_at" bson:"updated_at"`
+}
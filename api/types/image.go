@@ -0,0 +1,8 @@
+package types
+
+// ImageVariants contains the URLs of each processed version of an uploaded image
+type ImageVariants struct {
+	Thumbnail string `json:"thumbnail" bson:"thumbnail"`
+	Medium    string `json:"medium" bson:"medium"`
+	Original  string `json:"original" bson:"original"`
+}
@@ -0,0 +1,51 @@
+package types
+
+import "time"
+
+// Kinds of native inventory transactions
+const (
+	TransactionStockIn  = "stock_in"
+	TransactionStockOut = "stock_out"
+	TransactionSet      = "set"
+)
+
+// NativeItem is the document stored in MongoDB for the current amount
+// of a single product at a location whose inventory is managed natively
+type NativeItem struct {
+	LocationID string    `json:"location_id" bson:"location_id"`
+	ProductID  string    `json:"product_id" bson:"product_id"`
+	Name       string    `json:"name" bson:"name"`
+	Amount     int       `json:"amount" bson:"amount"`
+	UpdatedAt  time.Time `json:"updated_at" bson:"updated_at"`
+}
+
+// NativeItemCreate is supplied through the dashboard and converted into
+// a NativeItem
+type NativeItemCreate struct {
+	ProductID string `json:"product_id"`
+	Name      string `json:"name"`
+	Amount    int    `json:"amount"`
+}
+
+// InventoryTransaction is the document stored in MongoDB for a single change
+// to the amount of a native item
+type InventoryTransaction struct {
+	ID              string    `json:"id" bson:"id"`
+	LocationID      string    `json:"location_id" bson:"location_id"`
+	ProductID       string    `json:"product_id" bson:"product_id"`
+	Kind            string    `json:"kind" bson:"kind"`
+	Quantity        int       `json:"quantity" bson:"quantity"`
+	ResultingAmount int       `json:"resulting_amount" bson:"resulting_amount"`
+	Reason          string    `json:"reason" bson:"reason"`
+	CreatedBy       string    `json:"created_by" bson:"created_by"`
+	CreatedAt       time.Time `json:"created_at" bson:"created_at"`
+}
+
+// InventoryTransactionCreate is supplied through the dashboard and converted into
+// an InventoryTransaction
+type InventoryTransactionCreate struct {
+	ProductID string `json:"product_id"`
+	Kind      string `json:"kind"`
+	Quantity  int    `json:"quantity"`
+	Reason    string `json:"reason"`
+}
@@ -1,5 +1,11 @@
 package types
 
+// Sources that a location's inventory can come from
+const (
+	InventorySourceTransact = "transact"
+	InventorySourceNative   = "native"
+)
+
 // Location is the internal representation of a location object,
 // taken directly from MongoDB.
 // Note: this struct should not be returned from the API directly;
@@ -9,6 +15,28 @@ type Location struct {
 	Name               string         `json:"name" bson:"name"`
 	Location           GeoCoordinates `json:"location" bson:"location"`
 	TransactIdentifier string         `json:"transact_identifier" bson:"transact_identifier"`
+	// Either InventorySourceTransact or InventorySourceNative
+	// (older locations without one use Transact)
+	InventorySource string `json:"inventory_source" bson:"inventory_source"`
+	// Optional limits on each user's visits to the location
+	MaxItemsPerVisit *int `json:"max_items_per_visit" bson:"max_items_per_visit"`
+	MaxVisitsPerWeek *int `json:"max_visits_per_week" bson:"max_visits_per_week"`
+}
+
+// IsNative determines whether the location's inventory is managed in the database
+// instead of coming from Transact
+func (l *Location) IsNative() bool {
+	return l.InventorySource == InventorySourceNative
+}
+
+// InventoryIdentifier gets the identifier that the location's products
+// are stored under in the partial products provider
+func (l *Location) InventoryIdentifier() string {
+	if l.IsNative() {
+		return l.ID
+	}
+
+	return l.TransactIdentifier
 }
 
 // Inner gets the inner representation for this location
@@ -39,4 +67,7 @@ type LocationCreate struct {
 	Name               string         `json:"name" bson:"name"`
 	Location           GeoCoordinates `json:"location" bson:"location"`
 	TransactIdentifier string         `json:"transact_identifier" bson:"transact_identifier"`
+	InventorySource    string         `json:"inventory_source" bson:"inventory_source"`
+	MaxItemsPerVisit   *int           `json:"max_items_per_visit" bson:"max_items_per_visit"`
+	MaxVisitsPerWeek   *int           `json:"max_visits_per_week" bson:"max_visits_per_week"`
 }
@@ -3,45 +3,141 @@ package types
 // ProductMetadata contains the data stored in MongoDB
 // that includes the additional product data, such as thumbnail and nutritional facts
 type ProductMetadata struct {
-	ID        string  `json:"id" bson:"id"`
-	Thumbnail *string `json:"thumbnail" bson:"thumbnail"`
-	Nutrition *string `json:"nutritional_facts" bson:"nutritional_facts"`
+	ID string `json:"id" bson:"id"`
+	// Optional name, description, and unit (such as "10.75 oz") that the product is shown with,
+	// instead of its raw name from Transact
+	DisplayName *string `json:"display_name" bson:"display_name"`
+	Description *string `json:"description" bson:"description"`
+	Unit        *string `json:"unit" bson:"unit"`
+	Thumbnail   *string `json:"thumbnail" bson:"thumbnail"`
+	// Optional set of processed versions of the thumbnail image
+	ThumbnailVariants *ImageVariants `json:"thumbnail_variants" bson:"thumbnail_variants"`
+	Nutrition         *string        `json:"nutritional_facts" bson:"nutritional_facts"`
+	// Optional limits on how much of the product each user can take
+	MaxPerVisit *int `json:"max_per_visit" bson:"max_per_visit"`
+	MaxPerWeek  *int `json:"max_per_week" bson:"max_per_week"`
+	// Optional category that the product is browsed under
+	CategoryID *string `json:"category_id" bson:"category_id"`
+	// Optional translated names and nutritional facts by locale
+	Translations map[string]ProductTranslation `json:"translations" bson:"translations"`
+}
+
+// ProductTranslation is the name, description, and nutritional facts of a product in another locale
+// (any of which can be left out to use the original)
+type ProductTranslation struct {
+	Name        *string `json:"name" bson:"name"`
+	Description *string `json:"description" bson:"description"`
+	Nutrition   *string `json:"nutritional_facts" bson:"nutritional_facts"`
+}
+
+// LocalizedName gets the name that the product is shown under in a locale,
+// falling back to its display name and then to its original name from Transact.
+// Products without any metadata are never renamed
+func (p *ProductMetadata) LocalizedName(locale string, name string) string {
+	if p == nil {
+		return name
+	}
+
+	if translation, ok := p.Translations[locale]; ok && translation.Name != nil && *translation.Name != "" {
+		return *translation.Name
+	}
+	if p.DisplayName != nil && *p.DisplayName != "" {
+		return *p.DisplayName
+	}
+
+	return name
+}
+
+// LocalizedDescription gets the description of the product in a locale,
+// falling back to the original description
+func (p *ProductMetadata) LocalizedDescription(locale string) *string {
+	if p == nil {
+		return nil
+	}
+
+	if translation, ok := p.Translations[locale]; ok && translation.Description != nil && *translation.Description != "" {
+		return translation.Description
+	}
+
+	return p.Description
+}
+
+// LocalizedNutrition gets the nutritional facts of the product in a locale,
+// falling back to the original nutritional facts
+func (p *ProductMetadata) LocalizedNutrition(locale string) *string {
+	if p == nil {
+		return nil
+	}
+
+	if translation, ok := p.Translations[locale]; ok && translation.Nutrition != nil && *translation.Nutrition != "" {
+		return translation.Nutrition
+	}
+
+	return p.Nutrition
 }
 
 // ProductDataSearch is the result of a full product with the amounts map omitted,
 // used in large collections of products
 type ProductDataSearch struct {
-	Name      string  `json:"name"`
-	ID        string  `json:"id"`
-	Thumbnail *string `json:"thumbnail"`
-	Nutrition *string `json:"nutritional_facts"`
+	Name string `json:"name"`
+	// The product's raw name from Transact (only included for admins)
+	TransactName      string         `json:"transact_name,omitempty"`
+	ID                string         `json:"id"`
+	Description       *string        `json:"description"`
+	Unit              *string        `json:"unit"`
+	Thumbnail         *string        `json:"thumbnail"`
+	ThumbnailVariants *ImageVariants `json:"thumbnail_variants"`
+	Nutrition         *string        `json:"nutritional_facts"`
+	CategoryID        *string        `json:"category_id"`
+	// Relevance to the search query, if one was given
+	Score *float64 `json:"score,omitempty"`
 }
 
 // ProductData is the result of a full product,
 // used when retrieving a single product
 type ProductData struct {
-	Name      string         `json:"name"`
-	ID        string         `json:"id"`
-	Thumbnail *string        `json:"thumbnail"`
-	Nutrition *string        `json:"nutritional_facts"`
-	Amounts   map[string]int `json:"amounts"`
+	Name string `json:"name"`
+	// The product's raw name from Transact (only included for admins)
+	TransactName      string         `json:"transact_name,omitempty"`
+	ID                string         `json:"id"`
+	Description       *string        `json:"description"`
+	Unit              *string        `json:"unit"`
+	Thumbnail         *string        `json:"thumbnail"`
+	ThumbnailVariants *ImageVariants `json:"thumbnail_variants"`
+	Nutrition         *string        `json:"nutritional_facts"`
+	CategoryID        *string        `json:"category_id"`
+	Amounts           map[string]int `json:"amounts"`
 }
 
 // LocationProductDataSearch is the result of a full product with the amount number omitted,
 // used in large collections of products
 type LocationProductDataSearch struct {
-	ID        string  `json:"id"`
-	Name      string  `json:"name"`
-	Thumbnail *string `json:"thumbnail"`
-	Amount    int     `json:"amount"`
+	ID   string `json:"id"`
+	Name string `json:"name"`
+	// The product's raw name from Transact (only included for admins)
+	TransactName      string         `json:"transact_name,omitempty"`
+	Description       *string        `json:"description"`
+	Unit              *string        `json:"unit"`
+	Thumbnail         *string        `json:"thumbnail"`
+	ThumbnailVariants *ImageVariants `json:"thumbnail_variants"`
+	CategoryID        *string        `json:"category_id"`
+	Amount            int            `json:"amount"`
+	// Relevance to the search query, if one was given
+	Score *float64 `json:"score,omitempty"`
 }
 
 // LocationProductData is the result of a full product,
 // used when retrieving a single product at a location
 type LocationProductData struct {
-	ID        string  `json:"id"`
-	Name      string  `json:"name"`
-	Thumbnail *string `json:"thumbnail"`
-	Nutrition *string `json:"nutritional_facts"`
-	Amount    int     `json:"amount"`
+	ID   string `json:"id"`
+	Name string `json:"name"`
+	// The product's raw name from Transact (only included for admins)
+	TransactName      string         `json:"transact_name,omitempty"`
+	Description       *string        `json:"description"`
+	Unit              *string        `json:"unit"`
+	Thumbnail         *string        `json:"thumbnail"`
+	ThumbnailVariants *ImageVariants `json:"thumbnail_variants"`
+	Nutrition         *string        `json:"nutritional_facts"`
+	CategoryID        *string        `json:"category_id"`
+	Amount            int            `json:"amount"`
 }
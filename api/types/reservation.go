@@ -0,0 +1,63 @@
+package types
+
+import "time"
+
+// Statuses that a reservation can be in
+const (
+	ReservationPending  = "pending"
+	ReservationReady    = "ready"
+	ReservationPickedUp = "picked_up"
+	ReservationExpired  = "expired"
+)
+
+// ActiveReservationStatuses are the statuses of reservations
+// that are still waiting to be picked up
+var ActiveReservationStatuses = []string{ReservationPending, ReservationReady}
+
+// Reservation is the document stored in MongoDB for a single user's
+// request to pick up a bag of items from a location later
+type Reservation struct {
+	ID         string            `json:"id" bson:"id"`
+	LocationID string            `json:"location_id" bson:"location_id"`
+	Username   string            `json:"username" bson:"username"`
+	Items      []ReservationItem `json:"items" bson:"items"`
+	Status     string            `json:"status" bson:"status"`
+	CreatedAt  time.Time         `json:"created_at" bson:"created_at"`
+	UpdatedAt  time.Time         `json:"updated_at" bson:"updated_at"`
+	ExpiresAt  time.Time         `json:"expires_at" bson:"expires_at"`
+}
+
+// IsActive determines whether the reservation is still waiting to be picked up
+func (r *Reservation) IsActive() bool {
+	return r.Status == ReservationPending || r.Status == ReservationReady
+}
+
+// HoldsItems determines whether the reservation still holds its items,
+// given when the inventory at its location was last loaded.
+// Active reservations always do, and picked up reservations keep holding them
+// until the inventory is loaded after the pickup
+// (since the items were only taken out of the inventory then)
+func (r *Reservation) HoldsItems(loadedAt time.Time) bool {
+	if r.IsActive() {
+		return true
+	}
+
+	return r.Status == ReservationPickedUp && !loadedAt.After(r.UpdatedAt)
+}
+
+// ReservationItem is a single product (and how many of it) in a reservation
+type ReservationItem struct {
+	ProductID string `json:"product_id" bson:"product_id"`
+	Quantity  int    `json:"quantity" bson:"quantity"`
+}
+
+// ReservationCreate is supplied by the app and converted into a Reservation
+type ReservationCreate struct {
+	Items []ReservationItem `json:"items"`
+}
+
+// ReservationUpdate is supplied through the dashboard to move a reservation
+// to a new status
+type ReservationUpdate struct {
+	Status string `json:"status"`
+}
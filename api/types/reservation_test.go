@@ -0,0 +1,35 @@
+package types
+
+import (
+	"testing"
+	"time"
+)
+
+func TestReservationHoldsItems(t *testing.T) {
+	loadedAt := time.Date(2021, time.March, 1, 12, 0, 0, 0, time.UTC)
+	before := loadedAt.Add(-time.Minute)
+	after := loadedAt.Add(time.Minute)
+
+	tests := []struct {
+		name     string
+		status   string
+		updated  time.Time
+		expected bool
+	}{
+		{"pending", ReservationPending, before, true},
+		{"ready", ReservationReady, before, true},
+		{"picked up before load", ReservationPickedUp, before, false},
+		{"picked up at load", ReservationPickedUp, loadedAt, true},
+		{"picked up after load", ReservationPickedUp, after, true},
+		{"expired", ReservationExpired, after, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			reservation := Reservation{Status: test.status, UpdatedAt: test.updated}
+			if actual := reservation.HoldsItems(loadedAt); actual != test.expected {
+				t.Errorf("HoldsItems() = %v, expected %v", actual, test.expected)
+			}
+		})
+	}
+}
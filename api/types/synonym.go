@@ -0,0 +1,17 @@
+package types
+
+import "time"
+
+// Synonym is the document stored in MongoDB for a group of search terms
+// that are treated as equivalent, such as "pb" and "peanut butter"
+type Synonym struct {
+	ID        string    `json:"id" bson:"id"`
+	Terms     []string  `json:"terms" bson:"terms"`
+	CreatedAt time.Time `json:"created_at" bson:"created_at"`
+}
+
+// SynonymCreate is supplied through the dashboard and converted into
+// a Synonym
+type SynonymCreate struct {
+	Terms []string `json:"terms"`
+}
@@ -0,0 +1,40 @@
+package types
+
+import "time"
+
+// TransactLocations is an overview of how the profit centers in the last Transact report
+// line up with the locations in the database
+type TransactLocations struct {
+	LoadedAt      time.Time                   `json:"loaded_at"`
+	ProfitCenters []TransactProfitCenter      `json:"profit_centers"`
+	Unmatched     []TransactUnmatchedLocation `json:"unmatched_locations"`
+}
+
+// TransactProfitCenter is a single profit center seen in the last Transact report,
+// with how many products it had and which locations are mapped to it
+type TransactProfitCenter struct {
+	Identifier   string   `json:"identifier"`
+	ItemCount    int      `json:"item_count"`
+	InStockCount int      `json:"in_stock_count"`
+	Mapped       bool     `json:"mapped"`
+	LocationIDs  []string `json:"location_ids"`
+}
+
+// TransactUnmatchedLocation is a Transact-backed location in the database
+// whose identifier isn't in the last Transact report,
+// with the profit center it was most likely meant to be (if any)
+type TransactUnmatchedLocation struct {
+	ID                 string  `json:"id"`
+	Name               string  `json:"name"`
+	TransactIdentifier string  `json:"transact_identifier"`
+	Suggestion         *string `json:"suggestion"`
+}
+
+// TransactLocationCreate is supplied through the dashboard
+// to create a location from a profit center that isn't mapped yet.
+// The name defaults to the profit center
+type TransactLocationCreate struct {
+	ProfitCenter string         `json:"profit_center"`
+	Name         string         `json:"name"`
+	Location     GeoCoordinates `json:"location"`
+}
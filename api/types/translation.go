@@ -0,0 +1,9 @@
+package types
+
+// MissingTranslations lists the translations that a single announcement or product is missing,
+// as a map of locale -> untranslated fields
+type MissingTranslations struct {
+	ID      string              `json:"id"`
+	Name    string              `json:"name"`
+	Missing map[string][]string `json:"missing"`
+}
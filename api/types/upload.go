@@ -0,0 +1,84 @@
+package types
+
+import "time"
+
+// Types of documents that can reference an upload
+const (
+	UploadReferenceProduct      = "product"
+	UploadReferenceAnnouncement = "announcement"
+)
+
+// Upload is the document stored in MongoDB for a single uploaded file
+type Upload struct {
+	ID string `json:"id" bson:"id"`
+	// The name that the upload provider stored the file under
+	Name       string    `json:"name" bson:"name"`
+	URL        string    `json:"url" bson:"url"`
+	Size       int64     `json:"size" bson:"size"`
+	MimeType   string    `json:"mime_type" bson:"mime_type"`
+	UploadedBy string    `json:"uploaded_by" bson:"uploaded_by"`
+	UploadedAt time.Time `json:"uploaded_at" bson:"uploaded_at"`
+	// Files that were uploaded together (such as the variants of an image)
+	// share a group and are only cleaned up once none of them are referenced
+	Group   string `json:"group" bson:"group"`
+	Variant string `json:"variant,omitempty" bson:"variant,omitempty"`
+	// The hex-encoded SHA-256 hash of the content that was uploaded,
+	// which for the variants of an image is the hash of the original image
+	SHA256 string `json:"sha256,omitempty" bson:"sha256,omitempty"`
+	// The last time the same content was uploaded again (or looked up by its hash)
+	// and this upload was returned instead
+	TouchedAt *time.Time `json:"touched_at,omitempty" bson:"touched_at,omitempty"`
+}
+
+// LastUsedAt gets the last time the upload was uploaded or returned for the same content
+func (u *Upload) LastUsedAt() time.Time {
+	if u.TouchedAt != nil && u.TouchedAt.After(u.UploadedAt) {
+		return *u.TouchedAt
+	}
+
+	return u.UploadedAt
+}
+
+// UploadReference is a single document that references an upload by its URL
+type UploadReference struct {
+	Type string `json:"type"`
+	ID   string `json:"id"`
+}
+
+// UploadData is an upload along with everything that currently references it
+type UploadData struct {
+	Upload
+	References []UploadReference `json:"references"`
+}
+
+// UploadPresignRequest is supplied through the dashboard
+// to get a URL that a file can be uploaded to directly
+type UploadPresignRequest struct {
+	MimeType string `json:"mime_type"`
+}
+
+// UploadPresign is a signed request that the dashboard can make
+// to upload a single file directly to storage
+type UploadPresign struct {
+	// The object key that has to be given once the upload completes
+	Key       string            `json:"key"`
+	UploadURL string            `json:"upload_url"`
+	Headers   map[string]string `json:"headers"`
+	ExpiresAt time.Time         `json:"expires_at"`
+	URL       string            `json:"url"`
+}
+
+// PresignedUpload is a signed request that was given out for a direct upload,
+// which is kept until the upload is completed so that only issued keys are accepted
+type PresignedUpload struct {
+	Key         string    `json:"key" bson:"key"`
+	MimeType    string    `json:"mime_type" bson:"mime_type"`
+	PresignedBy string    `json:"presigned_by" bson:"presigned_by"`
+	ExpiresAt   time.Time `json:"expires_at" bson:"expires_at"`
+}
+
+// UploadCompleteRequest is supplied through the dashboard
+// once a file has been uploaded directly to storage
+type UploadCompleteRequest struct {
+	Key string `json:"key"`
+}
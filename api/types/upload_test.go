@@ -0,0 +1,31 @@
+package types
+
+import (
+	"testing"
+	"time"
+)
+
+func TestUploadLastUsedAt(t *testing.T) {
+	uploadedAt := time.Date(2021, time.March, 1, 12, 0, 0, 0, time.UTC)
+	before := uploadedAt.Add(-time.Hour)
+	after := uploadedAt.Add(time.Hour)
+
+	tests := []struct {
+		name      string
+		touchedAt *time.Time
+		expected  time.Time
+	}{
+		{"never touched", nil, uploadedAt},
+		{"touched later", &after, after},
+		{"touched earlier", &before, uploadedAt},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			upload := Upload{UploadedAt: uploadedAt, TouchedAt: test.touchedAt}
+			if actual := upload.LastUsedAt(); !actual.Equal(test.expected) {
+				t.Errorf("expected %s, got %s", test.expected, actual)
+			}
+		})
+	}
+}
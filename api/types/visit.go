@@ -0,0 +1,56 @@
+package types
+
+import "time"
+
+// Visit is the document stored in MongoDB for a single time
+// that a user was checked out of a location with some items
+type Visit struct {
+	ID         string      `json:"id" bson:"id"`
+	LocationID string      `json:"location_id" bson:"location_id"`
+	Username   string      `json:"username" bson:"username"`
+	Items      []VisitItem `json:"items" bson:"items"`
+	RecordedBy string      `json:"recorded_by" bson:"recorded_by"`
+	CreatedAt  time.Time   `json:"created_at" bson:"created_at"`
+}
+
+// VisitItem is a single product (and how many of it) taken during a visit
+type VisitItem struct {
+	ProductID string `json:"product_id" bson:"product_id"`
+	Quantity  int    `json:"quantity" bson:"quantity"`
+}
+
+// VisitCreate is supplied by staff when checking a user out
+// and converted into a Visit
+type VisitCreate struct {
+	Username string      `json:"username"`
+	Items    []VisitItem `json:"items"`
+}
+
+// LocationUsage is a user's usage of a single location during the current week
+type LocationUsage struct {
+	LocationID       string `json:"location_id"`
+	Name             string `json:"name"`
+	VisitsThisWeek   int    `json:"visits_this_week"`
+	MaxVisitsPerWeek *int   `json:"max_visits_per_week"`
+	VisitsRemaining  *int   `json:"visits_remaining"`
+	MaxItemsPerVisit *int   `json:"max_items_per_visit"`
+}
+
+// ProductUsage is a user's usage of a single product during the current week
+// (across all locations)
+type ProductUsage struct {
+	ProductID     string `json:"product_id"`
+	UsedThisWeek  int    `json:"used_this_week"`
+	MaxPerWeek    *int   `json:"max_per_week"`
+	WeekRemaining *int   `json:"week_remaining"`
+	MaxPerVisit   *int   `json:"max_per_visit"`
+}
+
+// Usage is a user's usage of every location and limited product
+// during the current week
+type Usage struct {
+	Username  string          `json:"username"`
+	WeekStart time.Time       `json:"week_start"`
+	Locations []LocationUsage `json:"locations"`
+	Products  []ProductUsage  `json:"products"`
+}
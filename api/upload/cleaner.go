@@ -0,0 +1,195 @@
+package upload
+
+import (
+	"context"
+	"time"
+
+	"github.com/hako/durafmt"
+	"github.com/rs/zerolog"
+
+	"github.com/jd-116/klemis-kitchen-api/config"
+	"github.com/jd-116/klemis-kitchen-api/db"
+)
+
+// CleanerDatabase is the subset of the database provider
+// that the cleaner needs
+type CleanerDatabase interface {
+	db.UploadProvider
+	ReferenceSource
+}
+
+// Cleaner periodically deletes uploads that nothing references
+// once they're older than a grace period
+// (so that files uploaded but not yet saved to a product aren't removed).
+// The grace period restarts whenever the same content is uploaded again.
+// It also deletes the files of direct uploads that were never completed
+// once the grace period has passed since their signed requests expired
+type Cleaner struct {
+	database    CleanerDatabase
+	provider    Provider
+	period      time.Duration
+	gracePeriod time.Duration
+	stop        chan struct{}
+	logger      zerolog.Logger
+}
+
+// NewCleaner creates a new upload cleaner from the upload configuration
+// (doesn't start goroutines)
+func NewCleaner(database CleanerDatabase, provider Provider, cfg config.UploadConfig,
+	logger zerolog.Logger) *Cleaner {
+
+	return &Cleaner{
+		database:    database,
+		provider:    provider,
+		period:      time.Duration(cfg.CleanupPeriod),
+		gracePeriod: time.Duration(cfg.OrphanGracePeriod),
+		stop:        make(chan struct{}),
+		logger:      logger,
+	}
+}
+
+// Connect starts the goroutine that periodically cleans up orphaned uploads
+func (c *Cleaner) Connect(ctx context.Context) error {
+	go c.periodClean()
+	return nil
+}
+
+// Disconnect stops the cleanup goroutine
+func (c *Cleaner) Disconnect(ctx context.Context) error {
+	c.stop <- struct{}{}
+	return nil
+}
+
+// Periodically cleans up orphaned uploads
+func (c *Cleaner) periodClean() {
+	humanDuration := durafmt.Parse(c.period).LimitFirstN(2).String()
+	c.logger.
+		Info().
+		Str("interval", humanDuration).
+		Msg("started timer to clean up orphaned uploads")
+	for {
+		select {
+		case <-c.stop:
+			return
+		case <-time.After(c.period):
+			c.tryClean()
+		}
+	}
+}
+
+// Attempts to clean up orphaned uploads,
+// printing out an error if it occurs
+func (c *Cleaner) tryClean() {
+	ctx, cancel := context.WithTimeout(context.Background(), c.period)
+	defer cancel()
+
+	count, err := c.Clean(ctx)
+	if err != nil {
+		// Report error,
+		// but continue the goroutine
+		c.logger.
+			Error().
+			Err(err).
+			Msg("an error occurred while cleaning up orphaned uploads")
+		return
+	}
+
+	if count > 0 {
+		c.logger.
+			Info().
+			Int("deleted_count", count).
+			Msg("cleaned up orphaned uploads")
+	}
+}
+
+// Clean deletes every upload that wasn't uploaded or touched within the grace period
+// where none of the uploads in its group are referenced,
+// along with the files of expired direct uploads that were never completed,
+// returning the number of uploads deleted.
+// Uploads whose file can't be deleted are kept and tried again next time
+func (c *Cleaner) Clean(ctx context.Context) (int, error) {
+	uploads, err := c.database.GetAllUploads(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	references, err := FindReferences(ctx, c.database, uploads)
+	if err != nil {
+		return 0, err
+	}
+
+	referencedGroups := make(map[string]struct{})
+	for _, upload := range uploads {
+		if len(references[upload.ID]) > 0 {
+			referencedGroups[upload.Group] = struct{}{}
+		}
+	}
+
+	cutoff := time.Now().Add(-c.gracePeriod)
+	count, err := c.cleanPresigned(ctx, cutoff)
+	if err != nil {
+		return count, err
+	}
+
+	for _, upload := range uploads {
+		if _, ok := referencedGroups[upload.Group]; ok || upload.LastUsedAt().After(cutoff) {
+			continue
+		}
+
+		err := c.provider.Delete(ctx, upload.Name)
+		if err != nil {
+			c.logger.
+				Warn().
+				Err(err).
+				Str("upload_id", upload.ID).
+				Str("file_name", upload.Name).
+				Msg("could not delete orphaned upload file")
+			continue
+		}
+
+		err = c.database.DeleteUpload(ctx, upload.ID)
+		if err != nil {
+			return count, err
+		}
+		count++
+	}
+
+	return count, nil
+}
+
+// Deletes the files of direct uploads whose signed requests expired before the cutoff
+// without being completed, returning the number of presigned uploads deleted
+func (c *Cleaner) cleanPresigned(ctx context.Context, cutoff time.Time) (int, error) {
+	expired, err := c.database.GetExpiredPresignedUploads(ctx, cutoff)
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for _, presigned := range expired {
+		// Keep the file if the upload was completed
+		// but the presigned upload couldn't be deleted afterwards
+		_, err := c.database.GetUploadByName(ctx, presigned.Key)
+		if _, ok := err.(*db.NotFoundError); ok {
+			// The file might never have been uploaded,
+			// which deleting it doesn't treat as an error
+			err = c.provider.Delete(ctx, presigned.Key)
+		}
+		if err != nil {
+			c.logger.
+				Warn().
+				Err(err).
+				Str("file_name", presigned.Key).
+				Msg("could not delete uncompleted direct upload file")
+			continue
+		}
+
+		err = c.database.DeletePresignedUpload(ctx, presigned.Key)
+		if err != nil {
+			return count, err
+		}
+		count++
+	}
+
+	return count, nil
+}
@@ -0,0 +1,140 @@
+package filesystem
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/rs/zerolog"
+	"github.com/segmentio/ksuid"
+
+	"github.com/jd-116/klemis-kitchen-api/config"
+	"github.com/jd-116/klemis-kitchen-api/upload"
+)
+
+// Provider implements an upload provider that stores files in a local directory,
+// which are then served by the API itself
+type Provider struct {
+	maxBytes  int64
+	directory string
+	// The URL that the files route is reachable at,
+	// which can be relative to the API server
+	baseURL string
+	logger  zerolog.Logger
+}
+
+// NewProvider creates a new instance of a Provider from the upload configuration,
+// creating the directory if needed
+func NewProvider(cfg config.UploadConfig, logger zerolog.Logger) (*Provider, error) {
+	err := os.MkdirAll(cfg.FilesystemDirectory, 0755)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Provider{
+		maxBytes:  int64(cfg.MaxSize.Bytes()),
+		directory: cfg.FilesystemDirectory,
+		baseURL:   strings.TrimSuffix(cfg.FilesystemBaseURL, "/"),
+		logger:    logger,
+	}, nil
+}
+
+// MaxBytes gets the max number of bytes that can be uploaded at once
+func (p *Provider) MaxBytes() int64 {
+	return p.maxBytes
+}
+
+// Upload a file to the directory,
+// returning the URL of the file once uploaded.
+// The file is written under a temporary name first
+// so that partially-uploaded files are never served
+func (p *Provider) Upload(ctx context.Context, part io.Reader, ext string, mime string) (*upload.Stored, error) {
+	// Generate the filename using a random ID
+	fileID, err := ksuid.NewRandom()
+	if err != nil {
+		return nil, err
+	}
+	fileName := fmt.Sprintf("%s.%s", fileID, strings.TrimPrefix(ext, "."))
+	p.logger.Info().Str("file_name", fileName).Str("directory", p.directory).Msg("uploading file")
+
+	tempFile, err := ioutil.TempFile(p.directory, ".upload-*")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(tempFile.Name())
+
+	hash := sha256.New()
+	_, err = io.Copy(io.MultiWriter(tempFile, hash), part)
+	if err != nil {
+		tempFile.Close()
+		return nil, err
+	}
+
+	err = tempFile.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	err = os.Rename(tempFile.Name(), filepath.Join(p.directory, fileName))
+	if err != nil {
+		return nil, err
+	}
+
+	// Return the URL of the file once uploaded
+	return &upload.Stored{
+		Name:   fileName,
+		URL:    fmt.Sprintf("%s/%s", p.baseURL, fileName),
+		SHA256: hex.EncodeToString(hash.Sum(nil)),
+	}, nil
+}
+
+// Delete deletes a stored file by its name.
+// Files that are already gone are ignored
+func (p *Provider) Delete(ctx context.Context, name string) error {
+	if name != filepath.Base(name) || strings.HasPrefix(name, ".") {
+		return upload.NewFileNotFoundError(name)
+	}
+
+	p.logger.Info().Str("file_name", name).Str("directory", p.directory).Msg("deleting file")
+	err := os.Remove(filepath.Join(p.directory, name))
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	return nil
+}
+
+// Owns determines whether a URL points to a file stored in the directory
+func (p *Provider) Owns(url string) bool {
+	if !strings.HasPrefix(url, p.baseURL+"/") {
+		return false
+	}
+
+	name := strings.TrimPrefix(url, p.baseURL+"/")
+	return name != "" && name == filepath.Base(name) && !strings.HasPrefix(name, ".")
+}
+
+// Open opens a stored file by its name
+// (which can't refer to anything outside of the directory)
+func (p *Provider) Open(name string) (upload.File, error) {
+	if name != filepath.Base(name) || strings.HasPrefix(name, ".") {
+		return nil, upload.NewFileNotFoundError(name)
+	}
+
+	file, err := os.Open(filepath.Join(p.directory, name))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, upload.NewFileNotFoundError(name)
+		}
+
+		return nil, err
+	}
+
+	return file, nil
+}
@@ -0,0 +1,101 @@
+package upload
+
+import (
+	"context"
+	"strings"
+
+	"github.com/jd-116/klemis-kitchen-api/db"
+	"github.com/jd-116/klemis-kitchen-api/types"
+)
+
+// ReferenceSource provides every document that can reference an upload
+type ReferenceSource interface {
+	db.ProductMetadataProvider
+	db.AnnouncementProvider
+}
+
+// FindReferences finds everything that references each of the uploads by its URL,
+// returning a map of upload ID -> references (only for uploads with any).
+// Product metadata references uploads through its thumbnail (and its variants),
+// and announcements reference any upload whose URL is one of their images
+// or appears in the body (or in any of its translations)
+func FindReferences(ctx context.Context, source ReferenceSource,
+	uploads []types.Upload) (map[string][]types.UploadReference, error) {
+
+	productMetadata, err := source.GetAllProducts(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	announcements, err := source.GetAllAnnouncements(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// Create URL -> upload IDs map so we can index it quickly
+	byURL := make(map[string][]string)
+	for _, upload := range uploads {
+		byURL[upload.URL] = append(byURL[upload.URL], upload.ID)
+	}
+
+	references := make(map[string][]types.UploadReference)
+	for _, metadata := range productMetadata {
+		urls := []string{}
+		if metadata.Thumbnail != nil {
+			urls = append(urls, *metadata.Thumbnail)
+		}
+		if variants := metadata.ThumbnailVariants; variants != nil {
+			urls = append(urls, variants.Thumbnail, variants.Medium, variants.Original)
+		}
+
+		seen := make(map[string]struct{})
+		for _, url := range urls {
+			for _, id := range byURL[url] {
+				if _, ok := seen[id]; ok {
+					continue
+				}
+				seen[id] = struct{}{}
+
+				references[id] = append(references[id], types.UploadReference{
+					Type: types.UploadReferenceProduct,
+					ID:   metadata.ID,
+				})
+			}
+		}
+	}
+
+	for _, announcement := range announcements {
+		images := make(map[string]struct{})
+		for _, image := range announcement.Images {
+			images[image] = struct{}{}
+		}
+
+		bodies := []string{announcement.Body}
+		for _, translation := range announcement.Translations {
+			bodies = append(bodies, translation.Body)
+		}
+
+		for _, upload := range uploads {
+			_, isImage := images[upload.URL]
+			if upload.URL != "" && (isImage || containsAny(bodies, upload.URL)) {
+				references[upload.ID] = append(references[upload.ID], types.UploadReference{
+					Type: types.UploadReferenceAnnouncement,
+					ID:   announcement.ID,
+				})
+			}
+		}
+	}
+
+	return references, nil
+}
+
+// Determines whether any of the texts contain the substring
+func containsAny(texts []string, substring string) bool {
+	for _, text := range texts {
+		if strings.Contains(text, substring) {
+			return true
+		}
+	}
+
+	return false
+}
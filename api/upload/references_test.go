@@ -0,0 +1,67 @@
+package upload
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/jd-116/klemis-kitchen-api/types"
+)
+
+// fakeReferenceSource is a ReferenceSource that only returns fixed documents
+type fakeReferenceSource struct {
+	ReferenceSource
+	products      []types.ProductMetadata
+	announcements []types.Announcement
+}
+
+func (f *fakeReferenceSource) GetAllProducts(ctx context.Context) ([]types.ProductMetadata, error) {
+	return f.products, nil
+}
+
+func (f *fakeReferenceSource) GetAllAnnouncements(ctx context.Context) ([]types.Announcement, error) {
+	return f.announcements, nil
+}
+
+func TestFindReferences(t *testing.T) {
+	thumbnail := "https://cdn.example.com/thumbnail.jpg"
+	uploads := []types.Upload{
+		{ID: "thumbnail", URL: thumbnail},
+		{ID: "image", URL: "https://cdn.example.com/image.jpg"},
+		{ID: "body", URL: "https://cdn.example.com/body.jpg"},
+		{ID: "translated", URL: "https://cdn.example.com/translated.jpg"},
+		{ID: "orphan", URL: "https://cdn.example.com/orphan.jpg"},
+	}
+	source := &fakeReferenceSource{
+		products: []types.ProductMetadata{
+			{ID: "product", Thumbnail: &thumbnail},
+		},
+		announcements: []types.Announcement{
+			{
+				ID:     "announcement",
+				Body:   "![menu](https://cdn.example.com/body.jpg)",
+				Images: []string{"https://cdn.example.com/image.jpg"},
+				Translations: map[string]types.AnnouncementTranslation{
+					"es": {Body: "![menú](https://cdn.example.com/translated.jpg)"},
+				},
+			},
+		},
+	}
+
+	references, err := FindReferences(context.Background(), source, uploads)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	product := []types.UploadReference{{Type: types.UploadReferenceProduct, ID: "product"}}
+	announcement := []types.UploadReference{{Type: types.UploadReferenceAnnouncement, ID: "announcement"}}
+	expected := map[string][]types.UploadReference{
+		"thumbnail":  product,
+		"image":      announcement,
+		"body":       announcement,
+		"translated": announcement,
+	}
+	if !reflect.DeepEqual(references, expected) {
+		t.Errorf("expected %v, got %v", expected, references)
+	}
+}
@@ -2,18 +2,26 @@ package s3
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"io"
+	"io/ioutil"
+	"net/http"
 	"strings"
+	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/aws/aws-sdk-go/aws/credentials"
 	"github.com/aws/aws-sdk-go/aws/session"
+	awsS3 "github.com/aws/aws-sdk-go/service/s3"
 	"github.com/aws/aws-sdk-go/service/s3/s3manager"
 	"github.com/rs/zerolog"
 	"github.com/segmentio/ksuid"
 
-	"github.com/jd-116/klemis-kitchen-api/env"
+	"github.com/jd-116/klemis-kitchen-api/config"
+	"github.com/jd-116/klemis-kitchen-api/upload"
 )
 
 // Provider implements an upload provider against the S3 API
@@ -21,95 +29,227 @@ type Provider struct {
 	maxBytes int64
 	session  *session.Session
 	uploader *s3manager.Uploader
+	client   *awsS3.S3
 	logger   zerolog.Logger
 	bucket   string
+	// Optional base URL that uploaded files are returned under
+	// instead of the URL that S3 gives back
+	publicURL string
+	// How long pre-signed upload URLs can be used for
+	presignExpiry time.Duration
 }
 
 // NewProvider creates a new instance of a Provider
-// and parses environment variables
-func NewProvider(logger zerolog.Logger) (*Provider, error) {
-	maxBytes, err := env.GetBytesEnv("max upload file size", "UPLOAD_MAX_SIZE")
+// from the upload configuration.
+// A custom endpoint can be given to use an S3-compatible service (such as MinIO)
+func NewProvider(cfg config.UploadConfig, logger zerolog.Logger) (*Provider, error) {
+	// Initialize the session.
+	// Services other than AWS usually need path-style addressing
+	// (http://endpoint/bucket/key instead of http://bucket.endpoint/key)
+	awsConfig := &aws.Config{
+		Region:           aws.String(cfg.AWSRegion),
+		Credentials:      credentials.NewStaticCredentials(cfg.AWSAccessKeyID, cfg.AWSSecretAccessKey, ""),
+		S3ForcePathStyle: aws.Bool(cfg.S3ForcePathStyle),
+	}
+	if cfg.S3Endpoint != "" {
+		awsConfig.Endpoint = aws.String(cfg.S3Endpoint)
+	}
+	session, err := session.NewSession(awsConfig)
 	if err != nil {
 		return nil, err
 	}
 
-	// Parse the S3 credentials from the environment
-	awsRegion, err := env.GetEnv("upload AWS region", "UPLOAD_AWS_REGION")
+	// Initialize the uploader
+	uploader := s3manager.NewUploader(session, func(u *s3manager.Uploader) {
+		u.PartSize = int64(cfg.PartSize.Bytes())
+		u.LeavePartsOnError = false
+	})
+
+	return &Provider{
+		logger:        logger,
+		maxBytes:      int64(cfg.MaxSize.Bytes()),
+		session:       session,
+		uploader:      uploader,
+		client:        awsS3.New(session),
+		bucket:        cfg.S3Bucket,
+		publicURL:     strings.TrimSuffix(cfg.S3PublicURL, "/"),
+		presignExpiry: time.Duration(cfg.PresignExpiry),
+	}, nil
+}
+
+// MaxBytes gets the max number of bytes that can be uploaded at once
+func (p *Provider) MaxBytes() int64 {
+	return p.maxBytes
+}
+
+// Upload an image to S3,
+// returning the URL of the file once uploaded
+func (p *Provider) Upload(ctx context.Context, part io.Reader, ext string, mime string) (*upload.Stored, error) {
+	// Generate the filename using a random ID
+	fileID, err := ksuid.NewRandom()
 	if err != nil {
 		return nil, err
 	}
-	awsAccessKeyID, err := env.GetEnv("upload AWS access key ID", "UPLOAD_AWS_ACCESS_KEY_ID")
+	fileName := fmt.Sprintf("%s.%s", fileID, strings.TrimPrefix(ext, "."))
+	p.logger.Info().Str("file_name", fileName).Str("s3_bucket", p.bucket).Msg("uploading file")
+
+	// Upload the file to S3, hashing it as it's read
+	hash := sha256.New()
+	result, err := p.uploader.UploadWithContext(ctx, &s3manager.UploadInput{
+		Bucket:      aws.String(p.bucket),
+		Key:         aws.String(fileName),
+		ContentType: aws.String(mime),
+		Body:        io.TeeReader(part, hash),
+	})
 	if err != nil {
 		return nil, err
 	}
-	awsSecretAccessKey, err := env.GetEnv("upload AWS secret access key", "UPLOAD_AWS_SECRET_ACCESS_KEY")
+
+	// Return the URL of the object once uploaded
+	url := result.Location
+	if p.publicURL != "" {
+		url = fmt.Sprintf("%s/%s", p.publicURL, fileName)
+	}
+	return &upload.Stored{
+		Name:   fileName,
+		URL:    url,
+		SHA256: hex.EncodeToString(hash.Sum(nil)),
+	}, nil
+}
+
+// Delete deletes an uploaded object from S3 by its name
+// (deleting an object that doesn't exist succeeds)
+func (p *Provider) Delete(ctx context.Context, name string) error {
+	p.logger.Info().Str("file_name", name).Str("s3_bucket", p.bucket).Msg("deleting file")
+	_, err := p.client.DeleteObjectWithContext(ctx, &awsS3.DeleteObjectInput{
+		Bucket: aws.String(p.bucket),
+		Key:    aws.String(name),
+	})
+	return err
+}
+
+// Presign creates a short-lived signed URL
+// that a single file can be PUT to directly,
+// which has to be sent with the given MIME type as its content type
+func (p *Provider) Presign(ctx context.Context, ext string, mime string) (*upload.Presigned, error) {
+	// Generate the filename using a random ID
+	fileID, err := ksuid.NewRandom()
 	if err != nil {
 		return nil, err
 	}
+	fileName := fmt.Sprintf("%s.%s", fileID, strings.TrimPrefix(ext, "."))
+	p.logger.Info().Str("file_name", fileName).Str("s3_bucket", p.bucket).Msg("pre-signing file upload")
 
-	// Initialize the session
-	session, err := session.NewSession(&aws.Config{
-		Region:      &awsRegion,
-		Credentials: credentials.NewStaticCredentials(awsAccessKeyID, awsSecretAccessKey, ""),
+	request, _ := p.client.PutObjectRequest(&awsS3.PutObjectInput{
+		Bucket:      aws.String(p.bucket),
+		Key:         aws.String(fileName),
+		ContentType: aws.String(mime),
 	})
+	request.SetContext(ctx)
+	expiresAt := time.Now().Add(p.presignExpiry)
+	uploadURL, err := request.Presign(p.presignExpiry)
 	if err != nil {
 		return nil, err
 	}
 
-	// Parse the uploader data from the environment
-	uploadPartSize, err := env.GetBytesEnv("upload part size", "UPLOAD_PART_SIZE")
+	url, err := p.objectURL(fileName)
 	if err != nil {
 		return nil, err
 	}
 
-	// Initialize the uploader
-	uploader := s3manager.NewUploader(session, func(u *s3manager.Uploader) {
-		u.PartSize = int64(uploadPartSize.Bytes())
-		u.LeavePartsOnError = false
+	return &upload.Presigned{
+		Name:      fileName,
+		UploadURL: uploadURL,
+		Headers: map[string]string{
+			"Content-Type": mime,
+		},
+		ExpiresAt: expiresAt,
+		URL:       url,
+	}, nil
+}
+
+// Stat gets the size and content type of an object that was uploaded directly,
+// along with its first bytes
+func (p *Provider) Stat(ctx context.Context, name string) (*upload.Object, error) {
+	head, err := p.client.HeadObjectWithContext(ctx, &awsS3.HeadObjectInput{
+		Bucket: aws.String(p.bucket),
+		Key:    aws.String(name),
 	})
+	if err != nil {
+		if requestErr, ok := err.(awserr.RequestFailure); ok && requestErr.StatusCode() == http.StatusNotFound {
+			return nil, upload.NewFileNotFoundError(name)
+		}
 
-	// Get the bucket name from the environment
-	s3Bucket, err := env.GetEnv("upload S3 bucket", "UPLOAD_S3_BUCKET")
+		return nil, err
+	}
+
+	url, err := p.objectURL(name)
 	if err != nil {
 		return nil, err
 	}
 
-	return &Provider{
-		logger:   logger,
-		maxBytes: int64(maxBytes.Bytes()),
-		session:  session,
-		uploader: uploader,
-		bucket:   s3Bucket,
-	}, nil
-}
+	object := &upload.Object{
+		Name:     name,
+		URL:      url,
+		Size:     aws.Int64Value(head.ContentLength),
+		MimeType: aws.StringValue(head.ContentType),
+		Header:   []byte{},
+	}
 
-// MaxBytes gets the max number of bytes that can be uploaded at once
-func (p *Provider) MaxBytes() int64 {
-	return p.maxBytes
+	// Ranges can't be requested from empty objects
+	if object.Size == 0 {
+		return object, nil
+	}
+
+	result, err := p.client.GetObjectWithContext(ctx, &awsS3.GetObjectInput{
+		Bucket: aws.String(p.bucket),
+		Key:    aws.String(name),
+		Range:  aws.String("bytes=0-511"),
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer result.Body.Close()
+
+	object.Header, err = ioutil.ReadAll(io.LimitReader(result.Body, 512))
+	if err != nil {
+		return nil, err
+	}
+
+	return object, nil
 }
 
-// Upload an image to S3,
-// returning the URL of the file once uploaded
-func (p *Provider) Upload(ctx context.Context, part io.Reader, ext string, mime string) (string, error) {
-	// Generate the filename using a random ID
-	fileID, err := ksuid.NewRandom()
+// Owns determines whether a URL points to an object in the bucket
+func (p *Provider) Owns(url string) bool {
+	// Object keys can't be empty, so get the bucket's URL from a placeholder key
+	placeholderURL, err := p.objectURL("_")
 	if err != nil {
-		return "", err
+		return false
+	}
+	baseURL := strings.TrimSuffix(placeholderURL, "_")
+	if !strings.HasPrefix(url, baseURL) {
+		return false
 	}
-	fileName := fmt.Sprintf("%s.%s", fileID, strings.TrimPrefix(ext, "."))
-	p.logger.Info().Str("file_name", fileName).Str("s3_bucket", p.bucket).Msg("uploading file")
 
-	// Upload the file to S3
-	result, err := p.uploader.UploadWithContext(ctx, &s3manager.UploadInput{
-		Bucket:      aws.String(p.bucket),
-		Key:         aws.String(fileName),
-		ContentType: aws.String(mime),
-		Body:        part,
+	name := strings.TrimPrefix(url, baseURL)
+	return name != "" && !strings.Contains(name, "/") && !strings.HasPrefix(name, ".")
+}
+
+// Gets the URL that an object can be referenced with
+// (the same URL that uploading it through the API would return)
+func (p *Provider) objectURL(name string) (string, error) {
+	if p.publicURL != "" {
+		return fmt.Sprintf("%s/%s", p.publicURL, name), nil
+	}
+
+	request, _ := p.client.GetObjectRequest(&awsS3.GetObjectInput{
+		Bucket: aws.String(p.bucket),
+		Key:    aws.String(name),
 	})
+	err := request.Build()
 	if err != nil {
 		return "", err
 	}
 
-	// Return the URL of the object once uploaded
-	return result.Location, nil
+	return request.HTTPRequest.URL.String(), nil
 }
@@ -0,0 +1,182 @@
+package upload
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"io/ioutil"
+	"os"
+	"time"
+
+	"github.com/segmentio/ksuid"
+
+	"github.com/jd-116/klemis-kitchen-api/db"
+	"github.com/jd-116/klemis-kitchen-api/types"
+)
+
+// Store uploads a file to the provider and records it in the database.
+// Files that are uploaded together (such as the variants of an image)
+// should be given the same group and the hash of the content they came from;
+// if the group is empty, the file is put in its own group instead,
+// and if a file with the same content was already uploaded on its own,
+// the existing upload is reused instead of storing another copy
+func Store(ctx context.Context, provider Provider, database db.UploadProvider, part io.Reader,
+	ext string, mime string, uploadedBy string, group string, variant string,
+	sourceHash string) (*types.Upload, error) {
+
+	if group == "" {
+		// The content has to be hashed before it's stored
+		// to know whether it's a duplicate, so it's spooled to a temporary file
+		spooled, hash, err := spool(part)
+		if err != nil {
+			return nil, err
+		}
+		defer func() {
+			spooled.Close()
+			os.Remove(spooled.Name())
+		}()
+
+		existing, err := FindDuplicate(ctx, database, hash)
+		if err != nil {
+			return nil, err
+		}
+		if len(existing) == 1 && existing[0].Variant == "" {
+			reused, err := Reuse(ctx, database, existing)
+			if err != nil {
+				return nil, err
+			}
+			return &reused[0], nil
+		}
+
+		part = spooled
+		sourceHash = hash
+	}
+
+	counter := &countingReader{reader: part}
+	stored, err := provider.Upload(ctx, counter, ext, mime)
+	if err != nil {
+		return nil, err
+	}
+
+	if sourceHash == "" {
+		sourceHash = stored.SHA256
+	}
+	return Record(ctx, database, *stored, counter.count, mime, uploadedBy, group, variant, sourceHash)
+}
+
+// Copies the content to a temporary file while hashing it,
+// returning the file (rewound to the start) and the hex-encoded SHA-256 hash
+func spool(part io.Reader) (*os.File, string, error) {
+	file, err := ioutil.TempFile("", "upload-*")
+	if err != nil {
+		return nil, "", err
+	}
+
+	hash := sha256.New()
+	_, err = io.Copy(io.MultiWriter(file, hash), part)
+	if err == nil {
+		_, err = file.Seek(0, io.SeekStart)
+	}
+	if err != nil {
+		file.Close()
+		os.Remove(file.Name())
+		return nil, "", err
+	}
+
+	return file, hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// FindDuplicate finds the uploads in the earliest group
+// whose content has the given hash, if there are any.
+// Uploads completed through presigned URLs have no hash,
+// so they're never found as duplicates
+func FindDuplicate(ctx context.Context, database db.UploadProvider, hash string) ([]types.Upload, error) {
+	uploads, err := database.GetUploadsByHash(ctx, hash)
+	if err != nil {
+		return nil, err
+	}
+
+	group := []types.Upload{}
+	for _, upload := range uploads {
+		if upload.Group == uploads[0].Group {
+			group = append(group, upload)
+		}
+	}
+
+	return group, nil
+}
+
+// Reuse marks a group of uploads found by FindDuplicate as touched
+// once it's returned in place of a new upload,
+// so that the cleaner gives it a new grace period before it has to be referenced
+func Reuse(ctx context.Context, database db.UploadProvider, group []types.Upload) ([]types.Upload, error) {
+	now := time.Now()
+	err := database.TouchUploads(ctx, group[0].Group, now)
+	if err != nil {
+		return nil, err
+	}
+
+	reused := []types.Upload{}
+	for _, upload := range group {
+		upload.TouchedAt = &now
+		reused = append(reused, upload)
+	}
+
+	return reused, nil
+}
+
+// Record records a file that has already been uploaded in the database,
+// putting it in its own group if the group is empty
+func Record(ctx context.Context, database db.UploadProvider, stored Stored, size int64,
+	mime string, uploadedBy string, group string, variant string, hash string) (*types.Upload, error) {
+
+	upload := types.Upload{
+		Name:       stored.Name,
+		URL:        stored.URL,
+		Size:       size,
+		MimeType:   mime,
+		UploadedBy: uploadedBy,
+		UploadedAt: time.Now(),
+		Group:      group,
+		Variant:    variant,
+		SHA256:     hash,
+	}
+
+	// Generate globally unique IDs for the upload
+	for {
+		rand, err := ksuid.NewRandom()
+		if err != nil {
+			return nil, err
+		}
+
+		upload.ID = rand.String()
+		if group == "" {
+			upload.Group = upload.ID
+		}
+
+		err = database.CreateUpload(ctx, upload)
+		if err != nil {
+			// If the error was a duplicate ID; try again
+			if _, ok := err.(*db.DuplicateIDError); ok {
+				continue
+			}
+
+			return nil, err
+		}
+
+		return &upload, nil
+	}
+}
+
+// Counts the bytes read through it
+type countingReader struct {
+	reader io.Reader
+	count  int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.reader.Read(p)
+	c.count += int64(n)
+	return n, err
+}
@@ -0,0 +1,145 @@
+package upload
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"io/ioutil"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jd-116/klemis-kitchen-api/db"
+	"github.com/jd-116/klemis-kitchen-api/types"
+)
+
+// fakeUploadDatabase is an UploadProvider that keeps uploads in memory
+type fakeUploadDatabase struct {
+	db.UploadProvider
+	uploads []types.Upload
+	touched []string
+}
+
+func (f *fakeUploadDatabase) GetUploadsByHash(ctx context.Context, hash string) ([]types.Upload, error) {
+	matches := []types.Upload{}
+	for _, upload := range f.uploads {
+		if upload.SHA256 == hash {
+			matches = append(matches, upload)
+		}
+	}
+	return matches, nil
+}
+
+func (f *fakeUploadDatabase) CreateUpload(ctx context.Context, upload types.Upload) error {
+	f.uploads = append(f.uploads, upload)
+	return nil
+}
+
+func (f *fakeUploadDatabase) TouchUploads(ctx context.Context, group string, touchedAt time.Time) error {
+	f.touched = append(f.touched, group)
+	return nil
+}
+
+// fakeProvider is a Provider that records the content of each upload
+type fakeProvider struct {
+	Provider
+	uploaded []string
+}
+
+func (f *fakeProvider) Upload(ctx context.Context, part io.Reader, ext string, mime string) (*Stored, error) {
+	content, err := ioutil.ReadAll(part)
+	if err != nil {
+		return nil, err
+	}
+
+	f.uploaded = append(f.uploaded, string(content))
+	return &Stored{Name: "stored" + ext, URL: "https://cdn.example.com/stored" + ext}, nil
+}
+
+func hashOf(content string) string {
+	hash := sha256.Sum256([]byte(content))
+	return hex.EncodeToString(hash[:])
+}
+
+func TestStore(t *testing.T) {
+	tests := []struct {
+		name     string
+		existing []types.Upload
+		content  string
+		group    string
+		reused   string
+	}{
+		{"new content", nil, "new", "", ""},
+		{"duplicate", []types.Upload{{ID: "earlier", Group: "earlier", SHA256: hashOf("same")}}, "same", "", "earlier"},
+		{"duplicate image variants", []types.Upload{
+			{ID: "thumbnail", Group: "image", Variant: "thumbnail", SHA256: hashOf("image")},
+			{ID: "original", Group: "image", Variant: "original", SHA256: hashOf("image")},
+		}, "image", "", ""},
+		{"grouped", []types.Upload{{ID: "earlier", Group: "earlier", SHA256: hashOf("variant")}}, "variant", "group", ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			database := &fakeUploadDatabase{uploads: test.existing}
+			provider := &fakeProvider{}
+
+			stored, err := Store(context.Background(), provider, database, strings.NewReader(test.content),
+				".txt", "text/plain", "admin", test.group, "", "")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if test.reused != "" {
+				if stored.ID != test.reused || stored.TouchedAt == nil {
+					t.Errorf("expected %s to be reused and touched, got %+v", test.reused, stored)
+				}
+				if len(provider.uploaded) != 0 {
+					t.Errorf("expected nothing to be stored, got %v", provider.uploaded)
+				}
+				if len(database.touched) != 1 || database.touched[0] != test.reused {
+					t.Errorf("expected only %s to be touched, got %v", test.reused, database.touched)
+				}
+				return
+			}
+
+			if len(provider.uploaded) != 1 || provider.uploaded[0] != test.content {
+				t.Errorf("expected %q to be stored, got %v", test.content, provider.uploaded)
+			}
+			if len(database.touched) != 0 {
+				t.Errorf("expected nothing to be touched, got %v", database.touched)
+			}
+			if stored.Size != int64(len(test.content)) {
+				t.Errorf("expected size %d, got %d", len(test.content), stored.Size)
+			}
+			if test.group == "" && stored.SHA256 != hashOf(test.content) {
+				t.Errorf("expected the content's hash, got %q", stored.SHA256)
+			}
+		})
+	}
+}
+
+func TestFindDuplicate(t *testing.T) {
+	database := &fakeUploadDatabase{uploads: []types.Upload{
+		{ID: "first-thumbnail", Group: "first", SHA256: "hash"},
+		{ID: "first-original", Group: "first", SHA256: "hash"},
+		{ID: "second", Group: "second", SHA256: "hash"},
+		{ID: "other", Group: "other", SHA256: "other"},
+	}}
+
+	group, err := FindDuplicate(context.Background(), database, "hash")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(group) != 2 || group[0].ID != "first-thumbnail" || group[1].ID != "first-original" {
+		t.Errorf("expected the earliest group, got %v", group)
+	}
+	if len(database.touched) != 0 {
+		t.Errorf("expected nothing to be touched, got %v", database.touched)
+	}
+
+	group, err = FindDuplicate(context.Background(), database, "missing")
+	if err != nil || len(group) != 0 {
+		t.Errorf("expected no uploads, got %v (%v)", group, err)
+	}
+}
@@ -2,11 +2,87 @@ package upload
 
 import (
 	"context"
+	"fmt"
 	"io"
+	"os"
+	"time"
 )
 
-// Provider represents a S3 provider implementation
+// Provider represents an upload provider implementation (such as S3)
 type Provider interface {
 	MaxBytes() int64
-	Upload(ctx context.Context, part io.Reader, ext string, mime string) (string, error)
+	Upload(ctx context.Context, part io.Reader, ext string, mime string) (*Stored, error)
+	Delete(ctx context.Context, name string) error
+	Owns(url string) bool
+}
+
+// Stored describes a single file once it has been uploaded
+type Stored struct {
+	// The name that the file was stored under,
+	// which is used to delete it
+	Name string
+	URL  string
+	// The hex-encoded SHA-256 hash of the file's content
+	SHA256 string
+}
+
+// Presigner represents an upload provider that clients can upload files to directly
+// using short-lived signed URLs, instead of the files passing through the API
+type Presigner interface {
+	Presign(ctx context.Context, ext string, mime string) (*Presigned, error)
+	Stat(ctx context.Context, name string) (*Object, error)
+}
+
+// Presigned describes a signed request that a client can make
+// to upload a single file directly to storage
+type Presigned struct {
+	// The name that the file will be stored under once uploaded
+	Name string
+	// The URL that the file should be PUT to,
+	// along with the headers that have to be sent with it
+	UploadURL string
+	Headers   map[string]string
+	ExpiresAt time.Time
+	// The URL that the file can be referenced with once uploaded
+	URL string
+}
+
+// Object describes a single file that was uploaded directly to storage
+type Object struct {
+	Name     string
+	URL      string
+	Size     int64
+	MimeType string
+	// The first bytes of the file (up to 512),
+	// which are used to check its actual content type
+	Header []byte
+}
+
+// FileSource represents an upload provider that serves the files it stores itself,
+// instead of them being served from another host (such as S3)
+type FileSource interface {
+	Open(name string) (File, error)
+}
+
+// File is a single stored file that can be served over HTTP
+type File interface {
+	io.ReadSeeker
+	io.Closer
+	Stat() (os.FileInfo, error)
+}
+
+// FileNotFoundError is an error used to encode when a stored file doesn't exist
+type FileNotFoundError struct {
+	Name string
+}
+
+// NewFileNotFoundError constructs a new FileNotFoundError
+func NewFileNotFoundError(name string) *FileNotFoundError {
+	return &FileNotFoundError{
+		Name: name,
+	}
+}
+
+func (e *FileNotFoundError) Error() string {
+	return fmt.Sprintf("file '%s' not found", e.Name)
 }
@@ -8,8 +8,12 @@ import (
 
 	"github.com/jd-116/klemis-kitchen-api/cas"
 	"github.com/jd-116/klemis-kitchen-api/db"
+	"github.com/jd-116/klemis-kitchen-api/images"
+	"github.com/jd-116/klemis-kitchen-api/limits"
 	"github.com/jd-116/klemis-kitchen-api/products"
+	"github.com/jd-116/klemis-kitchen-api/reservations"
 	"github.com/jd-116/klemis-kitchen-api/types"
+	"github.com/jd-116/klemis-kitchen-api/upload"
 	"github.com/rs/zerolog/hlog"
 )
 
@@ -20,6 +24,8 @@ func ResponseCodeFromError(err error) int {
 		return http.StatusBadRequest
 	case *db.NotFoundError:
 		return http.StatusNotFound
+	case *db.InsufficientAmountError:
+		return http.StatusConflict
 	case *cas.CASValidationFailedError:
 		return http.StatusUnauthorized
 	case *products.CacheNotInitializedError:
@@ -28,6 +34,20 @@ func ResponseCodeFromError(err error) int {
 		return http.StatusNotFound
 	case *products.PartialProductNotFoundError:
 		return http.StatusNotFound
+	case *products.RefreshRateLimitedError:
+		return http.StatusTooManyRequests
+	case *products.RefreshJobNotFoundError:
+		return http.StatusNotFound
+	case *limits.ExceededError:
+		return http.StatusForbidden
+	case *reservations.LimitExceededError:
+		return http.StatusForbidden
+	case *reservations.InvalidTransitionError:
+		return http.StatusConflict
+	case *upload.FileNotFoundError:
+		return http.StatusNotFound
+	case *images.InvalidImageError:
+		return http.StatusBadRequest
 	case *json.InvalidUTF8Error:
 		return http.StatusBadRequest
 	case *json.InvalidUnmarshalError:
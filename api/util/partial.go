@@ -0,0 +1,14 @@
+package util
+
+import "encoding/json"
+
+// DecodePartial converts a single value decoded from a partial JSON update
+// (which is made of maps, slices, and primitives) into the type that the target points to
+func DecodePartial(value interface{}, target interface{}) error {
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(encoded, target)
+}